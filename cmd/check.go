@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/sibprogrammer/xq/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// checkFormatting formats the files and compares the result with the content on disk.
+// The names of the files which would be changed (or the unified diff) are printed. The files are
+// processed in parallel, the errors are reported per file without stopping the check.
+func checkFormatting(cmd *cobra.Command, fileNames []string, jsonOutputMode bool, indent string) error {
+	flags := cmd.Flags()
+	xPathQuery, _ := getXpathQuery(flags)
+	cssQuery, _ := flags.GetString("query")
+	inPlace, _ := flags.GetBool("in-place")
+	showDiff, _ := flags.GetBool("diff")
+	jobs, _ := flags.GetInt("jobs")
+
+	if xPathQuery != "" || cssQuery != "" || inPlace {
+		return errors.New("formatting check is incompatible with nodes selection and in-place formatting")
+	}
+	if len(fileNames) == 0 {
+		return errors.New("formatting check requires file names")
+	}
+
+	changed, failed := 0, 0
+	var failure error
+	processFiles(fileNames, jobs, func(fileName string) fileResult {
+		content, err := os.ReadFile(fileName)
+		if err != nil {
+			return fileResult{err: err}
+		}
+
+		formatted := new(bytes.Buffer)
+		err = processContent(bytes.NewReader(content), formatted, flags, jsonOutputMode, indent, utils.ColorsDisabled)
+		if err != nil || bytes.Equal(content, formatted.Bytes()) {
+			return fileResult{err: err}
+		}
+
+		if showDiff {
+			return fileResult{output: []byte(utils.UnifiedDiff(fileName, fileName, string(content), formatted.String()))}
+		}
+		return fileResult{output: []byte(fileName + "\n")}
+	}, func(fileName string, result fileResult) {
+		if result.err != nil {
+			failed++
+			failure = getMostSevereError(failure, result.err)
+			_, _ = io.WriteString(cmd.ErrOrStderr(), utils.FormatError(utils.WithFileName(result.err, fileName)))
+		} else if result.output != nil {
+			changed++
+			_, _ = cmd.OutOrStdout().Write(result.output)
+		}
+	})
+
+	switch {
+	case failed > 0 && changed > 0:
+		return &filesError{fmt.Sprintf("%d file(s) would be reformatted, %d file(s) failed", changed, failed), failure}
+	case failed > 0:
+		return &filesError{fmt.Sprintf("%d file(s) failed", failed), failure}
+	case changed > 0:
+		return fmt.Errorf("%d file(s) would be reformatted", changed)
+	}
+
+	return nil
+}
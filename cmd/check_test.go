@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckFormatting(t *testing.T) {
+	command := NewRootCmd()
+	InitFlags(command)
+
+	formattedFile := filepath.Join("..", "test", "data", "xml", "formatted.xml")
+	unformattedFile := filepath.Join(t.TempDir(), "unformatted.xml")
+	err := os.WriteFile(unformattedFile, []byte("<root><item>1</item></root>\n"), 0600)
+	assert.Nil(t, err)
+
+	output, err := execute(command, "--check", formattedFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "", output)
+
+	output, err = execute(command, "--check", formattedFile, unformattedFile)
+	assert.ErrorContains(t, err, "1 file(s) would be reformatted")
+	assert.Contains(t, output, unformattedFile+"\nError:")
+
+	output, err = execute(command, "--diff", unformattedFile)
+	assert.ErrorContains(t, err, "1 file(s) would be reformatted")
+	expected := "--- " + unformattedFile + "\n+++ " + unformattedFile + "\n" +
+		"@@ -1 +1,3 @@\n-<root><item>1</item></root>\n+<root>\n+  <item>1</item>\n+</root>\n"
+	assert.Contains(t, output, expected)
+
+	content, err := os.ReadFile(unformattedFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "<root><item>1</item></root>\n", string(content))
+
+	_, err = execute(command, "--check", "-i", unformattedFile)
+	assert.ErrorContains(t, err, "incompatible")
+}
@@ -0,0 +1,71 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/sibprogrammer/xq/internal/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// applyConfig uses the values of the config options as the defaults of the flags with the same
+// names, the flags of the subcommands included. The unknown options are reported as warnings,
+// so that the config files written for other versions can be used.
+func applyConfig(cmd *cobra.Command) error {
+	flags := map[string][]*pflag.Flag{}
+	collect := func(flag *pflag.Flag) {
+		// the edit operations are not options and can not be predefined
+		if _, ok := flag.Value.(*editOperationsValue); !ok && flag.Name != "help" && flag.Name != "version" {
+			flags[flag.Name] = append(flags[flag.Name], flag)
+		}
+	}
+	cmd.Flags().VisitAll(collect)
+	cmd.PersistentFlags().VisitAll(collect)
+	for _, subCmd := range cmd.Commands() {
+		subCmd.Flags().VisitAll(collect)
+	}
+
+	for option, values := range utils.GetConfig().Values {
+		if len(flags[option]) == 0 {
+			for _, value := range values {
+				if !value.IsEnv {
+					_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "Warning: unknown option %s in %s\n", option, value.Source)
+				}
+			}
+			continue
+		}
+
+		for _, flag := range flags[option] {
+			if err := applyConfigValues(flag, option, values); err != nil {
+				return err
+			}
+			flag.DefValue = flag.Value.String()
+		}
+	}
+
+	return nil
+}
+
+// applyConfigValues sets the value of the flag, all the values are added to the repeatable flags.
+func applyConfigValues(flag *pflag.Flag, option string, values []utils.ConfigValue) error {
+	sliceValue, ok := flag.Value.(pflag.SliceValue)
+	if !ok {
+		values = values[len(values)-1:]
+	} else if err := sliceValue.Replace(nil); err != nil {
+		return err
+	}
+
+	for _, value := range values {
+		var err error
+		if ok {
+			err = sliceValue.Append(value.Value)
+		} else {
+			err = flag.Value.Set(value.Value)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid value %q of option %s in %s", value.Value, option, value.Source)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/sibprogrammer/xq/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfig(t *testing.T) {
+	xmlFilePath, err := filepath.Abs(filepath.Join("..", "test", "data", "xml", "unformatted.xml"))
+	assert.Nil(t, err)
+
+	homeDir := t.TempDir()
+	projectDir := filepath.Join(homeDir, "project")
+	assert.Nil(t, os.MkdirAll(filepath.Join(homeDir, ".config", "xq"), 0700))
+	assert.Nil(t, os.MkdirAll(projectDir, 0700))
+	assert.Nil(t, os.WriteFile(filepath.Join(homeDir, ".config", "xq", "config"),
+		[]byte("indent = 8\nno-color = true\nignore-whitespace = true\n"), 0600))
+	assert.Nil(t, os.WriteFile(filepath.Join(projectDir, ".xq"), []byte("indent = 1\n"), 0600))
+	t.Setenv("HOME", homeDir)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XQ_NODE", "true")
+	t.Chdir(projectDir)
+	noColor := color.NoColor
+	defer func() {
+		color.NoColor = noColor
+		_ = utils.LoadConfig()
+	}()
+
+	command := NewRootCmd()
+	InitFlags(command)
+
+	output, err := execute(command, xmlFilePath)
+	assert.Nil(t, err)
+	assert.Contains(t, output, "\n <first_name>John</first_name>")
+
+	output, err = execute(command, "-x", "/user/first_name", xmlFilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, "<first_name>John</first_name>", output)
+
+	output, err = execute(command, "--indent", "2", "--color", xmlFilePath)
+	assert.Nil(t, err)
+	assert.Contains(t, output, "\n  \x1b[33m<first_name")
+
+	diffCmd, _, err := command.Find([]string{"diff"})
+	assert.Nil(t, err)
+	ignoreWhitespace, _ := diffCmd.Flags().GetBool("ignore-whitespace")
+	assert.True(t, ignoreWhitespace)
+
+	assert.Nil(t, os.WriteFile(filepath.Join(projectDir, ".xq"), []byte("indentation = 1\n"), 0600))
+	assert.Nil(t, utils.LoadConfig(utils.GetConfigFiles()...))
+	stderr := new(bytes.Buffer)
+	command.SetErr(stderr)
+	assert.Nil(t, applyConfig(command))
+	assert.Equal(t, "Warning: unknown option indentation in "+filepath.Join(projectDir, ".xq")+"\n", stderr.String())
+	command.SetErr(nil)
+
+	assert.Nil(t, os.WriteFile(filepath.Join(projectDir, ".xq"), []byte("ns = a=urn:a\nns = b=urn:b\n"), 0600))
+	assert.Nil(t, utils.LoadConfig(utils.GetConfigFiles()...))
+	assert.Nil(t, applyConfig(command))
+	bindings, _ := command.PersistentFlags().GetStringArray("ns")
+	assert.Equal(t, []string{"a=urn:a", "b=urn:b"}, bindings)
+
+	assert.Nil(t, os.WriteFile(filepath.Join(projectDir, ".xq"), []byte("depth = deep\n"), 0600))
+	assert.Nil(t, utils.LoadConfig(utils.GetConfigFiles()...))
+	assert.ErrorContains(t, applyConfig(command), `invalid value "deep" of option depth`)
+}
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/sibprogrammer/xq/internal/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+func newDiffCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "diff [flags] old-file new-file",
+		Short: "Compare two XML, HTML, JSON or YAML documents by structure",
+		Long: "Compares the documents by their tree structure and reports the added (+), removed (-) " +
+			"and changed (~) elements, attributes and text nodes using XPath-like locations.",
+		Args:         cobra.ExactArgs(2),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			oldDoc, err := parseDiffDocument(cmd.Flags(), args[0])
+			if err != nil {
+				return err
+			}
+			newDoc, err := parseDiffDocument(cmd.Flags(), args[1])
+			if err != nil {
+				return err
+			}
+
+			options := utils.DiffOptions{Colors: getColorMode(cmd.Flags())}
+			options.IgnoreAttrOrder, _ = cmd.Flags().GetBool("ignore-attr-order")
+			options.IgnoreWhitespace, _ = cmd.Flags().GetBool("ignore-whitespace")
+			options.IgnorePrefixes, _ = cmd.Flags().GetBool("ignore-prefixes")
+
+			output := new(bytes.Buffer)
+			differences, err := utils.DiffDocuments(oldDoc, newDoc, output, options)
+			if err != nil {
+				return err
+			}
+			if err = utils.PagerPrint(output, cmd.OutOrStdout(), getPager(cmd.Flags())); err != nil {
+				return err
+			}
+
+			if differences > 0 {
+				return fmt.Errorf("%d difference(s) found", differences)
+			}
+
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.Bool("ignore-attr-order", false, "Ignore the order of attributes")
+	flags.Bool("ignore-whitespace", false, "Ignore whitespace-only text nodes")
+	flags.Bool("ignore-prefixes", false, "Compare names by namespace URI instead of prefix")
+
+	return cmd
+}
+
+func parseDiffDocument(flags *pflag.FlagSet, fileName string) (*xmlquery.Node, error) {
+	content, err := os.ReadFile(fileName)
+	if err != nil {
+		return nil, err
+	}
+
+	contentType, reader := detectFormat(flags, bytes.NewReader(content))
+	doc, err := utils.ParseDocument(reader, contentType)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %w", fileName, err)
+	}
+
+	return doc, nil
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffCmd(t *testing.T) {
+	command := NewRootCmd()
+	InitFlags(command)
+
+	dir := t.TempDir()
+	oldFile := filepath.Join(dir, "old.xml")
+	newFile := filepath.Join(dir, "new.json")
+	assert.Nil(t, os.WriteFile(oldFile, []byte("<root>\n  <item id=\"1\">one</item>\n</root>\n"), 0600))
+	assert.Nil(t, os.WriteFile(newFile, []byte(`{"root": {"item": {"@id": "1", "#text": "one"}}}`), 0600))
+
+	output, err := execute(command, "diff", "--ignore-whitespace", oldFile, newFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "", output)
+
+	output, err = execute(command, "diff", oldFile, newFile)
+	assert.ErrorContains(t, err, "2 difference(s) found")
+	assert.Contains(t, output, "- /root/text()[1]: \"\\n  \"\n- /root/text()[2]: \"\\n\"\n")
+
+	_, err = execute(command, "diff", oldFile)
+	assert.ErrorContains(t, err, "accepts 2 arg(s)")
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/sibprogrammer/xq/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+type editOperationsValue struct {
+	operations *[]utils.EditOperation
+	action     utils.EditAction
+}
+
+func (value *editOperationsValue) String() string {
+	return ""
+}
+
+func (value *editOperationsValue) Set(input string) error {
+	operation, err := utils.ParseEditOperation(value.action, input)
+	if err != nil {
+		return err
+	}
+
+	*value.operations = append(*value.operations, operation)
+	return nil
+}
+
+func (value *editOperationsValue) Type() string {
+	return "string"
+}
+
+func newEditCmd() *cobra.Command {
+	var operations []utils.EditOperation
+
+	cmd := &cobra.Command{
+		Use:   "edit [flags] [file...]",
+		Short: "Update, insert, rename or delete XML nodes selected by XPath",
+		Long: "Applies the edit operations to XML documents in the order they are provided " +
+			"and outputs the formatted result.",
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			defer func() {
+				operations = nil
+			}()
+
+			if len(operations) == 0 {
+				return errors.New("at least one edit operation should be provided")
+			}
+
+			indent, err := getIndent(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			inPlace, _ := cmd.Flags().GetBool("in-place")
+			namespaces, err := getNamespaces(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			options := utils.QueryOptions{
+				Indent:     indent,
+				Colors:     getColorMode(cmd.Flags()),
+				Namespaces: namespaces,
+			}
+
+			if len(args) == 0 {
+				if inPlace {
+					return errors.New("in-place editing requires file names")
+				}
+				output := new(bytes.Buffer)
+				if err = utils.EditXml(os.Stdin, output, operations, options); err != nil {
+					return err
+				}
+				return utils.PagerPrint(output, cmd.OutOrStdout(), getPager(cmd.Flags()))
+			}
+
+			var readers []io.Reader
+			for _, fileName := range args {
+				content, err := os.ReadFile(fileName)
+				if err != nil {
+					return err
+				}
+
+				output := new(bytes.Buffer)
+				if err = utils.EditXml(bytes.NewReader(content), output, operations, options); err != nil {
+					return err
+				}
+
+				if inPlace {
+					if err = os.WriteFile(fileName, output.Bytes(), 0600); err != nil {
+						return err
+					}
+					continue
+				}
+				readers = append(readers, output)
+			}
+
+			if inPlace {
+				return nil
+			}
+
+			return utils.PagerPrint(io.MultiReader(readers...), cmd.OutOrStdout(), getPager(cmd.Flags()))
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.Var(&editOperationsValue{&operations, utils.EditUpdate}, "update",
+		"Replace the content of the matched nodes, `XPATH=VALUE`")
+	flags.Var(&editOperationsValue{&operations, utils.EditDelete}, "delete",
+		"Delete the nodes matched by `XPATH`")
+	flags.Var(&editOperationsValue{&operations, utils.EditInsertBefore}, "insert-before",
+		"Insert an XML fragment before the matched nodes, `XPATH=XML`")
+	flags.Var(&editOperationsValue{&operations, utils.EditInsertAfter}, "insert-after",
+		"Insert an XML fragment after the matched nodes, `XPATH=XML`")
+	flags.Var(&editOperationsValue{&operations, utils.EditAppend}, "append",
+		"Append an XML fragment to the children of the matched nodes, `XPATH=XML`")
+	flags.Var(&editOperationsValue{&operations, utils.EditRename}, "rename",
+		"Rename the matched elements or attributes, `XPATH=NAME`")
+	flags.Var(&editOperationsValue{&operations, utils.EditSetAttr}, "set-attr",
+		"Set an attribute of the matched elements, `XPATH@NAME=VALUE`")
+
+	return cmd
+}
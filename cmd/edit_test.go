@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEditCmd(t *testing.T) {
+	command := NewRootCmd()
+	InitFlags(command)
+
+	fileName := filepath.Join(t.TempDir(), "test.xml")
+	err := os.WriteFile(fileName, []byte(`<root><item id="1">one</item></root>`), 0600)
+	assert.Nil(t, err)
+
+	output, err := execute(command, "edit", "--update", "//item=two", "--set-attr", "//item@id=2", fileName)
+	assert.Nil(t, err)
+	assert.Contains(t, output, `<item id="2">two</item>`)
+
+	output, err = execute(command, "edit", "-i", "--delete", "//item", fileName)
+	assert.Nil(t, err)
+	assert.Equal(t, "", output)
+
+	content, err := os.ReadFile(fileName)
+	assert.Nil(t, err)
+	assert.Equal(t, "<root/>\n", string(content))
+
+	_, err = execute(command, "edit", fileName)
+	assert.ErrorContains(t, err, "at least one edit operation")
+
+	_, err = execute(command, "edit", "--update", "//item", fileName)
+	assert.ErrorContains(t, err, "expected XPATH=VALUE")
+
+	_, err = execute(command, "edit", "--delete", "//item", "nonexistent.xml")
+	assert.ErrorContains(t, err, "no such file or directory")
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"io/fs"
+	"os"
+
+	"github.com/sibprogrammer/xq/internal/utils"
+)
+
+// The exit codes allow the scripts to distinguish the kinds of errors.
+const (
+	exitError      = 1
+	exitParseError = 2
+	exitQueryError = 3
+	exitIOError    = 4
+)
+
+// filesError reports the failures of several files, the exit code follows the most severe one.
+type filesError struct {
+	message string
+	err     error
+}
+
+func (e *filesError) Error() string {
+	return e.message
+}
+
+func (e *filesError) Unwrap() error {
+	return e.err
+}
+
+// getMostSevereError returns the error with the higher exit code, the first one is kept if the
+// codes are equal.
+func getMostSevereError(current error, err error) error {
+	if current == nil || getExitCode(err) > getExitCode(current) {
+		return err
+	}
+
+	return current
+}
+
+func getExitCode(err error) int {
+	var diagnostic *utils.Diagnostic
+	if errors.As(err, &diagnostic) {
+		switch diagnostic.Kind {
+		case utils.ErrorParse:
+			return exitParseError
+		case utils.ErrorQuery:
+			return exitQueryError
+		}
+	}
+
+	var xmlError *xml.SyntaxError
+	var jsonError *json.SyntaxError
+	if errors.As(err, &xmlError) || errors.As(err, &jsonError) {
+		return exitParseError
+	}
+
+	var pathError *fs.PathError
+	var syscallError *os.SyscallError
+	var linkError *os.LinkError
+	if errors.As(err, &pathError) || errors.As(err, &syscallError) || errors.As(err, &linkError) {
+		return exitIOError
+	}
+
+	return exitError
+}
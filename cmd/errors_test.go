@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/sibprogrammer/xq/internal/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrors(t *testing.T) {
+	command := NewRootCmd()
+	InitFlags(command)
+
+	invalidFile := filepath.Join(t.TempDir(), "invalid.xml")
+	err := os.WriteFile(invalidFile, []byte("<root>\n  <a>1</b>\n</root>\n"), 0600)
+	assert.Nil(t, err)
+
+	output, err := execute(command, "--check", invalidFile)
+	assert.Equal(t, exitParseError, getExitCode(err))
+	assert.Contains(t, output, "Error: "+invalidFile+":2:7: XML syntax error: unexpected end element </b>\n"+
+		" 2 |   <a>1</b>\n   |       ^\nHint: the closing tag has no matching opening tag\n")
+
+	_, err = execute(command, "-i", invalidFile)
+	assert.ErrorContains(t, err, "1 file(s) failed")
+	assert.Equal(t, exitParseError, getExitCode(err))
+
+	assert.Equal(t, exitIOError, getExitCode(&filesError{"2 file(s) failed",
+		getMostSevereError(&utils.Diagnostic{Kind: utils.ErrorParse}, &os.PathError{Op: "open", Err: os.ErrNotExist})}))
+
+	_, err = execute(command, "--no-pager", invalidFile)
+	assert.ErrorContains(t, err, invalidFile+":2:7: XML syntax error")
+	assert.Equal(t, exitParseError, getExitCode(err))
+
+	invalidJSONFile := filepath.Join(t.TempDir(), "invalid.json")
+	assert.Nil(t, os.WriteFile(invalidJSONFile, []byte("{\"a\":\n  [1, 2,]}\n"), 0600))
+	_, err = execute(command, "--no-pager", "-j", invalidJSONFile)
+	assert.Equal(t, exitParseError, getExitCode(err))
+	assert.Contains(t, utils.FormatError(err), "2:9: JSON syntax error: invalid character ']' looking for beginning of value\n"+
+		" 2 |   [1, 2,]}\n   |         ^\n")
+
+	_, err = execute(command, "--no-pager", "-x", "//a[", filepath.Join("..", "test", "data", "xml", "formatted.xml"))
+	assert.ErrorContains(t, err, "XPath error")
+	assert.Equal(t, exitQueryError, getExitCode(err))
+
+	_, err = execute(command, filepath.Join(t.TempDir(), "missing.xml"))
+	assert.Equal(t, exitIOError, getExitCode(err))
+
+	assert.Equal(t, exitIOError, getExitCode(fmt.Errorf("write: %w", &os.PathError{Op: "write", Err: os.ErrClosed})))
+	assert.Equal(t, exitError, getExitCode(errors.New("indent should be between 0-8 spaces")))
+}
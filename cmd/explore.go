@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+
+	"github.com/sibprogrammer/xq/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// exploreContent opens the interactive tree browser for the document.
+func exploreContent(cmd *cobra.Command, readers []io.Reader, options utils.QueryOptions) error {
+	flags := cmd.Flags()
+	xPathQuery, _ := getXpathQuery(flags)
+	cssQuery, _ := flags.GetString("query")
+	inPlace, _ := flags.GetBool("in-place")
+	if xPathQuery != "" || cssQuery != "" || inPlace {
+		return errors.New("interactive mode is incompatible with nodes selection and in-place formatting")
+	}
+	if len(readers) != 1 {
+		return errors.New("interactive mode requires a single document")
+	}
+
+	contentType, reader := detectFormat(flags, readers[0])
+	doc, err := utils.ParseExplorerDocument(reader, contentType)
+	if err != nil {
+		return err
+	}
+
+	return utils.RunExplorer(doc, options)
+}
@@ -0,0 +1,21 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplore(t *testing.T) {
+	command := NewRootCmd()
+	InitFlags(command)
+
+	xmlFilePath := filepath.Join("..", "test", "data", "xml", "unformatted.xml")
+
+	_, err := execute(command, "--tui", "-x", "//city", xmlFilePath)
+	assert.ErrorContains(t, err, "incompatible")
+
+	_, err = execute(command, "--tui", xmlFilePath, xmlFilePath)
+	assert.ErrorContains(t, err, "requires a single document")
+}
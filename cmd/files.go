@@ -0,0 +1,345 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/sibprogrammer/xq/internal/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// defaultExtensions are the extensions of the files processed in the directories if no --include
+// patterns are provided.
+var defaultExtensions = []string{".xml", ".xsd", ".xsl", ".xslt", ".wsdl", ".svg", ".xhtml", ".html", ".htm",
+	".json", ".yaml", ".yml"}
+
+// fileReader opens the file on the first read and closes it once the content is read, so that
+// only the files being processed are kept open.
+type fileReader struct {
+	name string
+	file *os.File
+	done bool
+}
+
+func (reader *fileReader) Read(buf []byte) (int, error) {
+	if reader.file == nil {
+		if reader.done {
+			return 0, io.EOF
+		}
+		file, err := os.Open(reader.name)
+		if err != nil {
+			return 0, err
+		}
+		reader.file = file
+	}
+
+	length, err := reader.file.Read(buf)
+	if err == io.EOF {
+		_ = reader.Close()
+	}
+
+	return length, err
+}
+
+func (reader *fileReader) Close() error {
+	reader.done = true
+	if reader.file == nil {
+		return nil
+	}
+
+	err := reader.file.Close()
+	reader.file = nil
+	return err
+}
+
+func closeReaders(readers []io.Reader) {
+	for _, reader := range readers {
+		if closer, ok := reader.(io.Closer); ok && reader != os.Stdin {
+			_ = closer.Close()
+		}
+	}
+}
+
+// getInputFiles expands the glob patterns and the directories (with --recursive) of the arguments
+// into the file names. The files found are filtered by the --include and --exclude patterns.
+func getInputFiles(flags *pflag.FlagSet, args []string) ([]string, error) {
+	recursive, _ := flags.GetBool("recursive")
+	includes, _ := flags.GetStringArray("include")
+	excludes, _ := flags.GetStringArray("exclude")
+	for _, pattern := range append(includes, excludes...) {
+		if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	var fileNames []string
+	// the files found in the directories and by the patterns are processed once
+	found := map[string]bool{}
+	add := func(fileName string) {
+		if !found[fileName] {
+			found[fileName] = true
+			fileNames = append(fileNames, fileName)
+		}
+	}
+
+	for _, arg := range args {
+		fileInfo, err := os.Stat(arg)
+		switch {
+		case err == nil && fileInfo.IsDir():
+			if !recursive {
+				return nil, fmt.Errorf("%s is a directory, use --recursive (-r) to process it", arg)
+			}
+			err = walkFiles(arg, -1, func(fileName string, relPath string) {
+				if isIncludedFile(relPath, includes, excludes) {
+					add(fileName)
+				}
+			})
+			if err != nil {
+				return nil, err
+			}
+		case err == nil:
+			fileNames = append(fileNames, arg)
+		case errors.Is(err, fs.ErrNotExist) && strings.ContainsAny(arg, "*?["):
+			pattern := path.Clean(filepath.ToSlash(arg))
+			count := len(fileNames)
+			base, depth := getGlobBase(pattern)
+			err = walkFiles(base, depth, func(fileName string, relPath string) {
+				if matchGlob(pattern, filepath.ToSlash(fileName)) && isIncludedFile(relPath, []string{"*"}, excludes) {
+					add(fileName)
+				}
+			})
+			if err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+			if len(fileNames) == count {
+				return nil, fmt.Errorf("no files match %s", arg)
+			}
+		default:
+			return nil, err
+		}
+	}
+
+	return fileNames, nil
+}
+
+// walkFiles calls the function for the files in the directory and its subdirectories up to the
+// given depth (-1 for unlimited), the hidden directories are skipped.
+func walkFiles(dir string, depth int, callback func(fileName string, relPath string)) error {
+	return filepath.WalkDir(dir, func(fileName string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, _ := filepath.Rel(dir, fileName)
+		relPath = filepath.ToSlash(relPath)
+		if entry.IsDir() {
+			if fileName != dir && (strings.HasPrefix(entry.Name(), ".") || depth >= 0 &&
+				strings.Count(relPath, "/") >= depth) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if entry.Type().IsRegular() {
+			callback(fileName, relPath)
+		}
+		return nil
+	})
+}
+
+// isIncludedFile checks the path relative to the processed directory against the patterns. The
+// patterns without slashes are matched against the file name only. The files with the known
+// extensions are included if no patterns are provided.
+func isIncludedFile(relPath string, includes []string, excludes []string) bool {
+	matches := func(pattern string, name string) bool {
+		if !strings.Contains(pattern, "/") {
+			return matchGlob(pattern, path.Base(name))
+		}
+		return matchGlob(strings.TrimPrefix(pattern, "/"), name)
+	}
+
+	for _, pattern := range excludes {
+		// the excluded directories exclude all the files inside
+		for name := relPath; name != "."; name = path.Dir(name) {
+			if matches(pattern, name) {
+				return false
+			}
+		}
+	}
+
+	if len(includes) == 0 {
+		return isDefaultExtension(relPath)
+	}
+	for _, pattern := range includes {
+		if matches(pattern, relPath) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isDefaultExtension(fileName string) bool {
+	extension := strings.ToLower(path.Ext(fileName))
+	for _, defaultExtension := range defaultExtensions {
+		if extension == defaultExtension {
+			return true
+		}
+	}
+
+	return false
+}
+
+// getGlobBase returns the directory of the pattern preceding the first segment with wildcards and
+// the depth of the subdirectories matched by the rest of the pattern (-1 for unlimited).
+func getGlobBase(pattern string) (string, int) {
+	segments := strings.Split(pattern, "/")
+	var base []string
+	for _, segment := range segments {
+		if strings.ContainsAny(segment, "*?[") {
+			break
+		}
+		base = append(base, segment)
+	}
+
+	depth := len(segments) - len(base) - 1
+	if strings.Contains(pattern, "**") {
+		depth = -1
+	}
+
+	switch {
+	case len(base) == 0:
+		return ".", depth
+	case len(base) == 1 && base[0] == "":
+		return "/", depth
+	}
+
+	return filepath.FromSlash(strings.Join(base, "/")), depth
+}
+
+// matchGlob matches the slash-separated path against the pattern, where ** matches any number
+// of directories.
+func matchGlob(pattern string, name string) bool {
+	return matchGlobSegments(strings.Split(pattern, "/"), strings.Split(name, "/"))
+}
+
+func matchGlobSegments(patterns []string, segments []string) bool {
+	for len(patterns) > 0 {
+		if patterns[0] == "**" {
+			for skip := 0; skip <= len(segments); skip++ {
+				if matchGlobSegments(patterns[1:], segments[skip:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segments) == 0 {
+			return false
+		}
+		if matched, _ := path.Match(patterns[0], segments[0]); !matched {
+			return false
+		}
+		patterns, segments = patterns[1:], segments[1:]
+	}
+
+	return len(segments) == 0
+}
+
+type fileResult struct {
+	output []byte
+	err    error
+}
+
+// processFiles calls the function for the files on the pool of workers. The results are passed
+// to the callback in the order of the files as soon as they are available.
+func processFiles(fileNames []string, jobs int, process func(fileName string) fileResult,
+	callback func(fileName string, result fileResult)) {
+	if jobs <= 0 {
+		jobs = runtime.NumCPU()
+	}
+
+	results := make([]chan fileResult, len(fileNames))
+	for index := range results {
+		results[index] = make(chan fileResult, 1)
+	}
+
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for range min(jobs, len(fileNames)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range indexes {
+				results[index] <- process(fileNames[index])
+			}
+		}()
+	}
+
+	go func() {
+		for index := range fileNames {
+			indexes <- index
+		}
+		close(indexes)
+	}()
+
+	for index, fileName := range fileNames {
+		callback(fileName, <-results[index])
+	}
+	wg.Wait()
+}
+
+// formatInPlace formats the files in parallel and writes back the changed ones. The errors are
+// reported per file, and the summary is printed if several files are processed.
+func formatInPlace(cmd *cobra.Command, fileNames []string, jsonOutputMode bool, indent string) error {
+	jobs, _ := cmd.Flags().GetInt("jobs")
+
+	changed, unchanged, failed := 0, 0, 0
+	var failure error
+	processFiles(fileNames, jobs, func(fileName string) fileResult {
+		content, err := os.ReadFile(fileName)
+		if err != nil {
+			return fileResult{err: err}
+		}
+
+		formatted := new(bytes.Buffer)
+		err = processContent(bytes.NewReader(content), formatted, cmd.Flags(), jsonOutputMode, indent, utils.ColorsDisabled)
+		if err != nil || bytes.Equal(content, formatted.Bytes()) {
+			return fileResult{err: err}
+		}
+
+		if err = os.WriteFile(fileName, formatted.Bytes(), 0600); err != nil {
+			return fileResult{err: err}
+		}
+		return fileResult{output: formatted.Bytes()}
+	}, func(fileName string, result fileResult) {
+		switch {
+		case result.err != nil:
+			failed++
+			failure = getMostSevereError(failure, result.err)
+			_, _ = io.WriteString(cmd.ErrOrStderr(), utils.FormatError(utils.WithFileName(result.err, fileName)))
+		case result.output != nil:
+			changed++
+		default:
+			unchanged++
+		}
+	})
+
+	if len(fileNames) > 1 {
+		_, _ = fmt.Fprintf(cmd.ErrOrStderr(), "%d file(s) reformatted, %d unchanged, %d failed\n",
+			changed, unchanged, failed)
+	}
+	if failed > 0 {
+		return &filesError{fmt.Sprintf("%d file(s) failed", failed), failure}
+	}
+
+	return nil
+}
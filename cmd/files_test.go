@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatchGlob(t *testing.T) {
+	assert.True(t, matchGlob("*.xml", "feed.xml"))
+	assert.False(t, matchGlob("*.xml", "dir/feed.xml"))
+	assert.True(t, matchGlob("config/**/*.xml", "config/feed.xml"))
+	assert.True(t, matchGlob("config/**/*.xml", "config/a/b/feed.xml"))
+	assert.False(t, matchGlob("config/**/*.xml", "other/a/feed.xml"))
+	assert.True(t, matchGlob("**/build", "a/build"))
+	assert.False(t, matchGlob("a/?.xml", "a/ab.xml"))
+
+	base, depth := getGlobBase("config/**/*.xml")
+	assert.Equal(t, "config", base)
+	assert.Equal(t, -1, depth)
+	base, depth = getGlobBase("*/*.xml")
+	assert.Equal(t, ".", base)
+	assert.Equal(t, 1, depth)
+}
+
+func TestRecursiveProcessing(t *testing.T) {
+	command := NewRootCmd()
+	InitFlags(command)
+
+	dir := t.TempDir()
+	files := map[string]string{
+		"one.xml":          "<root><item>1</item></root>\n",
+		"sub/two.xml":      "<root>\n  <item>2</item>\n</root>\n",
+		"sub/broken.xml":   "<root><item>",
+		"sub/notes.txt":    "notes\n",
+		"build/three.xml":  "<root><item>3</item></root>\n",
+		".hidden/four.xml": "<root><item>4</item></root>\n",
+	}
+	for name, content := range files {
+		fileName := filepath.Join(dir, filepath.FromSlash(name))
+		assert.Nil(t, os.MkdirAll(filepath.Dir(fileName), 0700))
+		assert.Nil(t, os.WriteFile(fileName, []byte(content), 0600))
+	}
+	path := func(name string) string {
+		return filepath.Join(dir, filepath.FromSlash(name))
+	}
+
+	_, err := execute(command, "--check", dir)
+	assert.ErrorContains(t, err, "is a directory")
+
+	output, err := execute(command, "--check", "-r", "--exclude", "broken.xml", dir)
+	assert.ErrorContains(t, err, "2 file(s) would be reformatted")
+	assert.Contains(t, output, path("build/three.xml")+"\n"+path("one.xml"))
+	assert.NotContains(t, output, "four.xml")
+
+	output, err = execute(command, "--check", "-r", "--exclude", "build", "--jobs", "1", dir)
+	assert.ErrorContains(t, err, "1 file(s) would be reformatted, 1 file(s) failed")
+	assert.Contains(t, output, "Error: "+path("sub/broken.xml")+":")
+	assert.NotContains(t, output, "three.xml")
+
+	output, err = execute(command, "--check", "-r", "--include", "*.txt", dir)
+	assert.ErrorContains(t, err, "1 file(s) would be reformatted")
+	assert.Contains(t, output, path("sub/notes.txt"))
+
+	output, err = execute(command, "--check", filepath.Join(dir, "**", "t*.xml"))
+	assert.ErrorContains(t, err, "1 file(s) would be reformatted")
+	assert.Contains(t, output, path("build/three.xml"))
+
+	_, err = execute(command, "--check", filepath.Join(dir, "*.json"))
+	assert.ErrorContains(t, err, "no files match")
+
+	output, err = execute(command, "-i", "-r", dir)
+	assert.ErrorContains(t, err, "1 file(s) failed")
+	assert.Contains(t, output, "2 file(s) reformatted, 1 unchanged, 1 failed")
+
+	content, err := os.ReadFile(path("one.xml"))
+	assert.Nil(t, err)
+	assert.Equal(t, "<root>\n  <item>1</item>\n</root>\n", string(content))
+	content, err = os.ReadFile(path(".hidden/four.xml"))
+	assert.Nil(t, err)
+	assert.Equal(t, files[".hidden/four.xml"], string(content))
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/sibprogrammer/xq/internal/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+type lintReport struct {
+	File string `json:"file"`
+	utils.LintFinding
+}
+
+func newLintCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "lint [flags] [file...]",
+		Short: "Check that XML and HTML documents are well-formed",
+		Long: "Parses the documents in strict mode and reports all the problems found: mismatched tags, " +
+			"duplicate attributes, undeclared namespace prefixes and invalid characters in XML, unclosed and " +
+			"deprecated elements and duplicate ids in HTML. JSON and YAML documents are checked for syntax errors.",
+		Args:         cobra.ArbitraryArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			format, _ := cmd.Flags().GetString("format")
+			if format != "text" && format != "json" {
+				return fmt.Errorf("unknown output format %q, use text or json", format)
+			}
+
+			fileNames, err := getInputFiles(cmd.Flags(), args)
+			if err != nil {
+				return err
+			}
+
+			var reports []lintReport
+			failed := 0
+			lintFile := func(fileName string, reader io.Reader) {
+				findings, err := lintContent(cmd.Flags(), reader)
+				if err != nil {
+					failed++
+					_, _ = io.WriteString(cmd.ErrOrStderr(), utils.FormatError(utils.WithFileName(err, fileName)))
+				}
+				for _, finding := range findings {
+					reports = append(reports, lintReport{File: fileName, LintFinding: finding})
+				}
+			}
+
+			if len(args) == 0 {
+				lintFile("<stdin>", cmd.InOrStdin())
+			}
+			for _, fileName := range fileNames {
+				reader := &fileReader{name: fileName}
+				lintFile(fileName, reader)
+				_ = reader.Close()
+			}
+
+			if format == "json" {
+				if reports == nil {
+					reports = []lintReport{}
+				}
+				encoder := json.NewEncoder(cmd.OutOrStdout())
+				encoder.SetEscapeHTML(false)
+				encoder.SetIndent("", "  ")
+				if err = encoder.Encode(reports); err != nil {
+					return err
+				}
+			} else {
+				for _, report := range reports {
+					_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%s:%d:%d: %s: %s [%s]\n", report.File, report.Line,
+						report.Column, report.Severity, report.Message, report.Rule)
+				}
+			}
+
+			switch {
+			case failed > 0 && len(reports) > 0:
+				return fmt.Errorf("%d problem(s) found, %d file(s) failed", len(reports), failed)
+			case failed > 0:
+				return fmt.Errorf("%d file(s) failed", failed)
+			case len(reports) > 0:
+				return fmt.Errorf("%d problem(s) found", len(reports))
+			}
+
+			return nil
+		},
+	}
+
+	flags := cmd.Flags()
+	flags.String("format", "text", "Output format of the problems: text or json")
+	flags.BoolP("recursive", "r", false, "Process the files in the directories and their subdirectories")
+	flags.StringArray("include", nil, "Process only the files matching the glob `pattern` in the directories")
+	flags.StringArray("exclude", nil, "Skip the files and directories matching the glob `pattern`")
+
+	return cmd
+}
+
+// lintContent checks the document of the detected type. The syntax errors of JSON and YAML
+// documents are returned as the findings.
+func lintContent(flags *pflag.FlagSet, reader io.Reader) ([]utils.LintFinding, error) {
+	contentType, reader := detectFormat(flags, reader)
+
+	var err error
+	switch contentType {
+	case utils.ContentHtml:
+		return utils.LintHtml(reader)
+	case utils.ContentJson:
+		err = utils.FormatJson(reader, io.Discard, "", utils.ColorsDisabled)
+	case utils.ContentYaml:
+		err = utils.FormatYaml(reader, io.Discard, "", utils.ColorsDisabled)
+	default:
+		return utils.LintXml(reader)
+	}
+
+	var diagnostic *utils.Diagnostic
+	if errors.As(err, &diagnostic) {
+		return []utils.LintFinding{{
+			Line:     diagnostic.Line,
+			Column:   diagnostic.Column,
+			Severity: utils.LintError,
+			Rule:     "syntax",
+			Message:  diagnostic.Message,
+		}}, nil
+	}
+
+	return nil, err
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLint(t *testing.T) {
+	command := NewRootCmd()
+	InitFlags(command)
+
+	dir := t.TempDir()
+	invalidFile := filepath.Join(dir, "invalid.xml")
+	err := os.WriteFile(invalidFile, []byte("<root>\n  <a b=\"1\" b=\"2\"></c>\n</root>\n"), 0600)
+	assert.Nil(t, err)
+	htmlFile := filepath.Join(dir, "page.html")
+	err = os.WriteFile(htmlFile, []byte("<html><body><center>text</center></body></html>"), 0600)
+	assert.Nil(t, err)
+
+	output, err := execute(command, "lint", filepath.Join("..", "test", "data", "xml", "formatted.xml"),
+		filepath.Join("..", "test", "data", "html", "formatted.html"), filepath.Join("..", "test", "data", "json", "store.json"))
+	assert.Nil(t, err)
+	assert.Equal(t, "", output)
+
+	output, err = execute(command, "lint", "-r", dir)
+	assert.ErrorContains(t, err, "4 problem(s) found")
+	assert.Contains(t, output, invalidFile+":2:3: error: attribute b is duplicated in element <a> [duplicate-attr]\n"+
+		invalidFile+":2:18: error: closing tag </c> has no matching opening tag [mismatched-tag]\n"+
+		invalidFile+":3:1: error: element <a> opened at 2:3 is closed by </root> [mismatched-tag]\n")
+	assert.Contains(t, output, htmlFile+":1:13: warning: element <center> is deprecated [deprecated-element]\n")
+
+	output, err = execute(command, "lint", "--format", "json", htmlFile)
+	assert.ErrorContains(t, err, "1 problem(s) found")
+	assert.Contains(t, output, `[
+  {
+    "file": "`+htmlFile+`",
+    "line": 1,
+    "column": 13,
+    "severity": "warning",
+    "rule": "deprecated-element",
+    "message": "element <center> is deprecated"
+  }
+]`)
+
+	_, err = execute(command, "lint", "--format", "xml", htmlFile)
+	assert.ErrorContains(t, err, "unknown output format")
+}
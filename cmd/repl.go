@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/sibprogrammer/xq/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+func newReplCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "repl [flags] file",
+		Short: "Evaluate XPath queries and CSS selectors interactively",
+		Long: "Parses the document once and evaluates the XPath queries or CSS selectors entered at " +
+			"the prompt. The element and attribute names are completed with Tab. Enter :help to list the commands.",
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			indent, err := getIndent(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			withTags, _ := cmd.Flags().GetBool("node")
+			jsonOutputMode, _ := cmd.Flags().GetBool("json")
+			namespaces, err := getNamespaces(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			mapper, err := getJSONMapper(cmd.Flags())
+			if err != nil {
+				return err
+			}
+			options := utils.QueryOptions{
+				WithTags:   withTags,
+				JSON:       jsonOutputMode,
+				Indent:     indent,
+				Colors:     getColorMode(cmd.Flags()),
+				Namespaces: namespaces,
+				JSONMapper: mapper,
+			}
+
+			file, err := os.Open(args[0])
+			if err != nil {
+				return err
+			}
+			defer func() {
+				_ = file.Close()
+			}()
+
+			contentType, reader := detectFormat(cmd.Flags(), file)
+			session, err := utils.NewQuerySession(reader, contentType)
+			if err != nil {
+				return fmt.Errorf("unable to parse %s: %w", args[0], err)
+			}
+
+			historyFile := ""
+			if homeDir, err := os.UserHomeDir(); err == nil {
+				historyFile = filepath.Join(homeDir, ".xq_history")
+			}
+
+			return utils.RunRepl(utils.NewRepl(session, options), cmd.InOrStdin(), cmd.OutOrStdout(), historyFile)
+		},
+	}
+
+	return cmd
+}
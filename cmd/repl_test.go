@@ -0,0 +1,33 @@
+package cmd
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepl(t *testing.T) {
+	command := NewRootCmd()
+	InitFlags(command)
+	t.Setenv("HOME", t.TempDir())
+
+	inputFile := filepath.Join("..", "test", "data", "xslt", "catalog.xml")
+
+	command.SetIn(strings.NewReader("//book[@id='b3']/title\n:css\nbook[id=b4] > author\n"))
+	output, err := execute(command, "repl", inputFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "Dune\nMary Beard", output)
+
+	command.SetIn(strings.NewReader("//year[. > 2000]\n"))
+	output, err = execute(command, "repl", "--no-color", "-n", inputFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "<year>2015</year>", output)
+
+	_, err = execute(command, "repl")
+	assert.ErrorContains(t, err, "accepts 1 arg")
+
+	_, err = execute(command, "repl", "nonexistent.xml")
+	assert.NotNil(t, err)
+}
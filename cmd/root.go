@@ -7,25 +7,33 @@ import (
 	"fmt"
 	"io"
 	"os"
-	"path/filepath"
 	"strings"
 
 	"github.com/antchfx/xmlquery"
 	"github.com/sibprogrammer/xq/internal/utils"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
+	"golang.org/x/term"
+	"gopkg.in/yaml.v3"
 )
 
 // Version information
 var Version string
 
+// streamThreshold is the size of the input file starting from which the XPath queries are
+// evaluated in streaming mode if possible.
+const streamThreshold = 64 << 20
+
 var rootCmd = NewRootCmd()
 
 func NewRootCmd() *cobra.Command {
-	return &cobra.Command{
+	cmd := &cobra.Command{
 		Use:          "xq",
 		Short:        "Command-line XML and HTML beautifier and content extractor",
+		Args:         cobra.ArbitraryArgs,
 		SilenceUsage: true,
+		// the errors are printed by Execute together with the source excerpts
+		SilenceErrors: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
 			var err error
 			var readers []io.Reader
@@ -47,99 +55,130 @@ func NewRootCmd() *cobra.Command {
 
 				readers = append(readers, os.Stdin)
 			} else {
-				for _, fileName := range args {
-					fileNames = append(fileNames, fileName)
-					f, err := os.Open(fileName)
-					if err != nil {
-						return err
-					}
-					defer func() {
-						_ = f.Close()
-					}()
-
-					readers = append(readers, f)
+				if fileNames, err = getInputFiles(cmd.Flags(), args); err != nil {
+					return err
+				}
+				for _, fileName := range fileNames {
+					readers = append(readers, &fileReader{name: fileName})
 				}
+				defer closeReaders(readers)
 			}
 
-			xPathQuery, singleNode := getXpathQuery(cmd.Flags())
+			xPathQuery, _ := getXpathQuery(cmd.Flags())
 			withTags, _ := cmd.Flags().GetBool("node")
 			colors := getColorMode(cmd.Flags())
 
-			options := utils.QueryOptions{
-				WithTags: withTags,
-				Indent:   indent,
-				Colors:   colors,
-			}
-
 			cssQuery, _ := cmd.Flags().GetString("query")
 			cssAttr, _ := cmd.Flags().GetString("attr")
 			if cssAttr != "" && cssQuery == "" {
 				return errors.New("query option (-q) is missed for attribute selection")
 			}
 			jsonOutputMode, _ := cmd.Flags().GetBool("json")
+			namespaces, err := getNamespaces(cmd.Flags())
+			if err != nil {
+				return err
+			}
+
+			options := utils.QueryOptions{
+				WithTags:   withTags,
+				JSON:       jsonOutputMode,
+				Indent:     indent,
+				Colors:     colors,
+				Namespaces: namespaces,
+			}
+			if err = checkOutputModes(cmd.Flags()); err != nil {
+				return err
+			}
+			if options.Template, err = getTemplate(cmd.Flags()); err != nil {
+				return err
+			}
+			if options.JSONMapper, err = getJSONMapper(cmd.Flags()); err != nil {
+				return err
+			}
+			if options.Markdown, _ = cmd.Flags().GetBool("to-markdown"); options.Markdown &&
+				(xPathQuery != "" || cssAttr != "" || options.Template != nil) {
+				return errors.New("markdown conversion (--to-markdown) supports the CSS query (-q) of elements only")
+			}
+			if textMode, _ := cmd.Flags().GetBool("text"); textMode {
+				if xPathQuery != "" || cssAttr != "" || options.Template != nil {
+					return errors.New("text rendering (--text) supports the CSS query (-q) of elements only")
+				}
+				options.TextWidth = getTextWidth(cmd.Flags())
+			}
 
 			if (xPathQuery != "" || cssQuery != "") && inPlace {
 				return errors.New("in-place formatting is incompatible with nodes selection")
 			}
 
-			pr, pw := io.Pipe()
+			if schemaFile, _ := cmd.Flags().GetString("validate-xsd"); schemaFile != "" {
+				return validateWithSchema(cmd, schemaFile, readers, fileNames)
+			}
 
-			if inPlace {
-				for i := range len(fileNames) {
-					var content []byte
-					fileName := fileNames[i]
-					reader := readers[i]
-					pr, pw := io.Pipe()
-
-					go func() {
-						err := processContent(reader, pw, cmd.Flags(), jsonOutputMode, indent, colors)
-						_ = pw.CloseWithError(err)
-					}()
+			if stylesheetFile, _ := cmd.Flags().GetString("xslt"); stylesheetFile != "" {
+				return transformWithXslt(cmd, stylesheetFile, readers, options)
+			}
 
-					if content, err = io.ReadAll(pr); err != nil {
-						return err
-					}
+			if tuiMode, _ := cmd.Flags().GetBool("tui"); tuiMode {
+				return exploreContent(cmd, readers, options)
+			}
 
-					if err = os.WriteFile(fileName, content, 0600); err != nil {
-						return err
-					}
-				}
+			if rowQuery, _ := cmd.Flags().GetString("row"); rowQuery != "" {
+				return extractTable(cmd, rowQuery, readers)
+			} else if columns, _ := cmd.Flags().GetStringArray("col"); len(columns) > 0 {
+				return errors.New("row option (--row) is missed for tabular extraction")
+			}
 
-				return nil
+			checkMode, _ := cmd.Flags().GetBool("check")
+			if diffMode, _ := cmd.Flags().GetBool("diff"); checkMode || diffMode {
+				return checkFormatting(cmd, fileNames, jsonOutputMode, indent)
 			}
 
+			if inPlace {
+				return formatInPlace(cmd, fileNames, jsonOutputMode, indent)
+			}
+
+			pr, pw := io.Pipe()
+
 			go func() {
-				defer func() {
-					_ = pw.Close()
-				}()
-
-				for _, reader := range readers {
-					if xPathQuery != "" {
-						err = utils.XPathQuery(reader, pw, xPathQuery, singleNode, options)
-					} else if cssQuery != "" {
-						err = utils.CSSQuery(reader, pw, cssQuery, cssAttr, options)
+				for index, reader := range readers {
+					var err error
+					if xPathQuery != "" || cssQuery != "" {
+						err = queryContent(reader, pw, cmd.Flags(), options)
 					} else {
 						err = processContent(reader, pw, cmd.Flags(), jsonOutputMode, indent, colors)
 					}
-				}
 
-				if err != nil {
-					fmt.Println("Error:", err)
-					os.Exit(1)
+					if err != nil {
+						fileName := "<stdin>"
+						if len(fileNames) > 0 {
+							fileName = fileNames[index]
+						}
+						err = utils.WithFileName(err, fileName)
+						_ = pw.CloseWithError(err)
+						return
+					}
 				}
+
+				_ = pw.Close()
 			}()
 
 			return utils.PagerPrint(pr, cmd.OutOrStdout(), getPager(cmd.Flags()))
 		},
 	}
+
+	cmd.AddCommand(newEditCmd())
+	cmd.AddCommand(newDiffCmd())
+	cmd.AddCommand(newReplCmd())
+	cmd.AddCommand(newLintCmd())
+
+	return cmd
 }
 
 func InitFlags(cmd *cobra.Command) {
-	homeDir, _ := os.UserHomeDir()
-	configFile := filepath.Join(homeDir, ".xq")
-	if err := utils.LoadConfig(configFile); err != nil {
-		fmt.Printf("Error while reading the config file: %v\n", err)
-		os.Exit(1)
+	if err := utils.LoadConfig(utils.GetConfigFiles()...); err != nil {
+		err = fmt.Errorf("error while reading the config file: %w", err)
+		_, _ = io.WriteString(cmd.ErrOrStderr(), utils.FormatError(err))
+		os.Exit(getExitCode(err))
 	}
 
 	cmd.Version = Version
@@ -148,6 +187,7 @@ func InitFlags(cmd *cobra.Command) {
 	cmd.Flags().BoolP("version", "v", false, "Print version information")
 	cmd.PersistentFlags().StringP("xpath", "x", "", "Extract the node(s) from XML")
 	cmd.PersistentFlags().StringP("extract", "e", "", "Extract a single node from XML")
+	cmd.PersistentFlags().StringArray("ns", nil, "Bind the namespace prefix used in the XPath queries, `prefix=uri`")
 	cmd.PersistentFlags().Bool("tab", utils.GetConfig().Tab, "Use tabs for indentation")
 	cmd.PersistentFlags().Int("indent", utils.GetConfig().Indent,
 		"Use the given number of spaces for indentation")
@@ -155,6 +195,7 @@ func InitFlags(cmd *cobra.Command) {
 	cmd.PersistentFlags().BoolP("color", "c", utils.GetConfig().Color,
 		"Force colorful output")
 	cmd.PersistentFlags().BoolP("html", "m", utils.GetConfig().Html, "Use HTML formatter")
+	cmd.Flags().Bool("stream", false, "Evaluate the XPath query while reading the document (enabled for large files)")
 	cmd.PersistentFlags().StringP("query", "q", "",
 		"Extract the node(s) using CSS selector")
 	cmd.PersistentFlags().StringP("attr", "a", "",
@@ -162,17 +203,58 @@ func InitFlags(cmd *cobra.Command) {
 	cmd.PersistentFlags().BoolP("node", "n", utils.GetConfig().Node,
 		"Return the node content instead of text")
 	cmd.PersistentFlags().BoolP("json", "j", false, "Output the result as JSON")
+	cmd.PersistentFlags().Bool("to-xml", false, "Convert JSON input to XML")
+	cmd.PersistentFlags().Bool("to-yaml", false, "Output the result as YAML")
+	cmd.PersistentFlags().Bool("to-markdown", false, "Convert HTML to Markdown (CommonMark with GFM tables)")
+	cmd.PersistentFlags().Bool("text", false, "Render HTML as the plain text with the numbered links")
+	cmd.PersistentFlags().Int("width", 0, "Wrap the plain text at the width (the terminal width if 0)")
+	cmd.PersistentFlags().String("json-style", utils.DefaultJSONStyle,
+		"Convention of the XML to JSON conversion: "+strings.Join(utils.GetJSONStyles(), ", "))
+	cmd.PersistentFlags().String("json-attr-prefix", "", "Prefix of the attribute keys in JSON (the style default if empty)")
+	cmd.PersistentFlags().String("json-text-key", "", "Key of the text content in JSON (the style default if empty)")
+	cmd.PersistentFlags().Bool("json-lossless", false,
+		"Convert XML to the ordered JSON representation which can be converted back with --to-xml")
+	cmd.PersistentFlags().StringArray("array-path", nil,
+		"Always output the elements matched by the XPath `query` as JSON arrays")
+	cmd.PersistentFlags().StringArray("type-path", nil,
+		"Convert the values matched by the XPath query to the JSON type: `query=type` (string, number, boolean or auto)")
+	cmd.PersistentFlags().Bool("infer-types", false, "Convert the numbers, booleans and empty values to the JSON types")
+	cmd.PersistentFlags().String("json-xsd", "",
+		"Take the JSON arrays and types from the declarations of the XML Schema `file`")
 	cmd.PersistentFlags().Bool("compact", false, "Compact JSON output (no indentation)")
 	cmd.PersistentFlags().IntP("depth", "d", -1, "Maximum nesting depth for JSON output (-1 for unlimited)")
 	cmd.PersistentFlags().BoolP("in-place", "i", false, "Format file in place")
+	cmd.Flags().BoolP("recursive", "r", false, "Process the files in the directories and their subdirectories")
+	cmd.Flags().StringArray("include", nil, "Process only the files matching the glob `pattern` in the directories")
+	cmd.Flags().StringArray("exclude", nil, "Skip the files and directories matching the glob `pattern`")
+	cmd.Flags().Int("jobs", 0, "Number of files formatted or checked in parallel (0 for the number of CPUs)")
+	cmd.Flags().Bool("check", false, "Check that the files are formatted, list the files which would be changed")
+	cmd.Flags().Bool("diff", false, "Print the unified diff of the formatting changes (implies --check)")
+	cmd.Flags().String("validate-xsd", "", "Validate XML against the XML Schema `file`")
+	cmd.Flags().String("xslt", "", "Transform XML using the XSLT 1.0 stylesheet `file`")
+	cmd.Flags().StringArray("xslt-param", nil, "Pass the `name=value` parameter to the XSLT stylesheet")
+	cmd.Flags().String("template", "", "Format a record for each matched node, e.g. '{@id}\\t{name}'")
+	cmd.Flags().String("separator", `\n`, "Separate the records formatted with the template by the given string")
+	cmd.Flags().String("row", "", "Extract a table with a record for each node matched by the XPath `query`")
+	cmd.Flags().StringArray("col", nil, "Add the `name=xpath` column evaluated relative to the row node")
+	cmd.Flags().String("delimiter", ",", "Use the given field delimiter for the table (\\t or tab for TSV)")
+	cmd.Flags().Bool("no-header", false, "Omit the header line of the table")
+	cmd.Flags().Bool("tui", false, "Browse the document in the interactive tree view")
 	cmd.PersistentFlags().Bool("no-pager", utils.GetConfig().NoPager, "Disable pager for the output")
+
+	if err := applyConfig(cmd); err != nil {
+		err = fmt.Errorf("error while reading the config file: %w", err)
+		_, _ = io.WriteString(cmd.ErrOrStderr(), utils.FormatError(err))
+		os.Exit(getExitCode(err))
+	}
 }
 
 func Execute() {
 	InitFlags(rootCmd)
 
 	if err := rootCmd.Execute(); err != nil {
-		os.Exit(1)
+		_, _ = io.WriteString(rootCmd.ErrOrStderr(), utils.FormatError(err))
+		os.Exit(getExitCode(err))
 	}
 }
 
@@ -210,6 +292,84 @@ func getXpathQuery(flags *pflag.FlagSet) (query string, single bool) {
 	return query, true
 }
 
+func getNamespaces(flags *pflag.FlagSet) (map[string]string, error) {
+	bindings, _ := flags.GetStringArray("ns")
+	return utils.ParseNamespaces(bindings)
+}
+
+// getTemplate parses the output template of the matched nodes, nil is returned if it is not defined.
+func getTemplate(flags *pflag.FlagSet) (*utils.OutputTemplate, error) {
+	template, _ := flags.GetString("template")
+	if template == "" {
+		return nil, nil
+	}
+
+	xPathQuery, _ := getXpathQuery(flags)
+	cssQuery, _ := flags.GetString("query")
+	if xPathQuery == "" && cssQuery == "" {
+		return nil, errors.New("template option (--template) requires a query (-x, -e or -q)")
+	}
+	cssAttr, _ := flags.GetString("attr")
+	jsonOutputMode, _ := flags.GetBool("json")
+	if cssAttr != "" || jsonOutputMode {
+		return nil, errors.New("template option (--template) is incompatible with --attr and --json")
+	}
+
+	separator, _ := flags.GetString("separator")
+	return utils.ParseTemplate(template, separator)
+}
+
+func getJSONMapper(flags *pflag.FlagSet) (utils.JSONMapper, error) {
+	style, _ := flags.GetString("json-style")
+	options := utils.JSONMapperOptions{}
+	options.AttrPrefix, _ = flags.GetString("json-attr-prefix")
+	options.TextKey, _ = flags.GetString("json-text-key")
+
+	var err error
+	if options.Shape, err = getJSONShape(flags); err != nil {
+		return nil, err
+	}
+
+	return utils.NewJSONMapper(style, options)
+}
+
+// getJSONShape returns the shape of the JSON converted from XML, nil is returned if the arrays
+// and the types are not defined.
+func getJSONShape(flags *pflag.FlagSet) (*utils.JSONShape, error) {
+	options := utils.JSONShapeOptions{}
+	options.ArrayPaths, _ = flags.GetStringArray("array-path")
+	options.TypePaths, _ = flags.GetStringArray("type-path")
+	options.AutoTypes, _ = flags.GetBool("infer-types")
+	schemaFile, _ := flags.GetString("json-xsd")
+	if len(options.ArrayPaths) == 0 && len(options.TypePaths) == 0 && !options.AutoTypes && schemaFile == "" {
+		return nil, nil
+	}
+
+	var err error
+	if schemaFile != "" {
+		if options.Schema, err = utils.LoadXmlSchema(schemaFile); err != nil {
+			return nil, fmt.Errorf("unable to load the schema: %w", err)
+		}
+	}
+	if options.Namespaces, err = getNamespaces(flags); err != nil {
+		return nil, err
+	}
+
+	return utils.NewJSONShape(options)
+}
+
+// getTextWidth returns the width of the plain text, the width of the terminal is used by default.
+func getTextWidth(flags *pflag.FlagSet) int {
+	if width, _ := flags.GetInt("width"); width > 0 {
+		return width
+	}
+	if width, _, err := term.GetSize(int(os.Stdout.Fd())); err == nil && width > 0 {
+		return width
+	}
+
+	return utils.DefaultTextWidth
+}
+
 func getPager(flags *pflag.FlagSet) string {
 	noPager, _ := flags.GetBool("no-pager")
 	if noPager {
@@ -225,7 +385,9 @@ func getPager(flags *pflag.FlagSet) string {
 
 func getColorMode(flags *pflag.FlagSet) int {
 	inPlace, _ := flags.GetBool("in-place")
-	if inPlace {
+	checkMode, _ := flags.GetBool("check")
+	diffMode, _ := flags.GetBool("diff")
+	if inPlace || checkMode || diffMode {
 		return utils.ColorsDisabled
 	}
 
@@ -236,8 +398,9 @@ func getColorMode(flags *pflag.FlagSet) int {
 		colors = utils.ColorsDisabled
 	}
 
+	// the option from the command line takes precedence over the config one
 	forcedColors, _ := flags.GetBool("color")
-	if forcedColors {
+	if forcedColors && !(disableColors && flags.Changed("no-color") && !flags.Changed("color")) {
 		colors = utils.ColorsForced
 	}
 
@@ -250,22 +413,27 @@ func detectFormat(flags *pflag.FlagSet, origReader io.Reader) (utils.ContentType
 		return utils.ContentHtml, origReader
 	}
 
-	buf := make([]byte, 10)
+	buf := make([]byte, 512)
 	length, err := origReader.Read(buf)
 	if err != nil {
 		return utils.ContentText, origReader
 	}
 
 	reader := io.MultiReader(bytes.NewReader(buf[:length]), origReader)
+	head := string(buf[:min(length, 10)])
 
-	if utils.IsJSON(string(buf)) {
+	if utils.IsJSON(head) {
 		return utils.ContentJson, reader
 	}
 
-	if utils.IsHTML(string(buf)) {
+	if utils.IsHTML(head) {
 		return utils.ContentHtml, reader
 	}
 
+	if utils.IsYAML(string(buf[:length]), length == len(buf)) {
+		return utils.ContentYaml, reader
+	}
+
 	return utils.ContentXml, reader
 }
 
@@ -274,8 +442,26 @@ func processContent(reader io.Reader, pw io.Writer, flags *pflag.FlagSet, jsonOu
 	var contentType utils.ContentType
 
 	contentType, reader = detectFormat(flags, reader)
-
-	if jsonOutputMode {
+	xmlOutputMode, _ := flags.GetBool("to-xml")
+	yamlOutputMode, _ := flags.GetBool("to-yaml")
+
+	if losslessMode, _ := flags.GetBool("json-lossless"); xmlOutputMode && losslessMode {
+		err = utils.LosslessToXml(reader, pw)
+	} else if xmlOutputMode {
+		err = processAsXml(reader, pw, indent, colors)
+	} else if yamlOutputMode {
+		err = processAsYaml(flags, reader, pw, contentType, indent, colors)
+	} else if markdownOutputMode, _ := flags.GetBool("to-markdown"); markdownOutputMode {
+		if contentType != utils.ContentHtml && contentType != utils.ContentXml {
+			return errors.New("markdown conversion requires HTML input")
+		}
+		err = utils.HtmlToMarkdown(reader, pw)
+	} else if textOutputMode, _ := flags.GetBool("text"); textOutputMode {
+		if contentType != utils.ContentHtml && contentType != utils.ContentXml {
+			return errors.New("text rendering requires HTML input")
+		}
+		err = utils.HtmlToText(reader, pw, getTextWidth(flags))
+	} else if jsonOutputMode {
 		err = processAsJSON(flags, reader, pw, contentType)
 	} else {
 		switch contentType {
@@ -285,6 +471,8 @@ func processContent(reader io.Reader, pw io.Writer, flags *pflag.FlagSet, jsonOu
 			err = utils.FormatXml(reader, pw, indent, colors)
 		case utils.ContentJson:
 			err = utils.FormatJson(reader, pw, indent, colors)
+		case utils.ContentYaml:
+			err = utils.FormatYaml(reader, pw, indent, colors)
 		default:
 			err = fmt.Errorf("unknown content type: %v", contentType)
 		}
@@ -293,6 +481,79 @@ func processContent(reader io.Reader, pw io.Writer, flags *pflag.FlagSet, jsonOu
 	return err
 }
 
+// queryContent extracts the nodes using the XPath query or the CSS selector. JSON and YAML
+// documents are queried as the virtual element trees.
+func queryContent(reader io.Reader, w io.Writer, flags *pflag.FlagSet, options utils.QueryOptions) error {
+	var contentType utils.ContentType
+	xPathQuery, singleNode := getXpathQuery(flags)
+	cssQuery, _ := flags.GetString("query")
+	cssAttr, _ := flags.GetString("attr")
+	streamMode, _ := flags.GetBool("stream")
+	if streamMode && xPathQuery == "" {
+		return errors.New("streaming mode is supported for XPath queries only")
+	}
+	if streamMode && options.JSON {
+		return errors.New("streaming mode is incompatible with JSON output")
+	}
+	// the streaming parser matches the prefixes of the path literally
+	if streamMode && len(options.Namespaces) > 0 {
+		return errors.New("streaming mode is incompatible with namespace bindings")
+	}
+	largeInput := isLargeInput(reader)
+
+	contentType, reader = detectFormat(flags, reader)
+	if xPathQuery != "" && contentType == utils.ContentXml && !options.JSON &&
+		(streamMode || largeInput && len(options.Namespaces) == 0 && utils.IsStreamableXPath(xPathQuery)) {
+		return utils.XPathStreamQuery(reader, w, xPathQuery, singleNode, options)
+	}
+	if streamMode {
+		return errors.New("streaming mode is supported for XML input only")
+	}
+
+	if options.Markdown && (contentType == utils.ContentJson || contentType == utils.ContentYaml) {
+		return errors.New("markdown conversion requires HTML input")
+	}
+	if options.TextWidth > 0 && (contentType == utils.ContentJson || contentType == utils.ContentYaml) {
+		return errors.New("text rendering requires HTML input")
+	}
+
+	if contentType == utils.ContentYaml {
+		data, err := utils.YamlToJSON(reader)
+		if err != nil {
+			return fmt.Errorf("error while parsing YAML: %w", err)
+		}
+		contentType, reader = utils.ContentJson, bytes.NewReader(data)
+	}
+
+	if contentType == utils.ContentJson {
+		if xPathQuery != "" {
+			return utils.JSONXPathQuery(reader, w, xPathQuery, singleNode, options)
+		}
+		return utils.JSONCSSQuery(reader, w, cssQuery, cssAttr, options)
+	}
+
+	if xPathQuery != "" {
+		return utils.XPathQuery(reader, w, xPathQuery, singleNode, options)
+	}
+	return utils.CSSQuery(reader, w, cssQuery, cssAttr, options)
+}
+
+// isLargeInput checks that the input is a file which is too large to be loaded into memory at once.
+func isLargeInput(reader io.Reader) bool {
+	var fileInfo os.FileInfo
+	var err error
+	switch file := reader.(type) {
+	case *os.File:
+		fileInfo, err = file.Stat()
+	case *fileReader:
+		fileInfo, err = os.Stat(file.name)
+	default:
+		return false
+	}
+
+	return err == nil && fileInfo.Mode().IsRegular() && fileInfo.Size() >= streamThreshold
+}
+
 func processAsJSON(flags *pflag.FlagSet, reader io.Reader, w io.Writer, contentType utils.ContentType) error {
 	var (
 		jsonCompact bool
@@ -300,24 +561,37 @@ func processAsJSON(flags *pflag.FlagSet, reader io.Reader, w io.Writer, contentT
 		result      interface{}
 	)
 	jsonCompact, _ = flags.GetBool("compact")
-	if flags.Changed("depth") {
-		jsonDepth, _ = flags.GetInt("depth")
-	} else {
-		jsonDepth = -1
-	}
+	jsonDepth, _ = flags.GetInt("depth")
 
 	switch contentType {
 	case utils.ContentXml, utils.ContentHtml:
+		if losslessMode, _ := flags.GetBool("json-lossless"); losslessMode {
+			nodes, err := utils.XmlToLossless(reader)
+			if err != nil {
+				return err
+			}
+			result = nodes
+			break
+		}
+		mapper, err := getJSONMapper(flags)
+		if err != nil {
+			return err
+		}
 		doc, err := xmlquery.Parse(reader)
 		if err != nil {
 			return fmt.Errorf("error while parsing XML: %w", err)
 		}
-		result = utils.NodeToJSON(doc, jsonDepth)
+		result = mapper.NodeToJSON(doc, jsonDepth)
 	case utils.ContentJson:
-		decoder := json.NewDecoder(reader)
-		if err := decoder.Decode(&result); err != nil {
+		if err := utils.DecodeJSON(reader, &result); err != nil {
 			return fmt.Errorf("error while parsing JSON: %w", err)
 		}
+	case utils.ContentYaml:
+		data, err := utils.YamlToJSON(reader)
+		if err != nil {
+			return fmt.Errorf("error while parsing YAML: %w", err)
+		}
+		result = json.RawMessage(data)
 	default:
 		// Treat as plain text
 		content, err := io.ReadAll(reader)
@@ -339,3 +613,59 @@ func processAsJSON(flags *pflag.FlagSet, reader io.Reader, w io.Writer, contentT
 	colors := getColorMode(flags)
 	return utils.FormatJson(bytes.NewReader(jsonData), w, indent, colors)
 }
+
+func processAsYaml(flags *pflag.FlagSet, reader io.Reader, w io.Writer, contentType utils.ContentType, indent string, colors int) error {
+	var data []byte
+	var err error
+
+	switch contentType {
+	case utils.ContentXml, utils.ContentHtml:
+		mapper, err := getJSONMapper(flags)
+		if err != nil {
+			return err
+		}
+		doc, err := xmlquery.Parse(reader)
+		if err != nil {
+			return fmt.Errorf("error while parsing XML: %w", err)
+		}
+		jsonDepth, _ := flags.GetInt("depth")
+		data, err = yaml.Marshal(mapper.NodeToJSON(doc, jsonDepth))
+		if err != nil {
+			return fmt.Errorf("error while marshaling YAML: %w", err)
+		}
+	case utils.ContentJson:
+		if data, err = utils.JSONToYaml(reader); err != nil {
+			return fmt.Errorf("error while parsing JSON: %w", err)
+		}
+	case utils.ContentYaml:
+		return utils.FormatYaml(reader, w, indent, colors)
+	default:
+		return fmt.Errorf("unable to convert content type to YAML: %v", contentType)
+	}
+
+	return utils.FormatYaml(bytes.NewReader(data), w, indent, colors)
+}
+
+func checkOutputModes(flags *pflag.FlagSet) error {
+	var modes []string
+	for _, name := range []string{"json", "to-xml", "to-yaml", "to-markdown", "text"} {
+		if enabled, _ := flags.GetBool(name); enabled {
+			modes = append(modes, "--"+name)
+		}
+	}
+
+	if len(modes) > 1 {
+		return fmt.Errorf("options %s are incompatible", strings.Join(modes, " and "))
+	}
+
+	return nil
+}
+
+func processAsXml(reader io.Reader, w io.Writer, indent string, colors int) error {
+	doc, err := utils.JSONToXmlDocument(reader)
+	if err != nil {
+		return fmt.Errorf("error while parsing JSON: %w", err)
+	}
+
+	return utils.FormatXml(strings.NewReader(doc.OutputXML(true)), w, indent, colors)
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 	"testing"
@@ -26,10 +27,23 @@ func execute(cmd *cobra.Command, args ...string) (string, error) {
 	}
 
 	err := cmd.Execute()
+	if err != nil {
+		buf.WriteString(utils.FormatError(err))
+	}
 
-	cmd.Flags().VisitAll(func(f *pflag.Flag) {
-		_ = f.Value.Set(f.DefValue)
-	})
+	resetFlag := func(f *pflag.Flag) {
+		// edit operations are accumulated by the flags and reset by the command itself
+		if sliceValue, ok := f.Value.(pflag.SliceValue); ok {
+			_ = sliceValue.Replace(nil)
+		} else if _, ok := f.Value.(*editOperationsValue); !ok {
+			_ = f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	cmd.Flags().VisitAll(resetFlag)
+	for _, subCmd := range cmd.Commands() {
+		subCmd.Flags().VisitAll(resetFlag)
+	}
 
 	return strings.TrimSpace(buf.String()), err
 }
@@ -44,6 +58,7 @@ func TestRootCmd(t *testing.T) {
 	formattedXmlFilePath := filepath.Join("..", "test", "data", "xml", "formatted.xml")
 	htmlFilePath := filepath.Join("..", "test", "data", "html", "unformatted.html")
 	jsonFilePath := filepath.Join("..", "test", "data", "json", "unformatted.json")
+	yamlFilePath := filepath.Join("..", "test", "data", "yaml", "unformatted.yaml")
 
 	output, err = execute(command)
 	assert.Nil(t, err)
@@ -69,6 +84,29 @@ func TestRootCmd(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Contains(t, output, "{")
 
+	output, err = execute(command, "--to-xml", jsonFilePath)
+	assert.Nil(t, err)
+	assert.Contains(t, output, "<menuitem>")
+
+	_, err = execute(command, "--to-xml", "-j", jsonFilePath)
+	assert.ErrorContains(t, err, "incompatible")
+
+	output, err = execute(command, yamlFilePath)
+	assert.Nil(t, err)
+	assert.Contains(t, output, "apiVersion: v1")
+
+	output, err = execute(command, "--to-yaml", xmlFilePath)
+	assert.Nil(t, err)
+	assert.Contains(t, output, "first_name: John")
+
+	output, err = execute(command, "--to-yaml", jsonFilePath)
+	assert.Nil(t, err)
+	assert.Contains(t, output, "price: 100.32")
+
+	output, err = execute(command, "-j", yamlFilePath)
+	assert.Nil(t, err)
+	assert.Contains(t, output, `"apiVersion": "v1"`)
+
 	output, err = execute(command, "--no-pager", xmlFilePath)
 	assert.Nil(t, err)
 	assert.Contains(t, output, "first_name")
@@ -85,10 +123,95 @@ func TestRootCmd(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Contains(t, output, "text")
 
+	output, err = execute(command, "--to-markdown", htmlFilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, "text\n\n[link](https://example.com)", output)
+
+	output, err = execute(command, "--to-markdown", "-q", "body > a", htmlFilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, "[link](https://example.com)", output)
+
+	_, err = execute(command, "--to-markdown", "-x", "//a", htmlFilePath)
+	assert.ErrorContains(t, err, "supports the CSS query (-q) of elements only")
+
+	_, err = execute(command, "--to-markdown", "-j", htmlFilePath)
+	assert.ErrorContains(t, err, "options --json and --to-markdown are incompatible")
+
+	output, err = execute(command, "--text", "--width", "40", htmlFilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, "text\n\n[1]link\n\nReferences\n\n   1. https://example.com", output)
+
+	output, err = execute(command, "--text", "-q", "body > p", htmlFilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, "text", output)
+
+	_, err = execute(command, "--text", "-a", "href", "-q", "a", htmlFilePath)
+	assert.ErrorContains(t, err, "supports the CSS query (-q) of elements only")
+
 	output, err = execute(command, "-x", "/user/@status", xmlFilePath)
 	assert.Nil(t, err)
 	assert.Contains(t, output, "active")
 
+	storeFilePath := filepath.Join("..", "test", "data", "json", "store.json")
+	output, err = execute(command, "-x", "//items[price>10]/name", storeFilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, "book\nlamp", output)
+
+	output, err = execute(command, "--no-color", "-j", "-x", "//price", storeFilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, "[\n  5,\n  12.5,\n  30\n]", output)
+
+	output, err = execute(command, "--no-color", "-j", "-x", "/user/address", xmlFilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, "[\n  {\n    \"city\": \"Bellville\",\n    \"street\": \"1234 Main Road\"\n  }\n]", output)
+
+	output, err = execute(command, "-x", "//items", "--template", `{name}\t{price}`, "--separator", ";", storeFilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, "pen\t5;book\t12.5;lamp\t30", output)
+
+	_, err = execute(command, "--template", "{name}", storeFilePath)
+	assert.ErrorContains(t, err, "template option (--template) requires a query")
+
+	output, err = execute(command, "--no-color", "-j", "--indent", "0", "--json-style", "jsonml", "-x", "/user/address",
+		xmlFilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, `[["address",["street","1234 Main Road"],["city","Bellville"]]]`, output)
+
+	_, err = execute(command, "-j", "--json-style", "xml", xmlFilePath)
+	assert.ErrorContains(t, err, `unknown JSON style "xml"`)
+
+	output, err = execute(command, "--no-color", "-j", "--indent", "0", "--array-path", "/user/address",
+		"--type-path", "//city=number", "-x", "/user", xmlFilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, `[{"@status": "active","address": [{"city": "Bellville","street": "1234 Main Road"}],`+
+		`"first_name": "John","last_name": "Smith"}]`, output)
+
+	_, err = execute(command, "-j", "--type-path", "//street", xmlFilePath)
+	assert.ErrorContains(t, err, `invalid type path "//street"`)
+
+	lossless, err := execute(command, "--no-color", "-j", "--json-lossless", xmlFilePath)
+	assert.Nil(t, err)
+	assert.Contains(t, lossless, `"type": "comment"`)
+	losslessFilePath := filepath.Join(t.TempDir(), "lossless.json")
+	assert.Nil(t, os.WriteFile(losslessFilePath, []byte(lossless), 0644))
+	output, err = execute(command, "--to-xml", "--json-lossless", losslessFilePath)
+	assert.Nil(t, err)
+	content, err := os.ReadFile(xmlFilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, strings.TrimSpace(string(content)), output)
+
+	output, err = execute(command, "-q", "items > name", storeFilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, "pen\nbook\nlamp", output)
+
+	output, err = execute(command, "--stream", "-x", "/user/address/city", xmlFilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, "Bellville", output)
+
+	output, err = execute(command, "-x", "//metadata/name", yamlFilePath)
+	assert.Nil(t, err)
+	assert.NotEmpty(t, output)
+
 	output, err = execute(command, "--no-color", "-x", "/user/@status", xmlFilePath)
 	assert.Nil(t, err)
 	assert.Contains(t, output, "active")
@@ -107,6 +230,31 @@ func TestRootCmd(t *testing.T) {
 	assert.ErrorContains(t, err, "invalid argument")
 }
 
+func TestNamespaces(t *testing.T) {
+	command := NewRootCmd()
+	InitFlags(command)
+	pomFilePath := filepath.Join("..", "test", "data", "ns", "pom.xml")
+
+	output, err := execute(command, "-x", "//m:dependency/m:artifactId", "--ns", "m=http://maven.apache.org/POM/4.0.0",
+		pomFilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, "junit\nslf4j-api", output)
+
+	output, err = execute(command, "--ns", "m=http://maven.apache.org/POM/4.0.0", "--row", "//m:dependency",
+		"--col", "m:groupId", "--col", "scope=m:scope", pomFilePath)
+	assert.Nil(t, err)
+	assert.Equal(t, "m:groupId,scope\njunit,test\norg.slf4j,", output)
+
+	_, err = execute(command, "-x", "//m:dependency", pomFilePath)
+	assert.ErrorContains(t, err, "prefix m not defined")
+
+	_, err = execute(command, "-x", "//m:dependency", "--ns", "m", pomFilePath)
+	assert.ErrorContains(t, err, `invalid namespace binding "m", use prefix=uri`)
+
+	_, err = execute(command, "-x", "//m:dependency", "--stream", "--ns", "m=urn:m", pomFilePath)
+	assert.ErrorContains(t, err, "streaming mode is incompatible with namespace bindings")
+}
+
 func TestCDATASupport(t *testing.T) {
 	input := "<root><![CDATA[1 & 2]]></root>"
 	doc, err := xmlquery.Parse(strings.NewReader(input))
@@ -158,6 +306,16 @@ func TestProcessAsJSON(t *testing.T) {
 				},
 			},
 		},
+		{
+			name:        "Simple YAML",
+			input:       "root:\n  child: value\n",
+			contentType: utils.ContentYaml,
+			expected: map[string]interface{}{
+				"root": map[string]interface{}{
+					"child": "value",
+				},
+			},
+		},
 		{
 			name:        "Plain text",
 			input:       "text",
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+
+	"github.com/sibprogrammer/xq/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// extractTable prints the rows matched by the XPath query as CSV or TSV records.
+func extractTable(cmd *cobra.Command, rowQuery string, readers []io.Reader) error {
+	flags := cmd.Flags()
+	xPathQuery, _ := getXpathQuery(flags)
+	cssQuery, _ := flags.GetString("query")
+	inPlace, _ := flags.GetBool("in-place")
+	if xPathQuery != "" || cssQuery != "" || inPlace {
+		return errors.New("tabular extraction is incompatible with nodes selection and in-place formatting")
+	}
+
+	var columns []utils.TableColumn
+	definitions, _ := flags.GetStringArray("col")
+	if len(definitions) == 0 {
+		return errors.New("at least one column (--col) is required for tabular extraction")
+	}
+	for _, definition := range definitions {
+		column, err := utils.ParseTableColumn(definition)
+		if err != nil {
+			return err
+		}
+		columns = append(columns, column)
+	}
+
+	namespaces, err := getNamespaces(flags)
+	if err != nil {
+		return err
+	}
+	options := utils.TableOptions{Namespaces: namespaces}
+	options.NoHeader, _ = flags.GetBool("no-header")
+	delimiter, _ := flags.GetString("delimiter")
+	switch delimiter {
+	case `\t`, "tab":
+		options.Delimiter = '\t'
+	default:
+		if utf8.RuneCountInString(delimiter) != 1 {
+			return fmt.Errorf("invalid delimiter %q, expected a single character", delimiter)
+		}
+		options.Delimiter, _ = utf8.DecodeRuneInString(delimiter)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		var err error
+		for _, reader := range readers {
+			if err = writeTable(cmd, reader, pw, rowQuery, columns, options); err != nil {
+				break
+			}
+			options.NoHeader = true
+		}
+		_ = pw.CloseWithError(err)
+	}()
+
+	return utils.PagerPrint(pr, cmd.OutOrStdout(), getPager(flags))
+}
+
+func writeTable(cmd *cobra.Command, reader io.Reader, w io.Writer, rowQuery string, columns []utils.TableColumn,
+	options utils.TableOptions) error {
+	var contentType utils.ContentType
+	contentType, reader = detectFormat(cmd.Flags(), reader)
+
+	switch contentType {
+	case utils.ContentYaml:
+		data, err := utils.YamlToJSON(reader)
+		if err != nil {
+			return fmt.Errorf("error while parsing YAML: %w", err)
+		}
+		return utils.JSONXPathTable(bytes.NewReader(data), w, rowQuery, columns, options)
+	case utils.ContentJson:
+		return utils.JSONXPathTable(reader, w, rowQuery, columns, options)
+	default:
+		return utils.XPathTable(reader, w, rowQuery, columns, options)
+	}
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTable(t *testing.T) {
+	command := NewRootCmd()
+	InitFlags(command)
+
+	inputFile := filepath.Join("..", "test", "data", "table", "feed.xml")
+
+	output, err := execute(command, "--row", "//entry", "--col", "id=@id", "--col", "author", "--col",
+		"date=published", inputFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "id,author,date\n1,Alice,2024-01-15\n2,,2024-02-01\n3,Bob,", output)
+
+	output, err = execute(command, "--row", "//entry[published]", "--col", "id=@id", "--col", "date=published",
+		"--delimiter", "tab", "--no-header", inputFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "1\t2024-01-15\n2\t2024-02-01", output)
+
+	output, err = execute(command, "--row", "//items", "--col", "name", "--col", "price",
+		filepath.Join("..", "test", "data", "json", "store.json"))
+	assert.Nil(t, err)
+	assert.Equal(t, "name,price\npen,5\nbook,12.5\nlamp,30", output)
+
+	_, err = execute(command, "--row", "//entry", inputFile)
+	assert.ErrorContains(t, err, "at least one column")
+
+	_, err = execute(command, "--col", "id=@id", inputFile)
+	assert.ErrorContains(t, err, "row option (--row) is missed")
+
+	_, err = execute(command, "--row", "//entry", "--col", "id=@id", "--delimiter", ";;", inputFile)
+	assert.ErrorContains(t, err, "invalid delimiter")
+
+	_, err = execute(command, "--row", "//entry", "--col", "id=@id", "-x", "//entry", inputFile)
+	assert.ErrorContains(t, err, "incompatible")
+}
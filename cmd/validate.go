@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/sibprogrammer/xq/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// validateWithSchema validates the documents against the XML Schema and prints the violations.
+// The error is returned if at least one of the documents is not valid.
+func validateWithSchema(cmd *cobra.Command, schemaFile string, readers []io.Reader, fileNames []string) error {
+	flags := cmd.Flags()
+	xPathQuery, _ := getXpathQuery(flags)
+	cssQuery, _ := flags.GetString("query")
+	inPlace, _ := flags.GetBool("in-place")
+	if xPathQuery != "" || cssQuery != "" || inPlace {
+		return errors.New("schema validation is incompatible with nodes selection and in-place formatting")
+	}
+
+	schema, err := utils.LoadXmlSchema(schemaFile)
+	if err != nil {
+		return fmt.Errorf("unable to load the schema: %w", err)
+	}
+
+	total := 0
+	for index, reader := range readers {
+		fileName := "<stdin>"
+		if index < len(fileNames) {
+			fileName = fileNames[index]
+		}
+
+		violations, err := schema.Validate(reader)
+		if err != nil {
+			return fmt.Errorf("%s: %w", fileName, err)
+		}
+
+		for _, violation := range violations {
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "%s:%d:%d: %s: %s\n", fileName,
+				violation.Line, violation.Column, violation.Path, violation.Message)
+		}
+		total += len(violations)
+	}
+
+	if total > 0 {
+		return fmt.Errorf("%d schema validation error(s) found", total)
+	}
+
+	return nil
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateXsd(t *testing.T) {
+	command := NewRootCmd()
+	InitFlags(command)
+
+	dataDir := filepath.Join("..", "test", "data", "xsd")
+	schemaFile := filepath.Join(dataDir, "library.xsd")
+
+	output, err := execute(command, "--validate-xsd", schemaFile, filepath.Join(dataDir, "valid.xml"))
+	assert.Nil(t, err)
+	assert.Equal(t, "", output)
+
+	invalidFile := filepath.Join(dataDir, "invalid.xml")
+	output, err = execute(command, "--validate-xsd", schemaFile, invalidFile)
+	assert.ErrorContains(t, err, "8 schema validation error(s) found")
+	assert.Contains(t, output, invalidFile+":11:3: /library/book[2]: missing required attribute \"id\" in element <book>\n")
+
+	_, err = execute(command, "--validate-xsd", schemaFile, "-x", "//book", invalidFile)
+	assert.ErrorContains(t, err, "incompatible")
+
+	_, err = execute(command, "--validate-xsd", "nonexistent.xsd", invalidFile)
+	assert.ErrorContains(t, err, "unable to load the schema")
+}
@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/sibprogrammer/xq/internal/utils"
+	"github.com/spf13/cobra"
+)
+
+// transformWithXslt applies the XSLT stylesheet to the documents and prints the formatted results.
+func transformWithXslt(cmd *cobra.Command, stylesheetFile string, readers []io.Reader, options utils.QueryOptions) error {
+	flags := cmd.Flags()
+	xPathQuery, _ := getXpathQuery(flags)
+	cssQuery, _ := flags.GetString("query")
+	inPlace, _ := flags.GetBool("in-place")
+	if xPathQuery != "" || cssQuery != "" || inPlace {
+		return errors.New("XSLT transformation is incompatible with nodes selection and in-place formatting")
+	}
+
+	params := map[string]string{}
+	rawParams, _ := flags.GetStringArray("xslt-param")
+	for _, rawParam := range rawParams {
+		name, value, found := strings.Cut(rawParam, "=")
+		if !found || name == "" {
+			return fmt.Errorf("invalid stylesheet parameter %q, expected name=value", rawParam)
+		}
+		params[name] = value
+	}
+
+	stylesheet, err := utils.LoadXslt(stylesheetFile)
+	if err != nil {
+		return fmt.Errorf("unable to load the stylesheet: %w", err)
+	}
+
+	pr, pw := io.Pipe()
+	go func() {
+		var err error
+		for _, reader := range readers {
+			if err = stylesheet.Transform(reader, pw, params, options); err != nil {
+				break
+			}
+		}
+		_ = pw.CloseWithError(err)
+	}()
+
+	return utils.PagerPrint(pr, cmd.OutOrStdout(), getPager(flags))
+}
@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXslt(t *testing.T) {
+	command := NewRootCmd()
+	InitFlags(command)
+
+	dataDir := filepath.Join("..", "test", "data", "xslt")
+	inputFile := filepath.Join(dataDir, "catalog.xml")
+
+	output, err := execute(command, "--xslt", filepath.Join(dataDir, "text.xsl"), inputFile)
+	assert.Nil(t, err)
+	assert.Equal(t, "fiction: The Hobbit, Dune\nhistory: SPQR\nscience: A Brief History of Time\nTotal: 65.48", output)
+
+	output, err = execute(command, "--xslt", filepath.Join(dataDir, "text.xsl"), "--xslt-param", "separator= - ",
+		inputFile)
+	assert.Nil(t, err)
+	assert.Contains(t, output, "history - SPQR\n")
+
+	output, err = execute(command, "--xslt", filepath.Join(dataDir, "catalog.xsl"), "--indent", "4", inputFile)
+	assert.Nil(t, err)
+	assert.Contains(t, output, "<books count=\"3\">\n    <book position=\"1\" ref=\"b2\">")
+
+	_, err = execute(command, "--xslt", filepath.Join(dataDir, "text.xsl"), "--xslt-param", "separator", inputFile)
+	assert.ErrorContains(t, err, "invalid stylesheet parameter")
+
+	_, err = execute(command, "--xslt", filepath.Join(dataDir, "text.xsl"), "-x", "//book", inputFile)
+	assert.ErrorContains(t, err, "incompatible")
+
+	_, err = execute(command, "--xslt", "nonexistent.xsl", inputFile)
+	assert.ErrorContains(t, err, "unable to load the stylesheet")
+}
@@ -2,7 +2,9 @@ package utils
 
 import (
 	"bufio"
+	"fmt"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
@@ -15,19 +17,109 @@ type ConfigOptions struct {
 	Html    bool
 	Node    bool
 	NoPager bool
+	// Values contains all the options by the names of the command-line flags. The values are
+	// kept in the order of precedence, the repeatable options can have several of them.
+	Values map[string][]ConfigValue
 }
 
+// ConfigValue is the value of the option together with the file or the environment variable
+// it is defined in.
+type ConfigValue struct {
+	Value  string
+	Source string
+	IsEnv  bool
+}
+
+const configEnvPrefix = "XQ_"
+
 var config ConfigOptions
 
-func LoadConfig(fileName string) error {
-	config.Indent = 2
-	config.Tab = false
-	config.NoColor = false
-	config.Color = false
-	config.Html = false
-	config.Node = false
-	config.NoPager = false
+// GetConfigFiles returns the config files in the order of increasing priority: ~/.xq,
+// $XDG_CONFIG_HOME/xq/config and the project .xq file found in the current directory or
+// in one of its parents.
+func GetConfigFiles() []string {
+	var fileNames []string
+
+	homeDir, _ := os.UserHomeDir()
+	if homeDir != "" {
+		fileNames = append(fileNames, filepath.Join(homeDir, ".xq"))
+	}
+
+	if configDir := os.Getenv("XDG_CONFIG_HOME"); configDir != "" {
+		fileNames = append(fileNames, filepath.Join(configDir, "xq", "config"))
+	} else if homeDir != "" {
+		fileNames = append(fileNames, filepath.Join(homeDir, ".config", "xq", "config"))
+	}
+
+	dir, err := os.Getwd()
+	for err == nil {
+		fileName := filepath.Join(dir, ".xq")
+		if fileInfo, err := os.Stat(fileName); err == nil && fileInfo.Mode().IsRegular() {
+			if homeDir == "" || fileName != filepath.Join(homeDir, ".xq") {
+				fileNames = append(fileNames, fileName)
+			}
+			break
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+
+	return fileNames
+}
+
+// LoadConfig reads the options from the config files, the options from the following files
+// override the previous ones. The XQ_* environment variables, e.g. XQ_NO_COLOR for no-color,
+// take precedence over the files. All the values of an option are kept, the last one is used
+// unless the option is repeatable.
+func LoadConfig(fileNames ...string) error {
+	config = ConfigOptions{Indent: 2, Values: map[string][]ConfigValue{}}
+
+	for _, fileName := range fileNames {
+		if err := readConfigFile(fileName); err != nil {
+			return err
+		}
+	}
+
+	for _, variable := range os.Environ() {
+		name, value, _ := strings.Cut(variable, "=")
+		if option, found := strings.CutPrefix(name, configEnvPrefix); found && option != "" {
+			option = strings.ReplaceAll(strings.ToLower(option), "_", "-")
+			config.Values[option] = append(config.Values[option], ConfigValue{Value: value, Source: name, IsEnv: true})
+		}
+	}
+
+	for option, values := range config.Values {
+		value := values[len(values)-1]
+		var err error
+		switch option {
+		case "indent":
+			config.Indent, err = strconv.Atoi(value.Value)
+		case "tab":
+			config.Tab, err = strconv.ParseBool(value.Value)
+		case "no-color":
+			config.NoColor, err = strconv.ParseBool(value.Value)
+		case "color":
+			config.Color, err = strconv.ParseBool(value.Value)
+		case "html":
+			config.Html, err = strconv.ParseBool(value.Value)
+		case "node":
+			config.Node, err = strconv.ParseBool(value.Value)
+		case "no-pager":
+			config.NoPager, err = strconv.ParseBool(value.Value)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid value %q of option %s in %s", value.Value, option, value.Source)
+		}
+	}
 
+	return nil
+}
+
+func readConfigFile(fileName string) error {
 	file, err := os.Open(fileName)
 	if os.IsNotExist(err) {
 		return nil
@@ -46,29 +138,22 @@ func LoadConfig(fileName string) error {
 		if strings.HasPrefix(text, "#") || len(text) == 0 {
 			continue
 		}
-		var parts = strings.Split(text, "=")
-		if len(parts) != 2 {
+		option, value, found := strings.Cut(text, "=")
+		if !found {
 			continue
 		}
-		option, value := parts[0], parts[1]
 		option = strings.TrimSpace(option)
 		value = strings.TrimSpace(value)
 
-		switch option {
-		case "indent":
-			config.Indent, _ = strconv.Atoi(value)
-		case "tab":
-			config.Tab, _ = strconv.ParseBool(value)
-		case "no-color":
-			config.NoColor, _ = strconv.ParseBool(value)
-		case "color":
-			config.Color, _ = strconv.ParseBool(value)
-		case "no-pager":
-			config.NoPager, _ = strconv.ParseBool(value)
+		// the quotes allow to keep the leading and trailing spaces
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
 		}
+
+		config.Values[option] = append(config.Values[option], ConfigValue{Value: value, Source: fileName})
 	}
 
-	return nil
+	return scanner.Err()
 }
 
 func GetConfig() ConfigOptions {
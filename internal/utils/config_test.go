@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"os"
 	"path/filepath"
 	"testing"
 
@@ -23,3 +24,47 @@ func TestLoadConfig(t *testing.T) {
 	config = GetConfig()
 	assert.Equal(t, config.Indent, 2)
 }
+
+func TestLoadConfigLayers(t *testing.T) {
+	dir := t.TempDir()
+	globalFile := filepath.Join(dir, "config")
+	projectFile := filepath.Join(dir, ".xq")
+	assert.Nil(t, os.WriteFile(globalFile, []byte("indent = 4\nhtml = true\nnode = 1\ndelimiter = ';'\n"), 0600))
+	assert.Nil(t, os.WriteFile(projectFile, []byte("# project style\nindent=3\nxslt-param = a=b\nxslt-param = c=d\n"), 0600))
+	t.Setenv("XQ_NO_PAGER", "true")
+	t.Setenv("XQ_COMPACT", "1")
+
+	assert.Nil(t, LoadConfig(globalFile, projectFile))
+	config := GetConfig()
+	assert.Equal(t, 3, config.Indent)
+	assert.True(t, config.Html)
+	assert.True(t, config.Node)
+	assert.True(t, config.NoPager)
+	assert.Equal(t, []ConfigValue{{Value: ";", Source: globalFile}}, config.Values["delimiter"])
+	assert.Equal(t, []ConfigValue{{Value: "a=b", Source: projectFile}, {Value: "c=d", Source: projectFile}},
+		config.Values["xslt-param"])
+	assert.Equal(t, []ConfigValue{{Value: "1", Source: "XQ_COMPACT", IsEnv: true}}, config.Values["compact"])
+
+	t.Setenv("XQ_INDENT", "wide")
+	assert.ErrorContains(t, LoadConfig(globalFile), `invalid value "wide" of option indent in XQ_INDENT`)
+}
+
+func TestGetConfigFiles(t *testing.T) {
+	homeDir := t.TempDir()
+	projectDir := filepath.Join(homeDir, "project")
+	workDir := filepath.Join(projectDir, "src", "main")
+	assert.Nil(t, os.MkdirAll(workDir, 0700))
+	assert.Nil(t, os.WriteFile(filepath.Join(homeDir, ".xq"), nil, 0600))
+	assert.Nil(t, os.WriteFile(filepath.Join(projectDir, ".xq"), nil, 0600))
+
+	t.Setenv("HOME", homeDir)
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(homeDir, "xdg"))
+	t.Chdir(workDir)
+	assert.Equal(t, []string{filepath.Join(homeDir, ".xq"), filepath.Join(homeDir, "xdg", "xq", "config"),
+		filepath.Join(projectDir, ".xq")}, GetConfigFiles())
+
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Chdir(homeDir)
+	assert.Equal(t, []string{filepath.Join(homeDir, ".xq"), filepath.Join(homeDir, ".config", "xq", "config")},
+		GetConfigFiles())
+}
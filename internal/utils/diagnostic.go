@@ -0,0 +1,417 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// sourceRecorderSize is the amount of the recently read content kept to show the excerpts.
+const sourceRecorderSize = 64 << 10
+
+type ErrorKind int
+
+const (
+	ErrorParse ErrorKind = iota + 1
+	ErrorQuery
+)
+
+// Diagnostic describes the error in the document or in the query together with its location,
+// the excerpt of the offending line and the hint how to fix it.
+type Diagnostic struct {
+	Kind    ErrorKind
+	File    string
+	Line    int
+	Column  int
+	Message string
+	Source  string
+	Hint    string
+	Err     error
+}
+
+func (diagnostic *Diagnostic) Error() string {
+	var location []string
+	if diagnostic.File != "" {
+		location = append(location, diagnostic.File)
+	}
+	if diagnostic.Line > 0 {
+		location = append(location, strconv.Itoa(diagnostic.Line))
+		if diagnostic.Column > 0 {
+			location = append(location, strconv.Itoa(diagnostic.Column))
+		}
+	}
+	if len(location) == 0 {
+		return diagnostic.Message
+	}
+
+	return strings.Join(location, ":") + ": " + diagnostic.Message
+}
+
+func (diagnostic *Diagnostic) Unwrap() error {
+	return diagnostic.Err
+}
+
+// Excerpt returns the offending line with the caret pointing to the column, followed by the hint.
+func (diagnostic *Diagnostic) Excerpt() string {
+	var result strings.Builder
+	if diagnostic.Source != "" {
+		prefix := ""
+		if diagnostic.Line > 0 {
+			prefix = strconv.Itoa(diagnostic.Line)
+		}
+		fmt.Fprintf(&result, " %s | %s\n", prefix, diagnostic.Source)
+
+		if diagnostic.Column > 0 {
+			// the tabs are kept to align the caret in the same way as the source line
+			var padding strings.Builder
+			for index, char := range []rune(diagnostic.Source) {
+				if index >= diagnostic.Column-1 {
+					break
+				}
+				if char == '\t' {
+					padding.WriteRune('\t')
+				} else {
+					padding.WriteRune(' ')
+				}
+			}
+			fmt.Fprintf(&result, " %s | %s^\n", strings.Repeat(" ", len(prefix)), padding.String())
+		}
+	}
+	if diagnostic.Hint != "" {
+		fmt.Fprintf(&result, "Hint: %s\n", diagnostic.Hint)
+	}
+
+	return result.String()
+}
+
+// FormatError returns the error message prefixed with "Error:", followed by the excerpt of the
+// source and the hint if the error is a diagnostic.
+func FormatError(err error) string {
+	result := fmt.Sprintf("Error: %v\n", err)
+	var diagnostic *Diagnostic
+	if errors.As(err, &diagnostic) {
+		result += diagnostic.Excerpt()
+	}
+
+	return result
+}
+
+// WithFileName adds the file name to the location of the diagnostic or prefixes the error with it.
+// The query errors are not related to the file and returned as is.
+func WithFileName(err error, fileName string) error {
+	var diagnostic *Diagnostic
+	if errors.As(err, &diagnostic) {
+		if diagnostic.File == "" && diagnostic.Kind != ErrorQuery {
+			diagnostic.File = fileName
+		}
+		return err
+	}
+
+	return fmt.Errorf("%s: %w", fileName, err)
+}
+
+// sourceRecorder keeps the recently read lines of the document to show the excerpt of the line
+// where the error is found.
+type sourceRecorder struct {
+	reader io.Reader
+	buf    []byte
+	// line is the number of the first line in the buffer
+	line int
+	// offset is the position of the buffer in the input
+	offset int64
+}
+
+func newSourceRecorder(reader io.Reader) *sourceRecorder {
+	return &sourceRecorder{reader: reader, line: 1}
+}
+
+func (recorder *sourceRecorder) Read(buf []byte) (int, error) {
+	length, err := recorder.reader.Read(buf)
+	recorder.buf = append(recorder.buf, buf[:length]...)
+
+	if len(recorder.buf) > sourceRecorderSize {
+		cut := bytes.IndexByte(recorder.buf[len(recorder.buf)-sourceRecorderSize/2:], '\n')
+		if cut >= 0 {
+			cut += len(recorder.buf) - sourceRecorderSize/2 + 1
+			recorder.line += bytes.Count(recorder.buf[:cut], []byte{'\n'})
+			recorder.offset += int64(cut)
+			recorder.buf = append(recorder.buf[:0], recorder.buf[cut:]...)
+		}
+	}
+
+	return length, err
+}
+
+// getLine returns the line by its number if it is still kept.
+func (recorder *sourceRecorder) getLine(line int) string {
+	if recorder == nil || line < recorder.line {
+		return ""
+	}
+
+	content := recorder.buf
+	for index := recorder.line; index < line; index++ {
+		pos := bytes.IndexByte(content, '\n')
+		if pos < 0 {
+			return ""
+		}
+		content = content[pos+1:]
+	}
+	if pos := bytes.IndexByte(content, '\n'); pos >= 0 {
+		content = content[:pos]
+	}
+
+	return strings.TrimRight(string(content), "\r")
+}
+
+// getPosition converts the offset in the input into the line and the byte position in it.
+func (recorder *sourceRecorder) getPosition(offset int64) (int, int) {
+	if offset < recorder.offset || offset > recorder.offset+int64(len(recorder.buf)) {
+		return 0, 0
+	}
+
+	content := recorder.buf[:offset-recorder.offset]
+	line := recorder.line + bytes.Count(content, []byte{'\n'})
+	pos := len(content) - bytes.LastIndexByte(content, '\n') - 1
+
+	return line, pos
+}
+
+// getColumn returns the column (in characters) of the 1-based byte column in the line.
+func getColumn(source string, column int) int {
+	if column > len(source)+1 {
+		return column
+	}
+
+	return utf8.RuneCountInString(source[:column-1]) + 1
+}
+
+var xmlErrorHints = []struct {
+	pattern string
+	hint    string
+}{
+	{"unexpected EOF", "the document ends before all the elements are closed"},
+	{"closed by", "the closing tag does not match the last opened element"},
+	{"unexpected end element", "the closing tag has no matching opening tag"},
+	{"invalid character entity", "escape the ampersand as &amp; if it is not a part of an entity"},
+	{"expected element name after <", "escape the less-than sign as &lt; in the text"},
+	{"attribute", "the attribute values should be quoted, e.g. name=\"value\""},
+	{"invalid XML name", "the names should start with a letter or an underscore"},
+}
+
+var xmlClosingTagRegexp = regexp.MustCompile(`</[^>]+>`)
+
+// newXmlDiagnostic converts the syntax error of the XML decoder into the diagnostic. The decoder
+// position is used as the column if it is on the line of the error.
+func newXmlDiagnostic(err error, source *sourceRecorder, decoder *xml.Decoder) error {
+	var syntaxError *xml.SyntaxError
+	if !errors.As(err, &syntaxError) {
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			return err
+		}
+		syntaxError = &xml.SyntaxError{Msg: "unexpected EOF"}
+	}
+
+	diagnostic := &Diagnostic{
+		Kind:    ErrorParse,
+		Line:    syntaxError.Line,
+		Message: "XML syntax error: " + syntaxError.Msg,
+		Err:     err,
+	}
+	if diagnostic.Line > 0 {
+		diagnostic.Source = source.getLine(diagnostic.Line)
+		if decoder != nil {
+			if line, column := decoder.InputPos(); line == diagnostic.Line {
+				// the decoder position is after the closing tag
+				if tag := xmlClosingTagRegexp.FindString(syntaxError.Msg); tag != "" {
+					prefix := diagnostic.Source[:min(column-1, len(diagnostic.Source))]
+					if pos := strings.LastIndex(prefix, tag[:len(tag)-1]); pos >= 0 {
+						column = pos + 1
+					}
+				}
+				diagnostic.Column = getColumn(diagnostic.Source, column)
+			}
+		}
+	}
+	for _, errorHint := range xmlErrorHints {
+		if strings.Contains(syntaxError.Msg, errorHint.pattern) {
+			diagnostic.Hint = errorHint.hint
+			break
+		}
+	}
+
+	return diagnostic
+}
+
+// newJsonDiagnostic converts the syntax error of the JSON decoder into the diagnostic.
+func newJsonDiagnostic(err error, decoder *json.Decoder, source *sourceRecorder) error {
+	const unexpectedEnd = "unexpected end of JSON input"
+	var syntaxError *json.SyntaxError
+	offset := decoder.InputOffset()
+	message := err.Error()
+	switch {
+	case errors.As(err, &syntaxError) && message != unexpectedEnd:
+		// the offset of the syntax error points after the invalid character
+		offset = max(syntaxError.Offset-1, 0)
+	case syntaxError != nil || errors.Is(err, io.ErrUnexpectedEOF):
+		// the error is pointed after the last character of the document
+		message = unexpectedEnd
+		offset = source.offset + int64(len(bytes.TrimRight(source.buf, " \t\r\n")))
+	default:
+		return err
+	}
+
+	diagnostic := &Diagnostic{Kind: ErrorParse, Message: "JSON syntax error: " + message, Err: err}
+	var pos int
+	if diagnostic.Line, pos = source.getPosition(offset); diagnostic.Line > 0 {
+		diagnostic.Source = source.getLine(diagnostic.Line)
+		diagnostic.Column = getColumn(diagnostic.Source, pos+1)
+	}
+
+	switch {
+	case message == unexpectedEnd:
+		diagnostic.Hint = "the document ends before all the objects and arrays are closed"
+	case strings.Contains(message, "'}' looking for beginning of object key"),
+		strings.Contains(message, "']' looking for beginning of value"),
+		strings.Contains(message, "',' looking for beginning of value"):
+		diagnostic.Hint = "remove the trailing or the duplicate comma"
+	case strings.Contains(message, "looking for beginning of object key"):
+		diagnostic.Hint = "the object keys should be enclosed in double quotes"
+	case strings.Contains(message, "'\\''"):
+		diagnostic.Hint = "the strings should be enclosed in double quotes"
+	case strings.Contains(message, "after object key:value pair"),
+		strings.Contains(message, "after array element"):
+		diagnostic.Hint = "the values should be separated by commas"
+	}
+
+	return diagnostic
+}
+
+// newJsonKeyDiagnostic describes the object key which can't be converted into XML. The decoder
+// position is after the key, so the opening quote of the key is pointed.
+func newJsonKeyDiagnostic(message string, decoder *json.Decoder, source *sourceRecorder) error {
+	diagnostic := &Diagnostic{
+		Kind:    ErrorParse,
+		Message: "JSON conversion error: " + message,
+		Hint:    "the keys should be valid XML names, e.g. item, ns:item or @id",
+	}
+	line, pos := source.getPosition(decoder.InputOffset())
+	if line > 0 {
+		diagnostic.Line = line
+		diagnostic.Source = source.getLine(line)
+		start := pos - 2
+		for start > 0 && (diagnostic.Source[start] != '"' || diagnostic.Source[start-1] == '\\') {
+			start--
+		}
+		diagnostic.Column = getColumn(diagnostic.Source, max(start, 0)+1)
+	}
+
+	return diagnostic
+}
+
+var yamlErrorRegexp = regexp.MustCompile(`^yaml: line (\d+): (.*)$`)
+
+// newYamlDiagnostic converts the YAML parser error with the line number into the diagnostic.
+func newYamlDiagnostic(err error, source *sourceRecorder) error {
+	matches := yamlErrorRegexp.FindStringSubmatch(err.Error())
+	if matches == nil {
+		return err
+	}
+
+	line, _ := strconv.Atoi(matches[1])
+	diagnostic := &Diagnostic{
+		Kind:    ErrorParse,
+		Line:    line,
+		Message: "YAML syntax error: " + matches[2],
+		Source:  source.getLine(line),
+		Err:     err,
+	}
+	if strings.Contains(matches[2], "tab") || strings.HasPrefix(strings.TrimLeft(diagnostic.Source, " "), "\t") {
+		diagnostic.Hint = "use spaces instead of tabs for indentation"
+	} else if strings.Contains(matches[2], "mapping values are not allowed") {
+		diagnostic.Hint = "quote the values containing a colon followed by a space"
+	}
+
+	return diagnostic
+}
+
+var xpathErrorHints = []struct {
+	pattern string
+	hint    string
+}{
+	{"not defined", "bind the namespace prefix with --ns prefix=uri or use local-name(), e.g. //*[local-name()='item']"},
+	{"node-set", "check the location steps and the operators of the query"},
+	{"unclosed string", "close the string literal with the same quote"},
+	{"invalid token", "check the brackets, the quotes and the operators of the query"},
+	{"invalid qualified name", "the names should be valid XML names, e.g. item or ns:item"},
+}
+
+// newXPathDiagnostic describes the invalid XPath query. The position of the unclosed bracket or
+// quote is pointed if found.
+func newXPathDiagnostic(query string, err any) error {
+	message := strings.TrimSuffix(fmt.Sprint(err), ".")
+	message = strings.TrimPrefix(message, "xpath: ")
+	diagnostic := &Diagnostic{
+		Kind:    ErrorQuery,
+		Message: "XPath error: " + message,
+		Source:  query,
+	}
+	if cause, ok := err.(error); ok {
+		diagnostic.Err = cause
+	}
+	for _, errorHint := range xpathErrorHints {
+		if strings.Contains(message, errorHint.pattern) {
+			diagnostic.Hint = errorHint.hint
+			break
+		}
+	}
+
+	// the messages of the XPath parser are misleading for the unbalanced brackets and quotes
+	if pos := findXPathUnbalanced(query); pos >= 0 && !strings.Contains(query, "\n") {
+		diagnostic.Column = getColumn(query, pos+1)
+		switch query[pos] {
+		case '"', '\'':
+			diagnostic.Hint = "the string literal is not closed"
+		case ']', ')':
+			diagnostic.Hint = "the closing bracket has no matching opening bracket"
+		default:
+			diagnostic.Hint = "the bracket is not closed"
+		}
+	}
+
+	return diagnostic
+}
+
+// findXPathUnbalanced returns the position of the first unclosed quote or bracket, or of the
+// unexpected closing bracket.
+func findXPathUnbalanced(query string) int {
+	var opened []int
+	for pos := 0; pos < len(query); pos++ {
+		switch query[pos] {
+		case '"', '\'':
+			end := strings.IndexByte(query[pos+1:], query[pos])
+			if end < 0 {
+				return pos
+			}
+			pos += end + 1
+		case '[', '(':
+			opened = append(opened, pos)
+		case ']', ')':
+			if len(opened) == 0 || query[opened[len(opened)-1]] != map[byte]byte{']': '[', ')': '('}[query[pos]] {
+				return pos
+			}
+			opened = opened[:len(opened)-1]
+		}
+	}
+	if len(opened) > 0 {
+		return opened[len(opened)-1]
+	}
+
+	return -1
+}
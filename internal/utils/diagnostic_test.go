@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatDiagnostics(t *testing.T) {
+	formatters := map[string]func(io.Reader, io.Writer, string, int) error{
+		"xml":  FormatXml,
+		"json": FormatJson,
+		"yaml": FormatYaml,
+	}
+
+	tests := []struct {
+		format   string
+		input    string
+		expected string
+	}{
+		{"xml", "<root>\n  <a>1</b>\n</root>\n", "Error: data:2:7: XML syntax error: unexpected end element </b>\n" +
+			" 2 |   <a>1</b>\n   |       ^\nHint: the closing tag has no matching opening tag\n"},
+		{"xml", "<root>\n  <a>", "Error: data:2:6: XML syntax error: unexpected EOF\n" +
+			" 2 |   <a>\n   |      ^\nHint: the document ends before all the elements are closed\n"},
+		{"json", "{\n  \"a\": [1, 2,]\n}", "Error: data:2:13: JSON syntax error: invalid character ',' looking for beginning of value\n" +
+			" 2 |   \"a\": [1, 2,]\n   |             ^\nHint: remove the trailing or the duplicate comma\n"},
+		{"json", "{\"a\": \"é\" \"b\"}", "Error: data:1:11: JSON syntax error: invalid character '\"' after object key:value pair\n" +
+			" 1 | {\"a\": \"é\" \"b\"}\n   |           ^\nHint: the values should be separated by commas\n"},
+		{"json", "[1,\n 2", "Error: data:2:3: JSON syntax error: unexpected end of JSON input\n" +
+			" 2 |  2\n   |   ^\nHint: the document ends before all the objects and arrays are closed\n"},
+		{"yaml", "a: 1\nb:\n\t- x\n", "Error: data:3: YAML syntax error: found character that cannot start any token\n" +
+			" 3 | \t- x\nHint: use spaces instead of tabs for indentation\n"},
+	}
+
+	for _, test := range tests {
+		err := formatters[test.format](strings.NewReader(test.input), io.Discard, "  ", ColorsDisabled)
+		var diagnostic *Diagnostic
+		assert.True(t, errors.As(err, &diagnostic), test.input)
+		if diagnostic != nil {
+			assert.Equal(t, ErrorParse, diagnostic.Kind)
+		}
+		assert.Equal(t, test.expected, FormatError(WithFileName(err, "data")), test.input)
+	}
+}
+
+func TestXPathDiagnostics(t *testing.T) {
+	err := XPathQuery(strings.NewReader("<a/>"), io.Discard, "//a[@id='1'", false, QueryOptions{})
+	var diagnostic *Diagnostic
+	assert.True(t, errors.As(err, &diagnostic))
+	assert.Equal(t, ErrorQuery, diagnostic.Kind)
+	assert.Equal(t, 4, diagnostic.Column)
+	assert.Equal(t, "  | //a[@id='1'\n  |    ^\nHint: the bracket is not closed\n", diagnostic.Excerpt())
+
+	err = XPathQuery(strings.NewReader("<a/>"), io.Discard, "//a[@id='1]", false, QueryOptions{})
+	assert.ErrorContains(t, err, "XPath error")
+	assert.Contains(t, FormatError(err), "Hint: the string literal is not closed")
+
+	err = XPathQuery(strings.NewReader("<a/>"), io.Discard, "//a[@id=]", false, QueryOptions{})
+	assert.Equal(t, "Error: XPath error: expression must evaluate to a node-set\n  | //a[@id=]\n"+
+		"Hint: check the location steps and the operators of the query\n", FormatError(WithFileName(err, "file")))
+
+	assert.Equal(t, "file: plain error", WithFileName(errors.New("plain error"), "file").Error())
+	assert.Equal(t, "Error: plain error\n", FormatError(errors.New("plain error")))
+}
+
+func TestSourceRecorder(t *testing.T) {
+	content := strings.Repeat("line\n", sourceRecorderSize/5) + "last line"
+	source := newSourceRecorder(strings.NewReader(content))
+	_, err := io.ReadAll(source)
+	assert.Nil(t, err)
+
+	lastLine := sourceRecorderSize/5 + 1
+	assert.Equal(t, "last line", source.getLine(lastLine))
+	assert.Equal(t, "line", source.getLine(lastLine-1))
+	assert.Equal(t, "", source.getLine(1))
+
+	line, pos := source.getPosition(int64(len(content) - 4))
+	assert.Equal(t, lastLine, line)
+	assert.Equal(t, 5, pos)
+}
@@ -0,0 +1,280 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+)
+
+type DiffOptions struct {
+	IgnoreAttrOrder  bool
+	IgnoreWhitespace bool
+	IgnorePrefixes   bool
+	Colors           int
+}
+
+type documentDiff struct {
+	writer      io.Writer
+	options     DiffOptions
+	differences int
+	tagColor    func(a ...interface{}) string
+	attrColor   func(a ...interface{}) string
+}
+
+type diffChild struct {
+	node     *xmlquery.Node
+	key      string
+	location string
+}
+
+// ParseDocument parses the document of the given type into the node tree. JSON and YAML
+// documents are converted using the same convention as JSONToNode.
+func ParseDocument(reader io.Reader, contentType ContentType) (*xmlquery.Node, error) {
+	switch contentType {
+	case ContentJson:
+		return JSONToNode(reader)
+	case ContentYaml:
+		data, err := YamlToJSON(reader)
+		if err != nil {
+			return nil, err
+		}
+		return JSONToNode(bytes.NewReader(data))
+	default:
+		return parseXml(reader)
+	}
+}
+
+// DiffDocuments compares the documents by their tree structure and writes the added, removed
+// and changed elements, attributes and text nodes. The number of differences is returned.
+func DiffDocuments(oldDoc *xmlquery.Node, newDoc *xmlquery.Node, writer io.Writer, options DiffOptions) (int, error) {
+	diff := &documentDiff{writer: writer, options: options}
+	diff.tagColor, diff.attrColor, _ = getXmlColorFuncs(options.Colors)
+
+	if err := diff.compareChildren(oldDoc, newDoc, ""); err != nil {
+		return diff.differences, err
+	}
+
+	return diff.differences, nil
+}
+
+func (diff *documentDiff) report(marker string, location string, details string) error {
+	diff.differences++
+
+	coloredLocation := diff.tagColor(location)
+	if index := strings.LastIndex(location, "/@"); index >= 0 {
+		coloredLocation = diff.tagColor(location[:index+1]) + diff.attrColor(location[index+1:])
+	}
+
+	_, err := fmt.Fprintf(diff.writer, "%s %s: %s\n", marker, coloredLocation, details)
+	return err
+}
+
+func (diff *documentDiff) getElementName(node *xmlquery.Node) string {
+	if diff.options.IgnorePrefixes && node.NamespaceURI != "" {
+		return "{" + node.NamespaceURI + "}" + node.Data
+	}
+	if node.Prefix != "" {
+		return node.Prefix + ":" + node.Data
+	}
+
+	return node.Data
+}
+
+func (diff *documentDiff) getAttrName(attr xmlquery.Attr) string {
+	if diff.options.IgnorePrefixes && attr.NamespaceURI != "" {
+		return "{" + attr.NamespaceURI + "}" + attr.Name.Local
+	}
+	if attr.Name.Space != "" {
+		return attr.Name.Space + ":" + attr.Name.Local
+	}
+
+	return attr.Name.Local
+}
+
+func isNamespaceDeclaration(attr xmlquery.Attr) bool {
+	return attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns")
+}
+
+// getDiffChildren returns the element and text children of the node together with their
+// keys used for the matching and the XPath-like locations.
+func (diff *documentDiff) getDiffChildren(node *xmlquery.Node, location string) []diffChild {
+	var children []diffChild
+	counts := map[string]int{}
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		var key string
+		switch child.Type {
+		case xmlquery.ElementNode:
+			key = diff.getElementName(child)
+		case xmlquery.TextNode, xmlquery.CharDataNode:
+			if strings.TrimSpace(child.Data) == "" && (diff.options.IgnoreWhitespace || child.Type == xmlquery.TextNode &&
+				child.Parent.Type == xmlquery.DocumentNode) {
+				continue
+			}
+			key = "text()"
+		default:
+			continue
+		}
+
+		// adjacent text and CDATA sections are compared as a single text node
+		if key == "text()" && len(children) > 0 && children[len(children)-1].key == key {
+			merged := *children[len(children)-1].node
+			merged.Data += child.Data
+			children[len(children)-1].node = &merged
+			continue
+		}
+
+		children = append(children, diffChild{node: child, key: key})
+		counts[key]++
+	}
+
+	positions := map[string]int{}
+	for index := range children {
+		child := &children[index]
+		name := child.key
+		if child.node.Type == xmlquery.ElementNode {
+			name = child.node.Data
+			if child.node.Prefix != "" {
+				name = child.node.Prefix + ":" + name
+			}
+		}
+		positions[child.key]++
+		child.location = location + "/" + name
+		if counts[child.key] > 1 {
+			child.location += "[" + strconv.Itoa(positions[child.key]) + "]"
+		}
+	}
+
+	return children
+}
+
+func (diff *documentDiff) compareChildren(oldNode *xmlquery.Node, newNode *xmlquery.Node, location string) error {
+	oldChildren := diff.getDiffChildren(oldNode, location)
+	newChildren := diff.getDiffChildren(newNode, location)
+
+	oldKeys := make([]string, len(oldChildren))
+	for index, child := range oldChildren {
+		oldKeys[index] = child.key
+	}
+	newKeys := make([]string, len(newChildren))
+	for index, child := range newChildren {
+		newKeys[index] = child.key
+	}
+
+	oldIndex, newIndex := 0, 0
+	for _, line := range diffLines(oldKeys, newKeys) {
+		var err error
+		switch line.kind {
+		case ' ':
+			err = diff.compareNodes(oldChildren[oldIndex], newChildren[newIndex])
+			oldIndex++
+			newIndex++
+		case '-':
+			err = diff.report("-", oldChildren[oldIndex].location, diff.describeNode(oldChildren[oldIndex].node))
+			oldIndex++
+		case '+':
+			err = diff.report("+", newChildren[newIndex].location, diff.describeNode(newChildren[newIndex].node))
+			newIndex++
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (diff *documentDiff) describeNode(node *xmlquery.Node) string {
+	if node.Type != xmlquery.ElementNode {
+		return strconv.Quote(node.Data)
+	}
+
+	return node.OutputXMLWithOptions(xmlquery.WithOutputSelf(), xmlquery.WithoutComments(), xmlquery.WithEmptyTagSupport())
+}
+
+func (diff *documentDiff) compareNodes(oldChild diffChild, newChild diffChild) error {
+	oldNode, newNode := oldChild.node, newChild.node
+
+	if oldNode.Type != xmlquery.ElementNode {
+		if oldNode.Data == newNode.Data {
+			return nil
+		}
+		return diff.report("~", newChild.location, strconv.Quote(oldNode.Data)+" -> "+strconv.Quote(newNode.Data))
+	}
+
+	if err := diff.compareAttributes(oldNode, newNode, newChild.location); err != nil {
+		return err
+	}
+
+	return diff.compareChildren(oldNode, newNode, newChild.location)
+}
+
+func (diff *documentDiff) compareAttributes(oldNode *xmlquery.Node, newNode *xmlquery.Node, location string) error {
+	filter := func(attrs []xmlquery.Attr) ([]string, map[string]xmlquery.Attr) {
+		var names []string
+		values := map[string]xmlquery.Attr{}
+		for _, attr := range attrs {
+			if diff.options.IgnorePrefixes && isNamespaceDeclaration(attr) {
+				continue
+			}
+			name := diff.getAttrName(attr)
+			names = append(names, name)
+			values[name] = attr
+		}
+		return names, values
+	}
+	oldNames, oldAttrs := filter(oldNode.Attr)
+	newNames, newAttrs := filter(newNode.Attr)
+
+	var commonOld []string
+	for _, name := range oldNames {
+		attr := oldAttrs[name]
+		attrLocation := location + "/@" + diff.getDisplayAttrName(attr)
+		newAttr, ok := newAttrs[name]
+		if !ok {
+			if err := diff.report("-", attrLocation, strconv.Quote(attr.Value)); err != nil {
+				return err
+			}
+			continue
+		}
+		commonOld = append(commonOld, name)
+		if attr.Value != newAttr.Value {
+			attrLocation = location + "/@" + diff.getDisplayAttrName(newAttr)
+			if err := diff.report("~", attrLocation, strconv.Quote(attr.Value)+" -> "+strconv.Quote(newAttr.Value)); err != nil {
+				return err
+			}
+		}
+	}
+
+	var commonNew []string
+	for _, name := range newNames {
+		attr := newAttrs[name]
+		if _, ok := oldAttrs[name]; ok {
+			commonNew = append(commonNew, name)
+			continue
+		}
+		if err := diff.report("+", location+"/@"+diff.getDisplayAttrName(attr), strconv.Quote(attr.Value)); err != nil {
+			return err
+		}
+	}
+
+	if !diff.options.IgnoreAttrOrder && strings.Join(commonOld, " ") != strings.Join(commonNew, " ") {
+		return diff.report("~", location+"/@*", "attribute order changed from ("+strings.Join(commonOld, ", ")+
+			") to ("+strings.Join(commonNew, ", ")+")")
+	}
+
+	return nil
+}
+
+func (diff *documentDiff) getDisplayAttrName(attr xmlquery.Attr) string {
+	if attr.Name.Space != "" {
+		return attr.Name.Space + ":" + attr.Name.Local
+	}
+
+	return attr.Name.Local
+}
@@ -0,0 +1,64 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffDocuments(t *testing.T) {
+	oldXml := "<root xmlns:a=\"urn:a\">\n  <item id=\"1\" type=\"x\">one</item>\n  <item id=\"2\">two</item>\n  <a:meta>m</a:meta>\n</root>"
+	newXml := `<root xmlns:b="urn:a"><item type="x" id="1">uno</item><item id="3" new="y">two</item><item id="4"/><b:meta>m</b:meta></root>`
+
+	tests := []struct {
+		options  DiffOptions
+		expected string
+	}{
+		{
+			DiffOptions{IgnoreWhitespace: true},
+			"- /root/@xmlns:a: \"urn:a\"\n" +
+				"+ /root/@xmlns:b: \"urn:a\"\n" +
+				"~ /root/item[1]/@*: attribute order changed from (id, type) to (type, id)\n" +
+				"~ /root/item[1]/text(): \"one\" -> \"uno\"\n" +
+				"~ /root/item[2]/@id: \"2\" -> \"3\"\n" +
+				"+ /root/item[2]/@new: \"y\"\n" +
+				"- /root/a:meta: <a:meta>m</a:meta>\n" +
+				"+ /root/item[3]: <item id=\"4\"/>\n" +
+				"+ /root/b:meta: <b:meta>m</b:meta>\n",
+		},
+		{
+			DiffOptions{IgnoreWhitespace: true, IgnoreAttrOrder: true, IgnorePrefixes: true},
+			"~ /root/item[1]/text(): \"one\" -> \"uno\"\n" +
+				"~ /root/item[2]/@id: \"2\" -> \"3\"\n" +
+				"+ /root/item[2]/@new: \"y\"\n" +
+				"+ /root/item[3]: <item id=\"4\"/>\n",
+		},
+	}
+
+	for _, testCase := range tests {
+		oldDoc, err := ParseDocument(strings.NewReader(oldXml), ContentXml)
+		assert.Nil(t, err)
+		newDoc, err := ParseDocument(strings.NewReader(newXml), ContentXml)
+		assert.Nil(t, err)
+
+		output := new(bytes.Buffer)
+		testCase.options.Colors = ColorsDisabled
+		differences, err := DiffDocuments(oldDoc, newDoc, output, testCase.options)
+		assert.Nil(t, err)
+		assert.Equal(t, testCase.expected, output.String())
+		assert.Equal(t, strings.Count(testCase.expected, "\n"), differences)
+	}
+
+	oldDoc, err := ParseDocument(strings.NewReader(`{"a": {"b": [1, 2], "@c": "x"}}`), ContentJson)
+	assert.Nil(t, err)
+	newDoc, err := ParseDocument(strings.NewReader("a:\n  '@c': x\n  b: [1, 3]\n"), ContentYaml)
+	assert.Nil(t, err)
+
+	output := new(bytes.Buffer)
+	differences, err := DiffDocuments(oldDoc, newDoc, output, DiffOptions{Colors: ColorsDisabled})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, differences)
+	assert.Equal(t, "~ /a/b[2]/text(): \"2\" -> \"3\"\n", output.String())
+}
@@ -0,0 +1,415 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+)
+
+type EditAction int
+
+const (
+	EditUpdate EditAction = iota
+	EditDelete
+	EditInsertBefore
+	EditInsertAfter
+	EditAppend
+	EditRename
+	EditSetAttr
+)
+
+type EditOperation struct {
+	Action EditAction
+	XPath  string
+	Name   string
+	Value  string
+}
+
+type editTarget struct {
+	node *xmlquery.Node
+	attr string
+}
+
+// ParseEditOperation parses the command-line representation of an edit operation.
+// Most of the actions expect XPATH=VALUE, the attribute setting expects XPATH@NAME=VALUE
+// and the deletion expects only XPATH.
+func ParseEditOperation(action EditAction, input string) (EditOperation, error) {
+	operation := EditOperation{Action: action, XPath: input}
+	if action == EditDelete {
+		return operation, nil
+	}
+
+	separator := findXPathSeparator(input, '=', false)
+	if separator < 0 {
+		return operation, fmt.Errorf("expected XPATH=VALUE, got %q", input)
+	}
+	operation.XPath, operation.Value = input[:separator], input[separator+1:]
+
+	switch action {
+	case EditRename:
+		operation.Name = strings.TrimSpace(operation.Value)
+		if !isQualifiedXmlName(operation.Name) {
+			return operation, fmt.Errorf("invalid name %q", operation.Name)
+		}
+	case EditSetAttr:
+		separator = findXPathSeparator(operation.XPath, '@', true)
+		if separator < 0 {
+			return operation, fmt.Errorf("expected XPATH@NAME=VALUE, got %q", input)
+		}
+		operation.XPath, operation.Name = operation.XPath[:separator], operation.XPath[separator+1:]
+		operation.XPath = strings.TrimSuffix(operation.XPath, "/")
+		if !isQualifiedXmlName(operation.Name) {
+			return operation, fmt.Errorf("invalid attribute name %q", operation.Name)
+		}
+	}
+
+	if strings.TrimSpace(operation.XPath) == "" {
+		return operation, fmt.Errorf("XPath expression is missed in %q", input)
+	}
+
+	return operation, nil
+}
+
+// findXPathSeparator finds the position of the separator which is not a part of a predicate,
+// a string literal or a comparison operator.
+func findXPathSeparator(input string, separator byte, last bool) int {
+	depth := 0
+	var quote byte
+	position := -1
+
+	for index := 0; index < len(input); index++ {
+		char := input[index]
+		switch {
+		case quote != 0:
+			if char == quote {
+				quote = 0
+			}
+		case char == '\'' || char == '"':
+			quote = char
+		case char == '[' || char == '(':
+			depth++
+		case char == ']' || char == ')':
+			depth--
+		case char == separator && depth == 0:
+			if separator == '=' && index > 0 && strings.ContainsRune("!<>", rune(input[index-1])) {
+				continue
+			}
+			if !last {
+				return index
+			}
+			position = index
+		}
+	}
+
+	return position
+}
+
+// EditXml applies the edit operations in the given order to the XML document and writes
+// the formatted result.
+func EditXml(reader io.Reader, writer io.Writer, operations []EditOperation, options QueryOptions) (errRes error) {
+	defer func() {
+		if err := recover(); err != nil {
+			errRes = &Diagnostic{Kind: ErrorQuery, Message: fmt.Sprintf("XPath error: %v", err)}
+		}
+	}()
+
+	if len(operations) == 0 {
+		return errors.New("no edit operations provided")
+	}
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	doc, err := parseXml(bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+
+	// the parser adds the XML declaration if it is missed in the document
+	content = bytes.TrimLeft(bytes.TrimPrefix(content, []byte("\xef\xbb\xbf")), " \t\r\n")
+	if declaration := doc.FirstChild; !bytes.HasPrefix(content, []byte("<?xml")) &&
+		declaration != nil && declaration.Type == xmlquery.DeclarationNode {
+		xmlquery.RemoveFromTree(declaration)
+	}
+
+	for _, operation := range operations {
+		if err = applyEditOperation(doc, operation, options.Namespaces); err != nil {
+			return err
+		}
+	}
+
+	return FormatXml(strings.NewReader(doc.OutputXML(true)), writer, options.Indent, options.Colors)
+}
+
+func applyEditOperation(doc *xmlquery.Node, operation EditOperation, namespaces map[string]string) error {
+	targets, err := findEditTargets(doc, operation.XPath, namespaces)
+	if err != nil {
+		return err
+	}
+
+	for _, target := range targets {
+		switch operation.Action {
+		case EditUpdate:
+			err = updateNode(target, operation.Value)
+		case EditDelete:
+			err = deleteNode(target)
+		case EditInsertBefore, EditInsertAfter, EditAppend:
+			err = insertNodes(target, operation.Action, operation.Value)
+		case EditRename:
+			err = renameNode(target, operation.Name)
+		case EditSetAttr:
+			if target.attr != "" || target.node.Type != xmlquery.ElementNode {
+				return fmt.Errorf("unable to set attribute %q: %q does not select elements", operation.Name, operation.XPath)
+			}
+			target.node.SetAttr(operation.Name, operation.Value)
+		default:
+			err = fmt.Errorf("unknown edit action: %v", operation.Action)
+		}
+
+		if err != nil {
+			return fmt.Errorf("%w (XPath %q)", err, operation.XPath)
+		}
+	}
+
+	return nil
+}
+
+func findEditTargets(doc *xmlquery.Node, query string, namespaces map[string]string) ([]editTarget, error) {
+	expr, err := compileXPath(doc, query, namespaces)
+	if err != nil {
+		return nil, newXPathDiagnostic(query, err)
+	}
+
+	// the expressions like count(//a) = 3 don't select anything to edit
+	iterator, ok := expr.Evaluate(xmlquery.CreateXPathNavigator(doc)).(*xpath.NodeIterator)
+	if !ok {
+		return nil, fmt.Errorf("XPath %q does not select nodes", query)
+	}
+
+	var targets []editTarget
+	for iterator.MoveNext() {
+		navigator, ok := iterator.Current().(*xmlquery.NodeNavigator)
+		if !ok {
+			continue
+		}
+		target := editTarget{node: navigator.Current()}
+		if navigator.NodeType() == xpath.AttributeNode {
+			target.attr = navigator.LocalName()
+			if navigator.Prefix() != "" {
+				target.attr = navigator.Prefix() + ":" + target.attr
+			}
+		}
+		targets = append(targets, target)
+	}
+
+	return targets, nil
+}
+
+func updateNode(target editTarget, value string) error {
+	node := target.node
+
+	if target.attr != "" {
+		node.SetAttr(target.attr, value)
+		return nil
+	}
+
+	switch node.Type {
+	case xmlquery.ElementNode:
+		for child := node.FirstChild; child != nil; child = node.FirstChild {
+			xmlquery.RemoveFromTree(child)
+		}
+		if value != "" {
+			xmlquery.AddChild(node, &xmlquery.Node{Type: xmlquery.TextNode, Data: value})
+		}
+	case xmlquery.TextNode, xmlquery.CharDataNode, xmlquery.CommentNode:
+		node.Data = value
+	default:
+		return errors.New("unable to update the selected node")
+	}
+
+	return nil
+}
+
+func deleteNode(target editTarget) error {
+	if target.attr != "" {
+		target.node.RemoveAttr(target.attr)
+		return nil
+	}
+
+	if target.node.Type == xmlquery.DocumentNode {
+		return errors.New("unable to delete the document node")
+	}
+	// the document without the element is malformed
+	if target.node.Type == xmlquery.ElementNode && target.node.Parent != nil &&
+		target.node.Parent.Type == xmlquery.DocumentNode {
+		return errors.New("unable to delete the document element")
+	}
+	xmlquery.RemoveFromTree(target.node)
+
+	return nil
+}
+
+func insertNodes(target editTarget, action EditAction, content string) error {
+	node := target.node
+	if target.attr != "" {
+		return errors.New("unable to insert nodes relative to an attribute")
+	}
+	if action != EditAppend && (node.Type == xmlquery.DocumentNode || node.Parent == nil) {
+		return errors.New("unable to insert nodes next to the document node")
+	}
+	if action == EditAppend && node.Type != xmlquery.ElementNode && node.Type != xmlquery.DocumentNode {
+		return errors.New("unable to append nodes to a non-element node")
+	}
+
+	nodes, err := parseXmlFragment(content, node)
+	if err != nil {
+		return err
+	}
+
+	parent := node
+	if action != EditAppend {
+		parent = node.Parent
+	}
+	if parent.Type == xmlquery.DocumentNode {
+		if err = checkDocumentLevelNodes(parent, nodes); err != nil {
+			return err
+		}
+	}
+
+	for index, newNode := range nodes {
+		switch action {
+		case EditAppend:
+			xmlquery.AddChild(node, newNode)
+		case EditInsertBefore:
+			insertNodeBefore(node, newNode)
+		case EditInsertAfter:
+			if index == 0 {
+				xmlquery.AddImmediateSibling(node, newNode)
+			} else {
+				xmlquery.AddImmediateSibling(nodes[index-1], newNode)
+			}
+		}
+	}
+
+	return nil
+}
+
+// checkDocumentLevelNodes checks that the nodes can be added to the document without making
+// it malformed: the document can have a single element and no text.
+func checkDocumentLevelNodes(doc *xmlquery.Node, nodes []*xmlquery.Node) error {
+	elements := 0
+	for child := doc.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == xmlquery.ElementNode {
+			elements++
+		}
+	}
+
+	for _, node := range nodes {
+		switch node.Type {
+		case xmlquery.ElementNode:
+			elements++
+		case xmlquery.TextNode, xmlquery.CharDataNode:
+			if strings.TrimSpace(node.Data) != "" {
+				return errors.New("unable to insert text next to the document element")
+			}
+		}
+	}
+	if elements > 1 {
+		return errors.New("unable to insert elements next to the document element")
+	}
+
+	return nil
+}
+
+func insertNodeBefore(sibling *xmlquery.Node, node *xmlquery.Node) {
+	if sibling.PrevSibling != nil {
+		xmlquery.AddImmediateSibling(sibling.PrevSibling, node)
+		return
+	}
+
+	parent := sibling.Parent
+	node.Parent = parent
+	node.PrevSibling = nil
+	node.NextSibling = sibling
+	sibling.PrevSibling = node
+	parent.FirstChild = node
+}
+
+// parseXmlFragment parses a piece of XML which may contain several top-level nodes
+// or just a text. Namespace prefixes declared in the context node scope can be used.
+func parseXmlFragment(content string, context *xmlquery.Node) ([]*xmlquery.Node, error) {
+	const wrapper = "xq-fragment"
+
+	var declarations []string
+	declared := map[string]bool{}
+	for node := context; node != nil; node = node.Parent {
+		for _, attr := range node.Attr {
+			name := attr.Name.Local
+			if attr.Name.Space == "xmlns" {
+				name = "xmlns:" + name
+			} else if attr.Name.Space != "" || name != "xmlns" {
+				continue
+			}
+			if !declared[name] {
+				declared[name] = true
+				value, _ := escapeText(attr.Value)
+				declarations = append(declarations, " "+name+"=\""+value+"\"")
+			}
+		}
+	}
+
+	fragment := "<" + wrapper + strings.Join(declarations, "") + ">" + content + "</" + wrapper + ">"
+	doc, err := xmlquery.ParseWithOptions(strings.NewReader(fragment), xmlquery.ParserOptions{
+		Decoder: &xmlquery.DecoderOptions{Strict: true},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse XML fragment %q: %w", content, err)
+	}
+
+	root := doc.SelectElement(wrapper)
+	if root == nil {
+		return nil, fmt.Errorf("unable to parse XML fragment %q", content)
+	}
+
+	nodes := root.ChildNodes()
+	for _, node := range nodes {
+		xmlquery.RemoveFromTree(node)
+	}
+
+	return nodes, nil
+}
+
+func renameNode(target editTarget, name string) error {
+	node := target.node
+	xmlName := toXmlName(name)
+
+	if target.attr != "" {
+		oldName := toXmlName(target.attr)
+		for index, attr := range node.Attr {
+			if attr.Name.Local == oldName.Local && attr.Name.Space == oldName.Space {
+				node.Attr[index].Name = xmlName
+			}
+		}
+		return nil
+	}
+
+	if node.Type != xmlquery.ElementNode {
+		return errors.New("unable to rename a non-element node")
+	}
+	node.Data = xmlName.Local
+	node.Prefix = xmlName.Space
+
+	return nil
+}
+
+func isQualifiedXmlName(name string) bool {
+	xmlName := toXmlName(name)
+	return isXmlName(xmlName.Local) && (xmlName.Space == "" || isXmlName(xmlName.Space))
+}
@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseEditOperation(t *testing.T) {
+	tests := []struct {
+		action   EditAction
+		input    string
+		expected EditOperation
+	}{
+		{EditDelete, "//a[@b='c']", EditOperation{Action: EditDelete, XPath: "//a[@b='c']"}},
+		{EditUpdate, "//a[@b='c=d']=x=y", EditOperation{Action: EditUpdate, XPath: "//a[@b='c=d']", Value: "x=y"}},
+		{EditUpdate, "//a[count(b)>=2]/c=", EditOperation{Action: EditUpdate, XPath: "//a[count(b)>=2]/c"}},
+		{EditInsertAfter, "/a/b=<c/>", EditOperation{Action: EditInsertAfter, XPath: "/a/b", Value: "<c/>"}},
+		{EditRename, "//a=ns:b", EditOperation{Action: EditRename, XPath: "//a", Name: "ns:b", Value: "ns:b"}},
+		{EditSetAttr, "//a[@id='1']@name=value", EditOperation{Action: EditSetAttr, XPath: "//a[@id='1']", Name: "name", Value: "value"}},
+		{EditSetAttr, "//a/@name=value", EditOperation{Action: EditSetAttr, XPath: "//a", Name: "name", Value: "value"}},
+	}
+
+	for _, testCase := range tests {
+		operation, err := ParseEditOperation(testCase.action, testCase.input)
+		assert.Nil(t, err)
+		assert.Equal(t, testCase.expected, operation)
+	}
+
+	errorTests := map[string]EditAction{
+		"//a":            EditUpdate,
+		"=value":         EditUpdate,
+		"//a=1b":         EditRename,
+		"//a=value":      EditSetAttr,
+		"//a@1name=test": EditSetAttr,
+	}
+
+	for input, action := range errorTests {
+		_, err := ParseEditOperation(action, input)
+		assert.Error(t, err, input)
+	}
+}
+
+func TestEditXml(t *testing.T) {
+	input := `<root xmlns:x="urn:x"><item id="1">one</item><item id="2">two</item><!-- note --></root>`
+
+	tests := []struct {
+		operations []EditOperation
+		expected   string
+	}{
+		{
+			[]EditOperation{{Action: EditUpdate, XPath: "//item[@id='2']", Value: "2"}},
+			"<root xmlns:x=\"urn:x\">\n  <item id=\"1\">one</item>\n  <item id=\"2\">2</item>\n  <!-- note -->\n</root>\n",
+		},
+		{
+			[]EditOperation{
+				{Action: EditUpdate, XPath: "//item/@id", Value: "0"},
+				{Action: EditUpdate, XPath: "//comment()", Value: " changed "},
+			},
+			"<root xmlns:x=\"urn:x\">\n  <item id=\"0\">one</item>\n  <item id=\"0\">two</item>\n  <!-- changed -->\n</root>\n",
+		},
+		{
+			[]EditOperation{
+				{Action: EditDelete, XPath: "//item[1]"},
+				{Action: EditDelete, XPath: "//@id"},
+				{Action: EditDelete, XPath: "//comment()"},
+			},
+			"<root xmlns:x=\"urn:x\">\n  <item>two</item>\n</root>\n",
+		},
+		{
+			[]EditOperation{
+				{Action: EditInsertBefore, XPath: "//item[1]", Value: "<first/>"},
+				{Action: EditInsertAfter, XPath: "//item[@id='2']", Value: "<x:a/><b/>"},
+				{Action: EditAppend, XPath: "//item[1]", Value: "<c/>"},
+				{Action: EditDelete, XPath: "//comment()"},
+			},
+			"<root xmlns:x=\"urn:x\">\n  <first/>\n  <item id=\"1\">one\n    <c/>\n  </item>\n  <item id=\"2\">two</item>\n  <x:a/>\n  <b/>\n</root>\n",
+		},
+		{
+			[]EditOperation{
+				{Action: EditRename, XPath: "//item", Name: "entry"},
+				{Action: EditRename, XPath: "//@id", Name: "key"},
+				{Action: EditSetAttr, XPath: "/root", Name: "version", Value: "2"},
+				{Action: EditDelete, XPath: "//comment()"},
+			},
+			"<root xmlns:x=\"urn:x\" version=\"2\">\n  <entry key=\"1\">one</entry>\n  <entry key=\"2\">two</entry>\n</root>\n",
+		},
+	}
+
+	for _, testCase := range tests {
+		output := new(strings.Builder)
+		err := EditXml(strings.NewReader(input), output, testCase.operations, QueryOptions{Indent: "  ", Colors: ColorsDisabled})
+		assert.Nil(t, err)
+		assert.Equal(t, testCase.expected, output.String())
+	}
+
+	output := new(strings.Builder)
+	err := EditXml(strings.NewReader(`<?xml version="1.0"?><root/>`), output,
+		[]EditOperation{{Action: EditSetAttr, XPath: "/root", Name: "a", Value: "b"}}, QueryOptions{Indent: "  ", Colors: ColorsDisabled})
+	assert.Nil(t, err)
+	assert.Equal(t, "<?xml version=\"1.0\"?>\n<root a=\"b\"/>\n", output.String())
+
+	errorTests := [][]EditOperation{
+		nil,
+		{{Action: EditUpdate, XPath: "//[", Value: "x"}},
+		{{Action: EditSetAttr, XPath: "//@id", Name: "a"}},
+		{{Action: EditInsertAfter, XPath: "/", Value: "<a/>"}},
+		{{Action: EditInsertAfter, XPath: "//item", Value: "<a>"}},
+		{{Action: EditRename, XPath: "//comment()", Name: "a"}},
+		{{Action: EditInsertAfter, XPath: "/root", Value: "<a/>"}},
+		{{Action: EditInsertBefore, XPath: "/root", Value: "text"}},
+		{{Action: EditAppend, XPath: "/", Value: "<a/>"}},
+	}
+
+	for _, operations := range errorTests {
+		err = EditXml(strings.NewReader(input), new(strings.Builder), operations, QueryOptions{})
+		assert.Error(t, err, operations)
+	}
+
+	err = EditXml(strings.NewReader(input), new(strings.Builder),
+		[]EditOperation{{Action: EditUpdate, XPath: "count(//item)=2", Value: "x"}}, QueryOptions{})
+	assert.ErrorContains(t, err, `XPath "count(//item)=2" does not select nodes`)
+
+	err = EditXml(strings.NewReader(input), new(strings.Builder),
+		[]EditOperation{{Action: EditDelete, XPath: "/root"}}, QueryOptions{})
+	assert.ErrorContains(t, err, "unable to delete the document element")
+
+	output.Reset()
+	err = EditXml(strings.NewReader(input), output,
+		[]EditOperation{{Action: EditInsertBefore, XPath: "/root", Value: "<!-- header -->"}}, QueryOptions{Colors: ColorsDisabled})
+	assert.Nil(t, err)
+	assert.True(t, strings.HasPrefix(output.String(), "<!-- header --><root"), output.String())
+}
@@ -0,0 +1,558 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/antchfx/xmlquery"
+	"golang.org/x/net/html"
+)
+
+const explorerHelp = "↑↓ move  ←→ collapse/expand  J/K sibling  p parent  E/C expand/collapse all  " +
+	"/ search  n/N next/prev  y copy XPath  q quit"
+
+type explorerLine struct {
+	node  *xmlquery.Node
+	depth int
+}
+
+type explorerSegment struct {
+	text  string
+	color func(a ...interface{}) string
+}
+
+// explorer is the state of the interactive tree browser. The keys are handled and the screen
+// is rendered separately from the terminal to keep the browser testable.
+type explorer struct {
+	doc      *xmlquery.Node
+	expanded map[*xmlquery.Node]bool
+	lines    []explorerLine
+	cursor   int
+	offset   int
+	height   int
+	search   string
+	input    *string
+	status   string
+	copied   string
+
+	tagColor     func(a ...interface{}) string
+	attrColor    func(a ...interface{}) string
+	commentColor func(a ...interface{}) string
+}
+
+func newExplorer(doc *xmlquery.Node, colors int) *explorer {
+	e := &explorer{
+		doc:      doc,
+		expanded: map[*xmlquery.Node]bool{doc: true},
+		height:   24,
+		status:   explorerHelp,
+	}
+	e.tagColor, e.attrColor, e.commentColor = getXmlColorFuncs(colors)
+	for _, child := range e.getChildren(doc) {
+		e.expanded[child] = true
+	}
+	e.update()
+
+	return e
+}
+
+// getChildren returns the nodes displayed under the node. The single text of an element is
+// displayed on the same line as the element itself.
+func (e *explorer) getChildren(node *xmlquery.Node) []*xmlquery.Node {
+	var children []*xmlquery.Node
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		switch child.Type {
+		case xmlquery.DeclarationNode:
+			continue
+		case xmlquery.TextNode, xmlquery.CharDataNode:
+			if strings.TrimSpace(child.Data) == "" {
+				continue
+			}
+		}
+		children = append(children, child)
+	}
+
+	if node.Type == xmlquery.ElementNode && len(children) == 1 && children[0].Type == xmlquery.TextNode &&
+		!strings.Contains(strings.TrimSpace(children[0].Data), "\n") {
+		return nil
+	}
+
+	return children
+}
+
+func (e *explorer) update() {
+	var current *xmlquery.Node
+	if e.cursor < len(e.lines) {
+		current = e.lines[e.cursor].node
+	}
+
+	e.lines = e.lines[:0]
+	var walk func(node *xmlquery.Node, depth int)
+	walk = func(node *xmlquery.Node, depth int) {
+		for _, child := range e.getChildren(node) {
+			e.lines = append(e.lines, explorerLine{node: child, depth: depth})
+			if e.expanded[child] {
+				walk(child, depth+1)
+			}
+		}
+	}
+	walk(e.doc, 0)
+
+	if current != nil {
+		e.moveToNode(current)
+	}
+	e.moveTo(e.cursor)
+}
+
+func (e *explorer) current() *xmlquery.Node {
+	if len(e.lines) == 0 {
+		return nil
+	}
+
+	return e.lines[e.cursor].node
+}
+
+func (e *explorer) moveTo(index int) {
+	e.cursor = max(0, min(index, len(e.lines)-1))
+
+	pageHeight := max(1, e.height-1)
+	if e.cursor < e.offset {
+		e.offset = e.cursor
+	} else if e.cursor >= e.offset+pageHeight {
+		e.offset = e.cursor - pageHeight + 1
+	}
+	e.offset = max(0, min(e.offset, len(e.lines)-pageHeight))
+}
+
+// moveToNode expands the ancestors of the node and moves the cursor to it.
+func (e *explorer) moveToNode(node *xmlquery.Node) {
+	changed := false
+	for parent := node.Parent; parent != nil && parent != e.doc; parent = parent.Parent {
+		if !e.expanded[parent] {
+			e.expanded[parent] = true
+			changed = true
+		}
+	}
+	if changed {
+		e.update()
+	}
+
+	for index, line := range e.lines {
+		if line.node == node {
+			e.moveTo(index)
+			return
+		}
+	}
+}
+
+func (e *explorer) setExpanded(node *xmlquery.Node, expanded bool, recursive bool) {
+	if len(e.getChildren(node)) > 0 {
+		e.expanded[node] = expanded
+	}
+	if recursive {
+		for _, child := range e.getChildren(node) {
+			e.setExpanded(child, expanded, true)
+		}
+	}
+}
+
+func (e *explorer) moveToSibling(forward bool) {
+	node := e.current()
+	if node == nil {
+		return
+	}
+
+	siblings := e.getChildren(node.Parent)
+	for index, sibling := range siblings {
+		if sibling != node {
+			continue
+		}
+		if forward && index+1 < len(siblings) {
+			e.moveToNode(siblings[index+1])
+		} else if !forward && index > 0 {
+			e.moveToNode(siblings[index-1])
+		}
+		return
+	}
+}
+
+func (e *explorer) moveToParent() {
+	if node := e.current(); node != nil && node.Parent != e.doc {
+		e.moveToNode(node.Parent)
+	}
+}
+
+// find moves the cursor to the next node containing the search text in its name, attributes
+// or text. The search wraps around the end of the document.
+func (e *explorer) find(forward bool) {
+	if e.search == "" {
+		return
+	}
+
+	var nodes []*xmlquery.Node
+	var walk func(node *xmlquery.Node)
+	walk = func(node *xmlquery.Node) {
+		for _, child := range e.getChildren(node) {
+			nodes = append(nodes, child)
+			walk(child)
+		}
+	}
+	walk(e.doc)
+
+	start := 0
+	for index, node := range nodes {
+		if node == e.current() {
+			start = index
+		}
+	}
+
+	search := strings.ToLower(e.search)
+	for step := 1; step <= len(nodes); step++ {
+		index := (start + step) % len(nodes)
+		if !forward {
+			index = (start - step + len(nodes)) % len(nodes)
+		}
+		if strings.Contains(strings.ToLower(e.getPlainLabel(nodes[index])), search) {
+			e.moveToNode(nodes[index])
+			e.status = e.getNodeXPath(nodes[index])
+			return
+		}
+	}
+
+	e.status = fmt.Sprintf("Pattern not found: %s", e.search)
+}
+
+// handleKey changes the state according to the pressed key. True is returned if the browser
+// should be closed.
+func (e *explorer) handleKey(key string) bool {
+	if e.input != nil {
+		switch key {
+		case "enter":
+			e.search, e.input = *e.input, nil
+			e.find(true)
+		case "esc", "ctrl+c":
+			e.input = nil
+			e.status = ""
+		case "backspace":
+			if *e.input == "" {
+				e.input = nil
+			} else {
+				_, size := utf8.DecodeLastRuneInString(*e.input)
+				*e.input = (*e.input)[:len(*e.input)-size]
+			}
+		default:
+			if utf8.RuneCountInString(key) == 1 {
+				*e.input += key
+			}
+		}
+		return false
+	}
+
+	node := e.current()
+	e.status = ""
+	pageHeight := max(1, e.height-1)
+
+	switch key {
+	case "q", "ctrl+c", "esc":
+		return true
+	case "up", "k":
+		e.moveTo(e.cursor - 1)
+	case "down", "j":
+		e.moveTo(e.cursor + 1)
+	case "pgup", "ctrl+b":
+		e.moveTo(e.cursor - pageHeight)
+	case "pgdn", "ctrl+f", " ":
+		e.moveTo(e.cursor + pageHeight)
+	case "home", "g":
+		e.moveTo(0)
+	case "end", "G":
+		e.moveTo(len(e.lines) - 1)
+	case "right", "l", "enter":
+		if node == nil || len(e.getChildren(node)) == 0 {
+			break
+		}
+		if e.expanded[node] {
+			e.moveTo(e.cursor + 1)
+		} else {
+			e.expanded[node] = true
+			e.update()
+		}
+	case "left", "h":
+		if node != nil && e.expanded[node] {
+			e.expanded[node] = false
+			e.update()
+		} else {
+			e.moveToParent()
+		}
+	case "E", "C":
+		if node != nil {
+			e.setExpanded(node, key == "E", true)
+			e.update()
+		}
+	case "J":
+		e.moveToSibling(true)
+	case "K":
+		e.moveToSibling(false)
+	case "p":
+		e.moveToParent()
+	case "/":
+		input := ""
+		e.input = &input
+	case "n", "N":
+		e.find(key == "n")
+	case "y":
+		if node != nil {
+			e.copied = e.getNodeXPath(node)
+			e.status = "Copied " + e.copied
+		}
+	case "?":
+		e.status = explorerHelp
+	}
+
+	return false
+}
+
+// getNodeXPath returns the XPath locating the node, the positions are added for the nodes
+// having the siblings of the same kind.
+func (e *explorer) getNodeXPath(node *xmlquery.Node) string {
+	var steps []string
+	for ; node != nil && node.Type != xmlquery.DocumentNode; node = node.Parent {
+		var step string
+		switch node.Type {
+		case xmlquery.ElementNode:
+			step = getExplorerNodeName(node)
+		case xmlquery.TextNode, xmlquery.CharDataNode:
+			step = "text()"
+		case xmlquery.CommentNode:
+			step = "comment()"
+		default:
+			step = "processing-instruction('" + node.Data + "')"
+		}
+
+		position, count := 0, 0
+		for sibling := node.Parent.FirstChild; sibling != nil; sibling = sibling.NextSibling {
+			if isSameExplorerStep(sibling, node) {
+				count++
+				if sibling == node {
+					position = count
+				}
+			}
+		}
+		if count > 1 {
+			step += "[" + strconv.Itoa(position) + "]"
+		}
+		steps = append([]string{step}, steps...)
+	}
+
+	return "/" + strings.Join(steps, "/")
+}
+
+func isSameExplorerStep(node *xmlquery.Node, other *xmlquery.Node) bool {
+	switch other.Type {
+	case xmlquery.ElementNode:
+		return node.Type == xmlquery.ElementNode && getExplorerNodeName(node) == getExplorerNodeName(other)
+	case xmlquery.TextNode, xmlquery.CharDataNode:
+		return node.Type == xmlquery.TextNode || node.Type == xmlquery.CharDataNode
+	case xmlquery.CommentNode:
+		return node.Type == xmlquery.CommentNode
+	}
+
+	return node.Type == other.Type && node.Data == other.Data
+}
+
+func getExplorerNodeName(node *xmlquery.Node) string {
+	if node.Prefix != "" {
+		return node.Prefix + ":" + node.Data
+	}
+
+	return node.Data
+}
+
+func (e *explorer) getLabel(node *xmlquery.Node) []explorerSegment {
+	plain := func(a ...interface{}) string { return fmt.Sprint(a...) }
+	text := func(data string) string {
+		return strings.Join(strings.Fields(data), " ")
+	}
+
+	switch node.Type {
+	case xmlquery.ElementNode:
+		name := getExplorerNodeName(node)
+		segments := []explorerSegment{{"<" + name, e.tagColor}}
+		for _, attr := range node.Attr {
+			attrName := attr.Name.Local
+			if attr.Name.Space != "" {
+				attrName = attr.Name.Space + ":" + attrName
+			}
+			escapedValue, _ := escapeText(attr.Value)
+			segments = append(segments, explorerSegment{" " + attrName, plain},
+				explorerSegment{"=\"" + escapedValue + "\"", e.attrColor})
+		}
+
+		children := e.getChildren(node)
+		switch {
+		case node.FirstChild == nil:
+			segments = append(segments, explorerSegment{"/>", e.tagColor})
+		case len(children) == 0:
+			segments = append(segments, explorerSegment{">", e.tagColor},
+				explorerSegment{text(node.InnerText()), plain}, explorerSegment{"</" + name + ">", e.tagColor})
+		case e.expanded[node]:
+			segments = append(segments, explorerSegment{">", e.tagColor})
+		default:
+			segments = append(segments, explorerSegment{">", e.tagColor}, explorerSegment{"…", plain},
+				explorerSegment{"</" + name + ">", e.tagColor})
+		}
+		return segments
+	case xmlquery.CommentNode:
+		return []explorerSegment{{"<!--" + text(node.Data) + "-->", e.commentColor}}
+	case xmlquery.CharDataNode:
+		return []explorerSegment{{"<![CDATA[", e.tagColor}, {text(node.Data), plain}, {"]]>", e.tagColor}}
+	case xmlquery.ProcessingInstruction:
+		return []explorerSegment{{"<?" + node.Data, e.tagColor}, {" " + text(node.InnerText()), plain},
+			{"?>", e.tagColor}}
+	}
+
+	return []explorerSegment{{text(node.Data), plain}}
+}
+
+func (e *explorer) getPlainLabel(node *xmlquery.Node) string {
+	var label strings.Builder
+	for _, segment := range e.getLabel(node) {
+		label.WriteString(segment.text)
+	}
+
+	return label.String()
+}
+
+// render writes the visible part of the tree and the status line.
+func (e *explorer) render(writer io.Writer, width int, height int) error {
+	e.height = height
+	e.moveTo(e.cursor)
+
+	var screen bytes.Buffer
+	screen.WriteString("\x1b[H")
+	for row := 0; row < height-1; row++ {
+		index := e.offset + row
+		if index < len(e.lines) {
+			line := e.lines[index]
+			marker := "  "
+			if len(e.getChildren(line.node)) > 0 {
+				marker = "▸ "
+				if e.expanded[line.node] {
+					marker = "▾ "
+				}
+			}
+			prefix := strings.Repeat("  ", line.depth) + marker
+			if index == e.cursor {
+				screen.WriteString("\x1b[7m" + truncateText(prefix+e.getPlainLabel(line.node), width) + "\x1b[0m")
+			} else {
+				screen.WriteString(renderSegments(append([]explorerSegment{{prefix, fmt.Sprint}},
+					e.getLabel(line.node)...), width))
+			}
+		}
+		screen.WriteString("\x1b[K\r\n")
+	}
+
+	status := e.status
+	if e.input != nil {
+		status = "/" + *e.input
+	} else if status == "" && e.current() != nil {
+		status = e.getNodeXPath(e.current())
+	}
+	screen.WriteString("\x1b[7m" + truncateText(status, width) + "\x1b[K\x1b[0m")
+
+	if e.copied != "" {
+		// the OSC 52 sequence puts the text into the clipboard of the terminal
+		screen.WriteString("\x1b]52;c;" + base64.StdEncoding.EncodeToString([]byte(e.copied)) + "\a")
+		e.copied = ""
+	}
+
+	_, err := writer.Write(screen.Bytes())
+	return err
+}
+
+func renderSegments(segments []explorerSegment, width int) string {
+	var line strings.Builder
+	for _, segment := range segments {
+		text := truncateText(segment.text, width)
+		width -= utf8.RuneCountInString(text)
+		if text != "" {
+			line.WriteString(segment.color(text))
+		}
+		if width <= 0 {
+			break
+		}
+	}
+
+	return line.String()
+}
+
+func truncateText(text string, width int) string {
+	if utf8.RuneCountInString(text) <= width {
+		return text
+	}
+
+	runes := []rune(text)
+	if width <= 1 {
+		return string(runes[:max(0, width)])
+	}
+
+	return string(runes[:width-1]) + "…"
+}
+
+// ParseExplorerDocument parses the document of the given type to be browsed in the explorer.
+// JSON and YAML documents are mapped into the virtual element trees.
+func ParseExplorerDocument(reader io.Reader, contentType ContentType) (*xmlquery.Node, error) {
+	switch contentType {
+	case ContentJson, ContentYaml:
+		if contentType == ContentYaml {
+			data, err := YamlToJSON(reader)
+			if err != nil {
+				return nil, fmt.Errorf("error while parsing YAML: %w", err)
+			}
+			reader = bytes.NewReader(data)
+		}
+		tree, err := parseJSONTree(reader)
+		if err != nil {
+			return nil, err
+		}
+		return tree.doc, nil
+	case ContentHtml:
+		root, err := html.Parse(reader)
+		if err != nil {
+			return nil, err
+		}
+		return htmlToNode(root), nil
+	default:
+		return parseXml(reader)
+	}
+}
+
+func htmlToNode(htmlNode *html.Node) *xmlquery.Node {
+	node := &xmlquery.Node{Type: xmlquery.DocumentNode}
+	switch htmlNode.Type {
+	case html.ElementNode:
+		node = &xmlquery.Node{Type: xmlquery.ElementNode, Data: htmlNode.Data}
+		for _, attr := range htmlNode.Attr {
+			node.Attr = append(node.Attr, xmlquery.Attr{Name: xml.Name{Space: attr.Namespace, Local: attr.Key},
+				Value: attr.Val})
+		}
+	case html.TextNode:
+		node = &xmlquery.Node{Type: xmlquery.TextNode, Data: htmlNode.Data}
+	case html.CommentNode:
+		node = &xmlquery.Node{Type: xmlquery.CommentNode, Data: htmlNode.Data}
+	case html.DoctypeNode:
+		node = &xmlquery.Node{Type: xmlquery.DeclarationNode, Data: htmlNode.Data}
+	}
+
+	for child := htmlNode.FirstChild; child != nil; child = child.NextSibling {
+		xmlquery.AddChild(node, htmlToNode(child))
+	}
+
+	return node
+}
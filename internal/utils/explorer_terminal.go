@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+	"golang.org/x/term"
+)
+
+// RunExplorer opens the full-screen tree browser for the document. The keys are read from the
+// terminal even if the document is provided through stdin.
+func RunExplorer(doc *xmlquery.Node, options QueryOptions) error {
+	input, output, err := openTerminal()
+	if err != nil {
+		return fmt.Errorf("unable to open the terminal: %w", err)
+	}
+	defer func() {
+		_ = input.Close()
+		_ = output.Close()
+	}()
+
+	state, err := term.MakeRaw(int(input.Fd()))
+	if err != nil {
+		return fmt.Errorf("unable to switch the terminal to raw mode: %w", err)
+	}
+	// switch to the alternate screen and hide the cursor while browsing
+	_, _ = io.WriteString(output, "\x1b[?1049h\x1b[?25l")
+	defer func() {
+		_, _ = io.WriteString(output, "\x1b[?25h\x1b[?1049l")
+		_ = term.Restore(int(input.Fd()), state)
+	}()
+
+	e := newExplorer(doc, options.Colors)
+	buf := make([]byte, 256)
+	for {
+		width, height, err := term.GetSize(int(output.Fd()))
+		if err != nil || width <= 0 || height <= 0 {
+			width, height = 80, 24
+		}
+		if err = e.render(output, width, height); err != nil {
+			return err
+		}
+
+		length, err := input.Read(buf)
+		if err != nil {
+			return err
+		}
+		for _, key := range parseTerminalKeys(buf[:length]) {
+			if e.handleKey(key) {
+				return nil
+			}
+		}
+	}
+}
+
+var terminalSequences = map[string]string{
+	"\x1b[A": "up", "\x1b[B": "down", "\x1b[C": "right", "\x1b[D": "left",
+	"\x1bOA": "up", "\x1bOB": "down", "\x1bOC": "right", "\x1bOD": "left",
+	"\x1b[5~": "pgup", "\x1b[6~": "pgdn",
+	"\x1b[H": "home", "\x1b[1~": "home", "\x1bOH": "home",
+	"\x1b[F": "end", "\x1b[4~": "end", "\x1bOF": "end",
+}
+
+// parseTerminalKeys converts the input of the terminal in raw mode into the key names.
+func parseTerminalKeys(data []byte) []string {
+	var keys []string
+	input := string(data)
+
+	for input != "" {
+		matched := false
+		for sequence, key := range terminalSequences {
+			if strings.HasPrefix(input, sequence) {
+				keys = append(keys, key)
+				input = input[len(sequence):]
+				matched = true
+				break
+			}
+		}
+		if matched {
+			continue
+		}
+
+		var key string
+		switch input[0] {
+		case '\r', '\n':
+			key = "enter"
+		case 0x7f, 0x08:
+			key = "backspace"
+		case 0x1b:
+			if len(input) > 2 && (input[1] == '[' || input[1] == 'O') {
+				// skip the unknown escape sequences up to their final byte
+				end := 2
+				for end < len(input) && (input[end] < 0x40 || input[end] > 0x7e) {
+					end++
+				}
+				input = input[min(end+1, len(input)):]
+				continue
+			}
+			key = "esc"
+		case 0x03:
+			key = "ctrl+c"
+		case 0x02:
+			key = "ctrl+b"
+		case 0x06:
+			key = "ctrl+f"
+		}
+		if key != "" {
+			keys = append(keys, key)
+			input = input[1:]
+			continue
+		}
+
+		r := []rune(input)[0]
+		keys = append(keys, string(r))
+		input = input[len(string(r)):]
+	}
+
+	return keys
+}
@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExplorer(t *testing.T) {
+	input := `<?xml version="1.0"?>
+<catalog xmlns:x="urn:x">
+  <!-- books -->
+  <book id="b1"><title>The Hobbit</title><price>12.50</price></book>
+  <book id="b2"><title>Dune</title><x:note>Sci-fi</x:note></book>
+  <magazine>Wired</magazine>
+</catalog>`
+	doc, err := ParseExplorerDocument(strings.NewReader(input), ContentXml)
+	assert.Nil(t, err)
+
+	e := newExplorer(doc, ColorsDisabled)
+	screen := func() string {
+		output := new(strings.Builder)
+		assert.Nil(t, e.render(output, 40, 8))
+		return output.String()
+	}
+
+	assert.Equal(t, []string{"<catalog xmlns:x=\"urn:x\">", "<!--books-->", "<book id=\"b1\">…</book>",
+		"<book id=\"b2\">…</book>", "<magazine>Wired</magazine>"}, getExplorerLabels(e))
+	assert.Contains(t, screen(), "\x1b[7m▾ <catalog xmlns:x=\"urn:x\">\x1b[0m")
+
+	e.handleKey("down")
+	e.handleKey("J")
+	assert.Equal(t, "/catalog/book[1]", e.getNodeXPath(e.current()))
+	e.handleKey("right")
+	e.handleKey("right")
+	assert.Equal(t, "/catalog/book[1]/title", e.getNodeXPath(e.current()))
+	assert.Contains(t, screen(), "    <title>The Hobbit</title>")
+	e.handleKey("p")
+	e.handleKey("left")
+	assert.Equal(t, "/catalog/book[1]", e.getNodeXPath(e.current()))
+	assert.Equal(t, 5, len(e.lines))
+
+	for _, key := range []string{"/", "s", "c", "i", "enter"} {
+		e.handleKey(key)
+	}
+	assert.Equal(t, "/catalog/book[2]/x:note", e.getNodeXPath(e.current()))
+	e.handleKey("y")
+	assert.Contains(t, screen(), "\x1b]52;c;L2NhdGFsb2cvYm9va1syXS94Om5vdGU=\a")
+	assert.NotContains(t, screen(), "\x1b]52")
+
+	e.handleKey("n")
+	assert.Equal(t, "/catalog/book[2]/x:note", e.getNodeXPath(e.current()))
+	for _, key := range []string{"/", "x", "y", "z", "enter"} {
+		e.handleKey(key)
+	}
+	assert.Contains(t, screen(), "Pattern not found: xyz")
+
+	e.handleKey("home")
+	e.handleKey("C")
+	assert.Equal(t, 1, len(e.lines))
+	e.handleKey("E")
+	assert.Equal(t, 9, len(e.lines))
+	e.handleKey("end")
+	assert.Equal(t, "/catalog/magazine", e.getNodeXPath(e.current()))
+	assert.Contains(t, screen(), "<magazine>Wired</magazine>")
+	assert.True(t, e.handleKey("q"))
+}
+
+func getExplorerLabels(e *explorer) []string {
+	var labels []string
+	for _, line := range e.lines {
+		labels = append(labels, e.getPlainLabel(line.node))
+	}
+	return labels
+}
+
+func TestParseExplorerDocument(t *testing.T) {
+	doc, err := ParseExplorerDocument(strings.NewReader(`<p>One<br>Two</p>`), ContentHtml)
+	assert.Nil(t, err)
+	e := newExplorer(doc, ColorsDisabled)
+	e.handleKey("E")
+	assert.Equal(t, []string{"<html>", "<head/>", "<body>", "<p>", "One", "<br/>", "Two"}, getExplorerLabels(e))
+	assert.Equal(t, "/html/body/p/text()[2]", e.getNodeXPath(e.lines[6].node))
+
+	doc, err = ParseExplorerDocument(strings.NewReader(`{"items": [{"name": "pen"}, {"name": "book"}]}`),
+		ContentJson)
+	assert.Nil(t, err)
+	e = newExplorer(doc, ColorsDisabled)
+	assert.Equal(t, []string{"<items>", "<name>pen</name>", "<items>", "<name>book</name>"}, getExplorerLabels(e))
+
+	output := new(strings.Builder)
+	assert.Nil(t, newExplorer(doc, ColorsForced).render(output, 40, 8))
+	assert.Contains(t, output.String(), "\x1b[33m</name>\x1b[0m")
+}
+
+func TestParseTerminalKeys(t *testing.T) {
+	assert.Equal(t, []string{"up", "down", "pgdn", "esc", "q", "enter", "é", "backspace"},
+		parseTerminalKeys([]byte("\x1b[A\x1bOB\x1b[6~\x1b[1;5A\x1bq\ré\x7f")))
+}
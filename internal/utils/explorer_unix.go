@@ -0,0 +1,20 @@
+//go:build !windows
+
+package utils
+
+import "os"
+
+func openTerminal() (*os.File, *os.File, error) {
+	input, err := os.Open("/dev/tty")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	output, err := os.OpenFile("/dev/tty", os.O_WRONLY, 0)
+	if err != nil {
+		_ = input.Close()
+		return nil, nil, err
+	}
+
+	return input, output, nil
+}
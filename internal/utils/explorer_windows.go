@@ -0,0 +1,31 @@
+//go:build windows
+
+package utils
+
+import (
+	"os"
+
+	"golang.org/x/sys/windows"
+)
+
+func openTerminal() (*os.File, *os.File, error) {
+	input, err := os.OpenFile("CONIN$", os.O_RDWR, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	output, err := os.OpenFile("CONOUT$", os.O_RDWR, 0)
+	if err != nil {
+		_ = input.Close()
+		return nil, nil, err
+	}
+
+	// the escape sequences are interpreted by the console only if enabled explicitly
+	var mode uint32
+	handle := windows.Handle(output.Fd())
+	if err = windows.GetConsoleMode(handle, &mode); err == nil {
+		_ = windows.SetConsoleMode(handle, mode|windows.ENABLE_VIRTUAL_TERMINAL_PROCESSING)
+	}
+
+	return input, output, nil
+}
@@ -0,0 +1,405 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// DefaultTextWidth is the width of the plain text used if the terminal width is unknown.
+const DefaultTextWidth = 80
+
+var htmlTextBullets = []string{"*", "+", "o", "#"}
+
+// HtmlToText renders the HTML document as the plain text in the spirit of lynx -dump: the block
+// elements are separated by the empty lines, the text is wrapped at the given width, the lists
+// get the bullets, the tables are laid out in columns and the links are numbered with the
+// references listed at the end. Scripts, styles and hidden elements are skipped.
+func HtmlToText(reader io.Reader, writer io.Writer, width int) error {
+	doc, err := html.Parse(reader)
+	if err != nil {
+		return err
+	}
+
+	return writeHtmlText(writer, []*html.Node{doc}, width)
+}
+
+// writeHtmlText renders the nodes as the consecutive blocks of the text.
+func writeHtmlText(writer io.Writer, nodes []*html.Node, width int) error {
+	if width <= 0 {
+		width = DefaultTextWidth
+	}
+	renderer := &textRenderer{linkNumbers: map[string]int{}}
+	blocks := renderer.newBlocks(width)
+	for _, node := range nodes {
+		blocks.add(node)
+	}
+
+	result := blocks.result()
+	if len(renderer.links) > 0 {
+		references := []string{"References", ""}
+		for index, link := range renderer.links {
+			references = append(references, fmt.Sprintf("%4d. %s", index+1, link))
+		}
+		result = append(result, strings.Join(references, "\n"))
+	}
+	if len(result) == 0 {
+		return nil
+	}
+
+	_, err := io.WriteString(writer, strings.Join(result, "\n\n")+"\n")
+	return err
+}
+
+// textRenderer keeps the state shared by the blocks of the document: the numbered links and
+// the nesting level of the lists.
+type textRenderer struct {
+	links       []string
+	linkNumbers map[string]int
+	listLevel   int
+}
+
+// textBlocks collects the wrapped blocks of the sibling nodes. The inline content between
+// the block elements forms the paragraphs.
+type textBlocks struct {
+	renderer *textRenderer
+	width    int
+	blocks   []string
+	inline   strings.Builder
+}
+
+func (renderer *textRenderer) newBlocks(width int) *textBlocks {
+	return &textBlocks{renderer: renderer, width: max(width, 1)}
+}
+
+func (blocks *textBlocks) result() []string {
+	blocks.flush()
+	return blocks.blocks
+}
+
+func (blocks *textBlocks) addChildren(node *html.Node) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		blocks.add(child)
+	}
+}
+
+func (blocks *textBlocks) addBlock(block string) {
+	blocks.flush()
+	if strings.TrimSpace(block) != "" {
+		blocks.blocks = append(blocks.blocks, block)
+	}
+}
+
+// flush wraps the paragraph of the collected inline content. The line breaks are kept, but
+// only a single empty line is kept between the lines.
+func (blocks *textBlocks) flush() {
+	var lines []string
+	for _, line := range strings.Split(blocks.inline.String(), "\n") {
+		line = strings.TrimSpace(htmlSpaceRegexp.ReplaceAllString(line, " "))
+		if line == "" {
+			if len(lines) > 0 && lines[len(lines)-1] != "" {
+				lines = append(lines, "")
+			}
+			continue
+		}
+		lines = append(lines, wrapText(line, blocks.width, false)...)
+	}
+	blocks.inline.Reset()
+
+	if paragraph := strings.TrimSpace(strings.Join(lines, "\n")); paragraph != "" {
+		blocks.blocks = append(blocks.blocks, paragraph)
+	}
+}
+
+func (blocks *textBlocks) add(node *html.Node) {
+	switch node.Type {
+	case html.DocumentNode:
+		blocks.addChildren(node)
+		return
+	case html.TextNode:
+		blocks.inline.WriteString(htmlSpaceRegexp.ReplaceAllString(node.Data, " "))
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	if isHiddenHtmlNode(node) {
+		return
+	}
+	if !htmlBlockTags[node.Data] {
+		blocks.inline.WriteString(blocks.renderer.getInline(node))
+		return
+	}
+
+	switch node.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		text := strings.TrimSpace(htmlSpaceRegexp.ReplaceAllString(blocks.renderer.getChildrenInline(node), " "))
+		if text == "" {
+			return
+		}
+		lines := wrapText(text, blocks.width, false)
+		underline := map[string]string{"h1": "=", "h2": "-"}[node.Data]
+		if underline != "" {
+			length := 0
+			for _, line := range lines {
+				length = max(length, utf8.RuneCountInString(line))
+			}
+			lines = append(lines, strings.Repeat(underline, length))
+		}
+		blocks.addBlock(strings.Join(lines, "\n"))
+	case "hr":
+		blocks.addBlock(strings.Repeat("-", blocks.width))
+	case "pre":
+		blocks.addBlock(strings.Trim(getHtmlText(node), "\n"))
+	case "blockquote":
+		quote := blocks.renderer.newBlocks(blocks.width - 4)
+		quote.addChildren(node)
+		blocks.addBlock(prefixLines(strings.Join(quote.result(), "\n\n"), "    ", ""))
+	case "ul", "ol", "menu", "dir":
+		blocks.addBlock(blocks.renderer.formatList(node, blocks.width))
+	case "table":
+		blocks.addBlock(blocks.renderer.formatTable(node, blocks.width))
+	default:
+		blocks.flush()
+		blocks.addChildren(node)
+		blocks.flush()
+	}
+}
+
+func (renderer *textRenderer) getChildrenInline(node *html.Node) string {
+	result := new(strings.Builder)
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		switch child.Type {
+		case html.TextNode:
+			result.WriteString(htmlSpaceRegexp.ReplaceAllString(child.Data, " "))
+		case html.ElementNode:
+			if !isHiddenHtmlNode(child) {
+				result.WriteString(renderer.getInline(child))
+			}
+		}
+	}
+
+	return result.String()
+}
+
+// getInline returns the text of the inline element, the line breaks are returned as newlines.
+// The links are prefixed with their reference numbers.
+func (renderer *textRenderer) getInline(node *html.Node) string {
+	switch node.Data {
+	case "br":
+		return "\n"
+	case "img":
+		if alt := strings.TrimSpace(getHtmlAttr(node, "alt")); alt != "" {
+			return "[" + alt + "]"
+		}
+		return ""
+	case "a":
+		content := renderer.getChildrenInline(node)
+		href := strings.TrimSpace(getHtmlAttr(node, "href"))
+		if href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+			return content
+		}
+		number, ok := renderer.linkNumbers[href]
+		if !ok {
+			renderer.links = append(renderer.links, href)
+			number = len(renderer.links)
+			renderer.linkNumbers[href] = number
+		}
+		return fmt.Sprintf("[%d]", number) + content
+	}
+
+	return renderer.getChildrenInline(node)
+}
+
+// formatList writes the list items, the continuation lines are indented by the width of the
+// item marker. The bullets of the nested lists change with the level.
+func (renderer *textRenderer) formatList(node *html.Node, width int) string {
+	ordered := node.Data == "ol"
+	number := 1
+	if start, err := strconv.Atoi(getHtmlAttr(node, "start")); err == nil && ordered {
+		number = start
+	}
+	bullet := htmlTextBullets[renderer.listLevel%len(htmlTextBullets)]
+	renderer.listLevel++
+	defer func() {
+		renderer.listLevel--
+	}()
+
+	var items []string
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode || child.Data != "li" || isHiddenHtmlNode(child) {
+			continue
+		}
+		marker := bullet + " "
+		if ordered {
+			marker = fmt.Sprintf("%d. ", number)
+			number++
+		}
+
+		item := renderer.newBlocks(width - len(marker))
+		item.addChildren(child)
+		indent := strings.Repeat(" ", len(marker))
+		content := prefixLines(strings.Join(item.result(), "\n"), indent, "")
+		items = append(items, marker+strings.TrimPrefix(content, indent))
+	}
+
+	return strings.Join(items, "\n")
+}
+
+// formatTable lays out the cells in the columns separated by two spaces. If the table is wider
+// than the width, the widest columns are narrowed and their cells are wrapped.
+func (renderer *textRenderer) formatTable(node *html.Node, width int) string {
+	var rows [][]string
+	for _, row := range getHtmlTableRows(node) {
+		if isHiddenHtmlNode(row) {
+			continue
+		}
+		var cells []string
+		for cell := row.FirstChild; cell != nil; cell = cell.NextSibling {
+			if cell.Type == html.ElementNode && (cell.Data == "td" || cell.Data == "th") && !isHiddenHtmlNode(cell) {
+				text := htmlSpaceRegexp.ReplaceAllString(renderer.getChildrenInline(cell), " ")
+				cells = append(cells, strings.TrimSpace(text))
+			}
+		}
+		rows = append(rows, cells)
+	}
+
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+	if columns == 0 {
+		return ""
+	}
+	widths := make([]int, columns)
+	for _, row := range rows {
+		for index, cell := range row {
+			widths[index] = max(widths[index], utf8.RuneCountInString(cell))
+		}
+	}
+	widths = fitTextColumns(widths, width-2*(columns-1))
+
+	var lines []string
+	for _, row := range rows {
+		cellLines := make([][]string, columns)
+		height := 1
+		for index := range cellLines {
+			if index < len(row) {
+				cellLines[index] = wrapText(row[index], widths[index], true)
+			}
+			height = max(height, len(cellLines[index]))
+		}
+		for lineIndex := 0; lineIndex < height; lineIndex++ {
+			line := new(strings.Builder)
+			for index, cell := range cellLines {
+				text := ""
+				if lineIndex < len(cell) {
+					text = cell[lineIndex]
+				}
+				if index > 0 {
+					line.WriteString("  ")
+				}
+				line.WriteString(text + strings.Repeat(" ", widths[index]-utf8.RuneCountInString(text)))
+			}
+			lines = append(lines, strings.TrimRight(line.String(), " "))
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// fitTextColumns shares the available width between the columns. The narrow columns keep
+// their widths, the rest of the width is split evenly between the wider ones.
+func fitTextColumns(widths []int, available int) []int {
+	total := 0
+	for _, width := range widths {
+		total += width
+	}
+	if total <= available {
+		return widths
+	}
+
+	order := make([]int, len(widths))
+	for index := range order {
+		order[index] = index
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return widths[order[i]] < widths[order[j]]
+	})
+
+	result := make([]int, len(widths))
+	for position, index := range order {
+		share := max(available/(len(order)-position), 1)
+		result[index] = min(widths[index], share)
+		available -= result[index]
+	}
+
+	return result
+}
+
+// wrapText splits the text into the lines not wider than the width. The words longer than
+// the width are left as is, unless they should be broken.
+func wrapText(text string, width int, breakWords bool) []string {
+	var lines []string
+	line := ""
+	for _, word := range strings.Fields(text) {
+		for breakWords && utf8.RuneCountInString(word) > width {
+			if line != "" {
+				lines = append(lines, line)
+				line = ""
+			}
+			runes := []rune(word)
+			lines = append(lines, string(runes[:width]))
+			word = string(runes[width:])
+		}
+		switch {
+		case word == "":
+		case line == "":
+			line = word
+		case utf8.RuneCountInString(line)+1+utf8.RuneCountInString(word) <= width:
+			line += " " + word
+		default:
+			lines = append(lines, line)
+			line = word
+		}
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+
+	return lines
+}
+
+// isHiddenHtmlNode checks that the element is skipped or hidden with the hidden attribute,
+// aria-hidden or the inline style.
+func isHiddenHtmlNode(node *html.Node) bool {
+	if node.Type != html.ElementNode {
+		return false
+	}
+	if htmlSkippedTags[node.Data] {
+		return true
+	}
+	for _, attr := range node.Attr {
+		switch attr.Key {
+		case "hidden":
+			return true
+		case "aria-hidden":
+			if strings.EqualFold(strings.TrimSpace(attr.Val), "true") {
+				return true
+			}
+		case "style":
+			style := strings.ToLower(strings.ReplaceAll(attr.Val, " ", ""))
+			if strings.Contains(style, "display:none") || strings.Contains(style, "visibility:hidden") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
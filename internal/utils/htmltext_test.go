@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHtmlToText(t *testing.T) {
+	tests := []struct {
+		input    string
+		width    int
+		expected string
+	}{
+		{`<h1>Title</h1><p>Some <b>bold</b> text<br>and a new line.</p><h3>Section</h3><div>Text</div>`, 80,
+			"Title\n=====\n\nSome bold text\nand a new line.\n\nSection\n\nText\n"},
+		{`<head><title>T</title><style>p {}</style></head><body><script>x()</script><p>Shown<span hidden>one</span>` +
+			`<span style="display: none">two</span><span aria-hidden="true">three</span></p></body>`, 80, "Shown\n"},
+		{`<p>The quick brown fox jumps over the lazy dog</p>`, 16, "The quick brown\nfox jumps over\nthe lazy dog\n"},
+		{`<p>See <a href="https://example.com">the site</a>, <a href="#top">top</a> and ` +
+			`<a href="https://example.com">again</a> <img src="i.png" alt="logo"></p>`, 80,
+			"See [1]the site, top and [1]again [logo]\n\nReferences\n\n   1. https://example.com\n"},
+		{`<ul><li>One</li><li>Two<ul><li>Nested</li></ul></li></ul><ol start="9"><li>Nine</li><li>Ten</li></ol>`, 80,
+			"* One\n* Two\n  + Nested\n\n9. Nine\n10. Ten\n"},
+		{"<pre>  keep\n    spaces</pre><blockquote>Quote</blockquote><hr>", 10, "  keep\n    spaces\n\n    Quote\n\n----------\n"},
+		{`<table><tr><th>Name</th><th>Description</th></tr><tr><td>pen</td><td>A blue pen to write</td></tr>` +
+			`<tr><td>book</td></tr></table>`, 20, "Name  Description\npen   A blue pen to\n      write\nbook\n"},
+		{``, 80, ""},
+	}
+
+	for _, test := range tests {
+		output := new(bytes.Buffer)
+		assert.Nil(t, HtmlToText(strings.NewReader(test.input), output, test.width))
+		assert.Equal(t, test.expected, output.String(), test.input)
+	}
+}
+
+func TestCSSQueryText(t *testing.T) {
+	input := `<html><body><nav>Menu</nav><article><h2>Title</h2><p>Text <a href="/more">more</a></p></article></body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(input))
+	assert.Nil(t, err)
+
+	output := new(bytes.Buffer)
+	assert.Nil(t, queryCSS(doc, output, "article", "", QueryOptions{TextWidth: 80}))
+	assert.Equal(t, "Title\n-----\n\nText [1]more\n\nReferences\n\n   1. /more\n", output.String())
+}
+
+func TestWrapText(t *testing.T) {
+	assert.Equal(t, []string{"a", "verylongword", "b"}, wrapText("a verylongword b", 5, false))
+	assert.Equal(t, []string{"a", "veryl", "ongwo", "rd b"}, wrapText("a verylongword b", 5, true))
+	assert.Equal(t, []int{3, 8, 9}, fitTextColumns([]int{3, 20, 30}, 20))
+	assert.Equal(t, []int{3, 5}, fitTextColumns([]int{3, 5}, 20))
+}
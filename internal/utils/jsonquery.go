@@ -0,0 +1,363 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+	"golang.org/x/net/html"
+)
+
+// jsonTree is the JSON document mapped into the virtual element tree: object keys become
+// elements, array items become repeated elements and scalars become text. Keys prefixed
+// with "@" and "#text" follow the NodeToJSON convention. The original values are kept to
+// return the typed results.
+type jsonTree struct {
+	doc    *xmlquery.Node
+	values map[*xmlquery.Node]interface{}
+}
+
+type jsonQueryResult struct {
+	value  interface{}
+	text   string
+	isNode bool
+}
+
+func parseJSONTree(reader io.Reader) (*jsonTree, error) {
+	decoder := json.NewDecoder(reader)
+	decoder.UseNumber()
+
+	tree := &jsonTree{
+		doc:    &xmlquery.Node{Type: xmlquery.DocumentNode},
+		values: map[*xmlquery.Node]interface{}{},
+	}
+
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, fmt.Errorf("error while parsing JSON: %w", err)
+	}
+	if _, err = tree.decodeValue(decoder, token, tree.doc); err != nil {
+		return nil, fmt.Errorf("error while parsing JSON: %w", err)
+	}
+	if _, err = decoder.Token(); err != io.EOF {
+		return nil, errors.New("error while parsing JSON: unexpected content after the end of JSON document")
+	}
+
+	return tree, nil
+}
+
+func (tree *jsonTree) decodeValue(decoder *json.Decoder, token json.Token, node *xmlquery.Node) (interface{}, error) {
+	var value interface{} = token
+
+	switch token {
+	case json.Delim('{'):
+		object := map[string]interface{}{}
+		for decoder.More() {
+			keyToken, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			key, ok := keyToken.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected JSON object key, got %T", keyToken)
+			}
+			if object[key], err = tree.decodeMember(decoder, node, key); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		value = object
+	case json.Delim('['):
+		array := []interface{}{}
+		for decoder.More() {
+			itemToken, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			item, err := tree.decodeValue(decoder, itemToken, tree.addElement(node, "item"))
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, item)
+		}
+		if _, err := decoder.Token(); err != nil {
+			return nil, err
+		}
+		value = array
+	default:
+		if text, _ := jsonScalarToString(token); text != "" {
+			xmlquery.AddChild(node, &xmlquery.Node{Type: xmlquery.TextNode, Data: text})
+		}
+	}
+
+	tree.values[node] = value
+	return value, nil
+}
+
+func (tree *jsonTree) decodeMember(decoder *json.Decoder, parent *xmlquery.Node, key string) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	if text, ok := jsonScalarToString(token); ok && (key == "#text" || strings.HasPrefix(key, "@") &&
+		parent.Type == xmlquery.ElementNode) {
+		if key == "#text" {
+			xmlquery.AddChild(parent, &xmlquery.Node{Type: xmlquery.TextNode, Data: text})
+		} else {
+			parent.Attr = append(parent.Attr, xmlquery.Attr{Name: xml.Name{Local: toValidXmlName(key[1:])}, Value: text})
+		}
+		return token, nil
+	}
+
+	// the array items are mapped into the elements repeated for each item
+	if token == json.Delim('[') {
+		array := []interface{}{}
+		for decoder.More() {
+			itemToken, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			item, err := tree.decodeValue(decoder, itemToken, tree.addElement(parent, key))
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, item)
+		}
+		_, err = decoder.Token()
+		return array, err
+	}
+
+	return tree.decodeValue(decoder, token, tree.addElement(parent, key))
+}
+
+func (tree *jsonTree) addElement(parent *xmlquery.Node, key string) *xmlquery.Node {
+	element := &xmlquery.Node{Type: xmlquery.ElementNode, Data: toValidXmlName(key)}
+	xmlquery.AddChild(parent, element)
+
+	return element
+}
+
+// toValidXmlName replaces the characters which are not allowed in XML names by underscores.
+func toValidXmlName(key string) string {
+	var name strings.Builder
+	for index, char := range key {
+		isLetter := unicode.IsLetter(char) || char == '_'
+		if index == 0 && !isLetter {
+			name.WriteByte('_')
+		}
+		if isLetter || unicode.IsDigit(char) || char == '-' || char == '.' {
+			name.WriteRune(char)
+		} else if index > 0 {
+			name.WriteByte('_')
+		}
+	}
+	if name.Len() == 0 {
+		return "_"
+	}
+
+	return name.String()
+}
+
+// getValue returns the original JSON value of the node.
+func (tree *jsonTree) getValue(node *xmlquery.Node) interface{} {
+	switch node.Type {
+	case xmlquery.AttributeNode:
+		if object, ok := tree.values[node.Parent].(map[string]interface{}); ok {
+			for key, value := range object {
+				if strings.HasPrefix(key, "@") && toValidXmlName(key[1:]) == node.Data {
+					return value
+				}
+			}
+		}
+		return node.InnerText()
+	case xmlquery.TextNode, xmlquery.CharDataNode:
+		switch value := tree.values[node.Parent].(type) {
+		case map[string]interface{}, []interface{}, nil:
+			return node.Data
+		default:
+			return value
+		}
+	}
+
+	if value, ok := tree.values[node]; ok {
+		return value
+	}
+
+	return NodeToJSON(node, -1)
+}
+
+// JSONXPathQuery evaluates the XPath query against the JSON document mapped into the virtual
+// element tree. The matched nodes are printed as JSON with the WithTags option, and all the
+// results are printed as typed JSON values with the JSON option.
+func JSONXPathQuery(reader io.Reader, writer io.Writer, query string, singleNode bool, options QueryOptions) error {
+	tree, err := parseJSONTree(reader)
+	if err != nil {
+		return err
+	}
+
+	return tree.queryXPath(writer, query, singleNode, options)
+}
+
+func (tree *jsonTree) queryXPath(writer io.Writer, query string, singleNode bool, options QueryOptions) (errRes error) {
+	defer func() {
+		if err := recover(); err != nil {
+			errRes = newXPathDiagnostic(query, err)
+		}
+	}()
+
+	expr, err := compileXPath(nil, query, options.Namespaces)
+	if err != nil {
+		return newXPathDiagnostic(query, err)
+	}
+	if options.Template != nil {
+		return printXPathTemplate(tree.doc, writer, expr, singleNode, options)
+	}
+
+	var results []jsonQueryResult
+	isNodeSet := false
+	switch value := expr.Evaluate(xmlquery.CreateXPathNavigator(tree.doc)).(type) {
+	case *xpath.NodeIterator:
+		isNodeSet = true
+		for value.MoveNext() {
+			navigator := value.Current().(*xmlquery.NodeNavigator)
+			node := navigator.Current()
+			if navigator.NodeType() == xpath.AttributeNode {
+				node = &xmlquery.Node{Type: xmlquery.AttributeNode, Parent: node, Data: navigator.LocalName()}
+			}
+			results = append(results, jsonQueryResult{value: tree.getValue(node), text: navigator.Value(), isNode: true})
+			if singleNode {
+				break
+			}
+		}
+	case float64:
+		results = append(results, jsonQueryResult{value: getXPathNumberValue(value), text: formatXPathNumber(value)})
+	case bool:
+		results = append(results, jsonQueryResult{value: value, text: fmt.Sprintf("%t", value)})
+	case string:
+		results = append(results, jsonQueryResult{value: value, text: value})
+	default:
+		return fmt.Errorf("unknown type error: %v", value)
+	}
+
+	return printJSONQueryResults(writer, results, isNodeSet && !singleNode, options)
+}
+
+// JSONCSSQuery selects the nodes of the JSON document mapped into the virtual element tree
+// using the CSS selector. The element names are matched case-insensitively.
+func JSONCSSQuery(reader io.Reader, writer io.Writer, query string, attr string, options QueryOptions) error {
+	tree, err := parseJSONTree(reader)
+	if err != nil {
+		return err
+	}
+
+	return tree.queryCSS(writer, query, attr, options)
+}
+
+func (tree *jsonTree) queryCSS(writer io.Writer, query string, attr string, options QueryOptions) error {
+	root, htmlNodes := nodeToHtml(tree.doc)
+	if options.Template != nil {
+		return printCSSTemplate(goquery.NewDocumentFromNode(root).Find(query), writer, options.Template)
+	}
+
+	var results []jsonQueryResult
+	goquery.NewDocumentFromNode(root).Find(query).Each(func(index int, item *goquery.Selection) {
+		node := htmlNodes[item.Nodes[0]]
+		if attr == "" {
+			results = append(results, jsonQueryResult{value: tree.getValue(node), text: item.Text(), isNode: true})
+			return
+		}
+		if value, ok := item.Attr(attr); ok {
+			for _, nodeAttr := range node.Attr {
+				if strings.EqualFold(nodeAttr.Name.Local, attr) {
+					attrNode := &xmlquery.Node{Type: xmlquery.AttributeNode, Parent: node, Data: nodeAttr.Name.Local}
+					results = append(results, jsonQueryResult{value: tree.getValue(attrNode), text: value})
+				}
+			}
+		} else if !options.JSON {
+			results = append(results, jsonQueryResult{})
+		}
+	})
+
+	return printJSONQueryResults(writer, results, true, options)
+}
+
+// nodeToHtml converts the node tree to be matched by the CSS selectors. The names are converted
+// to lower case, the original nodes are returned for the converted ones.
+func nodeToHtml(node *xmlquery.Node) (*html.Node, map[*html.Node]*xmlquery.Node) {
+	htmlNodes := map[*html.Node]*xmlquery.Node{}
+
+	var convert func(node *xmlquery.Node) *html.Node
+	convert = func(node *xmlquery.Node) *html.Node {
+		htmlNode := &html.Node{Type: html.DocumentNode}
+		switch node.Type {
+		case xmlquery.ElementNode:
+			htmlNode = &html.Node{Type: html.ElementNode, Data: strings.ToLower(node.Data)}
+			for _, nodeAttr := range node.Attr {
+				htmlNode.Attr = append(htmlNode.Attr, html.Attribute{Key: strings.ToLower(nodeAttr.Name.Local),
+					Val: nodeAttr.Value})
+			}
+		case xmlquery.TextNode, xmlquery.CharDataNode:
+			htmlNode = &html.Node{Type: html.TextNode, Data: node.Data}
+		case xmlquery.CommentNode:
+			htmlNode = &html.Node{Type: html.CommentNode, Data: node.Data}
+		}
+		htmlNodes[htmlNode] = node
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != xmlquery.DeclarationNode && child.Type != xmlquery.ProcessingInstruction {
+				htmlNode.AppendChild(convert(child))
+			}
+		}
+		return htmlNode
+	}
+
+	return convert(node), htmlNodes
+}
+
+func printJSONQueryResults(writer io.Writer, results []jsonQueryResult, asArray bool, options QueryOptions) error {
+	formatValue := func(value interface{}) error {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		return FormatJson(bytes.NewReader(data), writer, options.Indent, options.Colors)
+	}
+
+	if options.JSON {
+		if asArray {
+			values := make([]interface{}, len(results))
+			for index, result := range results {
+				values[index] = result.value
+			}
+			return formatValue(values)
+		}
+		if len(results) == 0 {
+			return formatValue(nil)
+		}
+		return formatValue(results[0].value)
+	}
+
+	for _, result := range results {
+		var err error
+		if options.WithTags && result.isNode {
+			err = formatValue(result.value)
+		} else {
+			_, err = fmt.Fprintf(writer, "%s\n", strings.TrimSpace(result.text))
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
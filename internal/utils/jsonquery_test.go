@@ -0,0 +1,74 @@
+package utils
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONXPathQuery(t *testing.T) {
+	type test struct {
+		query   string
+		single  bool
+		options QueryOptions
+		result  string
+	}
+
+	tests := []test{
+		{query: "//items[price>10]/name", result: "book\nlamp"},
+		{query: "/store/items[1]/tags[2]", result: "cheap"},
+		{query: "count(//items)", result: "3"},
+		{query: "//items[@id='x1']/price", single: true, result: "12.5"},
+		{query: "//items[price>10]/name", options: QueryOptions{JSON: true}, result: `["book","lamp"]`},
+		{query: "//price", options: QueryOptions{JSON: true}, result: `[5,12.5,30]`},
+		{query: "//available/text()", options: QueryOptions{JSON: true}, result: `[true]`},
+		{query: "//items/@id", options: QueryOptions{JSON: true}, result: `["x1"]`},
+		{query: "//missing", single: true, options: QueryOptions{JSON: true}, result: `null`},
+		{query: "sum(//price) > 40", options: QueryOptions{JSON: true}, result: `true`},
+		{query: "//items[2]", single: true, options: QueryOptions{WithTags: true},
+			result: `{"@id": "x1","name": "book","price": 12.5,"stock": null}`},
+	}
+
+	for _, testCase := range tests {
+		fileReader := getFileReader(filepath.Join("..", "..", "test", "data", "json", "store.json"))
+		output := new(strings.Builder)
+		err := JSONXPathQuery(fileReader, output, testCase.query, testCase.single, testCase.options)
+		assert.Nil(t, err)
+		assert.Equal(t, testCase.result, strings.Trim(output.String(), "\n"), testCase.query)
+	}
+
+	output := new(strings.Builder)
+	err := JSONXPathQuery(strings.NewReader(`[{"first name": "John"}, [1, 2]]`), output, "/item[1]/first_name | /item[2]/item[2]",
+		false, QueryOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "John\n2\n", output.String())
+
+	err = JSONXPathQuery(strings.NewReader(`{"a": `), output, "//a", false, QueryOptions{})
+	assert.ErrorContains(t, err, "error while parsing JSON")
+}
+
+func TestJSONCSSQuery(t *testing.T) {
+	type test struct {
+		query   string
+		attr    string
+		options QueryOptions
+		result  string
+	}
+
+	tests := []test{
+		{query: "items > name", result: "pen\nbook\nlamp"},
+		{query: "items:nth-of-type(3) price", result: "30"},
+		{query: "items", attr: "id", options: QueryOptions{JSON: true}, result: `["x1"]`},
+		{query: "items[id=x1] price, available", options: QueryOptions{JSON: true}, result: `[12.5,true]`},
+	}
+
+	for _, testCase := range tests {
+		fileReader := getFileReader(filepath.Join("..", "..", "test", "data", "json", "store.json"))
+		output := new(strings.Builder)
+		err := JSONCSSQuery(fileReader, output, testCase.query, testCase.attr, testCase.options)
+		assert.Nil(t, err)
+		assert.Equal(t, testCase.result, strings.Trim(output.String(), "\n"), testCase.query)
+	}
+}
@@ -0,0 +1,312 @@
+package utils
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+)
+
+// JSONShapeOptions define the stable shape of the JSON converted from XML. The type paths
+// are written as query=type, the type is one of string, number, boolean or auto.
+type JSONShapeOptions struct {
+	ArrayPaths []string
+	TypePaths  []string
+	AutoTypes  bool
+	Schema     *XmlSchema
+	Namespaces map[string]string
+}
+
+// JSONShape makes the JSON converted from XML independent of the particular document: the
+// elements matched by the array paths are always arrays, even if they are not repeated, and
+// the values are converted to the types of the type paths, the types of the schema
+// declarations or the types inferred from the values. The type paths take precedence over
+// the schema, the later paths take precedence over the earlier ones. The values which can't
+// be converted are kept as strings.
+type JSONShape struct {
+	arrays    []*xpath.Expr
+	types     []jsonTypeRule
+	autoTypes bool
+	schema    *XmlSchema
+
+	// the matched nodes of the last converted document
+	root  *xmlquery.Node
+	index *jsonShapeIndex
+}
+
+type jsonTypeRule struct {
+	expr      *xpath.Expr
+	valueType string
+}
+
+type jsonShapeIndex struct {
+	arrays    map[*xmlquery.Node]bool
+	types     map[jsonValueKey]string
+	autoTypes bool
+}
+
+// jsonValueKey identifies the value of the element or of its attribute with the given name.
+type jsonValueKey struct {
+	node *xmlquery.Node
+	attr string
+}
+
+var (
+	jsonNumberRegexp   = regexp.MustCompile(`^-?(0|[1-9]\d*)(\.\d+)?([eE][+-]?\d+)?$`)
+	xsdNumberRegexp    = regexp.MustCompile(`^[+-]?(\d+\.?\d*|\.\d+)([eE][+-]?\d+)?$`)
+	jsonShapeTypeNames = []string{"string", "number", "boolean", "auto"}
+)
+
+// NewJSONShape compiles the array and the type paths.
+func NewJSONShape(options JSONShapeOptions) (*JSONShape, error) {
+	shape := &JSONShape{autoTypes: options.AutoTypes, schema: options.Schema}
+
+	for _, path := range options.ArrayPaths {
+		expr, err := compileXPath(nil, path, options.Namespaces)
+		if err != nil {
+			return nil, newXPathDiagnostic(path, err)
+		}
+		shape.arrays = append(shape.arrays, expr)
+	}
+
+	for _, path := range options.TypePaths {
+		// the query can contain the equal signs in the predicates, but the type can't
+		separator := strings.LastIndex(path, "=")
+		if separator <= 0 {
+			return nil, fmt.Errorf("invalid type path %q, use query=type", path)
+		}
+		query, valueType := strings.TrimSpace(path[:separator]), strings.TrimSpace(path[separator+1:])
+		if !isJSONShapeType(valueType) {
+			return nil, fmt.Errorf("unknown type %q of the type path, use one of: %s", valueType,
+				strings.Join(jsonShapeTypeNames, ", "))
+		}
+		expr, err := compileXPath(nil, query, options.Namespaces)
+		if err != nil {
+			return nil, newXPathDiagnostic(query, err)
+		}
+		shape.types = append(shape.types, jsonTypeRule{expr: expr, valueType: valueType})
+	}
+
+	return shape, nil
+}
+
+func isJSONShapeType(name string) bool {
+	for _, typeName := range jsonShapeTypeNames {
+		if name == typeName {
+			return true
+		}
+	}
+	return false
+}
+
+// getIndex returns the nodes of the document of the given node matched by the paths and the
+// schema. The result is cached for the last document since the query results of the same
+// document are converted one by one.
+func (shape *JSONShape) getIndex(node *xmlquery.Node) *jsonShapeIndex {
+	if shape == nil || node == nil {
+		return nil
+	}
+
+	root := node
+	for root.Parent != nil {
+		root = root.Parent
+	}
+	if shape.root != root {
+		shape.root, shape.index = root, shape.resolve(root)
+	}
+
+	return shape.index
+}
+
+func (shape *JSONShape) resolve(root *xmlquery.Node) *jsonShapeIndex {
+	index := &jsonShapeIndex{
+		arrays:    map[*xmlquery.Node]bool{},
+		types:     map[jsonValueKey]string{},
+		autoTypes: shape.autoTypes,
+	}
+
+	if shape.schema != nil && root.Type == xmlquery.DocumentNode {
+		if element := getDocumentElement(root); element != nil {
+			name := xml.Name{Space: element.NamespaceURI, Local: element.Data}
+			// the schema errors are reported by the validation, the shape just skips the element then
+			if decl, err := shape.schema.getGlobalElement(name); err == nil && decl != nil {
+				shape.addSchemaElement(index, element, decl)
+			}
+		}
+	}
+
+	for _, expr := range shape.arrays {
+		selectJSONShapeNodes(root, expr, func(key jsonValueKey) {
+			if key.attr == "" {
+				index.arrays[key.node] = true
+			}
+		})
+	}
+	for _, rule := range shape.types {
+		selectJSONShapeNodes(root, rule.expr, func(key jsonValueKey) {
+			index.types[key] = rule.valueType
+		})
+	}
+
+	return index
+}
+
+func selectJSONShapeNodes(root *xmlquery.Node, expr *xpath.Expr, callback func(key jsonValueKey)) {
+	nodes, ok := expr.Evaluate(xmlquery.CreateXPathNavigator(root)).(*xpath.NodeIterator)
+	if !ok {
+		return
+	}
+
+	for nodes.MoveNext() {
+		navigator := nodes.Current().(*xmlquery.NodeNavigator)
+		switch navigator.NodeType() {
+		case xpath.ElementNode:
+			callback(jsonValueKey{node: navigator.Current()})
+		case xpath.AttributeNode:
+			callback(jsonValueKey{node: navigator.Current(),
+				attr: getQualifiedName(navigator.Prefix(), navigator.LocalName(), ":")})
+		}
+	}
+}
+
+// addSchemaElement marks the children declared with maxOccurs greater than one as arrays and
+// takes the types of the values from the simple types of the declarations.
+func (shape *JSONShape) addSchemaElement(index *jsonShapeIndex, node *xmlquery.Node, decl *xsdElement) {
+	typeDef, err := shape.schema.getElementType(decl)
+	if err != nil || typeDef.anyType {
+		return
+	}
+
+	for _, attr := range node.Attr {
+		for _, attrDecl := range typeDef.attributes {
+			if attrDecl.simple != nil && attrDecl.name.Local == attr.Name.Local && attrDecl.name.Space == attr.NamespaceURI {
+				key := jsonValueKey{node: node, attr: getQualifiedName(attr.Name.Space, attr.Name.Local, ":")}
+				index.types[key] = getXsdJSONType(attrDecl.simple)
+			}
+		}
+	}
+
+	if typeDef.simple != nil {
+		index.types[jsonValueKey{node: node}] = getXsdJSONType(typeDef.simple)
+		return
+	}
+	if typeDef.content == nil {
+		return
+	}
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != xmlquery.ElementNode {
+			continue
+		}
+		childDecl, repeated := findXsdElementOccurs(typeDef.content, xml.Name{Space: child.NamespaceURI, Local: child.Data}, false)
+		if childDecl == nil {
+			continue
+		}
+		if repeated {
+			index.arrays[child] = true
+		}
+		shape.addSchemaElement(index, child, childDecl)
+	}
+}
+
+func getXsdJSONType(simpleType *xsdSimpleType) string {
+	switch simpleType.getPrimitive() {
+	case "decimal", "float", "double":
+		return "number"
+	case "boolean":
+		return "boolean"
+	}
+	return "string"
+}
+
+func (index *jsonShapeIndex) isArray(node *xmlquery.Node) bool {
+	return index != nil && index.arrays[node]
+}
+
+// isTyped reports whether the empty value should be converted rather than left as an empty object.
+func (index *jsonShapeIndex) isTyped(key jsonValueKey) bool {
+	if index == nil {
+		return false
+	}
+	_, ok := index.types[key]
+	return ok || index.autoTypes
+}
+
+// convert returns the value converted to the type of the element or the attribute.
+func (index *jsonShapeIndex) convert(key jsonValueKey, value string) interface{} {
+	if index == nil {
+		return value
+	}
+	valueType, ok := index.types[key]
+	if !ok {
+		if !index.autoTypes {
+			return value
+		}
+		valueType = "auto"
+	}
+
+	trimmed := strings.TrimSpace(value)
+	switch valueType {
+	case "number":
+		if trimmed == "" {
+			return nil
+		}
+		if number, ok := parseJSONShapeNumber(trimmed, xsdNumberRegexp); ok {
+			return number
+		}
+	case "boolean":
+		switch trimmed {
+		case "":
+			return nil
+		case "true", "1":
+			return true
+		case "false", "0":
+			return false
+		}
+	case "auto":
+		// only the canonical forms are inferred, so the identifiers like 007 stay strings
+		switch trimmed {
+		case "":
+			return nil
+		case "true":
+			return true
+		case "false":
+			return false
+		}
+		if number, ok := parseJSONShapeNumber(trimmed, jsonNumberRegexp); ok {
+			return number
+		}
+	}
+
+	return value
+}
+
+// parseJSONShapeNumber converts the value matching the pattern to the JSON number. The integers
+// out of the int64 range are kept as is to avoid the loss of precision of float64.
+func parseJSONShapeNumber(value string, pattern *regexp.Regexp) (interface{}, bool) {
+	if !pattern.MatchString(value) {
+		return nil, false
+	}
+	number, err := strconv.ParseInt(value, 10, 64)
+	if err == nil {
+		return number, true
+	}
+	if errors.Is(err, strconv.ErrRange) {
+		sign, digits := "", strings.TrimPrefix(value, "+")
+		if strings.HasPrefix(digits, "-") {
+			sign, digits = "-", digits[1:]
+		}
+		return json.Number(sign + strings.TrimLeft(digits, "0")), true
+	}
+	if number, err := strconv.ParseFloat(value, 64); err == nil && !math.IsInf(number, 0) {
+		return number, true
+	}
+	return nil, false
+}
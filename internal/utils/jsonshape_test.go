@@ -0,0 +1,95 @@
+package utils
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONShape(t *testing.T) {
+	input := `<orders><order id="7" paid="true"><item qty="2"><name>pen</name><price>1.50</price></item>` +
+		`<zip>007</zip><note/><total currency="EUR">3</total></order></orders>`
+	doc, err := xmlquery.Parse(strings.NewReader(input))
+	assert.Nil(t, err)
+
+	tests := []struct {
+		options  JSONShapeOptions
+		expected string
+	}{
+		{JSONShapeOptions{ArrayPaths: []string{"//order", "//order/item"}, TypePaths: []string{"//price=number"}},
+			`{"orders":{"order":[{"@id":"7","@paid":"true","item":[{"@qty":"2","name":"pen","price":1.5}],` +
+				`"note":{},"total":{"#text":"3","@currency":"EUR"},"zip":"007"}]}}`},
+		{JSONShapeOptions{AutoTypes: true}, `{"orders":{"order":{"@id":7,"@paid":true,"item":{"@qty":2,` +
+			`"name":"pen","price":1.5},"note":null,"total":{"#text":3,"@currency":"EUR"},"zip":"007"}}}`},
+		{JSONShapeOptions{AutoTypes: true, TypePaths: []string{"//@id=string", "//note=string", "//zip=number"}},
+			`{"orders":{"order":{"@id":"7","@paid":true,"item":{"@qty":2,"name":"pen","price":1.5},"note":"",` +
+				`"total":{"#text":3,"@currency":"EUR"},"zip":7}}}`},
+		{JSONShapeOptions{TypePaths: []string{"//*[@qty='2']/name=number", "//@paid=boolean", "//note=boolean"}},
+			`{"orders":{"order":{"@id":"7","@paid":true,"item":{"@qty":"2","name":"pen","price":"1.50"},"note":null,` +
+				`"total":{"#text":"3","@currency":"EUR"},"zip":"007"}}}`},
+	}
+
+	for _, test := range tests {
+		shape, err := NewJSONShape(test.options)
+		assert.Nil(t, err)
+		mapper, err := NewJSONMapper(DefaultJSONStyle, JSONMapperOptions{Shape: shape})
+		assert.Nil(t, err)
+		data, err := json.Marshal(mapper.NodeToJSON(doc, -1))
+		assert.Nil(t, err)
+		assert.Equal(t, test.expected, string(data))
+	}
+
+	shape, err := NewJSONShape(JSONShapeOptions{AutoTypes: true, TypePaths: []string{"//b=number"}})
+	assert.Nil(t, err)
+	mapper, err := NewJSONMapper(DefaultJSONStyle, JSONMapperOptions{Shape: shape})
+	assert.Nil(t, err)
+	doc, err = xmlquery.Parse(strings.NewReader(`<r><a>12345678901234567890123</a><b>+00098765432109876543210</b></r>`))
+	assert.Nil(t, err)
+	data, err := json.Marshal(mapper.NodeToJSON(doc, -1))
+	assert.Nil(t, err)
+	assert.Equal(t, `{"r":{"a":12345678901234567890123,"b":98765432109876543210}}`, string(data))
+
+	_, err = NewJSONShape(JSONShapeOptions{TypePaths: []string{"//price"}})
+	assert.EqualError(t, err, `invalid type path "//price", use query=type`)
+	_, err = NewJSONShape(JSONShapeOptions{TypePaths: []string{"//price=int"}})
+	assert.ErrorContains(t, err, `unknown type "int" of the type path`)
+	_, err = NewJSONShape(JSONShapeOptions{ArrayPaths: []string{"//order["}})
+	assert.NotNil(t, err)
+
+	shape, err = NewJSONShape(JSONShapeOptions{AutoTypes: true})
+	assert.Nil(t, err)
+	_, err = NewJSONMapper("parker", JSONMapperOptions{Shape: shape})
+	assert.ErrorContains(t, err, "supported by the default JSON style only")
+}
+
+func TestJSONShapeSchema(t *testing.T) {
+	schema, err := LoadXmlSchema(filepath.Join("..", "..", "test", "data", "xsd", "library.xsd"))
+	assert.Nil(t, err)
+	shape, err := NewJSONShape(JSONShapeOptions{Schema: schema, TypePaths: []string{"//*[local-name()='year']=string"}})
+	assert.Nil(t, err)
+	mapper, err := NewJSONMapper(DefaultJSONStyle, JSONMapperOptions{Shape: shape})
+	assert.Nil(t, err)
+
+	content, err := os.ReadFile(filepath.Join("..", "..", "test", "data", "xsd", "valid.xml"))
+	assert.Nil(t, err)
+	// the book with a single author still has the array declared by the schema
+	content = []byte(strings.Replace(string(content), "<author>Brian Kernighan</author>", "", 1))
+	doc, err := xmlquery.Parse(strings.NewReader(string(content)))
+	assert.Nil(t, err)
+	book := xmlquery.FindOne(doc, "//*[local-name()='book']")
+
+	data, err := json.Marshal(mapper.NodeToJSON(book, -1))
+	assert.Nil(t, err)
+	assert.Equal(t, `{"@id":"b1","author":["Alan Donovan"],"price":{"#text":34.99,"@currency":"USD"},`+
+		`"title":"The Go Programming Language","year":"2015"}`, string(data))
+
+	data, err = json.Marshal(mapper.NodeToJSON(doc, -1))
+	assert.Nil(t, err)
+	assert.Contains(t, string(data), `"@available":false,"@id":"b2"`)
+	assert.Contains(t, string(data), `"book":[{`)
+}
@@ -0,0 +1,350 @@
+package utils
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/antchfx/xmlquery"
+)
+
+// JSONMapper converts the XML node tree into the value encoded as JSON. The nested elements
+// deeper than the given depth are replaced with their text, a negative depth means no limit.
+type JSONMapper interface {
+	NodeToJSON(node *xmlquery.Node, depth int) interface{}
+}
+
+// JSONMapperOptions customize the keys of the mapped objects. The defaults of the style are
+// used for the empty values. The shape is supported by the default style only, the keys can't
+// be customized for the styles without them.
+type JSONMapperOptions struct {
+	AttrPrefix string
+	TextKey    string
+	Shape      *JSONShape
+}
+
+// JSONMapperFactory creates the mapper of the JSON style with the given options.
+type JSONMapperFactory func(options JSONMapperOptions) JSONMapper
+
+const DefaultJSONStyle = "default"
+
+var jsonStyles = struct {
+	sync.RWMutex
+	factories map[string]JSONMapperFactory
+}{factories: map[string]JSONMapperFactory{
+	DefaultJSONStyle: func(options JSONMapperOptions) JSONMapper {
+		return defaultJSONMapper{attrPrefix: getOption(options.AttrPrefix, "@"),
+			textKey: getOption(options.TextKey, "#text"), shape: options.Shape}
+	},
+	"badgerfish": func(options JSONMapperOptions) JSONMapper {
+		return badgerfishJSONMapper{attrPrefix: getOption(options.AttrPrefix, "@"),
+			textKey: getOption(options.TextKey, "$")}
+	},
+	"parker": func(options JSONMapperOptions) JSONMapper {
+		return parkerJSONMapper{}
+	},
+	"jsonml": func(options JSONMapperOptions) JSONMapper {
+		return jsonmlJSONMapper{}
+	},
+	"gdata": func(options JSONMapperOptions) JSONMapper {
+		return gdataJSONMapper{attrPrefix: options.AttrPrefix, textKey: getOption(options.TextKey, "$t")}
+	},
+}}
+
+// jsonStylesWithoutKeys are the built-in styles which have no attribute and text keys.
+var jsonStylesWithoutKeys = map[string]bool{"parker": true, "jsonml": true}
+
+// RegisterJSONStyle makes the mapper available by the style name, the mapper registered
+// before with the same name is replaced.
+func RegisterJSONStyle(name string, factory JSONMapperFactory) {
+	jsonStyles.Lock()
+	defer jsonStyles.Unlock()
+	jsonStyles.factories[name] = factory
+}
+
+// GetJSONStyles returns the sorted names of the registered styles.
+func GetJSONStyles() []string {
+	jsonStyles.RLock()
+	defer jsonStyles.RUnlock()
+
+	var names []string
+	for name := range jsonStyles.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+// NewJSONMapper creates the mapper of the registered style, the default style is used if empty.
+func NewJSONMapper(style string, options JSONMapperOptions) (JSONMapper, error) {
+	if style == "" {
+		style = DefaultJSONStyle
+	}
+	jsonStyles.RLock()
+	factory, ok := jsonStyles.factories[style]
+	jsonStyles.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown JSON style %q, use one of: %s", style, strings.Join(GetJSONStyles(), ", "))
+	}
+	if options.Shape != nil && style != DefaultJSONStyle {
+		return nil, fmt.Errorf("forced arrays and types are supported by the %s JSON style only", DefaultJSONStyle)
+	}
+	if (options.AttrPrefix != "" || options.TextKey != "") && jsonStylesWithoutKeys[style] {
+		return nil, fmt.Errorf("attribute prefix and text key are not supported by the %s JSON style", style)
+	}
+
+	return factory(options), nil
+}
+
+func getOption(value string, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+func getQualifiedName(prefix string, name string, separator string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + separator + name
+}
+
+func getDocumentElement(node *xmlquery.Node) *xmlquery.Node {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == xmlquery.ElementNode {
+			return child
+		}
+	}
+	return nil
+}
+
+func getTextParts(node *xmlquery.Node) []string {
+	var parts []string
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == xmlquery.TextNode || child.Type == xmlquery.CharDataNode {
+			if text := strings.TrimSpace(child.Data); text != "" {
+				parts = append(parts, text)
+			}
+		}
+	}
+	return parts
+}
+
+// badgerfishJSONMapper implements the BadgerFish convention: the text is always stored by the
+// text key, the names keep the prefixes and the namespace declarations are collected in the
+// xmlns map with the default namespace stored as "$".
+type badgerfishJSONMapper struct {
+	attrPrefix string
+	textKey    string
+}
+
+func (mapper badgerfishJSONMapper) NodeToJSON(node *xmlquery.Node, depth int) interface{} {
+	if node == nil {
+		return nil
+	}
+
+	switch node.Type {
+	case xmlquery.DocumentNode:
+		result := map[string]interface{}{}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type == xmlquery.ElementNode {
+				addToResult(result, getQualifiedName(child.Prefix, child.Data, ":"), mapper.elementToJSON(child, depth))
+			}
+		}
+		return result
+	case xmlquery.ElementNode:
+		return mapper.elementToJSON(node, depth)
+	case xmlquery.TextNode, xmlquery.CharDataNode:
+		return map[string]interface{}{mapper.textKey: strings.TrimSpace(node.Data)}
+	}
+
+	return nil
+}
+
+func (mapper badgerfishJSONMapper) elementToJSON(node *xmlquery.Node, depth int) interface{} {
+	result := map[string]interface{}{}
+	if depth == 0 {
+		if text := getTextContent(node); text != "" {
+			result[mapper.textKey] = text
+		}
+		return result
+	}
+
+	namespaces := map[string]interface{}{}
+	for _, attr := range node.Attr {
+		switch {
+		case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+			namespaces["$"] = attr.Value
+		case attr.Name.Space == "xmlns":
+			namespaces[attr.Name.Local] = attr.Value
+		default:
+			result[mapper.attrPrefix+getQualifiedName(attr.Name.Space, attr.Name.Local, ":")] = attr.Value
+		}
+	}
+	if len(namespaces) > 0 {
+		result[mapper.attrPrefix+"xmlns"] = namespaces
+	}
+
+	if textParts := getTextParts(node); len(textParts) > 0 {
+		result[mapper.textKey] = strings.Join(textParts, "\n")
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == xmlquery.ElementNode {
+			addToResult(result, getQualifiedName(child.Prefix, child.Data, ":"), mapper.elementToJSON(child, depth-1))
+		}
+	}
+
+	return result
+}
+
+// parkerJSONMapper implements the Parker convention: the attributes and the root element are
+// omitted, the elements without children become their text or null if they are empty.
+type parkerJSONMapper struct{}
+
+func (mapper parkerJSONMapper) NodeToJSON(node *xmlquery.Node, depth int) interface{} {
+	if node == nil {
+		return nil
+	}
+
+	switch node.Type {
+	case xmlquery.DocumentNode:
+		if root := getDocumentElement(node); root != nil {
+			return mapper.elementToJSON(root, depth)
+		}
+	case xmlquery.ElementNode:
+		return mapper.elementToJSON(node, depth)
+	case xmlquery.TextNode, xmlquery.CharDataNode:
+		return strings.TrimSpace(node.Data)
+	}
+
+	return nil
+}
+
+func (mapper parkerJSONMapper) elementToJSON(node *xmlquery.Node, depth int) interface{} {
+	if depth != 0 && getDocumentElement(node) != nil {
+		result := map[string]interface{}{}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type == xmlquery.ElementNode {
+				addToResult(result, child.Data, mapper.elementToJSON(child, depth-1))
+			}
+		}
+		return result
+	}
+
+	if text := getTextContent(node); text != "" {
+		return text
+	}
+	return nil
+}
+
+// jsonmlJSONMapper implements the JsonML convention: every element is an array of the name,
+// the optional attributes object and the children in the document order.
+type jsonmlJSONMapper struct{}
+
+func (mapper jsonmlJSONMapper) NodeToJSON(node *xmlquery.Node, depth int) interface{} {
+	if node == nil {
+		return nil
+	}
+
+	switch node.Type {
+	case xmlquery.DocumentNode:
+		if root := getDocumentElement(node); root != nil {
+			return mapper.elementToJSON(root, depth)
+		}
+	case xmlquery.ElementNode:
+		return mapper.elementToJSON(node, depth)
+	case xmlquery.TextNode, xmlquery.CharDataNode:
+		return node.Data
+	}
+
+	return nil
+}
+
+func (mapper jsonmlJSONMapper) elementToJSON(node *xmlquery.Node, depth int) interface{} {
+	result := []interface{}{getQualifiedName(node.Prefix, node.Data, ":")}
+	if len(node.Attr) > 0 {
+		attrs := map[string]interface{}{}
+		for _, attr := range node.Attr {
+			attrs[getQualifiedName(attr.Name.Space, attr.Name.Local, ":")] = attr.Value
+		}
+		result = append(result, attrs)
+	}
+
+	if depth == 0 {
+		if text := getTextContent(node); text != "" {
+			result = append(result, text)
+		}
+		return result
+	}
+
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		switch child.Type {
+		case xmlquery.ElementNode:
+			result = append(result, mapper.elementToJSON(child, depth-1))
+		case xmlquery.TextNode, xmlquery.CharDataNode:
+			// the indentation between the elements is not a part of the content
+			if strings.TrimSpace(child.Data) != "" {
+				result = append(result, child.Data)
+			}
+		}
+	}
+
+	return result
+}
+
+// gdataJSONMapper implements the GData convention: the attributes are stored without a prefix,
+// the text is stored as "$t" and the colons of the qualified names are replaced with "$".
+type gdataJSONMapper struct {
+	attrPrefix string
+	textKey    string
+}
+
+func (mapper gdataJSONMapper) NodeToJSON(node *xmlquery.Node, depth int) interface{} {
+	if node == nil {
+		return nil
+	}
+
+	switch node.Type {
+	case xmlquery.DocumentNode:
+		result := map[string]interface{}{}
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type == xmlquery.ElementNode {
+				addToResult(result, getQualifiedName(child.Prefix, child.Data, "$"), mapper.elementToJSON(child, depth))
+			}
+		}
+		return result
+	case xmlquery.ElementNode:
+		return mapper.elementToJSON(node, depth)
+	case xmlquery.TextNode, xmlquery.CharDataNode:
+		return map[string]interface{}{mapper.textKey: strings.TrimSpace(node.Data)}
+	}
+
+	return nil
+}
+
+func (mapper gdataJSONMapper) elementToJSON(node *xmlquery.Node, depth int) interface{} {
+	result := map[string]interface{}{}
+	if depth == 0 {
+		if text := getTextContent(node); text != "" {
+			result[mapper.textKey] = text
+		}
+		return result
+	}
+
+	for _, attr := range node.Attr {
+		result[mapper.attrPrefix+getQualifiedName(attr.Name.Space, attr.Name.Local, "$")] = attr.Value
+	}
+	if textParts := getTextParts(node); len(textParts) > 0 {
+		result[mapper.textKey] = strings.Join(textParts, "\n")
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == xmlquery.ElementNode {
+			addToResult(result, getQualifiedName(child.Prefix, child.Data, "$"), mapper.elementToJSON(child, depth-1))
+		}
+	}
+
+	return result
+}
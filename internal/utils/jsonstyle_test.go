@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONStyles(t *testing.T) {
+	input := `<feed xmlns="urn:atom" xmlns:g="urn:g"><title type="text">News</title>` +
+		`<entry g:id="1"><title>A</title></entry><entry><title>B <b>C</b></title><empty/></entry></feed>`
+	doc, err := xmlquery.Parse(strings.NewReader(input))
+	assert.Nil(t, err)
+
+	tests := []struct {
+		style    string
+		options  JSONMapperOptions
+		expected string
+	}{
+		{DefaultJSONStyle, JSONMapperOptions{}, `{"feed":{"@g":"urn:g","@xmlns":"urn:atom","entry":[{"@id":"1",` +
+			`"title":"A"},{"empty":{},"title":{"#text":"B","b":"C"}}],"title":{"#text":"News","@type":"text"}}}`},
+		{DefaultJSONStyle, JSONMapperOptions{AttrPrefix: "-", TextKey: "_"}, `{"feed":{"-g":"urn:g",` +
+			`"-xmlns":"urn:atom","entry":[{"-id":"1","title":"A"},{"empty":{},"title":{"_":"B","b":"C"}}],` +
+			`"title":{"-type":"text","_":"News"}}}`},
+		{"badgerfish", JSONMapperOptions{}, `{"feed":{"@xmlns":{"$":"urn:atom","g":"urn:g"},"entry":[{"@g:id":"1",` +
+			`"title":{"$":"A"}},{"empty":{},"title":{"$":"B","b":{"$":"C"}}}],"title":{"$":"News","@type":"text"}}}`},
+		{"parker", JSONMapperOptions{}, `{"entry":[{"title":"A"},{"empty":null,"title":{"b":"C"}}],"title":"News"}`},
+		{"jsonml", JSONMapperOptions{}, `["feed",{"xmlns":"urn:atom","xmlns:g":"urn:g"},["title",{"type":"text"},` +
+			`"News"],["entry",{"g:id":"1"},["title","A"]],["entry",["title","B ",["b","C"]],["empty"]]]`},
+		{"gdata", JSONMapperOptions{}, `{"feed":{"entry":[{"g$id":"1","title":{"$t":"A"}},{"empty":{},` +
+			`"title":{"$t":"B","b":{"$t":"C"}}}],"title":{"$t":"News","type":"text"},"xmlns":"urn:atom",` +
+			`"xmlns$g":"urn:g"}}`},
+	}
+
+	for _, test := range tests {
+		mapper, err := NewJSONMapper(test.style, test.options)
+		assert.Nil(t, err)
+		data, err := json.Marshal(mapper.NodeToJSON(doc, -1))
+		assert.Nil(t, err)
+		assert.Equal(t, test.expected, string(data), test.style)
+	}
+
+	mapper, _ := NewJSONMapper("jsonml", JSONMapperOptions{})
+	data, _ := json.Marshal(mapper.NodeToJSON(doc, 1))
+	assert.Equal(t, `["feed",{"xmlns":"urn:atom","xmlns:g":"urn:g"},["title",{"type":"text"},"News"],`+
+		`["entry",{"g:id":"1"},"A"],["entry","B\nC"]]`, string(data))
+
+	_, err = NewJSONMapper("yaml", JSONMapperOptions{})
+	assert.EqualError(t, err, `unknown JSON style "yaml", use one of: badgerfish, default, gdata, jsonml, parker`)
+
+	_, err = NewJSONMapper("parker", JSONMapperOptions{AttrPrefix: "-"})
+	assert.EqualError(t, err, "attribute prefix and text key are not supported by the parker JSON style")
+	_, err = NewJSONMapper("jsonml", JSONMapperOptions{TextKey: "_"})
+	assert.EqualError(t, err, "attribute prefix and text key are not supported by the jsonml JSON style")
+}
+
+func TestRegisterJSONStyle(t *testing.T) {
+	RegisterJSONStyle("names", func(options JSONMapperOptions) JSONMapper {
+		return namesJSONMapper{}
+	})
+	defer func() {
+		jsonStyles.Lock()
+		delete(jsonStyles.factories, "names")
+		jsonStyles.Unlock()
+	}()
+
+	mapper, err := NewJSONMapper("names", JSONMapperOptions{})
+	assert.Nil(t, err)
+	doc, _ := xmlquery.Parse(strings.NewReader("<a><b/></a>"))
+	assert.Equal(t, "b", mapper.NodeToJSON(getDocumentElement(doc), -1))
+	assert.Contains(t, GetJSONStyles(), "names")
+}
+
+type namesJSONMapper struct{}
+
+func (namesJSONMapper) NodeToJSON(node *xmlquery.Node, depth int) interface{} {
+	return node.FirstChild.Data
+}
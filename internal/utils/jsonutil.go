@@ -1,7 +1,13 @@
 package utils
 
 import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
 	"strings"
+	"unicode"
 
 	"github.com/antchfx/xmlquery"
 )
@@ -11,10 +17,24 @@ import (
 // only the text content of the node is included. A depth of 1 means the node's children
 // are included, but not their children, and so on.
 func NodeToJSON(node *xmlquery.Node, depth int) interface{} {
+	return defaultJSONMapper{attrPrefix: "@", textKey: "#text"}.NodeToJSON(node, depth)
+}
+
+// defaultJSONMapper prefixes the attribute names, stores the text of the elements with
+// attributes or children by the text key and uses arrays for the repeated elements only,
+// unless the shape forces the arrays and the types of the values.
+type defaultJSONMapper struct {
+	attrPrefix string
+	textKey    string
+	shape      *JSONShape
+}
+
+func (mapper defaultJSONMapper) NodeToJSON(node *xmlquery.Node, depth int) interface{} {
 	if node == nil {
 		return nil
 	}
 
+	index := mapper.shape.getIndex(node)
 	switch node.Type {
 	case xmlquery.DocumentNode:
 		result := make(map[string]interface{})
@@ -32,7 +52,10 @@ func NodeToJSON(node *xmlquery.Node, depth int) interface{} {
 		for child := node.FirstChild; child != nil; child = child.NextSibling {
 			switch child.Type {
 			case xmlquery.ElementNode:
-				childResult := nodeToJSONInternal(child, depth)
+				childResult := mapper.elementToJSON(child, depth, index)
+				if index.isArray(child) {
+					childResult = []interface{}{childResult}
+				}
 				result[child.Data] = childResult
 			case xmlquery.TextNode, xmlquery.CharDataNode:
 				text := strings.TrimSpace(child.Data)
@@ -43,12 +66,12 @@ func NodeToJSON(node *xmlquery.Node, depth int) interface{} {
 		}
 
 		if len(textParts) > 0 {
-			result["#text"] = strings.Join(textParts, "\n")
+			result[mapper.textKey] = strings.Join(textParts, "\n")
 		}
 		return result
 
 	case xmlquery.ElementNode:
-		return nodeToJSONInternal(node, depth)
+		return mapper.elementToJSON(node, depth, index)
 
 	case xmlquery.TextNode, xmlquery.CharDataNode:
 		return strings.TrimSpace(node.Data)
@@ -58,14 +81,16 @@ func NodeToJSON(node *xmlquery.Node, depth int) interface{} {
 	}
 }
 
-func nodeToJSONInternal(node *xmlquery.Node, depth int) interface{} {
+func (mapper defaultJSONMapper) elementToJSON(node *xmlquery.Node, depth int, index *jsonShapeIndex) interface{} {
+	key := jsonValueKey{node: node}
 	if depth == 0 {
-		return getTextContent(node)
+		return index.convert(key, getTextContent(node))
 	}
 
 	result := make(map[string]interface{})
 	for _, attr := range node.Attr {
-		result["@"+attr.Name.Local] = attr.Value
+		attrKey := jsonValueKey{node: node, attr: getQualifiedName(attr.Name.Space, attr.Name.Local, ":")}
+		result[mapper.attrPrefix+attr.Name.Local] = index.convert(attrKey, attr.Value)
 	}
 
 	var textParts []string
@@ -77,16 +102,23 @@ func nodeToJSONInternal(node *xmlquery.Node, depth int) interface{} {
 				textParts = append(textParts, text)
 			}
 		case xmlquery.ElementNode:
-			childResult := nodeToJSONInternal(child, depth-1)
+			childResult := mapper.elementToJSON(child, depth-1, index)
+			if _, ok := result[child.Data].([]interface{}); !ok && index.isArray(child) {
+				result[child.Data] = []interface{}{childResult}
+				continue
+			}
 			addToResult(result, child.Data, childResult)
 		}
 	}
 
 	if len(textParts) > 0 {
+		text := index.convert(key, strings.Join(textParts, "\n"))
 		if len(result) == 0 {
-			return strings.Join(textParts, "\n")
+			return text
 		}
-		result["#text"] = strings.Join(textParts, "\n")
+		result[mapper.textKey] = text
+	} else if len(result) == 0 && index.isTyped(key) {
+		return index.convert(key, "")
 	}
 
 	return result
@@ -102,7 +134,9 @@ func getTextContent(node *xmlquery.Node) string {
 				parts = append(parts, text)
 			}
 		case xmlquery.ElementNode:
-			parts = append(parts, getTextContent(child))
+			if text := getTextContent(child); text != "" {
+				parts = append(parts, text)
+			}
 		}
 	}
 	return strings.Join(parts, "\n")
@@ -123,3 +157,191 @@ func addToResult(result map[string]interface{}, key string, value interface{}) {
 		result[key] = value
 	}
 }
+
+// DecodeJSON decodes the JSON value from the reader. The syntax errors are reported with the
+// location and the excerpt of the line.
+func DecodeJSON(reader io.Reader, value any) error {
+	source := newSourceRecorder(reader)
+	decoder := json.NewDecoder(source)
+	if err := decoder.Decode(value); err != nil {
+		return newJsonDiagnostic(err, decoder, source)
+	}
+
+	return nil
+}
+
+// JSONToNode converts a JSON document into an xmlquery.Node tree. It follows the same
+// convention as NodeToJSON: keys prefixed with "@" become attributes, "#text" becomes
+// character data and arrays become repeated sibling elements. The order of the keys
+// in the JSON document is preserved.
+func JSONToNode(reader io.Reader) (*xmlquery.Node, error) {
+	source := newSourceRecorder(reader)
+	decoder := json.NewDecoder(source)
+	decoder.UseNumber()
+
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if token != json.Delim('{') {
+		return nil, errors.New("JSON document should be an object")
+	}
+
+	doc := &xmlquery.Node{Type: xmlquery.DocumentNode}
+	if err = jsonObjectToNode(decoder, source, doc); err != nil {
+		return nil, err
+	}
+
+	if _, err = decoder.Token(); err != io.EOF {
+		return nil, errors.New("unexpected content after the end of JSON document")
+	}
+
+	return doc, nil
+}
+
+// JSONToXmlDocument converts a JSON document into a well-formed XML document, so the JSON
+// object should have a single key which becomes the root element.
+func JSONToXmlDocument(reader io.Reader) (*xmlquery.Node, error) {
+	doc, err := JSONToNode(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	roots := 0
+	for child := doc.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != xmlquery.ElementNode {
+			return nil, errors.New("JSON document should not have text at the top level")
+		}
+		roots++
+	}
+	if roots != 1 {
+		return nil, fmt.Errorf("JSON document should have a single root element, got %d", roots)
+	}
+
+	return doc, nil
+}
+
+func jsonObjectToNode(decoder *json.Decoder, source *sourceRecorder, parent *xmlquery.Node) error {
+	for decoder.More() {
+		keyToken, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		key, ok := keyToken.(string)
+		if !ok {
+			return fmt.Errorf("expected JSON object key, got %T", keyToken)
+		}
+		switch {
+		case key == "#text":
+		case strings.HasPrefix(key, "@"):
+			if !isQualifiedXmlName(key[1:]) {
+				return newJsonKeyDiagnostic(fmt.Sprintf("key %q cannot be used as an XML attribute name", key),
+					decoder, source)
+			}
+		case !isQualifiedXmlName(key):
+			return newJsonKeyDiagnostic(fmt.Sprintf("key %q cannot be used as an XML element name", key),
+				decoder, source)
+		}
+		if err = jsonValueToNode(decoder, source, parent, key); err != nil {
+			return err
+		}
+	}
+
+	_, err := decoder.Token()
+	return err
+}
+
+func jsonValueToNode(decoder *json.Decoder, source *sourceRecorder, parent *xmlquery.Node, key string) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	if delim, ok := token.(json.Delim); ok && delim == '[' {
+		// the repeated attributes would make the element malformed
+		if strings.HasPrefix(key, "@") {
+			return fmt.Errorf("value of %q should be a scalar", key)
+		}
+		for decoder.More() {
+			if err = jsonValueToNode(decoder, source, parent, key); err != nil {
+				return err
+			}
+		}
+		_, err = decoder.Token()
+		return err
+	}
+
+	if strings.HasPrefix(key, "@") || key == "#text" {
+		value, ok := jsonScalarToString(token)
+		if !ok {
+			return fmt.Errorf("value of %q should be a scalar", key)
+		}
+		if key == "#text" {
+			xmlquery.AddChild(parent, &xmlquery.Node{Type: xmlquery.TextNode, Data: value})
+			return nil
+		}
+		if parent.Type != xmlquery.ElementNode {
+			return fmt.Errorf("attribute %q should belong to an element", key)
+		}
+		name := toXmlName(key[1:])
+		for _, attr := range parent.Attr {
+			if attr.Name == name {
+				return fmt.Errorf("duplicate attribute %q", key)
+			}
+		}
+		parent.Attr = append(parent.Attr, xmlquery.Attr{Name: name, Value: value})
+		return nil
+	}
+
+	name := toXmlName(key)
+	element := &xmlquery.Node{Type: xmlquery.ElementNode, Data: name.Local, Prefix: name.Space}
+	xmlquery.AddChild(parent, element)
+
+	if delim, ok := token.(json.Delim); ok && delim == '{' {
+		return jsonObjectToNode(decoder, source, element)
+	}
+
+	if value, _ := jsonScalarToString(token); value != "" {
+		xmlquery.AddChild(element, &xmlquery.Node{Type: xmlquery.TextNode, Data: value})
+	}
+
+	return nil
+}
+
+func jsonScalarToString(token json.Token) (string, bool) {
+	switch typedToken := token.(type) {
+	case string:
+		return typedToken, true
+	case json.Number:
+		return typedToken.String(), true
+	case bool:
+		return fmt.Sprintf("%t", typedToken), true
+	case nil:
+		return "", true
+	default:
+		return "", false
+	}
+}
+
+func toXmlName(name string) xml.Name {
+	if prefix, local, found := strings.Cut(name, ":"); found {
+		return xml.Name{Space: prefix, Local: local}
+	}
+	return xml.Name{Local: name}
+}
+
+func isXmlName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for index, char := range name {
+		isLetter := unicode.IsLetter(char) || char == '_'
+		if index == 0 && !isLetter {
+			return false
+		}
+		if !isLetter && !unicode.IsDigit(char) && char != '-' && char != '.' {
+			return false
+		}
+	}
+	return true
+}
@@ -45,3 +45,64 @@ func TestXmlToJSON(t *testing.T) {
 		assert.Equal(t, expectedJson, output.String())
 	}
 }
+
+func TestJSONToNode(t *testing.T) {
+	files := []string{"formatted.json", "formatted2.json", "formatted3.json"}
+
+	for _, fileName := range files {
+		data, readErr := os.ReadFile(filepath.Join("..", "..", "test", "data", "xml2json", fileName))
+		assert.Nil(t, readErr)
+
+		node, err := JSONToNode(bytes.NewReader(data))
+		assert.Nil(t, err)
+
+		var expected interface{}
+		assert.Nil(t, json.Unmarshal(data, &expected))
+		assert.Equal(t, expected, NodeToJSON(node, -1))
+	}
+
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`{"root": {"@id": "1", "item": ["a", "b"]}}`, `<root id="1"><item>a</item><item>b</item></root>`},
+		{`{"root": {"@id": 2, "#text": "text", "empty": null, "flag": true}}`, `<root id="2">text<empty></empty><flag>true</flag></root>`},
+		{`{"ns:root": {"@xmlns:ns": "urn:test", "z": "1", "a": "2"}}`, `<ns:root xmlns:ns="urn:test"><z>1</z><a>2</a></ns:root>`},
+	}
+
+	for _, testCase := range tests {
+		node, err := JSONToNode(strings.NewReader(testCase.input))
+		assert.Nil(t, err)
+		assert.Equal(t, testCase.expected, node.OutputXML(true))
+	}
+
+	errorInputs := []string{`[1, 2]`, `{"root": {"@attr": {}}}`, `{"@attr": "value"}`, `{"first name": "John"}`, `{} {}`,
+		`{"root": `, `{"a": {"@x": ["1", "2"]}}`, `{"a": {"@x": "1", "@x": "2"}}`, `{"a": {"@1 x": "v"}}`,
+		`{"a": {"@": "v"}}`, `{"a": {"@x:": "v"}}`}
+	for _, input := range errorInputs {
+		_, err := JSONToNode(strings.NewReader(input))
+		assert.Error(t, err, input)
+	}
+
+	_, err := JSONToNode(strings.NewReader("{\"a\": {\n  \"b\": 1, \"@1 x\": \"v\"}}"))
+	var diagnostic *Diagnostic
+	assert.ErrorAs(t, err, &diagnostic)
+	assert.Equal(t, `JSON conversion error: key "@1 x" cannot be used as an XML attribute name`, diagnostic.Message)
+	assert.Equal(t, 2, diagnostic.Line)
+	assert.Equal(t, 11, diagnostic.Column)
+}
+
+func TestJSONToXmlDocument(t *testing.T) {
+	node, err := JSONToXmlDocument(strings.NewReader(`{"root": {"@id": "1", "item": ["a", "b"]}}`))
+	assert.Nil(t, err)
+	assert.Equal(t, `<root id="1"><item>a</item><item>b</item></root>`, node.OutputXML(true))
+
+	_, err = JSONToXmlDocument(strings.NewReader(`{"a": 1, "b": 2}`))
+	assert.ErrorContains(t, err, "JSON document should have a single root element, got 2")
+	_, err = JSONToXmlDocument(strings.NewReader(`{"a": [1, 2]}`))
+	assert.ErrorContains(t, err, "JSON document should have a single root element, got 2")
+	_, err = JSONToXmlDocument(strings.NewReader(`{}`))
+	assert.ErrorContains(t, err, "JSON document should have a single root element, got 0")
+	_, err = JSONToXmlDocument(strings.NewReader(`{"#text": "a", "b": 1}`))
+	assert.ErrorContains(t, err, "JSON document should not have text at the top level")
+}
@@ -0,0 +1,408 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+// LintFinding describes a problem found in the document.
+type LintFinding struct {
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+	Severity string `json:"severity"`
+	Rule     string `json:"rule"`
+	Message  string `json:"message"`
+}
+
+// htmlDeprecatedElements are the obsolete elements according to the HTML Living Standard.
+var htmlDeprecatedElements = []string{"acronym", "applet", "basefont", "bgsound", "big", "blink", "center",
+	"dir", "font", "frame", "frameset", "isindex", "keygen", "listing", "marquee", "menuitem", "multicol",
+	"nextid", "nobr", "noembed", "noframes", "plaintext", "rb", "rtc", "spacer", "strike", "tt", "xmp"}
+
+// htmlOptionalEndElements are the elements which end tags may be omitted.
+var htmlOptionalEndElements = []string{"html", "head", "body", "p", "li", "dt", "dd", "rt", "rp", "optgroup",
+	"option", "colgroup", "caption", "thead", "tbody", "tfoot", "tr", "td", "th"}
+
+var lintTagNameRegexp = regexp.MustCompile(`^<([^\s/>!?]+)`)
+
+type lintElement struct {
+	name   string
+	line   int
+	column int
+	scope  map[string]bool
+}
+
+type linter struct {
+	content    []byte
+	lineStarts []int
+	findings   []LintFinding
+}
+
+func newLinter(content []byte) *linter {
+	linter := &linter{content: content, lineStarts: []int{0}}
+	for index, char := range content {
+		if char == '\n' {
+			linter.lineStarts = append(linter.lineStarts, index+1)
+		}
+	}
+
+	return linter
+}
+
+func (linter *linter) getPosition(offset int) (int, int) {
+	offset = min(offset, len(linter.content))
+	line := sort.Search(len(linter.lineStarts), func(index int) bool { return linter.lineStarts[index] > offset })
+	return line, utf8.RuneCount(linter.content[linter.lineStarts[line-1]:offset]) + 1
+}
+
+func (linter *linter) report(offset int, severity string, rule string, format string, args ...any) {
+	line, column := linter.getPosition(offset)
+	linter.reportAt(line, column, severity, rule, format, args...)
+}
+
+func (linter *linter) reportAt(line int, column int, severity string, rule string, format string, args ...any) {
+	linter.findings = append(linter.findings, LintFinding{
+		Line:     line,
+		Column:   column,
+		Severity: severity,
+		Rule:     rule,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+func (linter *linter) sortedFindings() []LintFinding {
+	sort.SliceStable(linter.findings, func(i, j int) bool {
+		first, second := linter.findings[i], linter.findings[j]
+		if first.Line != second.Line {
+			return first.Line < second.Line
+		}
+		return first.Column < second.Column
+	})
+
+	return linter.findings
+}
+
+// LintXml checks that the XML document is well-formed. Unlike the formatter, the document is parsed
+// in strict mode, and the parsing is resumed after the errors to report all the problems found.
+func LintXml(reader io.Reader) ([]LintFinding, error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	linter := newLinter(content)
+	var stack []lintElement
+	hasRoot := false
+	scope := map[string]bool{"xml": true}
+
+	for offset := 0; offset < len(content); {
+		decoder := xml.NewDecoder(bytes.NewReader(content[offset:]))
+		decoder.Strict = true
+		decoder.CharsetReader = getCharsetReader
+		base := offset
+
+		for {
+			tokenOffset := base + int(decoder.InputOffset())
+			token, err := decoder.RawToken()
+			if err == io.EOF {
+				offset = len(content)
+				break
+			}
+			if err != nil {
+				offset = linter.recoverXml(err, base+int(decoder.InputOffset()), tokenOffset, &stack, scope)
+				break
+			}
+
+			switch typedToken := token.(type) {
+			case xml.StartElement:
+				line, column := linter.getPosition(tokenOffset)
+				if len(stack) == 0 && hasRoot {
+					linter.reportAt(line, column, LintError, "multiple-roots",
+						"element <%s> is outside of the root element", getRawXmlName(typedToken.Name))
+				}
+				hasRoot = true
+
+				if len(stack) > 0 {
+					scope = stack[len(stack)-1].scope
+				}
+				element := lintElement{name: getRawXmlName(typedToken.Name), line: line, column: column,
+					scope: linter.checkXmlAttrs(typedToken, line, column, scope)}
+				if typedToken.Name.Space != "" && !element.scope[typedToken.Name.Space] {
+					linter.reportAt(line, column, LintError, "undeclared-prefix",
+						"namespace prefix %q of element <%s> is not declared", typedToken.Name.Space, element.name)
+				}
+				stack = append(stack, element)
+			case xml.EndElement:
+				stack = linter.closeElement(stack, getRawXmlName(typedToken.Name), tokenOffset, false)
+			case xml.CharData:
+				if len(stack) == 0 && len(bytes.TrimSpace(typedToken)) > 0 {
+					linter.report(tokenOffset+len(typedToken)-len(bytes.TrimLeft(typedToken, " \t\r\n")),
+						LintError, "text-outside-root", "text is outside of the root element")
+				}
+			}
+		}
+	}
+
+	for _, element := range stack {
+		linter.reportAt(element.line, element.column, LintError, "unclosed-element",
+			"element <%s> is not closed", element.name)
+	}
+	if !hasRoot && len(bytes.TrimSpace(content)) > 0 {
+		linter.report(0, LintError, "no-root", "no root element found")
+	}
+
+	return linter.sortedFindings(), nil
+}
+
+// recoverXml reports the syntax error and returns the offset to resume the parsing from. The
+// element is still opened if the error is found in its start tag to avoid the further errors.
+func (linter *linter) recoverXml(err error, errorOffset int, tokenOffset int, stack *[]lintElement,
+	scope map[string]bool) int {
+	message := err.Error()
+	var syntaxError *xml.SyntaxError
+	if errors.As(err, &syntaxError) {
+		message = syntaxError.Msg
+	}
+	rule := "syntax"
+	if strings.Contains(message, "illegal character") {
+		rule = "invalid-char"
+	}
+	linter.report(max(errorOffset-1, tokenOffset), LintError, rule, "%s", message)
+
+	next := tokenOffset + 1
+	if matches := lintTagNameRegexp.FindSubmatch(linter.content[tokenOffset:]); matches != nil {
+		end := bytes.IndexByte(linter.content[tokenOffset:], '>')
+		if end < 0 {
+			return len(linter.content)
+		}
+		if linter.content[tokenOffset+end-1] != '/' {
+			if len(*stack) > 0 {
+				scope = (*stack)[len(*stack)-1].scope
+			}
+			line, column := linter.getPosition(tokenOffset)
+			*stack = append(*stack, lintElement{name: string(matches[1]), line: line, column: column, scope: scope})
+		}
+		return tokenOffset + end + 1
+	} else if len(linter.content) > tokenOffset && linter.content[tokenOffset] == '<' {
+		// the broken comments, declarations and closing tags are skipped
+		if end := bytes.IndexByte(linter.content[tokenOffset:], '>'); end >= 0 {
+			return tokenOffset + end + 1
+		}
+		return len(linter.content)
+	}
+
+	if pos := bytes.IndexByte(linter.content[max(next, errorOffset):], '<'); pos >= 0 {
+		return max(next, errorOffset) + pos
+	}
+
+	return len(linter.content)
+}
+
+// checkXmlAttrs reports the duplicate attributes and the undeclared prefixes. The namespace scope
+// of the element is returned.
+func (linter *linter) checkXmlAttrs(element xml.StartElement, line int, column int, scope map[string]bool) map[string]bool {
+	for _, attr := range element.Attr {
+		if attr.Name.Space == "xmlns" {
+			scope = maps.Clone(scope)
+			scope[attr.Name.Local] = true
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, attr := range element.Attr {
+		name := getRawXmlName(attr.Name)
+		if seen[name] {
+			linter.reportAt(line, column, LintError, "duplicate-attr",
+				"attribute %s is duplicated in element <%s>", name, getRawXmlName(element.Name))
+		}
+		seen[name] = true
+
+		if attr.Name.Space != "" && attr.Name.Space != "xmlns" && !scope[attr.Name.Space] {
+			linter.reportAt(line, column, LintError, "undeclared-prefix",
+				"namespace prefix %q of attribute %s is not declared", attr.Name.Space, name)
+		}
+	}
+
+	return scope
+}
+
+// closeElement pops the elements up to the closed one. The mismatched tags are reported, the
+// elements which end tags can be omitted are closed silently in HTML.
+func (linter *linter) closeElement(stack []lintElement, name string, offset int, isHtml bool) []lintElement {
+	index := len(stack) - 1
+	for index >= 0 && stack[index].name != name {
+		index--
+	}
+	if index < 0 {
+		linter.report(offset, LintError, "mismatched-tag", "closing tag </%s> has no matching opening tag", name)
+		return stack
+	}
+
+	for _, element := range stack[index+1:] {
+		if isHtml && slices.Contains(htmlOptionalEndElements, element.name) {
+			continue
+		}
+		if isHtml {
+			linter.reportAt(element.line, element.column, LintError, "unclosed-element",
+				"element <%s> is not closed", element.name)
+		} else {
+			linter.report(offset, LintError, "mismatched-tag", "element <%s> opened at %d:%d is closed by </%s>",
+				element.name, element.line, element.column, name)
+		}
+	}
+
+	return stack[:index]
+}
+
+// LintHtml reports the unclosed and deprecated elements, the duplicate attributes and ids.
+func LintHtml(reader io.Reader) ([]LintFinding, error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	linter := newLinter(content)
+	tokenizer := html.NewTokenizer(bytes.NewReader(content))
+	var stack []lintElement
+	ids := map[string]string{}
+	offset := 0
+
+	for {
+		tokenType := tokenizer.Next()
+		if tokenType == html.ErrorToken {
+			if err := tokenizer.Err(); err != io.EOF {
+				return nil, err
+			}
+			break
+		}
+		tokenOffset := offset
+		raw := tokenizer.Raw()
+		offset += len(raw)
+
+		switch tokenType {
+		case html.StartTagToken, html.SelfClosingTagToken:
+			line, column := linter.getPosition(tokenOffset)
+			rawName, hasAttr := tokenizer.TagName()
+			name := string(rawName)
+			if slices.Contains(htmlDeprecatedElements, name) {
+				linter.reportAt(line, column, LintWarning, "deprecated-element", "element <%s> is deprecated", name)
+			}
+
+			// the tokenizer drops the duplicate attributes, so they are found in the raw tag
+			seen := map[string]bool{}
+			for _, key := range getRawHtmlAttrNames(raw) {
+				if seen[key] {
+					linter.reportAt(line, column, LintError, "duplicate-attr",
+						"attribute %s is duplicated in element <%s>", key, name)
+				}
+				seen[key] = true
+			}
+
+			for hasAttr {
+				var key, value []byte
+				key, value, hasAttr = tokenizer.TagAttr()
+				if string(key) == "id" {
+					if previous, ok := ids[string(value)]; ok {
+						linter.reportAt(line, column, LintError, "duplicate-id",
+							"id %q is already used at %s", value, previous)
+					} else {
+						ids[string(value)] = fmt.Sprintf("%d:%d", line, column)
+					}
+				}
+			}
+
+			if tokenType == html.StartTagToken && !htmlVoidElements[name] {
+				stack = append(stack, lintElement{name: name, line: line, column: column})
+			}
+		case html.EndTagToken:
+			rawName, _ := tokenizer.TagName()
+			if name := string(rawName); !htmlVoidElements[name] {
+				stack = linter.closeElement(stack, name, tokenOffset, true)
+			}
+		}
+	}
+
+	for _, element := range stack {
+		if !slices.Contains(htmlOptionalEndElements, element.name) {
+			linter.reportAt(element.line, element.column, LintError, "unclosed-element",
+				"element <%s> is not closed", element.name)
+		}
+	}
+
+	return linter.sortedFindings(), nil
+}
+
+// getRawHtmlAttrNames returns the lowercase names of all the attributes of the start tag.
+func getRawHtmlAttrNames(tag []byte) []string {
+	isSpace := func(char byte) bool {
+		return char == ' ' || char == '\t' || char == '\n' || char == '\r' || char == '\f'
+	}
+
+	pos := 1
+	for pos < len(tag) && !isSpace(tag[pos]) && tag[pos] != '/' && tag[pos] != '>' {
+		pos++
+	}
+
+	var names []string
+	for pos < len(tag) && tag[pos] != '>' {
+		if isSpace(tag[pos]) || tag[pos] == '/' {
+			pos++
+			continue
+		}
+
+		start := pos
+		for pos < len(tag) && !isSpace(tag[pos]) && tag[pos] != '=' && tag[pos] != '>' &&
+			(tag[pos] != '/' || pos == start) {
+			pos++
+		}
+		names = append(names, strings.ToLower(string(tag[start:pos])))
+
+		for pos < len(tag) && isSpace(tag[pos]) {
+			pos++
+		}
+		if pos >= len(tag) || tag[pos] != '=' {
+			continue
+		}
+		pos++
+		for pos < len(tag) && isSpace(tag[pos]) {
+			pos++
+		}
+		if pos < len(tag) && (tag[pos] == '"' || tag[pos] == '\'') {
+			end := bytes.IndexByte(tag[pos+1:], tag[pos])
+			if end < 0 {
+				break
+			}
+			pos += end + 2
+		} else {
+			for pos < len(tag) && !isSpace(tag[pos]) && tag[pos] != '>' {
+				pos++
+			}
+		}
+	}
+
+	return names
+}
+
+func getRawXmlName(name xml.Name) string {
+	if name.Space == "" {
+		return name.Local
+	}
+
+	return name.Space + ":" + name.Local
+}
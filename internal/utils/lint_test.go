@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func formatFindings(findings []LintFinding) string {
+	var result strings.Builder
+	for _, finding := range findings {
+		_, _ = fmt.Fprintf(&result, "%d:%d %s %s: %s\n", finding.Line, finding.Column, finding.Severity,
+			finding.Rule, finding.Message)
+	}
+	return result.String()
+}
+
+func TestLintXml(t *testing.T) {
+	input := `<?xml version="1.0"?>
+<root xmlns:a="urn:a">
+  <item id="1" id="2">x &nbsp; y</item>
+  <b:item a:x="1" c:y="2"/>
+  <list><entry></list>
+  <bad attr>text</bad>
+  <text>` + "\x01" + `</text>
+</root>
+<extra/>
+`
+	findings, err := LintXml(strings.NewReader(input))
+	assert.Nil(t, err)
+	expected := `3:3 error duplicate-attr: attribute id is duplicated in element <item>
+3:30 error syntax: invalid character entity &nbsp;
+4:3 error undeclared-prefix: namespace prefix "c" of attribute c:y is not declared
+4:3 error undeclared-prefix: namespace prefix "b" of element <b:item> is not declared
+5:16 error mismatched-tag: element <entry> opened at 5:9 is closed by </list>
+6:12 error syntax: attribute name without = in element
+7:9 error invalid-char: illegal character code U+0001
+9:1 error multiple-roots: element <extra> is outside of the root element
+`
+	assert.Equal(t, expected, formatFindings(findings))
+
+	findings, err = LintXml(strings.NewReader("<a>\n  <b></c>\n"))
+	assert.Nil(t, err)
+	assert.Equal(t, "1:1 error unclosed-element: element <a> is not closed\n"+
+		"2:3 error unclosed-element: element <b> is not closed\n"+
+		"2:6 error mismatched-tag: closing tag </c> has no matching opening tag\n", formatFindings(findings))
+
+	findings, err = LintXml(strings.NewReader(`<a xmlns:x="urn:x"><x:b x:c="1"/></a>`))
+	assert.Nil(t, err)
+	assert.Empty(t, findings)
+
+	findings, err = LintXml(strings.NewReader("text"))
+	assert.Nil(t, err)
+	assert.Equal(t, "1:1 error text-outside-root: text is outside of the root element\n"+
+		"1:1 error no-root: no root element found\n", formatFindings(findings))
+}
+
+func TestLintHtml(t *testing.T) {
+	input := `<!DOCTYPE html>
+<html><body>
+<center><font color=red>Hi</font></center>
+<div id="a"><span id="a" class="x" CLASS='y'>t<br></div>
+<ul><li>one<li>two</ul>
+<p>para
+<section>
+</body></html>
+`
+	findings, err := LintHtml(strings.NewReader(input))
+	assert.Nil(t, err)
+	expected := `3:1 warning deprecated-element: element <center> is deprecated
+3:9 warning deprecated-element: element <font> is deprecated
+4:13 error duplicate-attr: attribute class is duplicated in element <span>
+4:13 error duplicate-id: id "a" is already used at 4:1
+4:13 error unclosed-element: element <span> is not closed
+7:1 error unclosed-element: element <section> is not closed
+`
+	assert.Equal(t, expected, formatFindings(findings))
+
+	assert.Equal(t, []string{"a", "b", "c", "d"}, getRawHtmlAttrNames([]byte(`<x a="1>" B=2 c d='/'/>`)))
+}
@@ -0,0 +1,226 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"golang.org/x/text/encoding/ianaindex"
+)
+
+// LosslessNode is the node of the order-preserving JSON representation of the XML document.
+// The type is one of element, text, cdata, comment, pi and directive. The names keep the
+// prefixes, the namespace of the elements is resolved, and the children of the elements
+// written with the start and the end tags are present even if empty.
+type LosslessNode struct {
+	Type       string          `json:"type"`
+	Name       string          `json:"name,omitempty"`
+	Namespace  string          `json:"namespace,omitempty"`
+	Attributes [][2]string     `json:"attributes,omitempty"`
+	Children   *[]LosslessNode `json:"children,omitempty"`
+	Value      string          `json:"value,omitempty"`
+}
+
+var xmlEncodingRegexp = regexp.MustCompile(`^\s*<\?xml[^>]*?\sencoding\s*=\s*["']([^"']+)["']`)
+
+// XmlToLossless converts the XML document into the sequence of the top-level nodes. All the
+// nodes are kept in the document order, so that LosslessToXml gives back the same document.
+func XmlToLossless(reader io.Reader) ([]LosslessNode, error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	// the document is converted beforehand to match the offsets of the decoder with the content
+	if matches := xmlEncodingRegexp.FindSubmatch(content); matches != nil {
+		charsetReader, err := getCharsetReader(string(matches[1]), bytes.NewReader(content))
+		if err != nil {
+			return nil, err
+		}
+		if content, err = io.ReadAll(charsetReader); err != nil {
+			return nil, err
+		}
+	}
+
+	source := newSourceRecorder(bytes.NewReader(content))
+	decoder := xml.NewDecoder(source)
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) {
+		return input, nil
+	}
+
+	type openElement struct {
+		node        LosslessNode
+		namespaces  map[string]string
+		selfClosing bool
+	}
+	stack := []openElement{{node: LosslessNode{Children: &[]LosslessNode{}},
+		namespaces: map[string]string{"xml": xmlNamespace}}}
+	addNode := func(node LosslessNode) {
+		parent := stack[len(stack)-1].node.Children
+		*parent = append(*parent, node)
+	}
+
+	for {
+		start := decoder.InputOffset()
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, newXmlDiagnostic(err, source, decoder)
+		}
+		raw := content[start:decoder.InputOffset()]
+
+		switch typedToken := token.(type) {
+		case xml.StartElement:
+			namespaces := map[string]string{}
+			for prefix, uri := range stack[len(stack)-1].namespaces {
+				namespaces[prefix] = uri
+			}
+			node := LosslessNode{Type: "element", Name: getQualifiedName(typedToken.Name.Space,
+				typedToken.Name.Local, ":"), Children: &[]LosslessNode{}}
+			for _, attr := range typedToken.Attr {
+				switch {
+				case attr.Name.Space == "" && attr.Name.Local == "xmlns":
+					namespaces[""] = attr.Value
+				case attr.Name.Space == "xmlns":
+					namespaces[attr.Name.Local] = attr.Value
+				}
+				node.Attributes = append(node.Attributes,
+					[2]string{getQualifiedName(attr.Name.Space, attr.Name.Local, ":"), attr.Value})
+			}
+			node.Namespace = namespaces[typedToken.Name.Space]
+			stack = append(stack, openElement{node: node, namespaces: namespaces,
+				selfClosing: bytes.HasSuffix(raw, []byte("/>"))})
+		case xml.EndElement:
+			name := getQualifiedName(typedToken.Name.Space, typedToken.Name.Local, ":")
+			if len(stack) == 1 {
+				line, _ := decoder.InputPos()
+				return nil, newXmlDiagnostic(&xml.SyntaxError{Msg: "unexpected end element </" + name + ">",
+					Line: line}, source, decoder)
+			}
+			element := stack[len(stack)-1]
+			if element.node.Name != name {
+				line, _ := decoder.InputPos()
+				return nil, newXmlDiagnostic(&xml.SyntaxError{Msg: fmt.Sprintf("element <%s> closed by </%s>",
+					element.node.Name, name), Line: line}, source, decoder)
+			}
+			if element.selfClosing {
+				element.node.Children = nil
+			}
+			stack = stack[:len(stack)-1]
+			addNode(element.node)
+		case xml.CharData:
+			if bytes.HasPrefix(raw, []byte("<![CDATA[")) {
+				addNode(LosslessNode{Type: "cdata", Value: string(typedToken)})
+			} else {
+				addNode(LosslessNode{Type: "text", Value: string(typedToken)})
+			}
+		case xml.Comment:
+			addNode(LosslessNode{Type: "comment", Value: string(typedToken)})
+		case xml.ProcInst:
+			addNode(LosslessNode{Type: "pi", Name: typedToken.Target, Value: string(typedToken.Inst)})
+		case xml.Directive:
+			addNode(LosslessNode{Type: "directive", Value: string(typedToken)})
+		}
+	}
+
+	if len(stack) > 1 {
+		line, _ := decoder.InputPos()
+		return nil, newXmlDiagnostic(&xml.SyntaxError{Msg: "unexpected EOF", Line: line}, source, decoder)
+	}
+
+	return *stack[0].node.Children, nil
+}
+
+// LosslessToXml writes the XML document from its lossless JSON representation. The document
+// is encoded using the encoding of the XML declaration.
+func LosslessToXml(reader io.Reader, writer io.Writer) error {
+	var nodes []LosslessNode
+	decoder := json.NewDecoder(reader)
+	if err := decoder.Decode(&nodes); err != nil {
+		return fmt.Errorf("invalid lossless JSON: %w", err)
+	}
+
+	output := new(bytes.Buffer)
+	for _, node := range nodes {
+		if err := writeLosslessNode(output, node); err != nil {
+			return err
+		}
+	}
+
+	if len(nodes) > 0 && nodes[0].Type == "pi" && nodes[0].Name == "xml" {
+		if matches := xmlEncodingRegexp.FindStringSubmatch("<?xml " + nodes[0].Value); matches != nil &&
+			!strings.EqualFold(matches[1], "utf-8") {
+			encoding, err := ianaindex.MIME.Encoding(matches[1])
+			if err != nil {
+				return err
+			}
+			if encoding != nil {
+				_, err = encoding.NewEncoder().Writer(writer).Write(output.Bytes())
+				return err
+			}
+		}
+	}
+
+	_, err := writer.Write(output.Bytes())
+	return err
+}
+
+func writeLosslessNode(output *bytes.Buffer, node LosslessNode) error {
+	switch node.Type {
+	case "element":
+		if node.Name == "" {
+			return fmt.Errorf("invalid lossless JSON: element without name")
+		}
+		output.WriteString("<" + node.Name)
+		for _, attr := range node.Attributes {
+			output.WriteString(" " + attr[0] + `="` + escapeLosslessText(attr[1], true) + `"`)
+		}
+		if node.Children == nil {
+			output.WriteString("/>")
+			return nil
+		}
+		output.WriteString(">")
+		for _, child := range *node.Children {
+			if err := writeLosslessNode(output, child); err != nil {
+				return err
+			}
+		}
+		output.WriteString("</" + node.Name + ">")
+	case "text":
+		output.WriteString(escapeLosslessText(node.Value, false))
+	case "cdata":
+		output.WriteString("<![CDATA[" + node.Value + "]]>")
+	case "comment":
+		output.WriteString("<!--" + node.Value + "-->")
+	case "pi":
+		output.WriteString("<?" + node.Name)
+		if node.Value != "" {
+			output.WriteString(" " + node.Value)
+		}
+		output.WriteString("?>")
+	case "directive":
+		output.WriteString("<!" + node.Value + ">")
+	default:
+		return fmt.Errorf("invalid lossless JSON: unknown node type %q", node.Type)
+	}
+
+	return nil
+}
+
+// escapeLosslessText escapes the markup characters, and the whitespace characters which would be
+// normalized by the parsers otherwise.
+func escapeLosslessText(text string, isAttr bool) string {
+	replacements := []string{"&", "&amp;", "<", "&lt;", ">", "&gt;"}
+	if isAttr {
+		replacements = append(replacements, `"`, "&quot;", "\n", "&#10;", "\t", "&#9;", "\r", "&#13;")
+	} else {
+		replacements = append(replacements, "\r", "&#13;")
+	}
+
+	return strings.NewReplacer(replacements...).Replace(text)
+}
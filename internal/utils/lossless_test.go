@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXmlToLossless(t *testing.T) {
+	input := `<?xml version="1.0"?>` + "\n" + `<!-- c --><a xmlns:g="urn:g" id="1"><g:b/><c></c>x &amp; <![CDATA[<y>]]>` +
+		`<?pi data?></a>`
+	nodes, err := XmlToLossless(strings.NewReader(input))
+	assert.Nil(t, err)
+
+	data := new(bytes.Buffer)
+	encoder := json.NewEncoder(data)
+	encoder.SetEscapeHTML(false)
+	assert.Nil(t, encoder.Encode(nodes))
+	assert.Equal(t, `[{"type":"pi","name":"xml","value":"version=\"1.0\""},{"type":"text","value":"\n"},`+
+		`{"type":"comment","value":" c "},{"type":"element","name":"a","attributes":[["xmlns:g","urn:g"],["id","1"]],`+
+		`"children":[{"type":"element","name":"g:b","namespace":"urn:g"},{"type":"element","name":"c","children":[]},`+
+		`{"type":"text","value":"x & "},{"type":"cdata","value":"<y>"},`+
+		`{"type":"pi","name":"pi","value":"data"}]}]`+"\n", data.String())
+
+	output := new(bytes.Buffer)
+	assert.Nil(t, LosslessToXml(data, output))
+	assert.Equal(t, input, output.String())
+
+	_, err = XmlToLossless(strings.NewReader("<a><b></a>"))
+	assert.ErrorContains(t, err, "element <b> closed by </a>")
+	_, err = XmlToLossless(strings.NewReader("<a>"))
+	assert.ErrorContains(t, err, "unexpected EOF")
+
+	err = LosslessToXml(strings.NewReader(`[{"type":"node"}]`), output)
+	assert.EqualError(t, err, `invalid lossless JSON: unknown node type "node"`)
+}
+
+func TestLosslessRoundTrip(t *testing.T) {
+	fileNames, err := filepath.Glob(filepath.Join("..", "..", "test", "data", "xml", "*.xml"))
+	assert.Nil(t, err)
+
+	for _, fileName := range fileNames {
+		content, err := os.ReadFile(fileName)
+		assert.Nil(t, err)
+		if bytes.Contains(content, []byte("windows-1251")) {
+			// the content of the sample is not a valid windows-1251 text, so it cannot be encoded back
+			continue
+		}
+		nodes, err := XmlToLossless(bytes.NewReader(content))
+		if err != nil {
+			// the samples of the formatter include the documents which are not well-formed
+			continue
+		}
+
+		data, err := json.Marshal(nodes)
+		assert.Nil(t, err)
+		output := new(bytes.Buffer)
+		assert.Nil(t, LosslessToXml(bytes.NewReader(data), output), fileName)
+
+		// the whitespace between the attributes and the quotes of the values are normalized
+		roundTrip, err := XmlToLossless(output)
+		assert.Nil(t, err, fileName)
+		assert.Equal(t, nodes, roundTrip, fileName)
+	}
+}
+
+func TestLosslessEncoding(t *testing.T) {
+	input := "<?xml version='1.0' encoding='ISO-8859-1'?><a>caf\xe9</a>"
+	nodes, err := XmlToLossless(strings.NewReader(input))
+	assert.Nil(t, err)
+	assert.Equal(t, "café", (*nodes[1].Children)[0].Value)
+
+	data, err := json.Marshal(nodes)
+	assert.Nil(t, err)
+	output := new(bytes.Buffer)
+	assert.Nil(t, LosslessToXml(bytes.NewReader(data), output))
+	assert.Equal(t, input, output.String())
+}
@@ -0,0 +1,502 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/net/html"
+)
+
+// htmlBlockTags are the elements which start a new block of the text.
+var htmlBlockTags = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "body": true, "caption": true,
+	"center": true, "dd": true, "details": true, "dialog": true, "dir": true, "div": true, "dl": true,
+	"dt": true, "fieldset": true, "figcaption": true, "figure": true, "footer": true, "form": true,
+	"h1": true, "h2": true, "h3": true, "h4": true, "h5": true, "h6": true, "header": true, "hgroup": true,
+	"hr": true, "html": true, "legend": true, "li": true, "main": true, "menu": true, "nav": true,
+	"ol": true, "p": true, "pre": true, "section": true, "summary": true, "table": true, "tbody": true,
+	"td": true, "tfoot": true, "th": true, "thead": true, "tr": true, "ul": true,
+}
+
+// htmlSkippedTags are the elements without the content to be read.
+var htmlSkippedTags = map[string]bool{
+	"head": true, "script": true, "style": true, "template": true, "noscript": true, "iframe": true,
+	"object": true, "embed": true, "canvas": true, "svg": true, "math": true, "select": true,
+	"textarea": true, "button": true, "input": true,
+}
+
+var (
+	htmlSpaceRegexp          = regexp.MustCompile(`[ \t\r\n\f]+`)
+	markdownLineStartRegexp  = regexp.MustCompile(`^(#{1,6}(?: |$)|[-+] |>|=+$|-+$)`)
+	markdownListNumberRegexp = regexp.MustCompile(`^(\d{1,9})([.)])( |$)`)
+	markdownBackticksRegexp  = regexp.MustCompile("`+")
+)
+
+// HtmlToMarkdown converts the HTML document to CommonMark. The tables are written using
+// the GitHub Flavored Markdown syntax.
+func HtmlToMarkdown(reader io.Reader, writer io.Writer) error {
+	doc, err := html.Parse(reader)
+	if err != nil {
+		return err
+	}
+
+	return writeMarkdown(writer, []*html.Node{doc})
+}
+
+// writeMarkdown writes the nodes as the consecutive blocks of the Markdown document.
+func writeMarkdown(writer io.Writer, nodes []*html.Node) error {
+	blocks := &markdownBlocks{}
+	for _, node := range nodes {
+		blocks.add(node)
+	}
+
+	result := blocks.result()
+	if len(result) == 0 {
+		return nil
+	}
+	_, err := io.WriteString(writer, strings.Join(result, "\n\n")+"\n")
+	return err
+}
+
+// markdownBlocks collects the Markdown blocks of the sibling nodes. The inline content
+// between the block elements forms the paragraphs.
+type markdownBlocks struct {
+	blocks []string
+	inline strings.Builder
+}
+
+func (blocks *markdownBlocks) result() []string {
+	blocks.flush()
+	return blocks.blocks
+}
+
+func (blocks *markdownBlocks) addChildren(node *html.Node) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		blocks.add(child)
+	}
+}
+
+func (blocks *markdownBlocks) addBlock(block string) {
+	blocks.flush()
+	if strings.TrimSpace(block) != "" {
+		blocks.blocks = append(blocks.blocks, block)
+	}
+}
+
+// flush finishes the paragraph of the collected inline content.
+func (blocks *markdownBlocks) flush() {
+	paragraph := formatMarkdownParagraph(blocks.inline.String())
+	blocks.inline.Reset()
+	if paragraph != "" {
+		blocks.blocks = append(blocks.blocks, paragraph)
+	}
+}
+
+func (blocks *markdownBlocks) add(node *html.Node) {
+	switch node.Type {
+	case html.DocumentNode:
+		blocks.addChildren(node)
+		return
+	case html.TextNode:
+		blocks.inline.WriteString(escapeMarkdown(htmlSpaceRegexp.ReplaceAllString(node.Data, " ")))
+		return
+	case html.ElementNode:
+	default:
+		return
+	}
+
+	if htmlSkippedTags[node.Data] {
+		return
+	}
+	if !htmlBlockTags[node.Data] {
+		blocks.inline.WriteString(getMarkdownInline(node))
+		return
+	}
+
+	switch node.Data {
+	case "h1", "h2", "h3", "h4", "h5", "h6":
+		text := strings.ReplaceAll(formatMarkdownParagraph(getMarkdownChildrenInline(node)), "\\\n", " ")
+		if text != "" {
+			level, _ := strconv.Atoi(node.Data[1:])
+			blocks.addBlock(strings.Repeat("#", level) + " " + text)
+		}
+	case "hr":
+		blocks.addBlock("---")
+	case "pre":
+		blocks.addBlock(formatMarkdownCodeBlock(node))
+	case "blockquote":
+		blocks.addBlock(prefixLines(getMarkdownBlocks(node), "> ", ">"))
+	case "ul", "ol", "menu", "dir":
+		blocks.addBlock(formatMarkdownList(node))
+	case "table":
+		blocks.addBlock(formatMarkdownTable(node))
+	default:
+		blocks.flush()
+		blocks.addChildren(node)
+		blocks.flush()
+	}
+}
+
+func getMarkdownBlocks(node *html.Node) string {
+	blocks := &markdownBlocks{}
+	blocks.addChildren(node)
+	return strings.Join(blocks.result(), "\n\n")
+}
+
+// formatMarkdownParagraph collapses the whitespace of the inline content. The line breaks
+// are written as the hard line breaks.
+func formatMarkdownParagraph(inline string) string {
+	var lines []string
+	for _, line := range strings.Split(inline, "\n") {
+		line = strings.TrimSpace(htmlSpaceRegexp.ReplaceAllString(line, " "))
+		if line == "" {
+			continue
+		}
+		if markdownLineStartRegexp.MatchString(line) {
+			line = "\\" + line
+		} else if matches := markdownListNumberRegexp.FindStringSubmatchIndex(line); matches != nil {
+			line = line[:matches[3]] + "\\" + line[matches[3]:]
+		}
+		lines = append(lines, line)
+	}
+
+	return strings.Join(lines, "\\\n")
+}
+
+func getMarkdownChildrenInline(node *html.Node) string {
+	result := new(strings.Builder)
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		switch child.Type {
+		case html.TextNode:
+			result.WriteString(escapeMarkdown(htmlSpaceRegexp.ReplaceAllString(child.Data, " ")))
+		case html.ElementNode:
+			if !htmlSkippedTags[child.Data] {
+				result.WriteString(getMarkdownInline(child))
+			}
+		}
+	}
+
+	return result.String()
+}
+
+// getMarkdownInline returns the inline content of the element, the line breaks are returned
+// as newlines. The nested block elements are written as their inline content.
+func getMarkdownInline(node *html.Node) string {
+	switch node.Data {
+	case "br":
+		return "\n"
+	case "img":
+		src := getHtmlAttr(node, "src")
+		if src == "" {
+			return ""
+		}
+		return "![" + escapeMarkdown(getHtmlAttr(node, "alt")) + "](" + formatMarkdownDestination(src, node) + ")"
+	case "code", "kbd", "samp", "tt":
+		return formatMarkdownCodeSpan(htmlSpaceRegexp.ReplaceAllString(getHtmlText(node), " "))
+	}
+
+	content := getMarkdownChildrenInline(node)
+	switch node.Data {
+	case "em", "i", "cite", "dfn", "var":
+		return wrapMarkdownInline(content, "*")
+	case "strong", "b":
+		return wrapMarkdownInline(content, "**")
+	case "del", "s", "strike":
+		return wrapMarkdownInline(content, "~~")
+	case "a":
+		href := getHtmlAttr(node, "href")
+		text := strings.TrimSpace(strings.ReplaceAll(content, "\n", " "))
+		if href == "" || strings.HasPrefix(strings.ToLower(href), "javascript:") {
+			return content
+		}
+		if text == "" {
+			text = escapeMarkdown(href)
+		}
+		return "[" + text + "](" + formatMarkdownDestination(href, node) + ")"
+	}
+
+	return content
+}
+
+// wrapMarkdownInline puts the emphasis markers around the content, the surrounding spaces
+// are moved out since the emphasis can't start or end with a space.
+func wrapMarkdownInline(content string, marker string) string {
+	trimmed := strings.TrimSpace(content)
+	if trimmed == "" {
+		return content
+	}
+	start := strings.Index(content, trimmed)
+
+	return content[:start] + marker + trimmed + marker + content[start+len(trimmed):]
+}
+
+func formatMarkdownDestination(url string, node *html.Node) string {
+	url = strings.NewReplacer(" ", "%20", "(", "%28", ")", "%29").Replace(strings.TrimSpace(url))
+	if title := getHtmlAttr(node, "title"); title != "" {
+		return url + ` "` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(title) + `"`
+	}
+	return url
+}
+
+func formatMarkdownCodeSpan(code string) string {
+	if code == "" {
+		return ""
+	}
+	fence := "`"
+	for _, backticks := range markdownBackticksRegexp.FindAllString(code, -1) {
+		if len(backticks) >= len(fence) {
+			fence = strings.Repeat("`", len(backticks)+1)
+		}
+	}
+	if strings.HasPrefix(code, "`") || strings.HasSuffix(code, "`") {
+		code = " " + code + " "
+	}
+
+	return fence + code + fence
+}
+
+func formatMarkdownCodeBlock(node *html.Node) string {
+	code := strings.TrimSuffix(getHtmlText(node), "\n")
+	language := getHtmlCodeLanguage(node)
+	for child := node.FirstChild; child != nil && language == ""; child = child.NextSibling {
+		if child.Type == html.ElementNode && child.Data == "code" {
+			language = getHtmlCodeLanguage(child)
+		}
+	}
+
+	fence := "```"
+	for _, backticks := range markdownBackticksRegexp.FindAllString(code, -1) {
+		if len(backticks) >= len(fence) {
+			fence = strings.Repeat("`", len(backticks)+1)
+		}
+	}
+
+	return fence + language + "\n" + code + "\n" + fence
+}
+
+func getHtmlCodeLanguage(node *html.Node) string {
+	for _, class := range strings.Fields(getHtmlAttr(node, "class")) {
+		for _, prefix := range []string{"language-", "lang-"} {
+			if strings.HasPrefix(class, prefix) {
+				return strings.TrimPrefix(class, prefix)
+			}
+		}
+	}
+	return ""
+}
+
+// formatMarkdownList writes the list items, the continuation lines are indented by the width
+// of the item marker.
+func formatMarkdownList(node *html.Node) string {
+	ordered := node.Data == "ol"
+	number := 1
+	if start, err := strconv.Atoi(getHtmlAttr(node, "start")); err == nil && ordered {
+		number = start
+	}
+
+	var items []string
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != html.ElementNode || child.Data != "li" {
+			continue
+		}
+		marker := "- "
+		if ordered {
+			marker = fmt.Sprintf("%d. ", number)
+			number++
+		}
+
+		blocks := &markdownBlocks{}
+		blocks.addChildren(child)
+		content := ""
+		for index, block := range blocks.result() {
+			switch {
+			case index == 0:
+				content = block
+			case strings.HasPrefix(block, "- ") || strings.HasPrefix(block, "1. "):
+				// the nested lists are kept close to the text of the item, only the bullet lists and
+				// the ordered lists starting with 1 can interrupt the paragraph
+				content += "\n" + block
+			default:
+				content += "\n\n" + block
+			}
+		}
+		indent := strings.Repeat(" ", len(marker))
+		items = append(items, marker+strings.TrimPrefix(prefixLines(content, indent, ""), indent))
+	}
+
+	return strings.Join(items, "\n")
+}
+
+// prefixLines prefixes every line of the text, the empty lines get the empty prefix.
+func prefixLines(text string, prefix string, emptyPrefix string) string {
+	lines := strings.Split(text, "\n")
+	for index, line := range lines {
+		if line == "" {
+			lines[index] = emptyPrefix
+		} else {
+			lines[index] = prefix + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// formatMarkdownTable writes the table using the GFM syntax. The first row is the header,
+// the columns are aligned according to the align attributes of its cells.
+func formatMarkdownTable(node *html.Node) string {
+	var rows [][]string
+	var alignments []string
+	for _, row := range getHtmlTableRows(node) {
+		var cells []string
+		for cell := row.FirstChild; cell != nil; cell = cell.NextSibling {
+			if cell.Type != html.ElementNode || (cell.Data != "td" && cell.Data != "th") {
+				continue
+			}
+			text := strings.ReplaceAll(formatMarkdownParagraph(getMarkdownChildrenInline(cell)), "\\\n", "<br>")
+			cells = append(cells, strings.ReplaceAll(text, "|", `\|`))
+			if len(rows) == 0 {
+				alignments = append(alignments, getHtmlCellAlignment(cell))
+			}
+		}
+		rows = append(rows, cells)
+	}
+	if len(rows) == 0 {
+		return ""
+	}
+
+	columns := 0
+	for _, row := range rows {
+		columns = max(columns, len(row))
+	}
+	widths := make([]int, columns)
+	for _, row := range rows {
+		for index, cell := range row {
+			widths[index] = max(widths[index], utf8.RuneCountInString(cell), 3)
+		}
+	}
+
+	formatRow := func(cells []string) string {
+		line := "|"
+		for index := 0; index < columns; index++ {
+			cell := ""
+			if index < len(cells) {
+				cell = cells[index]
+			}
+			line += " " + cell + strings.Repeat(" ", widths[index]-utf8.RuneCountInString(cell)) + " |"
+		}
+		return line
+	}
+
+	lines := []string{formatRow(rows[0])}
+	separator := "|"
+	for index, width := range widths {
+		alignment := ""
+		if index < len(alignments) {
+			alignment = alignments[index]
+		}
+		switch alignment {
+		case "left":
+			separator += " :" + strings.Repeat("-", width-1) + " |"
+		case "right":
+			separator += " " + strings.Repeat("-", width-1) + ": |"
+		case "center":
+			separator += " :" + strings.Repeat("-", width-2) + ": |"
+		default:
+			separator += " " + strings.Repeat("-", width) + " |"
+		}
+	}
+	lines = append(lines, separator)
+	for _, row := range rows[1:] {
+		lines = append(lines, formatRow(row))
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// getHtmlTableRows returns the rows of the table, the rows of the nested tables are skipped.
+func getHtmlTableRows(table *html.Node) []*html.Node {
+	var rows []*html.Node
+	var collect func(node *html.Node)
+	collect = func(node *html.Node) {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != html.ElementNode {
+				continue
+			}
+			switch child.Data {
+			case "tr":
+				rows = append(rows, child)
+			case "thead", "tbody", "tfoot":
+				collect(child)
+			}
+		}
+	}
+	collect(table)
+
+	return rows
+}
+
+func getHtmlCellAlignment(cell *html.Node) string {
+	if align := strings.ToLower(getHtmlAttr(cell, "align")); align != "" {
+		return align
+	}
+	for _, declaration := range strings.Split(getHtmlAttr(cell, "style"), ";") {
+		if property, value, found := strings.Cut(declaration, ":"); found &&
+			strings.EqualFold(strings.TrimSpace(property), "text-align") {
+			return strings.ToLower(strings.TrimSpace(value))
+		}
+	}
+	return ""
+}
+
+func getHtmlAttr(node *html.Node, name string) string {
+	for _, attr := range node.Attr {
+		if attr.Namespace == "" && attr.Key == name {
+			return attr.Val
+		}
+	}
+	return ""
+}
+
+// getHtmlText returns the text content of the node as is.
+func getHtmlText(node *html.Node) string {
+	if node.Type == html.TextNode {
+		return node.Data
+	}
+	if node.Type == html.ElementNode && node.Data == "br" {
+		return "\n"
+	}
+
+	result := new(strings.Builder)
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		result.WriteString(getHtmlText(child))
+	}
+	return result.String()
+}
+
+// escapeMarkdown escapes the characters of the text which would be read as the Markdown syntax.
+// The underscores inside the words are kept as is since they can't start the emphasis.
+func escapeMarkdown(text string) string {
+	result := new(strings.Builder)
+	runes := []rune(text)
+	isWordChar := func(index int) bool {
+		return index >= 0 && index < len(runes) && (unicode.IsLetter(runes[index]) || unicode.IsDigit(runes[index]))
+	}
+
+	for index, char := range runes {
+		switch char {
+		case '\\', '`', '*', '[', ']', '<':
+			result.WriteRune('\\')
+		case '_':
+			if !isWordChar(index-1) || !isWordChar(index+1) {
+				result.WriteRune('\\')
+			}
+		}
+		result.WriteRune(char)
+	}
+
+	return result.String()
+}
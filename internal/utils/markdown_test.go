@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHtmlToMarkdown(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`<h2>The <em>title</em></h2><p>Some <strong>bold</strong> and <i>italic </i>text.</p>`,
+			"## The *title*\n\nSome **bold** and *italic* text.\n"},
+		{`<head><title>T</title><style>p {}</style></head><body><script>x()</script><p>Text</p></body>`, "Text\n"},
+		{`<p>A <a href="/a b" title="Page">link</a>, <a href="https://example.com"></a> and <img src="i.png" alt="pic">` +
+			`</p>`, "A [link](/a%20b \"Page\"), [https://example.com](https://example.com) and ![pic](i.png)\n"},
+		{`<p>Line<br>Next <code>a` + "`" + `b</code></p>`, "Line\\\nNext ``a`b``\n"},
+		{`<p>*not* [a link] snake_case _x_</p><p>1. not a list</p><p># not a heading</p>`,
+			"\\*not\\* \\[a link\\] snake_case \\_x\\_\n\n1\\. not a list\n\n\\# not a heading\n"},
+		{`<ul><li>One</li><li>Two<ul><li>Nested</li></ul></li></ul><ol start="3"><li><p>Three</p><p>More</p></li></ol>`,
+			"- One\n- Two\n  - Nested\n\n3. Three\n\n   More\n"},
+		{`<blockquote><p>Quote</p><blockquote>Nested</blockquote></blockquote>`, "> Quote\n>\n> > Nested\n"},
+		{"<pre><code class=\"language-go\">func main() {\n\tprintln(\"```\")\n}\n</code></pre>",
+			"````go\nfunc main() {\n\tprintln(\"```\")\n}\n````\n"},
+		{`<table><tr><th>Name</th><th align="right">Price</th></tr><tr><td>Pen | ink</td><td>1.50</td></tr>` +
+			`<tr><td>Book</td></tr></table>`,
+			"| Name       | Price |\n| ---------- | ----: |\n| Pen \\| ink | 1.50  |\n| Book       |       |\n"},
+		{`<div>Text <span>inline</span><hr><section><del>old</del></section></div>`, "Text inline\n\n---\n\n~~old~~\n"},
+		{``, ""},
+	}
+
+	for _, test := range tests {
+		output := new(bytes.Buffer)
+		assert.Nil(t, HtmlToMarkdown(strings.NewReader(test.input), output))
+		assert.Equal(t, test.expected, output.String(), test.input)
+	}
+}
+
+func TestCSSQueryMarkdown(t *testing.T) {
+	input := `<html><body><nav>Menu</nav><article><h1>Title</h1><p>Text</p></article><p>Footer</p></body></html>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(input))
+	assert.Nil(t, err)
+
+	output := new(bytes.Buffer)
+	assert.Nil(t, queryCSS(doc, output, "article", "", QueryOptions{Markdown: true}))
+	assert.Equal(t, "# Title\n\nText\n", output.String())
+}
@@ -0,0 +1,381 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/antchfx/xmlquery"
+	"golang.org/x/net/html"
+	"golang.org/x/term"
+)
+
+const replHistorySize = 1000
+
+const replHelp = `Enter an XPath query or a CSS selector depending on the mode. Commands:
+  :xpath        evaluate the XPath queries (default)
+  :css          evaluate the CSS selectors
+  :attr [name]  extract the attribute value for the CSS selectors, reset if omitted
+  :node         toggle the node content output instead of text (-n)
+  :json         toggle the JSON output (-j)
+  :single       toggle the extraction of a single node (-e)
+  :help         show this help
+  :quit         exit (Ctrl-D)
+`
+
+var replCommands = []string{":xpath", ":css", ":attr", ":node", ":json", ":single", ":help", ":quit"}
+
+var xpathFunctionNames = []string{"boolean(", "ceiling(", "concat(", "contains(", "count(", "ends-with(",
+	"false()", "floor(", "lang(", "last()", "local-name(", "lower-case(", "matches(", "name(",
+	"namespace-uri(", "normalize-space(", "not(", "number(", "position()", "replace(", "reverse(", "round(",
+	"starts-with(", "string(", "string-join(", "string-length(", "substring(", "substring-after(",
+	"substring-before(", "sum(", "translate(", "true()", "upper-case("}
+
+// QuerySession keeps the parsed document to evaluate the queries against it without parsing
+// the document again.
+type QuerySession struct {
+	doc      *xmlquery.Node
+	jsonTree *jsonTree
+	cssDoc   *goquery.Document
+	elements []string
+	attrs    []string
+}
+
+// NewQuerySession parses the document of the given type. JSON and YAML documents are mapped
+// into the virtual element trees.
+func NewQuerySession(reader io.Reader, contentType ContentType) (*QuerySession, error) {
+	session := &QuerySession{}
+
+	switch contentType {
+	case ContentJson, ContentYaml:
+		if contentType == ContentYaml {
+			data, err := YamlToJSON(reader)
+			if err != nil {
+				return nil, fmt.Errorf("error while parsing YAML: %w", err)
+			}
+			reader = bytes.NewReader(data)
+		}
+		tree, err := parseJSONTree(reader)
+		if err != nil {
+			return nil, err
+		}
+		session.jsonTree, session.doc = tree, tree.doc
+	case ContentHtml:
+		root, err := html.Parse(reader)
+		if err != nil {
+			return nil, err
+		}
+		session.doc = htmlToNode(root)
+		session.cssDoc = goquery.NewDocumentFromNode(root)
+	default:
+		doc, err := parseXml(reader)
+		if err != nil {
+			return nil, err
+		}
+		session.doc = doc
+	}
+
+	elements, attrs := map[string]bool{}, map[string]bool{}
+	var walk func(node *xmlquery.Node)
+	walk = func(node *xmlquery.Node) {
+		for child := node.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type != xmlquery.ElementNode {
+				continue
+			}
+			elements[getExplorerNodeName(child)] = true
+			for _, attr := range child.Attr {
+				if attr.Name.Space != "" {
+					attrs[attr.Name.Space+":"+attr.Name.Local] = true
+				} else {
+					attrs[attr.Name.Local] = true
+				}
+			}
+			walk(child)
+		}
+	}
+	walk(session.doc)
+
+	for name := range elements {
+		session.elements = append(session.elements, name)
+	}
+	for name := range attrs {
+		session.attrs = append(session.attrs, name)
+	}
+	slices.Sort(session.elements)
+	slices.Sort(session.attrs)
+
+	return session, nil
+}
+
+// XPathQuery evaluates the XPath query with the same output as XPathQuery.
+func (session *QuerySession) XPathQuery(writer io.Writer, query string, singleNode bool, options QueryOptions) error {
+	if session.jsonTree != nil {
+		return session.jsonTree.queryXPath(writer, query, singleNode, options)
+	}
+
+	return queryXPath(session.doc, writer, query, singleNode, options)
+}
+
+// CSSQuery selects the nodes using the CSS selector with the same output as CSSQuery.
+func (session *QuerySession) CSSQuery(writer io.Writer, query string, attr string, options QueryOptions) error {
+	if session.jsonTree != nil {
+		return session.jsonTree.queryCSS(writer, query, attr, options)
+	}
+
+	if session.cssDoc == nil {
+		root, _ := nodeToHtml(session.doc)
+		session.cssDoc = goquery.NewDocumentFromNode(root)
+	}
+
+	return queryCSS(session.cssDoc, writer, query, attr, options)
+}
+
+// Repl reads the queries and the commands line by line and evaluates them against the document.
+type Repl struct {
+	session *QuerySession
+	options QueryOptions
+	css     bool
+	single  bool
+	attr    string
+}
+
+func NewRepl(session *QuerySession, options QueryOptions) *Repl {
+	return &Repl{session: session, options: options}
+}
+
+func (repl *Repl) prompt() string {
+	if repl.css {
+		return "css> "
+	}
+
+	return "xpath> "
+}
+
+// Execute evaluates the query or the command. True is returned if the session should be closed.
+func (repl *Repl) Execute(writer io.Writer, line string) (bool, error) {
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return false, nil
+	}
+
+	if !strings.HasPrefix(line, ":") {
+		if repl.css {
+			return false, repl.session.CSSQuery(writer, line, repl.attr, repl.options)
+		}
+		return false, repl.session.XPathQuery(writer, line, repl.single, repl.options)
+	}
+
+	command, argument, _ := strings.Cut(line, " ")
+	argument = strings.TrimSpace(argument)
+	toggle := func(name string, value *bool) error {
+		*value = !*value
+		state := "off"
+		if *value {
+			state = "on"
+		}
+		_, err := fmt.Fprintf(writer, "%s: %s\n", name, state)
+		return err
+	}
+
+	switch command {
+	case ":quit", ":q", ":exit":
+		return true, nil
+	case ":help":
+		_, err := io.WriteString(writer, replHelp)
+		return false, err
+	case ":xpath":
+		repl.css = false
+	case ":css":
+		repl.css = true
+	case ":attr":
+		repl.attr = argument
+	case ":node":
+		return false, toggle("node output", &repl.options.WithTags)
+	case ":json":
+		return false, toggle("JSON output", &repl.options.JSON)
+	case ":single":
+		return false, toggle("single node", &repl.single)
+	default:
+		return false, fmt.Errorf("unknown command %s, see :help", command)
+	}
+
+	return false, nil
+}
+
+// Complete completes the name at the position using the element and attribute names of the
+// document. The line is returned unchanged together with the candidates if the name is ambiguous.
+func (repl *Repl) Complete(line string, pos int) (string, int, []string) {
+	start := pos
+	for start > 0 && isReplNameChar(line[start-1]) {
+		start--
+	}
+	word := line[start:pos]
+
+	var candidates []string
+	switch {
+	case start == 0 && strings.HasPrefix(word, ":"):
+		candidates = replCommands
+	case strings.HasPrefix(word, "@"):
+		for _, attr := range repl.session.attrs {
+			candidates = append(candidates, "@"+attr)
+		}
+	case repl.css && start > 0 && line[start-1] == '[':
+		candidates = repl.session.attrs
+	default:
+		candidates = slices.Clone(repl.session.elements)
+		if !repl.css {
+			candidates = append(candidates, xpathFunctionNames...)
+		}
+	}
+
+	var matches []string
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, word) {
+			matches = append(matches, candidate)
+		}
+	}
+	if len(matches) == 0 {
+		return line, pos, nil
+	}
+
+	completion := matches[0]
+	for _, match := range matches[1:] {
+		for !strings.HasPrefix(match, completion) {
+			completion = completion[:len(completion)-1]
+		}
+	}
+	if completion == word && len(matches) > 1 {
+		return line, pos, matches
+	}
+
+	return line[:start] + completion + line[pos:], start + len(completion), nil
+}
+
+func isReplNameChar(char byte) bool {
+	return isXmlNameStart(char) || char >= '0' && char <= '9' || char == '-' || char == '.' || char == ':' ||
+		char == '@' || char >= 0x80
+}
+
+// RunRepl reads the lines from the input and prints the results. The line editing, history and
+// completion are available if the input is a terminal, the history is kept in the given file.
+func RunRepl(repl *Repl, input io.Reader, output io.Writer, historyFile string) error {
+	inputFile, isFile := input.(*os.File)
+	if !isFile || !term.IsTerminal(int(inputFile.Fd())) {
+		scanner := bufio.NewScanner(input)
+		scanner.Buffer(nil, 1<<20)
+		for scanner.Scan() {
+			if quit, err := repl.Execute(output, scanner.Text()); err != nil {
+				_, _ = io.WriteString(output, FormatError(err))
+			} else if quit {
+				return nil
+			}
+		}
+		return scanner.Err()
+	}
+
+	state, err := term.MakeRaw(int(inputFile.Fd()))
+	if err != nil {
+		return fmt.Errorf("unable to switch the terminal to raw mode: %w", err)
+	}
+	defer func() {
+		_ = term.Restore(int(inputFile.Fd()), state)
+	}()
+
+	terminal := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{input, output}, repl.prompt())
+	terminal.History = loadReplHistory(historyFile)
+	terminal.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' {
+			return "", 0, false
+		}
+		newLine, newPos, candidates := repl.Complete(line, pos)
+		if len(candidates) > 0 {
+			_, _ = fmt.Fprintf(terminal, "%s\n", strings.Join(candidates, "  "))
+		}
+		return newLine, newPos, true
+	}
+
+	for {
+		line, err := terminal.ReadLine()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		quit, err := repl.Execute(terminal, line)
+		if err != nil {
+			_, _ = io.WriteString(terminal, FormatError(err))
+		} else if quit {
+			return nil
+		}
+		terminal.SetPrompt(repl.prompt())
+	}
+}
+
+// replHistory is the history of the entered lines saved into the file. The lines are appended
+// to the file, it is rewritten with the last entries once it exceeds the history size.
+type replHistory struct {
+	entries   []string
+	fileName  string
+	fileLines int
+}
+
+func loadReplHistory(fileName string) *replHistory {
+	history := &replHistory{fileName: fileName}
+	if content, err := os.ReadFile(fileName); err == nil {
+		for _, line := range strings.Split(string(content), "\n") {
+			if strings.TrimSpace(line) != "" {
+				history.entries = append(history.entries, line)
+			}
+		}
+	}
+	history.fileLines = len(history.entries)
+	history.entries = history.entries[max(0, len(history.entries)-replHistorySize):]
+
+	return history
+}
+
+func (history *replHistory) Add(entry string) {
+	if strings.TrimSpace(entry) == "" || len(history.entries) > 0 && history.entries[len(history.entries)-1] == entry {
+		return
+	}
+	history.entries = append(history.entries, entry)
+	if len(history.entries) > replHistorySize {
+		history.entries = history.entries[1:]
+	}
+
+	if history.fileName == "" {
+		return
+	}
+	if history.fileLines >= replHistorySize {
+		content := strings.Join(history.entries, "\n") + "\n"
+		if err := os.WriteFile(history.fileName, []byte(content), 0600); err == nil {
+			history.fileLines = len(history.entries)
+		}
+		return
+	}
+	if file, err := os.OpenFile(history.fileName, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600); err == nil {
+		if _, err = fmt.Fprintln(file, entry); err == nil {
+			history.fileLines++
+		}
+		_ = file.Close()
+	}
+}
+
+func (history *replHistory) Len() int {
+	return len(history.entries)
+}
+
+func (history *replHistory) At(index int) string {
+	return history.entries[len(history.entries)-1-index]
+}
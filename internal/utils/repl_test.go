@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRepl(t *testing.T) {
+	input, err := os.ReadFile(filepath.Join("..", "..", "test", "data", "xslt", "catalog.xml"))
+	assert.Nil(t, err)
+	session, err := NewQuerySession(strings.NewReader(string(input)), ContentXml)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"author", "book", "catalog", "price", "title", "year"}, session.elements)
+	assert.Equal(t, []string{"category", "id"}, session.attrs)
+
+	repl := NewRepl(session, QueryOptions{Indent: "  ", Colors: ColorsDisabled})
+	output := new(strings.Builder)
+	execute := func(line string) string {
+		output.Reset()
+		quit, err := repl.Execute(output, line)
+		assert.Nil(t, err)
+		assert.False(t, quit)
+		return output.String()
+	}
+
+	assert.Equal(t, "A Brief History of Time\nDune\nSPQR\n", execute("//book[year > 1950 and price < 25]/title"))
+	assert.Equal(t, "4\n", execute("count(//book)"))
+	assert.Equal(t, "single node: on\n", execute(":single"))
+	assert.Equal(t, "The Hobbit\n", execute("//title"))
+	assert.Equal(t, "node output: on\n", execute(":node"))
+	assert.Equal(t, "<title>The Hobbit</title>\n", execute("//title"))
+	assert.Equal(t, "", execute(":css"))
+	assert.Equal(t, "<author>Mary Beard</author>\n", execute("book[category=history] author"))
+	assert.Equal(t, "", execute(":attr id"))
+	assert.Equal(t, "b1\nb3\n", execute("book[category=fiction]"))
+	assert.Contains(t, execute(":help"), ":single")
+
+	_, err = repl.Execute(output, ":unknown")
+	assert.ErrorContains(t, err, "unknown command")
+
+	quit, err := repl.Execute(output, ":quit")
+	assert.Nil(t, err)
+	assert.True(t, quit)
+}
+
+func TestReplComplete(t *testing.T) {
+	session, err := NewQuerySession(strings.NewReader(`{"store": {"book": [{"@id": "1", "title": "A"}]}}`),
+		ContentJson)
+	assert.Nil(t, err)
+	repl := NewRepl(session, QueryOptions{})
+
+	complete := func(line string) (string, []string) {
+		newLine, pos, candidates := repl.Complete(line, len(line))
+		assert.Equal(t, len(newLine), pos)
+		return newLine, candidates
+	}
+
+	line, candidates := complete("//st")
+	assert.Equal(t, "//st", line)
+	assert.Equal(t, []string{"store", "starts-with(", "string(", "string-join(", "string-length("}, candidates)
+
+	line, _ = complete("//sto")
+	assert.Equal(t, "//store", line)
+	line, _ = complete("//book[@")
+	assert.Equal(t, "//book[@id", line)
+	line, _ = complete(":si")
+	assert.Equal(t, ":single", line)
+	line, candidates = complete("//xyz")
+	assert.Equal(t, "//xyz", line)
+	assert.Nil(t, candidates)
+
+	_, _ = repl.Execute(new(strings.Builder), ":css")
+	line, _ = complete("book > ti")
+	assert.Equal(t, "book > title", line)
+	line, _ = complete("book[")
+	assert.Equal(t, "book[id", line)
+
+	newLine, pos, _ := repl.Complete("//ti/text()", 4)
+	assert.Equal(t, "//title/text()", newLine)
+	assert.Equal(t, 7, pos)
+}
+
+func TestRunRepl(t *testing.T) {
+	session, err := NewQuerySession(strings.NewReader("items:\n  - name: pen\n    price: 5\n"), ContentYaml)
+	assert.Nil(t, err)
+
+	output := new(strings.Builder)
+	input := strings.NewReader("//price\n:json\n//price\n//name[\n:quit\n//name\n")
+	assert.Nil(t, RunRepl(NewRepl(session, QueryOptions{Colors: ColorsDisabled}), input, output, ""))
+	assert.Equal(t, "5\nJSON output: on\n[5]\nError: XPath error: expression must evaluate to a node-set\n"+
+		"  | //name[\n  |       ^\nHint: the bracket is not closed\n",
+		strings.ReplaceAll(output.String(), "\n\n", "\n"))
+}
+
+func TestReplHistory(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "history")
+
+	history := loadReplHistory(fileName)
+	history.Add("//a")
+	history.Add("//a")
+	history.Add("  ")
+	history.Add("//b")
+	assert.Equal(t, 2, history.Len())
+	assert.Equal(t, "//b", history.At(0))
+
+	history = loadReplHistory(fileName)
+	assert.Equal(t, 2, history.Len())
+	assert.Equal(t, "//a", history.At(1))
+
+	var lines []string
+	for index := 1; index <= replHistorySize+5; index++ {
+		lines = append(lines, "//e"+strconv.Itoa(index))
+	}
+	assert.Nil(t, os.WriteFile(fileName, []byte(strings.Join(lines, "\n")+"\n"), 0600))
+	history = loadReplHistory(fileName)
+	history.Add("//c")
+	content, err := os.ReadFile(fileName)
+	assert.Nil(t, err)
+	lines = strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	assert.Equal(t, replHistorySize, len(lines))
+	assert.Equal(t, "//e7", lines[0])
+	assert.Equal(t, "//c", lines[len(lines)-1])
+}
@@ -0,0 +1,239 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+)
+
+// getStreamPath returns the path to the elements which can be parsed one by one to evaluate the
+// query, e.g. //record for //record[price > 10]/@id. Only the location paths of the element names
+// are supported, and the predicates are allowed for the last element step only. The predicates
+// should not depend on the position of the element or on the content outside of it.
+func getStreamPath(query string) (string, bool) {
+	query = strings.TrimSpace(query)
+	if !strings.HasPrefix(query, "/") {
+		return "", false
+	}
+
+	type streamStep struct {
+		separator  string
+		name       string
+		predicates []string
+	}
+
+	var steps []streamStep
+	for pos := 0; pos < len(query); {
+		step := streamStep{separator: "/"}
+		if strings.HasPrefix(query[pos:], "//") {
+			step.separator = "//"
+		}
+		pos += len(step.separator)
+
+		end := pos
+		for end < len(query) && query[end] != '/' && query[end] != '[' {
+			end++
+		}
+		step.name = strings.TrimSpace(query[pos:end])
+		pos = end
+
+		for pos < len(query) && query[pos] == '[' {
+			closing := findXPathClosing(query, pos)
+			if closing < 0 {
+				return "", false
+			}
+			step.predicates = append(step.predicates, query[pos+1:closing])
+			pos = closing + 1
+		}
+		if pos < len(query) && query[pos] != '/' {
+			return "", false
+		}
+
+		steps = append(steps, step)
+	}
+
+	// the attributes and the text of the streamed elements can be selected as well
+	last := steps[len(steps)-1]
+	if len(steps) > 1 && len(last.predicates) == 0 && last.separator == "/" &&
+		(last.name == "text()" || last.name == "@*" || strings.HasPrefix(last.name, "@") &&
+			isQualifiedXmlName(last.name[1:])) {
+		steps = steps[:len(steps)-1]
+	}
+
+	var path strings.Builder
+	for index, step := range steps {
+		name, isWildcard := strings.CutSuffix(step.name, ":*")
+		if step.name != "*" && !(isWildcard && isXmlName(name) || !isWildcard && isQualifiedXmlName(name)) {
+			return "", false
+		}
+		if len(step.predicates) > 0 && index < len(steps)-1 {
+			return "", false
+		}
+		for _, predicate := range step.predicates {
+			if !isStreamablePredicate(predicate) {
+				return "", false
+			}
+		}
+		path.WriteString(step.separator + step.name)
+	}
+
+	return path.String(), true
+}
+
+// isStreamablePredicate checks that the predicate can be evaluated for the element detached from
+// its siblings and ancestors, so it can't refer to the parent or the ancestors of the element.
+func isStreamablePredicate(predicate string) bool {
+	expr, err := xpath.Compile(predicate)
+	if err != nil {
+		return false
+	}
+
+	// the numeric predicates are compared with the position of the element
+	element := &xmlquery.Node{Type: xmlquery.ElementNode, Data: "element"}
+	if _, isNumber := expr.Evaluate(xmlquery.CreateXPathNavigator(element)).(float64); isNumber {
+		return false
+	}
+
+	var quote byte
+	previous := byte('[')
+	for pos := 0; pos < len(predicate); pos++ {
+		char := predicate[pos]
+		switch {
+		case quote != 0:
+			if char == quote {
+				quote = 0
+			}
+			continue
+		case char == '"' || char == '\'':
+			quote = char
+		case char == '/' && strings.IndexByte("[(,=<>!+-|", previous) >= 0:
+			return false
+		case char == '.' && pos+1 < len(predicate) && predicate[pos+1] == '.':
+			return false
+		case isXmlNameStart(char):
+			end := pos
+			for end < len(predicate) && (isXmlNameStart(predicate[end]) || predicate[end] == '-') {
+				end++
+			}
+			switch predicate[pos:end] {
+			case "position", "last", "preceding", "preceding-sibling", "following", "following-sibling", "parent",
+				"ancestor", "ancestor-or-self":
+				return false
+			}
+			pos = end - 1
+		}
+		if char != ' ' && char != '\t' && char != '\n' {
+			previous = predicate[pos]
+		}
+	}
+
+	return true
+}
+
+func isXmlNameStart(char byte) bool {
+	return char >= 'a' && char <= 'z' || char >= 'A' && char <= 'Z' || char == '_'
+}
+
+// IsStreamableXPath checks that the XPath query can be evaluated without loading the whole
+// document into memory.
+func IsStreamableXPath(query string) bool {
+	_, ok := getStreamPath(query)
+	return ok
+}
+
+// XPathStreamQuery evaluates the XPath query while the document is read. Every matched element is
+// printed as soon as it is closed and released afterwards, so the memory usage does not depend on
+// the document size.
+func XPathStreamQuery(reader io.Reader, writer io.Writer, query string, singleNode bool, options QueryOptions) (errRes error) {
+	defer func() {
+		if err := recover(); err != nil {
+			errRes = newXPathDiagnostic(query, err)
+		}
+	}()
+
+	streamPath, ok := getStreamPath(query)
+	if !ok {
+		return fmt.Errorf("the XPath query %q can not be evaluated in streaming mode", query)
+	}
+
+	expr, err := compileXPath(nil, query, options.Namespaces)
+	if err != nil {
+		return newXPathDiagnostic(query, err)
+	}
+
+	var templateExprs []*xpath.Expr
+	var records *recordWriter
+	if options.Template != nil {
+		if templateExprs, err = options.Template.compileXPath(nil, options.Namespaces); err != nil {
+			return err
+		}
+		records = options.Template.newRecordWriter(writer)
+	}
+
+	parser, err := xmlquery.CreateStreamParserWithOptions(reader, xmlquery.ParserOptions{
+		Decoder: &xmlquery.DecoderOptions{
+			Strict:        false,
+			CharsetReader: getCharsetReader,
+		},
+	}, streamPath)
+	if err != nil {
+		return err
+	}
+
+	for {
+		element, err := parser.Read()
+		if err == io.EOF {
+			if records != nil {
+				return records.close()
+			}
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		root := element
+		for ancestor := element; ancestor.Parent != nil; ancestor = ancestor.Parent {
+			// the content before the element is not used anymore
+			for ancestor.PrevSibling != nil {
+				xmlquery.RemoveFromTree(ancestor.PrevSibling)
+			}
+			root = ancestor.Parent
+		}
+
+		matches := expr.Select(xmlquery.CreateXPathNavigator(root))
+		for matches.MoveNext() {
+			navigator := matches.Current().(*xmlquery.NodeNavigator)
+			if !isDescendantNode(navigator.Current(), element) {
+				continue
+			}
+
+			switch {
+			case records != nil:
+				if err = records.write(options.Template.formatXPath(templateExprs, navigator)); err == nil && singleNode {
+					err = records.close()
+				}
+			case options.WithTags && navigator.NodeType() == xpath.ElementNode:
+				err = printNodeContent(writer, navigator.Current(), options)
+			default:
+				_, err = fmt.Fprintf(writer, "%s\n", strings.TrimSpace(navigator.Value()))
+			}
+			if err != nil || singleNode {
+				return err
+			}
+		}
+	}
+}
+
+func isDescendantNode(node *xmlquery.Node, ancestor *xmlquery.Node) bool {
+	for ; node != nil; node = node.Parent {
+		if node == ancestor {
+			return true
+		}
+	}
+
+	return false
+}
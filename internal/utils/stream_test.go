@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetStreamPath(t *testing.T) {
+	tests := map[string]string{
+		"/a/b/c":                        "/a/b/c",
+		"//record":                      "//record",
+		"//record[price > 10]":          "//record",
+		"//record[@id='1'][.//name]":    "//record",
+		"/export//record/@id":           "/export//record",
+		"//ns:record/text()":            "//ns:record",
+		"/a/*/ns:*":                     "/a/*/ns:*",
+		"//record[contains(name, '/')]": "//record",
+	}
+	for query, expected := range tests {
+		path, ok := getStreamPath(query)
+		assert.True(t, ok, query)
+		assert.Equal(t, expected, path, query)
+	}
+
+	for _, query := range []string{"record", "//record[1]", "//record[last()]", "//record[position() < 3]",
+		"/a[@x]/b", "//record[/a/b = 1]", "//record[count(/a) > 1]", "//record[following-sibling::x]",
+		"//rec[../x]/@id", "//rec[name/../../x]", "//rec[parent::x]", "//rec[ancestor::x/@y = 1]",
+		"//a | //b", "/a/../b", "//record/name[1]", "//a[", "count(//a)"} {
+		_, ok := getStreamPath(query)
+		assert.False(t, ok, query)
+	}
+}
+
+func TestXPathStreamQuery(t *testing.T) {
+	input := `<?xml version="1.0"?>
+<export>
+  <header><count>3</count></header>
+  <batch>
+    <record id="1"><price>5</price><record id="1.1"><price>50</price></record></record>
+    <record id="2"><price>20</price></record>
+  </batch>
+  <batch><record id="3"><price>30</price></record></batch>
+</export>`
+
+	tests := map[string]string{
+		"//record/@id":           "1\n1.1\n2\n3\n",
+		"//record[price>10]/@id": "1.1\n2\n3\n",
+		"/export/batch/record":   "550\n20\n30\n",
+		"//header/count/text()":  "3\n",
+	}
+	for query, expected := range tests {
+		output := new(strings.Builder)
+		assert.Nil(t, XPathStreamQuery(strings.NewReader(input), output, query, false, QueryOptions{}), query)
+		assert.Equal(t, expected, output.String(), query)
+	}
+
+	output := new(strings.Builder)
+	err := XPathStreamQuery(strings.NewReader(input), output, "//record[@id='2']", true,
+		QueryOptions{WithTags: true, Indent: "  ", Colors: ColorsDisabled})
+	assert.Nil(t, err)
+	assert.Equal(t, "<record id=\"2\">\n  <price>20</price>\n</record>\n", output.String())
+
+	err = XPathStreamQuery(strings.NewReader(input), output, "//record[1]", false, QueryOptions{})
+	assert.ErrorContains(t, err, "can not be evaluated in streaming mode")
+
+	err = XPathStreamQuery(strings.NewReader("<a><b>text"), output, "//b", false, QueryOptions{})
+	assert.NotNil(t, err)
+}
+
+func TestXPathStreamQueryLargeInput(t *testing.T) {
+	const count = 20000
+	pr, pw := io.Pipe()
+	go func() {
+		_, _ = io.WriteString(pw, "<export>")
+		for index := range count {
+			_, _ = fmt.Fprintf(pw, "<batch><record id=\"%d\"><value>%d</value></record></batch>\n", index, index%10)
+		}
+		_, _ = io.WriteString(pw, "</export>")
+		_ = pw.Close()
+	}()
+
+	output := new(strings.Builder)
+	assert.Nil(t, XPathStreamQuery(pr, output, "//record[value = 9]/@id", false, QueryOptions{}))
+	lines := strings.Split(strings.TrimSpace(output.String()), "\n")
+	assert.Equal(t, count/10, len(lines))
+	assert.Equal(t, "9", lines[0])
+	assert.Equal(t, fmt.Sprint(count-1), lines[len(lines)-1])
+}
@@ -0,0 +1,134 @@
+package utils
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+)
+
+// TableColumn is the column of the table extracted from the document. The query is evaluated
+// relative to each row node.
+type TableColumn struct {
+	Name  string
+	Query string
+}
+
+type TableOptions struct {
+	Delimiter  rune
+	NoHeader   bool
+	Namespaces map[string]string
+}
+
+// ParseTableColumn parses the column definition in the name=xpath form. The expression itself
+// is used as the name if the name is omitted.
+func ParseTableColumn(definition string) (TableColumn, error) {
+	if name, query, found := strings.Cut(definition, "="); found && isQualifiedXmlName(name) {
+		if strings.TrimSpace(query) == "" {
+			return TableColumn{}, fmt.Errorf("empty XPath query of the column %q", name)
+		}
+		return TableColumn{Name: name, Query: query}, nil
+	}
+
+	if strings.TrimSpace(definition) == "" {
+		return TableColumn{}, errors.New("empty column definition")
+	}
+
+	return TableColumn{Name: definition, Query: definition}, nil
+}
+
+// XPathTable prints a delimited record for each node matched by the row query. The fields are
+// the values of the column queries, the missing values are left empty to keep the alignment.
+func XPathTable(reader io.Reader, writer io.Writer, rowQuery string, columns []TableColumn,
+	options TableOptions) error {
+	doc, err := parseXml(reader)
+	if err != nil {
+		return err
+	}
+
+	return writeTable(doc, writer, rowQuery, columns, options)
+}
+
+// JSONXPathTable is the same as XPathTable for the JSON document mapped into the virtual
+// element tree.
+func JSONXPathTable(reader io.Reader, writer io.Writer, rowQuery string, columns []TableColumn,
+	options TableOptions) error {
+	tree, err := parseJSONTree(reader)
+	if err != nil {
+		return err
+	}
+
+	return writeTable(tree.doc, writer, rowQuery, columns, options)
+}
+
+func writeTable(doc *xmlquery.Node, writer io.Writer, rowQuery string, columns []TableColumn,
+	options TableOptions) (errRes error) {
+	defer func() {
+		if err := recover(); err != nil {
+			errRes = &Diagnostic{Kind: ErrorQuery, Message: fmt.Sprintf("XPath error: %v", err)}
+		}
+	}()
+
+	rowExpr, err := compileXPath(doc, rowQuery, options.Namespaces)
+	if err != nil {
+		return fmt.Errorf("unable to parse the row XPath query: %w", newXPathDiagnostic(rowQuery, err))
+	}
+
+	exprs := make([]*xpath.Expr, len(columns))
+	header := make([]string, len(columns))
+	for index, column := range columns {
+		if exprs[index], err = compileXPath(doc, column.Query, options.Namespaces); err != nil {
+			return fmt.Errorf("unable to parse the XPath query of the column %q: %w", column.Name,
+				newXPathDiagnostic(column.Query, err))
+		}
+		header[index] = column.Name
+	}
+
+	csvWriter := csv.NewWriter(writer)
+	if options.Delimiter != 0 {
+		csvWriter.Comma = options.Delimiter
+	}
+
+	if !options.NoHeader {
+		if err = csvWriter.Write(header); err != nil {
+			return err
+		}
+	}
+
+	rows := rowExpr.Select(xmlquery.CreateXPathNavigator(doc))
+	for rows.MoveNext() {
+		record := make([]string, len(exprs))
+		for index, expr := range exprs {
+			record[index] = getTableValue(expr.Evaluate(rows.Current().Copy()))
+		}
+		if err = csvWriter.Write(record); err != nil {
+			return err
+		}
+	}
+
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// getTableValue converts the result of the column query into the field value. The first
+// matched node is used for the node-sets.
+func getTableValue(value interface{}) string {
+	switch typedValue := value.(type) {
+	case float64:
+		return formatXPathNumber(typedValue)
+	case bool:
+		return fmt.Sprintf("%t", typedValue)
+	case string:
+		return strings.TrimSpace(typedValue)
+	case *xpath.NodeIterator:
+		if typedValue.MoveNext() {
+			return strings.TrimSpace(typedValue.Current().Value())
+		}
+	}
+
+	return ""
+}
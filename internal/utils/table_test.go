@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTableColumn(t *testing.T) {
+	column, err := ParseTableColumn("title=normalize-space(title)")
+	assert.Nil(t, err)
+	assert.Equal(t, TableColumn{Name: "title", Query: "normalize-space(title)"}, column)
+
+	column, err = ParseTableColumn("@id")
+	assert.Nil(t, err)
+	assert.Equal(t, TableColumn{Name: "@id", Query: "@id"}, column)
+
+	column, err = ParseTableColumn("author[@role='editor']")
+	assert.Nil(t, err)
+	assert.Equal(t, TableColumn{Name: "author[@role='editor']", Query: "author[@role='editor']"}, column)
+
+	_, err = ParseTableColumn("name=")
+	assert.ErrorContains(t, err, "empty XPath query")
+
+	_, err = ParseTableColumn("")
+	assert.ErrorContains(t, err, "empty column definition")
+}
+
+func TestXPathTable(t *testing.T) {
+	input, err := os.ReadFile(filepath.Join("..", "..", "test", "data", "table", "feed.xml"))
+	assert.Nil(t, err)
+	columns := []TableColumn{
+		{Name: "id", Query: "@id"},
+		{Name: "title", Query: "title"},
+		{Name: "author", Query: "author"},
+		{Name: "length", Query: "string-length(title)"},
+	}
+
+	output := new(strings.Builder)
+	err = XPathTable(strings.NewReader(string(input)), output, "//entry", columns, TableOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "id,title,author,length\n1,\"Hello, world\",Alice,12\n2,\"The \"\"quoted\"\" title\",,18\n"+
+		"3,\"Multi\nline\",Bob,10\n", output.String())
+
+	output.Reset()
+	err = XPathTable(strings.NewReader(string(input)), output, "//entry[author]", columns[:3],
+		TableOptions{Delimiter: '\t', NoHeader: true})
+	assert.Nil(t, err)
+	assert.Equal(t, "1\tHello, world\tAlice\n3\t\"Multi\nline\"\tBob\n", output.String())
+
+	output.Reset()
+	err = XPathTable(strings.NewReader(string(input)), output, "//entry[", columns, TableOptions{})
+	assert.ErrorContains(t, err, "unable to parse the row XPath query")
+
+	err = XPathTable(strings.NewReader(string(input)), output, "//entry", []TableColumn{{Name: "x", Query: "]"}},
+		TableOptions{})
+	assert.ErrorContains(t, err, `column "x"`)
+}
+
+func TestJSONXPathTable(t *testing.T) {
+	input := `{"items": [{"name": "pen", "price": 5}, {"name": "book", "price": 12.5, "@id": "x1"}]}`
+	columns := []TableColumn{{Name: "name", Query: "name"}, {Name: "price", Query: "price * 2"},
+		{Name: "id", Query: "@id"}}
+
+	output := new(strings.Builder)
+	err := JSONXPathTable(strings.NewReader(input), output, "//items", columns, TableOptions{Delimiter: ';'})
+	assert.Nil(t, err)
+	assert.Equal(t, "name;price;id\npen;10;\nbook;25;x1\n", output.String())
+}
@@ -0,0 +1,239 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+)
+
+// OutputTemplate formats a record for each matched node. The {…} placeholders are the XPath
+// queries evaluated relative to the node, or the CSS lookups for the CSS selectors: {@name} for
+// the attribute, {selector} for the text of the first matched descendant and {} for the text of
+// the node itself.
+type OutputTemplate struct {
+	parts     []templatePart
+	separator string
+}
+
+type templatePart struct {
+	text        string
+	query       string
+	placeholder bool
+}
+
+// ParseTemplate parses the template and the record separator. The escape sequences \t, \n, \r,
+// \\, \{ and \} are supported in both.
+func ParseTemplate(template string, separator string) (*OutputTemplate, error) {
+	parsedSeparator, err := unescapeTemplate(separator)
+	if err != nil {
+		return nil, fmt.Errorf("invalid separator: %w", err)
+	}
+	result := &OutputTemplate{separator: parsedSeparator}
+
+	text := new(strings.Builder)
+	for pos := 0; pos < len(template); pos++ {
+		switch char := template[pos]; char {
+		case '\\':
+			if pos+1 == len(template) {
+				return nil, fmt.Errorf("invalid template: incomplete escape sequence at position %d", pos+1)
+			}
+			escaped, err := unescapeTemplate(template[pos : pos+2])
+			if err != nil {
+				return nil, fmt.Errorf("invalid template: %w", err)
+			}
+			text.WriteString(escaped)
+			pos++
+		case '{':
+			end := findPlaceholderEnd(template, pos+1)
+			if end < 0 {
+				return nil, fmt.Errorf("invalid template: placeholder at position %d is not closed", pos+1)
+			}
+			if text.Len() > 0 {
+				result.parts = append(result.parts, templatePart{text: text.String()})
+				text.Reset()
+			}
+			query := strings.TrimSpace(template[pos+1 : end])
+			result.parts = append(result.parts, templatePart{query: query, placeholder: true})
+			pos = end
+		case '}':
+			return nil, fmt.Errorf("invalid template: unexpected } at position %d, use \\} for the brace", pos+1)
+		default:
+			text.WriteByte(char)
+		}
+	}
+	if text.Len() > 0 {
+		result.parts = append(result.parts, templatePart{text: text.String()})
+	}
+
+	return result, nil
+}
+
+// findPlaceholderEnd returns the position of the brace closing the placeholder. The braces in
+// the string literals of the query are skipped.
+func findPlaceholderEnd(template string, start int) int {
+	var quote byte
+	for pos := start; pos < len(template); pos++ {
+		switch char := template[pos]; {
+		case quote != 0:
+			if char == quote {
+				quote = 0
+			}
+		case char == '\'' || char == '"':
+			quote = char
+		case char == '}':
+			return pos
+		}
+	}
+
+	return -1
+}
+
+func unescapeTemplate(value string) (string, error) {
+	replacements := map[byte]string{'t': "\t", 'n': "\n", 'r': "\r", '\\': "\\", '{': "{", '}': "}"}
+	result := new(strings.Builder)
+	for pos := 0; pos < len(value); pos++ {
+		if value[pos] != '\\' {
+			result.WriteByte(value[pos])
+			continue
+		}
+		if pos+1 == len(value) {
+			return "", fmt.Errorf("incomplete escape sequence at position %d", pos+1)
+		}
+		replacement, ok := replacements[value[pos+1]]
+		if !ok {
+			return "", fmt.Errorf("unknown escape sequence \\%c at position %d", value[pos+1], pos+1)
+		}
+		result.WriteString(replacement)
+		pos++
+	}
+
+	return result.String(), nil
+}
+
+// compileXPath compiles the placeholders of the template, the empty ones select the node itself.
+func (template *OutputTemplate) compileXPath(doc *xmlquery.Node, namespaces map[string]string) ([]*xpath.Expr, error) {
+	exprs := make([]*xpath.Expr, len(template.parts))
+	for index, part := range template.parts {
+		if !part.placeholder {
+			continue
+		}
+		query := part.query
+		if query == "" {
+			query = "."
+		}
+		expr, err := compileXPath(doc, query, namespaces)
+		if err != nil {
+			return nil, newXPathDiagnostic(query, err)
+		}
+		exprs[index] = expr
+	}
+
+	return exprs, nil
+}
+
+func (template *OutputTemplate) formatXPath(exprs []*xpath.Expr, navigator xpath.NodeNavigator) string {
+	record := new(strings.Builder)
+	for index, part := range template.parts {
+		if part.placeholder {
+			record.WriteString(getTableValue(exprs[index].Evaluate(navigator.Copy())))
+		} else {
+			record.WriteString(part.text)
+		}
+	}
+
+	return record.String()
+}
+
+func (template *OutputTemplate) formatCSS(selection *goquery.Selection) string {
+	record := new(strings.Builder)
+	for _, part := range template.parts {
+		switch {
+		case !part.placeholder:
+			record.WriteString(part.text)
+		case part.query == "" || part.query == ".":
+			record.WriteString(strings.TrimSpace(selection.Text()))
+		case strings.HasPrefix(part.query, "@"):
+			record.WriteString(strings.TrimSpace(selection.AttrOr(part.query[1:], "")))
+		default:
+			record.WriteString(strings.TrimSpace(selection.Find(part.query).First().Text()))
+		}
+	}
+
+	return record.String()
+}
+
+// recordWriter writes the records joined by the separator of the template. The output is
+// terminated by a newline.
+type recordWriter struct {
+	writer    io.Writer
+	separator string
+	count     int
+}
+
+func (template *OutputTemplate) newRecordWriter(writer io.Writer) *recordWriter {
+	return &recordWriter{writer: writer, separator: template.separator}
+}
+
+func (records *recordWriter) write(record string) error {
+	if records.count > 0 {
+		record = records.separator + record
+	}
+	records.count++
+	_, err := io.WriteString(records.writer, record)
+	return err
+}
+
+func (records *recordWriter) close() error {
+	if records.count == 0 {
+		return nil
+	}
+	_, err := io.WriteString(records.writer, "\n")
+	return err
+}
+
+// printXPathTemplate prints the record formatted with the template for each node matched by
+// the query.
+func printXPathTemplate(doc *xmlquery.Node, writer io.Writer, expr *xpath.Expr, singleNode bool,
+	options QueryOptions) error {
+	exprs, err := options.Template.compileXPath(doc, options.Namespaces)
+	if err != nil {
+		return err
+	}
+
+	nodes, ok := expr.Evaluate(xmlquery.CreateXPathNavigator(doc)).(*xpath.NodeIterator)
+	if !ok {
+		return errors.New("the query should select the nodes to be formatted with the template")
+	}
+
+	records := options.Template.newRecordWriter(writer)
+	for nodes.MoveNext() {
+		if err = records.write(options.Template.formatXPath(exprs, nodes.Current())); err != nil {
+			return err
+		}
+		if singleNode {
+			break
+		}
+	}
+
+	return records.close()
+}
+
+// printCSSTemplate prints the record formatted with the template for each selected node.
+func printCSSTemplate(selection *goquery.Selection, writer io.Writer, template *OutputTemplate) error {
+	records := template.newRecordWriter(writer)
+	var err error
+	selection.EachWithBreak(func(index int, item *goquery.Selection) bool {
+		err = records.write(template.formatCSS(item))
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return records.close()
+}
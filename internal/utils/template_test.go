@@ -0,0 +1,77 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseTemplate(t *testing.T) {
+	template, err := ParseTemplate(`{@id}\t{name[@lang='{x}']}\{\}\\`, `\n--\n`)
+	assert.Nil(t, err)
+	assert.Equal(t, []templatePart{
+		{query: "@id", placeholder: true},
+		{text: "\t"},
+		{query: "name[@lang='{x}']", placeholder: true},
+		{text: "{}\\"},
+	}, template.parts)
+	assert.Equal(t, "\n--\n", template.separator)
+
+	errors := map[string]string{
+		"{@id":    "invalid template: placeholder at position 1 is not closed",
+		"@id}":    "invalid template: unexpected } at position 4, use \\} for the brace",
+		`{@id}\x`: `invalid template: unknown escape sequence \x at position 1`,
+		`{@id}\`:  "invalid template: incomplete escape sequence at position 6",
+	}
+	for input, expected := range errors {
+		_, err = ParseTemplate(input, "")
+		assert.EqualError(t, err, expected, input)
+	}
+
+	_, err = ParseTemplate("{.}", `\`)
+	assert.EqualError(t, err, "invalid separator: incomplete escape sequence at position 1")
+}
+
+func TestXPathQueryTemplate(t *testing.T) {
+	input := `<list><item id="1"><name>pen</name><tag/><tag/></item><item id="2"><name>book</name></item></list>`
+	template, err := ParseTemplate(`{@id}\t{name}\t{count(tag)}\t{name = 'pen'}`, `\n`)
+	assert.Nil(t, err)
+
+	output := new(strings.Builder)
+	err = XPathQuery(strings.NewReader(input), output, "//item", false, QueryOptions{Template: template})
+	assert.Nil(t, err)
+	assert.Equal(t, "1\tpen\t2\ttrue\n2\tbook\t0\tfalse\n", output.String())
+
+	output.Reset()
+	err = XPathStreamQuery(strings.NewReader(input), output, "//item", true, QueryOptions{Template: template})
+	assert.Nil(t, err)
+	assert.Equal(t, "1\tpen\t2\ttrue\n", output.String())
+
+	template, _ = ParseTemplate("{}", ", ")
+	output.Reset()
+	err = JSONXPathQuery(strings.NewReader(`{"items": [1, 2, 3]}`), output, "//items", false,
+		QueryOptions{Template: template})
+	assert.Nil(t, err)
+	assert.Equal(t, "1, 2, 3\n", output.String())
+
+	err = XPathQuery(strings.NewReader(input), output, "count(//item)", false, QueryOptions{Template: template})
+	assert.ErrorContains(t, err, "the query should select the nodes")
+
+	template, _ = ParseTemplate("{name[}", "")
+	err = XPathQuery(strings.NewReader(input), output, "//item", false, QueryOptions{Template: template})
+	assert.ErrorContains(t, err, "XPath error")
+}
+
+func TestCSSQueryTemplate(t *testing.T) {
+	input := `<ul><li class="new"><a href="/a">A</a> <b>first</b></li><li><a href="/b">B</a></li></ul>`
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(input))
+	assert.Nil(t, err)
+
+	template, err := ParseTemplate("{a}|{b}|{@class}|{}", `\n`)
+	assert.Nil(t, err)
+	output := new(strings.Builder)
+	assert.Nil(t, queryCSS(doc, output, "li", "", QueryOptions{Template: template}))
+	assert.Equal(t, "A|first|new|A first\nB|||B\n", output.String())
+}
@@ -0,0 +1,184 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+)
+
+const diffContextLines = 3
+
+type diffLine struct {
+	kind byte
+	text string
+}
+
+// UnifiedDiff returns the line-based difference between the texts in the unified format.
+// The empty string is returned if the texts are equal.
+func UnifiedDiff(oldName string, newName string, oldText string, newText string) string {
+	if oldText == newText {
+		return ""
+	}
+
+	lines := diffLines(splitLines(oldText), splitLines(newText))
+
+	var result strings.Builder
+	result.WriteString("--- " + oldName + "\n")
+	result.WriteString("+++ " + newName + "\n")
+
+	oldLine, newLine := 1, 1
+	for start := 0; start < len(lines); {
+		// find the next changed line
+		for start < len(lines) && lines[start].kind == ' ' {
+			start++
+			oldLine++
+			newLine++
+		}
+		if start == len(lines) {
+			break
+		}
+
+		// the hunk includes the context around the changes which are close to each other
+		hunkStart := max(start-diffContextLines, 0)
+		hunkEnd := start
+		for index := start; index < len(lines); index++ {
+			if lines[index].kind != ' ' {
+				hunkEnd = index + 1
+			} else if index-hunkEnd >= 2*diffContextLines {
+				break
+			}
+		}
+		hunkEnd = min(hunkEnd+diffContextLines, len(lines))
+
+		oldStart, newStart := oldLine-(start-hunkStart), newLine-(start-hunkStart)
+		oldCount, newCount := 0, 0
+		var hunk strings.Builder
+		for _, line := range lines[hunkStart:hunkEnd] {
+			if line.kind != '+' {
+				oldCount++
+			}
+			if line.kind != '-' {
+				newCount++
+			}
+			hunk.WriteByte(line.kind)
+			hunk.WriteString(line.text)
+			if !strings.HasSuffix(line.text, "\n") {
+				hunk.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+
+		result.WriteString(fmt.Sprintf("@@ -%s +%s @@\n", formatHunkRange(oldStart, oldCount),
+			formatHunkRange(newStart, newCount)))
+		result.WriteString(hunk.String())
+
+		for _, line := range lines[start:hunkEnd] {
+			if line.kind != '+' {
+				oldLine++
+			}
+			if line.kind != '-' {
+				newLine++
+			}
+		}
+		start = hunkEnd
+	}
+
+	return result.String()
+}
+
+func formatHunkRange(start int, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", start-1)
+	case 1:
+		return fmt.Sprintf("%d", start)
+	default:
+		return fmt.Sprintf("%d,%d", start, count)
+	}
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	return lines
+}
+
+// diffLines finds the shortest edit script using the Myers algorithm.
+func diffLines(oldLines []string, newLines []string) []diffLine {
+	n, m := len(oldLines), len(newLines)
+	limit := n + m
+	offset := limit + 1
+	frontier := make([]int, 2*limit+3)
+	var trace [][]int
+
+	for distance := 0; distance <= limit; distance++ {
+		// only the diagonals reachable within the distance are stored
+		trace = append(trace, append([]int{}, frontier[offset-distance-1:offset+distance+2]...))
+
+		for diagonal := -distance; diagonal <= distance; diagonal += 2 {
+			var x int
+			if diagonal == -distance || (diagonal != distance && frontier[offset+diagonal-1] < frontier[offset+diagonal+1]) {
+				x = frontier[offset+diagonal+1]
+			} else {
+				x = frontier[offset+diagonal-1] + 1
+			}
+			y := x - diagonal
+			for x < n && y < m && oldLines[x] == newLines[y] {
+				x++
+				y++
+			}
+			frontier[offset+diagonal] = x
+
+			if x >= n && y >= m {
+				return backtrackDiff(trace, oldLines, newLines)
+			}
+		}
+	}
+
+	return nil
+}
+
+func backtrackDiff(trace [][]int, oldLines []string, newLines []string) []diffLine {
+	var result []diffLine
+	x, y := len(oldLines), len(newLines)
+
+	for distance := len(trace) - 1; distance >= 0; distance-- {
+		frontier := trace[distance]
+		shift := distance + 1
+		diagonal := x - y
+
+		var previousDiagonal int
+		if diagonal == -distance || (diagonal != distance && frontier[shift+diagonal-1] < frontier[shift+diagonal+1]) {
+			previousDiagonal = diagonal + 1
+		} else {
+			previousDiagonal = diagonal - 1
+		}
+		previousX := frontier[shift+previousDiagonal]
+		previousY := previousX - previousDiagonal
+
+		for x > previousX && y > previousY {
+			result = append(result, diffLine{' ', oldLines[x-1]})
+			x--
+			y--
+		}
+		if distance > 0 {
+			if x == previousX {
+				result = append(result, diffLine{'+', newLines[y-1]})
+			} else {
+				result = append(result, diffLine{'-', oldLines[x-1]})
+			}
+		}
+		x, y = previousX, previousY
+	}
+
+	for left, right := 0, len(result)-1; left < right; left, right = left+1, right-1 {
+		result[left], result[right] = result[right], result[left]
+	}
+
+	return result
+}
@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		old      string
+		new      string
+		expected string
+	}{
+		{"a\nb\n", "a\nb\n", ""},
+		{"", "x\n", "--- old\n+++ new\n@@ -0,0 +1 @@\n+x\n"},
+		{"x\ny\n", "y\n", "--- old\n+++ new\n@@ -1,2 +1 @@\n-x\n y\n"},
+		{"a\nb", "a\nb\n", "--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n"},
+		{
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			"1\n2x\n3\n4\n5\n6\n7\n8\n9\n10\n12\n",
+			"--- old\n+++ new\n@@ -1,5 +1,5 @@\n 1\n-2\n+2x\n 3\n 4\n 5\n@@ -8,5 +8,4 @@\n 8\n 9\n 10\n-11\n 12\n",
+		},
+	}
+
+	for _, testCase := range tests {
+		assert.Equal(t, testCase.expected, UnifiedDiff("old", "new", testCase.old, testCase.new))
+	}
+}
@@ -4,9 +4,9 @@ import (
 	"bytes"
 	"encoding/json"
 	"encoding/xml"
-	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os/exec"
 	"regexp"
 	"strconv"
@@ -33,17 +33,53 @@ const (
 	ContentXml ContentType = iota
 	ContentHtml
 	ContentJson
+	ContentYaml
 	ContentText
 )
 
 type QueryOptions struct {
 	WithTags bool
+	JSON     bool
 	Indent   string
 	Colors   int
+	// Namespaces binds the prefixes used in the XPath queries to the namespace URIs.
+	Namespaces map[string]string
+	// Template formats a record for each matched node instead of the text.
+	Template *OutputTemplate
+	// JSONMapper converts the matched elements for the JSON output, NodeToJSON is used if nil.
+	JSONMapper JSONMapper
+	// Markdown converts the HTML elements matched by the CSS selector to Markdown.
+	Markdown bool
+	// TextWidth renders the HTML elements matched by the CSS selector as the plain text wrapped
+	// at the width if positive.
+	TextWidth int
+}
+
+// newColorFunc returns the function highlighting the text with the color attributes. The
+// default mode follows the global setting, the other modes don't depend on it and don't
+// change it, so that the documents can be formatted concurrently in different modes.
+func newColorFunc(colors int, attributes ...color.Attribute) func(a ...any) string {
+	result := color.New(attributes...)
+	switch colors {
+	case ColorsForced:
+		result.EnableColor()
+	case ColorsDisabled:
+		result.DisableColor()
+	}
+
+	return result.SprintFunc()
+}
+
+// getXmlColorFuncs returns the functions highlighting the tags, the attributes and the comments
+// of the markup documents.
+func getXmlColorFuncs(colors int) (tagColor, attrColor, commentColor func(a ...any) string) {
+	return newColorFunc(colors, color.FgYellow), newColorFunc(colors, color.FgGreen),
+		newColorFunc(colors, color.FgHiBlue)
 }
 
 func FormatXml(reader io.Reader, writer io.Writer, indent string, colors int) error {
-	decoder := xml.NewDecoder(reader)
+	source := newSourceRecorder(reader)
+	decoder := xml.NewDecoder(source)
 	decoder.Strict = false
 	decoder.CharsetReader = getCharsetReader
 
@@ -58,13 +94,7 @@ func FormatXml(reader io.Reader, writer io.Writer, indent string, colors int) er
 		newline = ""
 	}
 
-	if ColorsDefault != colors {
-		color.NoColor = colors == ColorsDisabled
-	}
-
-	tagColor := color.New(color.FgYellow).SprintFunc()
-	attrColor := color.New(color.FgGreen).SprintFunc()
-	commentColor := color.New(color.FgHiBlue).SprintFunc()
+	tagColor, attrColor, commentColor := getXmlColorFuncs(colors)
 
 	write := func(args ...any) error {
 		_, err := fmt.Fprint(writer, args...)
@@ -79,7 +109,7 @@ func FormatXml(reader io.Reader, writer io.Writer, indent string, colors int) er
 		}
 
 		if err != nil {
-			return err
+			return newXmlDiagnostic(err, source, decoder)
 		}
 
 		switch typedToken := token.(type) {
@@ -207,45 +237,51 @@ func FormatXml(reader io.Reader, writer io.Writer, indent string, colors int) er
 	return write("\n")
 }
 
-func XPathQuery(reader io.Reader, writer io.Writer, query string, singleNode bool, options QueryOptions) (errRes error) {
+func XPathQuery(reader io.Reader, writer io.Writer, query string, singleNode bool, options QueryOptions) error {
+	doc, err := parseXml(reader)
+	if err != nil {
+		return err
+	}
+
+	return queryXPath(doc, writer, query, singleNode, options)
+}
+
+func queryXPath(doc *xmlquery.Node, writer io.Writer, query string, singleNode bool, options QueryOptions) (errRes error) {
 	defer func() {
 		if err := recover(); err != nil {
-			errRes = fmt.Errorf("XPath error: %v", err)
+			errRes = newXPathDiagnostic(query, err)
 		}
 	}()
 
-	doc, err := xmlquery.ParseWithOptions(reader, xmlquery.ParserOptions{
-		Decoder: &xmlquery.DecoderOptions{
-			Strict:        false,
-			CharsetReader: getCharsetReader,
-		},
-	})
+	expr, err := compileXPath(doc, query, options.Namespaces)
 	if err != nil {
-		return err
+		return newXPathDiagnostic(query, err)
+	}
+
+	if options.Template != nil {
+		return printXPathTemplate(doc, writer, expr, singleNode, options)
+	}
+	if options.JSON {
+		return printXPathJSON(doc, writer, expr, singleNode, options)
 	}
 
 	if singleNode {
-		if n := xmlquery.FindOne(doc, query); n != nil {
+		if n := xmlquery.QuerySelector(doc, expr); n != nil {
 			return printNodeContent(writer, n, options)
 		}
 	} else if options.WithTags {
-		for _, n := range xmlquery.Find(doc, query) {
+		for _, n := range xmlquery.QuerySelectorAll(doc, expr) {
 			err := printNodeContent(writer, n, options)
 			if err != nil {
 				return err
 			}
 		}
 	} else {
-		expr, _ := xpath.Compile(query)
-		if expr == nil {
-			return errors.New("unable to parse the XPath query")
-		}
-
 		val := expr.Evaluate(xmlquery.CreateXPathNavigator(doc))
 
 		switch typedVal := val.(type) {
 		case float64:
-			_, err = fmt.Fprintf(writer, "%.0f\n", typedVal)
+			_, err = fmt.Fprintf(writer, "%s\n", formatXPathNumber(typedVal))
 		case bool:
 			_, err = fmt.Fprintf(writer, "%t\n", typedVal)
 		case string:
@@ -270,6 +306,125 @@ func XPathQuery(reader io.Reader, writer io.Writer, query string, singleNode boo
 	return nil
 }
 
+// printXPathJSON prints the result of the query as typed JSON. The matched elements are
+// converted with the JSON mapper, the attributes and the text nodes become strings. The node-set is
+// printed as an array unless a single node is extracted.
+func printXPathJSON(doc *xmlquery.Node, writer io.Writer, expr *xpath.Expr, singleNode bool,
+	options QueryOptions) error {
+	mapper := options.JSONMapper
+	if mapper == nil {
+		mapper, _ = NewJSONMapper("", JSONMapperOptions{})
+	}
+
+	var results []jsonQueryResult
+	isNodeSet := false
+	switch value := expr.Evaluate(xmlquery.CreateXPathNavigator(doc)).(type) {
+	case *xpath.NodeIterator:
+		isNodeSet = true
+		for value.MoveNext() {
+			navigator := value.Current().(*xmlquery.NodeNavigator)
+			var result interface{}
+			switch navigator.NodeType() {
+			case xpath.ElementNode, xpath.RootNode:
+				result = mapper.NodeToJSON(navigator.Current(), -1)
+			case xpath.AttributeNode:
+				result = navigator.Value()
+			default:
+				result = strings.TrimSpace(navigator.Value())
+			}
+			results = append(results, jsonQueryResult{value: result, isNode: true})
+			if singleNode {
+				break
+			}
+		}
+	case float64:
+		results = append(results, jsonQueryResult{value: getXPathNumberValue(value)})
+	case bool, string:
+		results = append(results, jsonQueryResult{value: value})
+	default:
+		return fmt.Errorf("unknown type error: %v", value)
+	}
+
+	return printJSONQueryResults(writer, results, isNodeSet && !singleNode, options)
+}
+
+// getXPathNumberValue returns the number to be encoded as JSON. JSON has no representation for
+// NaN and infinities, so they become null.
+func getXPathNumberValue(value float64) interface{} {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil
+	}
+	return value
+}
+
+// compileXPath compiles the query with the namespace prefixes declared in the document, the
+// provided bindings take precedence. The prefixes are matched literally if none are declared.
+func compileXPath(doc *xmlquery.Node, query string, namespaces map[string]string) (*xpath.Expr, error) {
+	bindings := map[string]string{}
+	if doc != nil {
+		collectNamespaces(doc, bindings)
+	}
+	for prefix, uri := range namespaces {
+		bindings[prefix] = uri
+	}
+	if len(bindings) == 0 {
+		return xpath.Compile(query)
+	}
+
+	bindings["xml"] = xmlNamespace
+	return xpath.CompileWithNS(query, bindings)
+}
+
+// collectNamespaces adds the prefixes declared in the document to the bindings. The declarations
+// of the root element are found first, so they win over the redeclarations of the same prefix.
+func collectNamespaces(node *xmlquery.Node, bindings map[string]string) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != xmlquery.ElementNode {
+			continue
+		}
+		for _, attr := range child.Attr {
+			if attr.Name.Space == "xmlns" {
+				if _, ok := bindings[attr.Name.Local]; !ok {
+					bindings[attr.Name.Local] = attr.Value
+				}
+			}
+		}
+		collectNamespaces(child, bindings)
+	}
+}
+
+// ParseNamespaces parses the prefix=uri bindings, one value may contain several bindings
+// separated by spaces.
+func ParseNamespaces(values []string) (map[string]string, error) {
+	namespaces := map[string]string{}
+	for _, value := range values {
+		for _, binding := range strings.Fields(value) {
+			prefix, uri, found := strings.Cut(binding, "=")
+			if !found || uri == "" || !isXmlName(prefix) {
+				return nil, fmt.Errorf("invalid namespace binding %q, use prefix=uri", binding)
+			}
+			namespaces[prefix] = uri
+		}
+	}
+
+	return namespaces, nil
+}
+
+func parseXml(reader io.Reader) (*xmlquery.Node, error) {
+	source := newSourceRecorder(reader)
+	doc, err := xmlquery.ParseWithOptions(source, xmlquery.ParserOptions{
+		Decoder: &xmlquery.DecoderOptions{
+			Strict:        false,
+			CharsetReader: getCharsetReader,
+		},
+	})
+	if err != nil {
+		return nil, newXmlDiagnostic(err, source, nil)
+	}
+
+	return doc, nil
+}
+
 func printNodeContent(writer io.Writer, node *xmlquery.Node, options QueryOptions) error {
 	if options.WithTags {
 		reader := strings.NewReader(node.OutputXML(true))
@@ -286,6 +441,20 @@ func CSSQuery(reader io.Reader, writer io.Writer, query string, attr string, opt
 		return err
 	}
 
+	return queryCSS(doc, writer, query, attr, options)
+}
+
+func queryCSS(doc *goquery.Document, writer io.Writer, query string, attr string, options QueryOptions) error {
+	if options.Template != nil {
+		return printCSSTemplate(doc.Find(query), writer, options.Template)
+	}
+	if options.Markdown {
+		return writeMarkdown(writer, doc.Find(query).Nodes)
+	}
+	if options.TextWidth > 0 {
+		return writeHtmlText(writer, doc.Find(query).Nodes, options.TextWidth)
+	}
+
 	doc.Find(query).Each(func(index int, item *goquery.Selection) {
 		if attr != "" {
 			_, _ = fmt.Fprintf(writer, "%s\n", strings.TrimSpace(item.AttrOr(attr, "")))
@@ -317,13 +486,7 @@ func CSSQuery(reader io.Reader, writer io.Writer, query string, attr string, opt
 func FormatHtml(reader io.Reader, writer io.Writer, indent string, colors int) error {
 	tokenizer := html.NewTokenizer(reader)
 
-	if ColorsDefault != colors {
-		color.NoColor = colors == ColorsDisabled
-	}
-
-	tagColor := color.New(color.FgYellow).SprintFunc()
-	attrColor := color.New(color.FgGreen).SprintFunc()
-	commentColor := color.New(color.FgHiBlue).SprintFunc()
+	tagColor, attrColor, commentColor := getXmlColorFuncs(colors)
 
 	level := 0
 	hasContent := false
@@ -445,16 +608,13 @@ func FormatHtml(reader io.Reader, writer io.Writer, indent string, colors int) e
 }
 
 func FormatJson(reader io.Reader, writer io.Writer, indent string, colors int) error {
-	decoder := json.NewDecoder(reader)
+	source := newSourceRecorder(reader)
+	decoder := json.NewDecoder(source)
 	decoder.UseNumber()
 
-	if ColorsDefault != colors {
-		color.NoColor = colors == ColorsDisabled
-	}
-
-	tagColor := color.New(color.FgYellow).SprintFunc()
-	attrColor := color.New(color.FgHiBlue).SprintFunc()
-	valueColor := color.New(color.FgGreen).SprintFunc()
+	tagColor := newColorFunc(colors, color.FgYellow)
+	attrColor := newColorFunc(colors, color.FgHiBlue)
+	valueColor := newColorFunc(colors, color.FgGreen)
 
 	newline := "\n"
 	if indent == "" {
@@ -585,11 +745,11 @@ func FormatJson(reader io.Reader, writer io.Writer, indent string, colors int) e
 		}
 
 		if err != nil {
-			return err
+			return newJsonDiagnostic(err, decoder, source)
 		}
 
 		if err := formatToken(token, 0); err != nil {
-			return err
+			return newJsonDiagnostic(err, decoder, source)
 		}
 	}
 
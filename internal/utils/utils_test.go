@@ -194,6 +194,69 @@ func TestXPathQueryBoolean(t *testing.T) {
 	}
 }
 
+func TestXPathQueryNamespaces(t *testing.T) {
+	content, err := os.ReadFile(filepath.Join("..", "..", "test", "data", "ns", "pom.xml"))
+	assert.Nil(t, err)
+	pom := string(content)
+	options := QueryOptions{Namespaces: map[string]string{"m": "http://maven.apache.org/POM/4.0.0"}}
+
+	output := new(strings.Builder)
+	err = XPathQuery(strings.NewReader(pom), output, "//m:dependency/m:artifactId", false, options)
+	assert.Nil(t, err)
+	assert.Equal(t, "junit\nslf4j-api\n", output.String())
+
+	output.Reset()
+	err = XPathQuery(strings.NewReader(pom), output, "/m:project/@xsi:schemaLocation", true, options)
+	assert.Nil(t, err)
+	assert.Contains(t, output.String(), "maven-4.0.0.xsd")
+
+	output.Reset()
+	err = XPathQuery(strings.NewReader(`<a xmlns:x="urn:x"><x:b>1</x:b><y:b xmlns:y="urn:x">2</y:b></a>`), output,
+		"//x:b", false, QueryOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "1\n2\n", output.String())
+
+	err = XPathQuery(strings.NewReader(pom), io.Discard, "//p:dependency", false, options)
+	assert.ErrorContains(t, err, "prefix p not defined")
+
+	namespaces, err := ParseNamespaces([]string{"m=urn:m x=urn:x", "x=urn:y"})
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{"m": "urn:m", "x": "urn:y"}, namespaces)
+	_, err = ParseNamespaces([]string{"a:b=urn:x"})
+	assert.ErrorContains(t, err, `invalid namespace binding "a:b=urn:x"`)
+}
+
+func TestXPathQueryJSON(t *testing.T) {
+	input := `<shop><item price="1.25" code="a">Pen</item><item price="2.5"><name>Book</name></item></shop>`
+	tests := []struct {
+		query    string
+		single   bool
+		expected string
+	}{
+		{"sum(//@price)", false, "3.75"},
+		{"count(//item) > 1", false, "true"},
+		{"string(//name)", false, `"Book"`},
+		{"number('x')", false, "null"},
+		{"//item", false, `[{"#text": "Pen","@code": "a","@price": "1.25"},{"@price": "2.5","name": "Book"}]`},
+		{"//item", true, `{"#text": "Pen","@code": "a","@price": "1.25"}`},
+		{"//@code | //item/text()", false, `["a","Pen"]`},
+		{"//missing", false, "[]"},
+		{"//missing", true, "null"},
+	}
+
+	for _, test := range tests {
+		output := new(strings.Builder)
+		err := XPathQuery(strings.NewReader(input), output, test.query, test.single, QueryOptions{JSON: true})
+		assert.Nil(t, err, test.query)
+		assert.Equal(t, test.expected+"\n", output.String(), test.query)
+	}
+
+	output := new(strings.Builder)
+	err := XPathQuery(strings.NewReader(input), output, "sum(//@price) div 2", false, QueryOptions{})
+	assert.Nil(t, err)
+	assert.Equal(t, "1.875\n", output.String())
+}
+
 func TestCSSQuery(t *testing.T) {
 	type test struct {
 		input  string
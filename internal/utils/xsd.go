@@ -0,0 +1,891 @@
+package utils
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+)
+
+const (
+	xsdNamespace = "http://www.w3.org/2001/XMLSchema"
+	xsiNamespace = "http://www.w3.org/2001/XMLSchema-instance"
+	xmlNamespace = "http://www.w3.org/XML/1998/namespace"
+)
+
+type xsdParticleKind int
+
+const (
+	xsdElementParticle xsdParticleKind = iota
+	xsdSequenceParticle
+	xsdChoiceParticle
+	xsdAllParticle
+	xsdAnyParticle
+)
+
+// XmlSchema is a set of XML Schema documents loaded from a schema file and the files
+// it includes or imports. The components are compiled lazily on the first use.
+type XmlSchema struct {
+	elements          map[xml.Name]xsdComponent
+	types             map[xml.Name]xsdComponent
+	groups            map[xml.Name]xsdComponent
+	attributeGroups   map[xml.Name]xsdComponent
+	attributes        map[xml.Name]xsdComponent
+	substitutions     map[xml.Name][]xml.Name
+	loadedFiles       map[string]bool
+	compiledElements  map[*xmlquery.Node]*xsdElement
+	compiledTypes     map[*xmlquery.Node]*xsdType
+	compiledSimple    map[*xmlquery.Node]*xsdSimpleType
+	compiledAttribute map[*xmlquery.Node]*xsdAttribute
+}
+
+type xsdDocument struct {
+	targetNamespace     string
+	qualifiedElements   bool
+	qualifiedAttributes bool
+}
+
+type xsdComponent struct {
+	node     *xmlquery.Node
+	document *xsdDocument
+}
+
+type xsdElement struct {
+	name         xml.Name
+	typeDef      *xsdType
+	nillable     bool
+	abstract     bool
+	fixed        *string
+	substitutes  []*xsdElement
+	component    xsdComponent
+	typeResolved bool
+}
+
+type xsdType struct {
+	name          string
+	anyType       bool
+	simple        *xsdSimpleType
+	mixed         bool
+	content       *xsdParticle
+	attributes    []*xsdAttribute
+	anyAttribute  *xsdWildcard
+	isSimpleType  bool
+	complexLoaded bool
+}
+
+type xsdAttribute struct {
+	name       xml.Name
+	simple     *xsdSimpleType
+	required   bool
+	prohibited bool
+	fixed      *string
+}
+
+type xsdWildcard struct {
+	namespaces      []string
+	not             []string
+	processContents string
+}
+
+type xsdParticle struct {
+	kind      xsdParticleKind
+	min       int
+	max       int
+	element   *xsdElement
+	children  []*xsdParticle
+	wildcard  *xsdWildcard
+	component xsdComponent
+}
+
+// LoadXmlSchema loads the schema from the file together with the local files referenced
+// by xs:include and xs:import.
+func LoadXmlSchema(fileName string) (*XmlSchema, error) {
+	schema := &XmlSchema{
+		elements:          map[xml.Name]xsdComponent{},
+		types:             map[xml.Name]xsdComponent{},
+		groups:            map[xml.Name]xsdComponent{},
+		attributeGroups:   map[xml.Name]xsdComponent{},
+		attributes:        map[xml.Name]xsdComponent{},
+		substitutions:     map[xml.Name][]xml.Name{},
+		loadedFiles:       map[string]bool{},
+		compiledElements:  map[*xmlquery.Node]*xsdElement{},
+		compiledTypes:     map[*xmlquery.Node]*xsdType{},
+		compiledSimple:    map[*xmlquery.Node]*xsdSimpleType{},
+		compiledAttribute: map[*xmlquery.Node]*xsdAttribute{},
+	}
+
+	if err := schema.loadFile(fileName, "", false); err != nil {
+		return nil, err
+	}
+
+	return schema, nil
+}
+
+func (schema *XmlSchema) loadFile(fileName string, includingNamespace string, included bool) error {
+	path, err := filepath.Abs(fileName)
+	if err != nil {
+		return err
+	}
+	if schema.loadedFiles[path] {
+		return nil
+	}
+	schema.loadedFiles[path] = true
+
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = file.Close()
+	}()
+
+	doc, err := xmlquery.ParseWithOptions(file, xmlquery.ParserOptions{
+		Decoder: &xmlquery.DecoderOptions{Strict: true, CharsetReader: getCharsetReader},
+	})
+	if err != nil {
+		return fmt.Errorf("unable to parse schema %s: %w", fileName, err)
+	}
+
+	root := getFirstElement(doc)
+	if !isXsdElement(root, "schema") {
+		return fmt.Errorf("%s is not an XML Schema document", fileName)
+	}
+
+	document := &xsdDocument{
+		targetNamespace:     root.SelectAttr("targetNamespace"),
+		qualifiedElements:   root.SelectAttr("elementFormDefault") == "qualified",
+		qualifiedAttributes: root.SelectAttr("attributeFormDefault") == "qualified",
+	}
+	if included && document.targetNamespace == "" {
+		document.targetNamespace = includingNamespace
+	}
+
+	for child := root.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != xmlquery.ElementNode || child.NamespaceURI != xsdNamespace {
+			continue
+		}
+
+		component := xsdComponent{node: child, document: document}
+		name := xml.Name{Space: document.targetNamespace, Local: child.SelectAttr("name")}
+		location := child.SelectAttr("schemaLocation")
+
+		switch child.Data {
+		case "include":
+			err = schema.loadReferencedFile(fileName, location, document.targetNamespace, true)
+		case "import":
+			if location != "" {
+				err = schema.loadReferencedFile(fileName, location, "", false)
+			}
+		case "redefine", "override":
+			err = fmt.Errorf("xs:%s is not supported", child.Data)
+		case "element":
+			schema.elements[name] = component
+			if head := child.SelectAttr("substitutionGroup"); head != "" {
+				headName, resolveErr := resolveXsdQName(child, head)
+				if resolveErr != nil {
+					return resolveErr
+				}
+				schema.substitutions[headName] = append(schema.substitutions[headName], name)
+			}
+		case "complexType", "simpleType":
+			schema.types[name] = component
+		case "group":
+			schema.groups[name] = component
+		case "attributeGroup":
+			schema.attributeGroups[name] = component
+		case "attribute":
+			schema.attributes[name] = component
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (schema *XmlSchema) loadReferencedFile(fileName string, location string, namespace string, included bool) error {
+	if strings.Contains(location, "://") {
+		return fmt.Errorf("only local schema files are supported, got %s", location)
+	}
+
+	return schema.loadFile(filepath.Join(filepath.Dir(fileName), location), namespace, included)
+}
+
+func getFirstElement(node *xmlquery.Node) *xmlquery.Node {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == xmlquery.ElementNode {
+			return child
+		}
+	}
+
+	return nil
+}
+
+func isXsdElement(node *xmlquery.Node, name string) bool {
+	return node != nil && node.Type == xmlquery.ElementNode && node.NamespaceURI == xsdNamespace && node.Data == name
+}
+
+func getXsdChildren(node *xmlquery.Node) []*xmlquery.Node {
+	var children []*xmlquery.Node
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == xmlquery.ElementNode && child.NamespaceURI == xsdNamespace && child.Data != "annotation" {
+			children = append(children, child)
+		}
+	}
+
+	return children
+}
+
+func getXsdChild(node *xmlquery.Node, names ...string) *xmlquery.Node {
+	for _, child := range getXsdChildren(node) {
+		for _, name := range names {
+			if child.Data == name {
+				return child
+			}
+		}
+	}
+
+	return nil
+}
+
+func getAttrValue(node *xmlquery.Node, name string) (string, bool) {
+	for _, attr := range node.Attr {
+		if attr.Name.Space == "" && attr.Name.Local == name {
+			return attr.Value, true
+		}
+	}
+
+	return "", false
+}
+
+// resolveXsdQName resolves the prefix of the qualified name using the namespace
+// declarations in scope of the node.
+func resolveXsdQName(node *xmlquery.Node, value string) (xml.Name, error) {
+	prefix, local, found := strings.Cut(strings.TrimSpace(value), ":")
+	if !found {
+		prefix, local = "", prefix
+	}
+	if prefix == "xml" {
+		return xml.Name{Space: xmlNamespace, Local: local}, nil
+	}
+
+	for current := node; current != nil; current = current.Parent {
+		for _, attr := range current.Attr {
+			if (prefix == "" && attr.Name.Space == "" && attr.Name.Local == "xmlns") ||
+				(prefix != "" && attr.Name.Space == "xmlns" && attr.Name.Local == prefix) {
+				return xml.Name{Space: attr.Value, Local: local}, nil
+			}
+		}
+	}
+
+	if prefix != "" {
+		return xml.Name{}, fmt.Errorf("undeclared namespace prefix in %q", value)
+	}
+
+	return xml.Name{Local: local}, nil
+}
+
+func parseXsdOccurs(node *xmlquery.Node) (int, int, error) {
+	minOccurs, maxOccurs := 1, 1
+	var err error
+
+	if value, ok := getAttrValue(node, "minOccurs"); ok {
+		if minOccurs, err = strconv.Atoi(value); err != nil {
+			return 0, 0, fmt.Errorf("invalid minOccurs value %q", value)
+		}
+	}
+	if value, ok := getAttrValue(node, "maxOccurs"); ok {
+		if value == "unbounded" {
+			maxOccurs = -1
+		} else if maxOccurs, err = strconv.Atoi(value); err != nil {
+			return 0, 0, fmt.Errorf("invalid maxOccurs value %q", value)
+		}
+	}
+
+	return minOccurs, maxOccurs, nil
+}
+
+func (schema *XmlSchema) getGlobalElement(name xml.Name) (*xsdElement, error) {
+	component, ok := schema.elements[name]
+	if !ok {
+		return nil, nil
+	}
+
+	return schema.compileElement(component, true)
+}
+
+func (schema *XmlSchema) lookup(components map[xml.Name]xsdComponent, node *xmlquery.Node, ref string, kind string) (xsdComponent, error) {
+	name, err := resolveXsdQName(node, ref)
+	if err != nil {
+		return xsdComponent{}, err
+	}
+
+	component, ok := components[name]
+	if !ok {
+		return xsdComponent{}, fmt.Errorf("schema error: %s %q is not defined", kind, ref)
+	}
+
+	return component, nil
+}
+
+func (schema *XmlSchema) compileElement(component xsdComponent, global bool) (*xsdElement, error) {
+	node := component.node
+	if element, ok := schema.compiledElements[node]; ok {
+		return element, nil
+	}
+
+	if ref := node.SelectAttr("ref"); ref != "" {
+		refComponent, err := schema.lookup(schema.elements, node, ref, "element")
+		if err != nil {
+			return nil, err
+		}
+		return schema.compileElement(refComponent, true)
+	}
+
+	name := xml.Name{Local: node.SelectAttr("name")}
+	form, hasForm := getAttrValue(node, "form")
+	if global || (hasForm && form == "qualified") || (!hasForm && component.document.qualifiedElements) {
+		name.Space = component.document.targetNamespace
+	}
+
+	element := &xsdElement{
+		name:      name,
+		nillable:  node.SelectAttr("nillable") == "true",
+		abstract:  node.SelectAttr("abstract") == "true",
+		component: component,
+	}
+	if fixed, ok := getAttrValue(node, "fixed"); ok {
+		element.fixed = &fixed
+	}
+	schema.compiledElements[node] = element
+
+	if global {
+		for _, memberName := range schema.substitutions[name] {
+			member, err := schema.compileElement(schema.elements[memberName], true)
+			if err != nil {
+				return nil, err
+			}
+			element.substitutes = append(element.substitutes, member)
+			element.substitutes = append(element.substitutes, member.substitutes...)
+		}
+	}
+
+	return element, nil
+}
+
+// getType returns the type of the element. The type is resolved on demand since
+// the element declarations can be recursive.
+func (schema *XmlSchema) getElementType(element *xsdElement) (*xsdType, error) {
+	if element.typeResolved {
+		return element.typeDef, nil
+	}
+	element.typeResolved = true
+
+	node := element.component.node
+	var err error
+
+	switch {
+	case node.SelectAttr("type") != "":
+		element.typeDef, err = schema.getTypeByName(node, node.SelectAttr("type"))
+	case getXsdChild(node, "complexType", "simpleType") != nil:
+		element.typeDef, err = schema.compileType(xsdComponent{getXsdChild(node, "complexType", "simpleType"), element.component.document})
+	case node.SelectAttr("substitutionGroup") != "":
+		var head *xsdElement
+		headComponent, lookupErr := schema.lookup(schema.elements, node, node.SelectAttr("substitutionGroup"), "element")
+		if lookupErr != nil {
+			return nil, lookupErr
+		}
+		if head, err = schema.compileElement(headComponent, true); err == nil {
+			element.typeDef, err = schema.getElementType(head)
+		}
+	default:
+		element.typeDef = &xsdType{name: "anyType", anyType: true}
+	}
+
+	return element.typeDef, err
+}
+
+func (schema *XmlSchema) getTypeByName(node *xmlquery.Node, typeName string) (*xsdType, error) {
+	name, err := resolveXsdQName(node, typeName)
+	if err != nil {
+		return nil, err
+	}
+
+	if name.Space == xsdNamespace {
+		if name.Local == "anyType" {
+			return &xsdType{name: "anyType", anyType: true}, nil
+		}
+		if simpleType := getXsdBuiltinSimpleType(name.Local); simpleType != nil {
+			return &xsdType{name: name.Local, simple: simpleType, isSimpleType: true}, nil
+		}
+		return nil, fmt.Errorf("schema error: unknown built-in type %q", typeName)
+	}
+
+	component, ok := schema.types[name]
+	if !ok {
+		return nil, fmt.Errorf("schema error: type %q is not defined", typeName)
+	}
+
+	return schema.compileType(component)
+}
+
+func (schema *XmlSchema) getSimpleTypeByName(node *xmlquery.Node, typeName string) (*xsdSimpleType, error) {
+	typeDef, err := schema.getTypeByName(node, typeName)
+	if err != nil {
+		return nil, err
+	}
+	if typeDef.anyType {
+		return getXsdBuiltinSimpleType("anySimpleType"), nil
+	}
+	if typeDef.simple == nil {
+		return nil, fmt.Errorf("schema error: %q is not a simple type", typeName)
+	}
+
+	return typeDef.simple, nil
+}
+
+func (schema *XmlSchema) compileType(component xsdComponent) (*xsdType, error) {
+	node := component.node
+	if typeDef, ok := schema.compiledTypes[node]; ok {
+		return typeDef, nil
+	}
+
+	typeDef := &xsdType{name: node.SelectAttr("name")}
+	schema.compiledTypes[node] = typeDef
+
+	if node.Data == "simpleType" {
+		simpleType, err := schema.compileSimpleType(component)
+		if err != nil {
+			return nil, err
+		}
+		typeDef.simple = simpleType
+		typeDef.isSimpleType = true
+		return typeDef, nil
+	}
+
+	return typeDef, schema.compileComplexType(component, typeDef)
+}
+
+func (schema *XmlSchema) compileComplexType(component xsdComponent, typeDef *xsdType) error {
+	node := component.node
+	typeDef.mixed = node.SelectAttr("mixed") == "true"
+	var err error
+
+	if content := getXsdChild(node, "simpleContent"); content != nil {
+		derivation := getXsdChild(content, "extension", "restriction")
+		if derivation == nil {
+			return errors.New("schema error: simpleContent without derivation")
+		}
+		baseType, err := schema.getTypeByName(derivation, derivation.SelectAttr("base"))
+		if err != nil {
+			return err
+		}
+		typeDef.simple = baseType.simple
+		if baseType.anyType {
+			typeDef.simple = getXsdBuiltinSimpleType("anySimpleType")
+		}
+		if derivation.Data == "restriction" {
+			restriction := &xsdSimpleType{base: typeDef.simple}
+			if inline := getXsdChild(derivation, "simpleType"); inline != nil {
+				if restriction.base, err = schema.compileSimpleType(xsdComponent{inline, component.document}); err != nil {
+					return err
+				}
+			}
+			if err = compileXsdFacets(derivation, restriction); err != nil {
+				return err
+			}
+			typeDef.simple = restriction
+		}
+		typeDef.attributes = baseType.attributes
+		typeDef.anyAttribute = baseType.anyAttribute
+		return schema.compileAttributes(xsdComponent{derivation, component.document}, typeDef, derivation.Data == "restriction")
+	}
+
+	if content := getXsdChild(node, "complexContent"); content != nil {
+		if mixed, ok := getAttrValue(content, "mixed"); ok {
+			typeDef.mixed = mixed == "true"
+		}
+		derivation := getXsdChild(content, "extension", "restriction")
+		if derivation == nil {
+			return errors.New("schema error: complexContent without derivation")
+		}
+		baseType, err := schema.getTypeByName(derivation, derivation.SelectAttr("base"))
+		if err != nil {
+			return err
+		}
+		if baseType.anyType && derivation.Data == "extension" {
+			typeDef.anyType = true
+		}
+		particle, err := schema.compileContentParticle(xsdComponent{derivation, component.document})
+		if err != nil {
+			return err
+		}
+		if derivation.Data == "extension" {
+			typeDef.mixed = typeDef.mixed || baseType.mixed
+			switch {
+			case baseType.content == nil:
+				typeDef.content = particle
+			case particle == nil:
+				typeDef.content = baseType.content
+			default:
+				typeDef.content = &xsdParticle{kind: xsdSequenceParticle, min: 1, max: 1,
+					children: []*xsdParticle{baseType.content, particle}}
+			}
+		} else {
+			typeDef.content = particle
+		}
+		typeDef.attributes = baseType.attributes
+		typeDef.anyAttribute = baseType.anyAttribute
+		return schema.compileAttributes(xsdComponent{derivation, component.document}, typeDef, derivation.Data == "restriction")
+	}
+
+	if typeDef.content, err = schema.compileContentParticle(component); err != nil {
+		return err
+	}
+
+	return schema.compileAttributes(component, typeDef, false)
+}
+
+func (schema *XmlSchema) compileContentParticle(component xsdComponent) (*xsdParticle, error) {
+	particleNode := getXsdChild(component.node, "sequence", "choice", "all", "group")
+	if particleNode == nil {
+		return nil, nil
+	}
+
+	return schema.compileParticle(xsdComponent{particleNode, component.document})
+}
+
+func (schema *XmlSchema) compileParticle(component xsdComponent) (*xsdParticle, error) {
+	node := component.node
+	minOccurs, maxOccurs, err := parseXsdOccurs(node)
+	if err != nil {
+		return nil, err
+	}
+	particle := &xsdParticle{min: minOccurs, max: maxOccurs, component: component}
+
+	switch node.Data {
+	case "element":
+		particle.kind = xsdElementParticle
+		if particle.element, err = schema.compileElement(component, false); err != nil {
+			return nil, err
+		}
+	case "any":
+		particle.kind = xsdAnyParticle
+		particle.wildcard = compileXsdWildcard(component)
+	case "group":
+		groupComponent, err := schema.lookup(schema.groups, node, node.SelectAttr("ref"), "group")
+		if err != nil {
+			return nil, err
+		}
+		group, err := schema.compileContentParticle(groupComponent)
+		if err != nil || group == nil {
+			return group, err
+		}
+		groupCopy := *group
+		groupCopy.min, groupCopy.max = minOccurs, maxOccurs
+		return &groupCopy, nil
+	case "sequence", "choice", "all":
+		particle.kind = map[string]xsdParticleKind{
+			"sequence": xsdSequenceParticle,
+			"choice":   xsdChoiceParticle,
+			"all":      xsdAllParticle,
+		}[node.Data]
+		for _, child := range getXsdChildren(node) {
+			childParticle, err := schema.compileParticle(xsdComponent{child, component.document})
+			if err != nil {
+				return nil, err
+			}
+			if childParticle != nil {
+				particle.children = append(particle.children, childParticle)
+			}
+		}
+	default:
+		return nil, fmt.Errorf("schema error: unexpected xs:%s in content model", node.Data)
+	}
+
+	return particle, nil
+}
+
+func compileXsdWildcard(component xsdComponent) *xsdWildcard {
+	node := component.node
+	wildcard := &xsdWildcard{processContents: node.SelectAttr("processContents")}
+	if wildcard.processContents == "" {
+		wildcard.processContents = "strict"
+	}
+
+	namespace := node.SelectAttr("namespace")
+	if namespace == "" {
+		namespace = "##any"
+	}
+
+	for _, item := range strings.Fields(namespace) {
+		switch item {
+		case "##any":
+			return wildcard
+		case "##other":
+			wildcard.not = append(wildcard.not, component.document.targetNamespace, "")
+		case "##targetNamespace":
+			wildcard.namespaces = append(wildcard.namespaces, component.document.targetNamespace)
+		case "##local":
+			wildcard.namespaces = append(wildcard.namespaces, "")
+		default:
+			wildcard.namespaces = append(wildcard.namespaces, item)
+		}
+	}
+	if wildcard.namespaces == nil && wildcard.not == nil {
+		wildcard.namespaces = []string{}
+	}
+
+	return wildcard
+}
+
+func (wildcard *xsdWildcard) allows(namespace string) bool {
+	for _, excluded := range wildcard.not {
+		if excluded == namespace {
+			return false
+		}
+	}
+	if wildcard.namespaces == nil {
+		return true
+	}
+	for _, allowed := range wildcard.namespaces {
+		if allowed == namespace {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (schema *XmlSchema) compileAttributes(component xsdComponent, typeDef *xsdType, restriction bool) error {
+	attributes := append([]*xsdAttribute{}, typeDef.attributes...)
+
+	var collect func(component xsdComponent) error
+	collect = func(component xsdComponent) error {
+		for _, child := range getXsdChildren(component.node) {
+			childComponent := xsdComponent{child, component.document}
+			switch child.Data {
+			case "attribute":
+				attribute, err := schema.compileAttribute(childComponent, false)
+				if err != nil {
+					return err
+				}
+				replaced := false
+				for index, existing := range attributes {
+					if existing.name == attribute.name {
+						attributes[index] = attribute
+						replaced = true
+					}
+				}
+				if !replaced {
+					attributes = append(attributes, attribute)
+				}
+			case "attributeGroup":
+				groupComponent, err := schema.lookup(schema.attributeGroups, child, child.SelectAttr("ref"), "attribute group")
+				if err != nil {
+					return err
+				}
+				if err = collect(groupComponent); err != nil {
+					return err
+				}
+			case "anyAttribute":
+				typeDef.anyAttribute = compileXsdWildcard(childComponent)
+			}
+		}
+		return nil
+	}
+
+	if err := collect(component); err != nil {
+		return err
+	}
+	if restriction {
+		var allowed []*xsdAttribute
+		for _, attribute := range attributes {
+			if !attribute.prohibited {
+				allowed = append(allowed, attribute)
+			}
+		}
+		attributes = allowed
+	}
+	typeDef.attributes = attributes
+
+	return nil
+}
+
+func (schema *XmlSchema) compileAttribute(component xsdComponent, global bool) (*xsdAttribute, error) {
+	node := component.node
+	if attribute, ok := schema.compiledAttribute[node]; ok {
+		return attribute, nil
+	}
+
+	use := node.SelectAttr("use")
+	var attribute *xsdAttribute
+
+	if ref := node.SelectAttr("ref"); ref != "" {
+		name, err := resolveXsdQName(node, ref)
+		if err != nil {
+			return nil, err
+		}
+		if name.Space == xmlNamespace {
+			attribute = &xsdAttribute{name: name, simple: getXsdBuiltinSimpleType("string")}
+		} else {
+			refComponent, err := schema.lookup(schema.attributes, node, ref, "attribute")
+			if err != nil {
+				return nil, err
+			}
+			globalAttribute, err := schema.compileAttribute(refComponent, true)
+			if err != nil {
+				return nil, err
+			}
+			attributeCopy := *globalAttribute
+			attribute = &attributeCopy
+		}
+	} else {
+		attribute = &xsdAttribute{name: xml.Name{Local: node.SelectAttr("name")}}
+		form, hasForm := getAttrValue(node, "form")
+		if global || (hasForm && form == "qualified") || (!hasForm && component.document.qualifiedAttributes) {
+			attribute.name.Space = component.document.targetNamespace
+		}
+
+		var err error
+		switch {
+		case node.SelectAttr("type") != "":
+			attribute.simple, err = schema.getSimpleTypeByName(node, node.SelectAttr("type"))
+		case getXsdChild(node, "simpleType") != nil:
+			attribute.simple, err = schema.compileSimpleType(xsdComponent{getXsdChild(node, "simpleType"), component.document})
+		default:
+			attribute.simple = getXsdBuiltinSimpleType("anySimpleType")
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if fixed, ok := getAttrValue(node, "fixed"); ok {
+		attribute.fixed = &fixed
+	}
+	attribute.required = use == "required"
+	attribute.prohibited = use == "prohibited"
+	schema.compiledAttribute[node] = attribute
+
+	return attribute, nil
+}
+
+func (schema *XmlSchema) compileSimpleType(component xsdComponent) (*xsdSimpleType, error) {
+	node := component.node
+	if simpleType, ok := schema.compiledSimple[node]; ok {
+		return simpleType, nil
+	}
+
+	simpleType := &xsdSimpleType{name: node.SelectAttr("name")}
+	schema.compiledSimple[node] = simpleType
+
+	derivation := getXsdChild(node, "restriction", "list", "union")
+	if derivation == nil {
+		return nil, errors.New("schema error: simpleType without derivation")
+	}
+	derivationComponent := xsdComponent{derivation, component.document}
+	var err error
+
+	switch derivation.Data {
+	case "restriction":
+		if base := derivation.SelectAttr("base"); base != "" {
+			simpleType.base, err = schema.getSimpleTypeByName(derivation, base)
+		} else if inline := getXsdChild(derivation, "simpleType"); inline != nil {
+			simpleType.base, err = schema.compileSimpleType(xsdComponent{inline, component.document})
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = compileXsdFacets(derivation, simpleType)
+	case "list":
+		simpleType.variety = xsdList
+		if itemType := derivation.SelectAttr("itemType"); itemType != "" {
+			simpleType.itemType, err = schema.getSimpleTypeByName(derivation, itemType)
+		} else if inline := getXsdChild(derivation, "simpleType"); inline != nil {
+			simpleType.itemType, err = schema.compileSimpleType(xsdComponent{inline, component.document})
+		} else {
+			err = errors.New("schema error: list without item type")
+		}
+	case "union":
+		simpleType.variety = xsdUnion
+		for _, memberType := range strings.Fields(derivation.SelectAttr("memberTypes")) {
+			member, err := schema.getSimpleTypeByName(derivation, memberType)
+			if err != nil {
+				return nil, err
+			}
+			simpleType.memberTypes = append(simpleType.memberTypes, member)
+		}
+		for _, inline := range getXsdChildren(derivation) {
+			member, err := schema.compileSimpleType(xsdComponent{inline, derivationComponent.document})
+			if err != nil {
+				return nil, err
+			}
+			simpleType.memberTypes = append(simpleType.memberTypes, member)
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return simpleType, nil
+}
+
+func compileXsdFacets(node *xmlquery.Node, simpleType *xsdSimpleType) error {
+	for _, facet := range getXsdChildren(node) {
+		value := facet.SelectAttr("value")
+		intValue := func() (*int, error) {
+			number, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, fmt.Errorf("schema error: invalid %s value %q", facet.Data, value)
+			}
+			return &number, nil
+		}
+		var err error
+
+		switch facet.Data {
+		case "enumeration":
+			simpleType.enumeration = append(simpleType.enumeration, value)
+		case "pattern":
+			pattern, compileErr := compileXsdPattern(value)
+			if compileErr != nil {
+				return fmt.Errorf("schema error: unsupported pattern %q: %w", value, compileErr)
+			}
+			simpleType.patterns = append(simpleType.patterns, pattern)
+		case "whiteSpace":
+			simpleType.whiteSpace = value
+		case "length":
+			simpleType.length, err = intValue()
+		case "minLength":
+			simpleType.minLength, err = intValue()
+		case "maxLength":
+			simpleType.maxLength, err = intValue()
+		case "totalDigits":
+			simpleType.totalDigits, err = intValue()
+		case "fractionDigits":
+			simpleType.fractionDigits, err = intValue()
+		case "minInclusive":
+			simpleType.minInclusive = &value
+		case "maxInclusive":
+			simpleType.maxInclusive = &value
+		case "minExclusive":
+			simpleType.minExclusive = &value
+		case "maxExclusive":
+			simpleType.maxExclusive = &value
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXmlSchemaValidate(t *testing.T) {
+	dataDir := filepath.Join("..", "..", "test", "data", "xsd")
+	schema, err := LoadXmlSchema(filepath.Join(dataDir, "library.xsd"))
+	assert.Nil(t, err)
+
+	file, err := os.Open(filepath.Join(dataDir, "valid.xml"))
+	assert.Nil(t, err)
+	defer func() {
+		_ = file.Close()
+	}()
+	violations, err := schema.Validate(file)
+	assert.Nil(t, err)
+	assert.Empty(t, violations)
+
+	content, err := os.ReadFile(filepath.Join(dataDir, "invalid.xml"))
+	assert.Nil(t, err)
+	violations, err = schema.Validate(strings.NewReader(string(content)))
+	assert.Nil(t, err)
+	assert.Len(t, violations, 8)
+	assert.Equal(t, SchemaViolation{
+		Line:    8,
+		Column:  5,
+		Path:    "/library/book[1]/year",
+		Message: "element <year> is not expected here; expected: <author>, <editor>",
+	}, violations[3])
+	assert.Equal(t, "/library/m:info/m:updated", violations[1].Path)
+
+	_, err = schema.Validate(strings.NewReader("<library"))
+	assert.ErrorContains(t, err, "unable to parse the document")
+}
+
+func TestXmlSchemaFeatures(t *testing.T) {
+	schemaContent := `<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema">
+  <xs:element name="root">
+    <xs:complexType>
+      <xs:sequence>
+        <xs:element name="sizes" minOccurs="0">
+          <xs:simpleType>
+            <xs:list itemType="xs:positiveInteger"/>
+          </xs:simpleType>
+        </xs:element>
+        <xs:element name="size" minOccurs="0">
+          <xs:simpleType>
+            <xs:union memberTypes="xs:integer">
+              <xs:simpleType>
+                <xs:restriction base="xs:token">
+                  <xs:enumeration value="auto"/>
+                </xs:restriction>
+              </xs:simpleType>
+            </xs:union>
+          </xs:simpleType>
+        </xs:element>
+        <xs:element name="point" minOccurs="0">
+          <xs:complexType>
+            <xs:all>
+              <xs:element name="x" type="xs:int"/>
+              <xs:element name="y" type="xs:int"/>
+            </xs:all>
+          </xs:complexType>
+        </xs:element>
+        <xs:element ref="shape" minOccurs="0" maxOccurs="unbounded"/>
+        <xs:element name="note" type="noteType" minOccurs="0" nillable="true"/>
+        <xs:element name="extra" minOccurs="0">
+          <xs:complexType>
+            <xs:sequence>
+              <xs:any processContents="skip" maxOccurs="unbounded"/>
+            </xs:sequence>
+            <xs:anyAttribute processContents="skip"/>
+          </xs:complexType>
+        </xs:element>
+      </xs:sequence>
+    </xs:complexType>
+  </xs:element>
+  <xs:element name="shape" abstract="true" type="shapeType"/>
+  <xs:element name="circle" substitutionGroup="shape" type="shapeType"/>
+  <xs:complexType name="shapeType">
+    <xs:attribute name="color" type="xs:string" fixed="red"/>
+  </xs:complexType>
+  <xs:complexType name="noteType" mixed="true">
+    <xs:sequence>
+      <xs:element name="b" type="xs:string" minOccurs="0" maxOccurs="unbounded"/>
+    </xs:sequence>
+  </xs:complexType>
+</xs:schema>`
+
+	schemaFile := filepath.Join(t.TempDir(), "schema.xsd")
+	assert.Nil(t, os.WriteFile(schemaFile, []byte(schemaContent), 0600))
+	schema, err := LoadXmlSchema(schemaFile)
+	assert.Nil(t, err)
+
+	xsi := ` xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance"`
+	tests := map[string][]string{
+		`<root><sizes> 1 2  3 </sizes><size>auto</size></root>`:                            nil,
+		`<root><size>12</size><point><y>1</y><x>2</x></point></root>`:                      nil,
+		`<root><circle color="red"/><circle/><note>a <b>bold</b> text</note></root>`:       nil,
+		`<root` + xsi + `><note xsi:nil="true"/><extra any="1"><a><b/></a></extra></root>`: nil,
+		`<root><sizes>1 0</sizes></root>`: {
+			`value "0" is out of range for positiveInteger`,
+		},
+		`<root><size>big</size></root>`: {
+			`value "big" does not match any member type of anonymous type`,
+		},
+		`<root><point><x>1</x></point></root>`: {
+			"content of element <point> is incomplete; expected: <y>",
+		},
+		`<root><shape/><circle color="blue"/></root>`: {
+			"element <shape> is not expected here; expected: <sizes>, <size>, <point>, <circle>, <note>, <extra>",
+			`attribute "color" must have the fixed value "red"`,
+		},
+		`<root` + xsi + `><point xsi:nil="true"/><note>text<i/></note></root>`: {
+			"element <point> is not nillable",
+			"element <i> is not expected here; expected: <b>",
+		},
+		`<root>text<unknown/></root>`: {
+			"text content is not allowed in element <root>",
+			"element <unknown> is not expected here; expected: <sizes>, <size>, <point>, <circle>, <note>, <extra>",
+		},
+		`<other/>`: {
+			"no declaration found for element <other>",
+		},
+	}
+
+	for input, expected := range tests {
+		violations, err := schema.Validate(strings.NewReader(input))
+		assert.Nil(t, err, input)
+
+		var messages []string
+		for _, violation := range violations {
+			messages = append(messages, violation.Message)
+		}
+		assert.Equal(t, expected, messages, input)
+	}
+}
+
+func TestLoadXmlSchemaErrors(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]string{
+		"<root/>": "is not an XML Schema document",
+		`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"><xs:include schemaLocation="missing.xsd"/></xs:schema>`:             "no such file",
+		`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"><xs:import schemaLocation="http://example.com/a.xsd"/></xs:schema>`: "only local schema files are supported",
+		`<xs:schema xmlns:xs="http://www.w3.org/2001/XMLSchema"><xs:redefine schemaLocation="a.xsd"/></xs:schema>`:                  "xs:redefine is not supported",
+	}
+
+	for content, expected := range tests {
+		schemaFile := filepath.Join(dir, "schema.xsd")
+		assert.Nil(t, os.WriteFile(schemaFile, []byte(content), 0600))
+		_, err := LoadXmlSchema(schemaFile)
+		assert.ErrorContains(t, err, expected, content)
+	}
+}
@@ -0,0 +1,470 @@
+package utils
+
+import (
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	xsdWhiteSpacePreserve = "preserve"
+	xsdWhiteSpaceReplace  = "replace"
+	xsdWhiteSpaceCollapse = "collapse"
+)
+
+type xsdVariety int
+
+const (
+	xsdAtomic xsdVariety = iota
+	xsdList
+	xsdUnion
+)
+
+// xsdSimpleType is a simple type definition. The facets defined on the type are checked
+// after the value is validated against the base type.
+type xsdSimpleType struct {
+	name        string
+	variety     xsdVariety
+	base        *xsdSimpleType
+	builtin     *xsdBuiltinType
+	itemType    *xsdSimpleType
+	memberTypes []*xsdSimpleType
+
+	whiteSpace     string
+	enumeration    []string
+	patterns       []*regexp.Regexp
+	length         *int
+	minLength      *int
+	maxLength      *int
+	minInclusive   *string
+	maxInclusive   *string
+	minExclusive   *string
+	maxExclusive   *string
+	totalDigits    *int
+	fractionDigits *int
+}
+
+type xsdBuiltinType struct {
+	name      string
+	primitive string
+	base      string
+	pattern   *regexp.Regexp
+	check     func(value string) bool
+	min, max  *big.Int
+}
+
+var xsdBuiltinTypes = map[string]*xsdBuiltinType{}
+
+func init() {
+	ncName := `[\p{L}_][\p{L}\p{N}._\-]*`
+	name := `[\p{L}_:][\p{L}\p{N}._:\-]*`
+	dateTimeTz := `(Z|[+\-]\d{2}:\d{2})?`
+	month := `(?P<month>\d{2})`
+	day := `(?P<day>\d{2})`
+	timeOfDay := `(?P<hour>\d{2}):(?P<minute>\d{2}):(?P<second>\d{2})(\.\d+)?`
+
+	builtins := []*xsdBuiltinType{
+		{name: "anySimpleType"},
+		{name: "string", base: "anySimpleType"},
+		{name: "normalizedString", base: "string", pattern: regexp.MustCompile(`^[^\t\n\r]*$`)},
+		{name: "token", base: "normalizedString"},
+		{name: "language", base: "token", pattern: regexp.MustCompile(`^[a-zA-Z]{1,8}(-[a-zA-Z0-9]{1,8})*$`)},
+		{name: "NMTOKEN", base: "token", pattern: regexp.MustCompile(`^[\p{L}\p{N}._:\-]+$`)},
+		{name: "Name", base: "token", pattern: regexp.MustCompile(`^` + name + `$`)},
+		{name: "NCName", base: "Name", pattern: regexp.MustCompile(`^` + ncName + `$`)},
+		{name: "ID", base: "NCName"},
+		{name: "IDREF", base: "NCName"},
+		{name: "ENTITY", base: "NCName"},
+		{name: "anyURI", base: "anySimpleType"},
+		{name: "QName", base: "anySimpleType", pattern: regexp.MustCompile(`^(` + ncName + `:)?` + ncName + `$`)},
+		{name: "NOTATION", base: "anySimpleType", pattern: regexp.MustCompile(`^(` + ncName + `:)?` + ncName + `$`)},
+		{name: "boolean", base: "anySimpleType", pattern: regexp.MustCompile(`^(true|false|1|0)$`)},
+		{name: "decimal", base: "anySimpleType", pattern: regexp.MustCompile(`^[+\-]?(\d+(\.\d*)?|\.\d+)$`)},
+		{name: "integer", base: "decimal", pattern: regexp.MustCompile(`^[+\-]?\d+$`)},
+		{name: "nonPositiveInteger", base: "integer", max: big.NewInt(0)},
+		{name: "negativeInteger", base: "nonPositiveInteger", max: big.NewInt(-1)},
+		{name: "long", base: "integer", min: big.NewInt(-1 << 63), max: big.NewInt(1<<63 - 1)},
+		{name: "int", base: "long", min: big.NewInt(-1 << 31), max: big.NewInt(1<<31 - 1)},
+		{name: "short", base: "int", min: big.NewInt(-1 << 15), max: big.NewInt(1<<15 - 1)},
+		{name: "byte", base: "short", min: big.NewInt(-1 << 7), max: big.NewInt(1<<7 - 1)},
+		{name: "nonNegativeInteger", base: "integer", min: big.NewInt(0)},
+		{name: "unsignedLong", base: "nonNegativeInteger", max: new(big.Int).SetUint64(1<<64 - 1)},
+		{name: "unsignedInt", base: "unsignedLong", max: big.NewInt(1<<32 - 1)},
+		{name: "unsignedShort", base: "unsignedInt", max: big.NewInt(1<<16 - 1)},
+		{name: "unsignedByte", base: "unsignedShort", max: big.NewInt(1<<8 - 1)},
+		{name: "positiveInteger", base: "nonNegativeInteger", min: big.NewInt(1)},
+		{name: "float", base: "anySimpleType", pattern: regexp.MustCompile(`^([+\-]?(\d+(\.\d*)?|\.\d+)([eE][+\-]?\d+)?|[+\-]?INF|NaN)$`)},
+		{name: "double", base: "anySimpleType", pattern: regexp.MustCompile(`^([+\-]?(\d+(\.\d*)?|\.\d+)([eE][+\-]?\d+)?|[+\-]?INF|NaN)$`)},
+		{name: "duration", base: "anySimpleType", check: isXsdDuration},
+		{name: "dateTime", base: "anySimpleType", pattern: regexp.MustCompile(`^(?P<year>-?\d{4,})-` + month + `-` + day + `T` + timeOfDay + dateTimeTz + `$`)},
+		{name: "date", base: "anySimpleType", pattern: regexp.MustCompile(`^(?P<year>-?\d{4,})-` + month + `-` + day + dateTimeTz + `$`)},
+		{name: "time", base: "anySimpleType", pattern: regexp.MustCompile(`^` + timeOfDay + dateTimeTz + `$`)},
+		{name: "gYearMonth", base: "anySimpleType", pattern: regexp.MustCompile(`^-?\d{4,}-` + month + dateTimeTz + `$`)},
+		{name: "gYear", base: "anySimpleType", pattern: regexp.MustCompile(`^-?\d{4,}` + dateTimeTz + `$`)},
+		{name: "gMonthDay", base: "anySimpleType", pattern: regexp.MustCompile(`^--` + month + `-` + day + dateTimeTz + `$`)},
+		{name: "gDay", base: "anySimpleType", pattern: regexp.MustCompile(`^---` + day + dateTimeTz + `$`)},
+		{name: "gMonth", base: "anySimpleType", pattern: regexp.MustCompile(`^--` + month + dateTimeTz + `$`)},
+		{name: "hexBinary", base: "anySimpleType", pattern: regexp.MustCompile(`^([0-9a-fA-F]{2})*$`)},
+		{name: "base64Binary", base: "anySimpleType", check: func(value string) bool {
+			_, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(value), ""))
+			return err == nil
+		}},
+	}
+
+	for _, builtin := range builtins {
+		builtin.primitive = builtin.name
+		if base, ok := xsdBuiltinTypes[builtin.base]; ok && base.base != "" {
+			builtin.primitive = base.primitive
+		}
+		xsdBuiltinTypes[builtin.name] = builtin
+	}
+}
+
+// getXsdBuiltinSimpleType returns the simple type for a built-in XSD type name.
+// The list types are represented as lists of the corresponding item types.
+func getXsdBuiltinSimpleType(name string) *xsdSimpleType {
+	listItems := map[string]string{"NMTOKENS": "NMTOKEN", "IDREFS": "IDREF", "ENTITIES": "ENTITY"}
+	if itemName, ok := listItems[name]; ok {
+		minLength := 1
+		return &xsdSimpleType{
+			name:      name,
+			variety:   xsdList,
+			itemType:  getXsdBuiltinSimpleType(itemName),
+			minLength: &minLength,
+		}
+	}
+
+	builtin, ok := xsdBuiltinTypes[name]
+	if !ok {
+		return nil
+	}
+
+	return &xsdSimpleType{name: name, builtin: builtin}
+}
+
+func (simpleType *xsdSimpleType) getWhiteSpace() string {
+	for current := simpleType; current != nil; current = current.base {
+		if current.whiteSpace != "" {
+			return current.whiteSpace
+		}
+		if current.variety != xsdAtomic {
+			return xsdWhiteSpaceCollapse
+		}
+		if current.builtin != nil {
+			switch current.builtin.name {
+			case "string", "anySimpleType":
+				return xsdWhiteSpacePreserve
+			case "normalizedString":
+				return xsdWhiteSpaceReplace
+			}
+			return xsdWhiteSpaceCollapse
+		}
+	}
+
+	return xsdWhiteSpacePreserve
+}
+
+func (simpleType *xsdSimpleType) getPrimitive() string {
+	for current := simpleType; current != nil; current = current.base {
+		if current.builtin != nil {
+			return current.builtin.primitive
+		}
+		if current.variety != xsdAtomic {
+			return ""
+		}
+	}
+
+	return ""
+}
+
+func normalizeXsdWhiteSpace(value string, whiteSpace string) string {
+	switch whiteSpace {
+	case xsdWhiteSpaceReplace:
+		return strings.NewReplacer("\t", " ", "\n", " ", "\r", " ").Replace(value)
+	case xsdWhiteSpaceCollapse:
+		return strings.Join(strings.Fields(value), " ")
+	}
+
+	return value
+}
+
+// validate checks the value against the simple type and returns the description
+// of the problem if the value is not valid.
+func (simpleType *xsdSimpleType) validate(value string) error {
+	return simpleType.validateNormalized(normalizeXsdWhiteSpace(value, simpleType.getWhiteSpace()))
+}
+
+func (simpleType *xsdSimpleType) validateNormalized(value string) error {
+	switch {
+	case simpleType.base != nil:
+		if err := simpleType.base.validateNormalized(value); err != nil {
+			return err
+		}
+	case simpleType.builtin != nil:
+		if err := validateXsdBuiltin(simpleType.builtin, value); err != nil {
+			return err
+		}
+	case simpleType.variety == xsdList:
+		for _, item := range strings.Fields(value) {
+			if err := simpleType.itemType.validateNormalized(item); err != nil {
+				return err
+			}
+		}
+	case simpleType.variety == xsdUnion:
+		valid := false
+		for _, memberType := range simpleType.memberTypes {
+			if memberType.validate(value) == nil {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("value %q does not match any member type of %s", value, simpleType.getName())
+		}
+	}
+
+	return simpleType.checkFacets(value)
+}
+
+func (simpleType *xsdSimpleType) getName() string {
+	for current := simpleType; current != nil; current = current.base {
+		if current.name != "" {
+			return current.name
+		}
+	}
+
+	return "anonymous type"
+}
+
+func (simpleType *xsdSimpleType) isList() bool {
+	for current := simpleType; current != nil; current = current.base {
+		if current.variety == xsdList {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validateXsdBuiltin(builtin *xsdBuiltinType, value string) error {
+	for current := builtin; current != nil; current = xsdBuiltinTypes[current.base] {
+		if current.pattern != nil && !checkXsdDateTimeRanges(current.pattern, value) {
+			return fmt.Errorf("value %q is not a valid %s", value, builtin.name)
+		}
+		if current.check != nil && !current.check(value) {
+			return fmt.Errorf("value %q is not a valid %s", value, builtin.name)
+		}
+		if current.min != nil || current.max != nil {
+			number, ok := new(big.Int).SetString(strings.TrimPrefix(value, "+"), 10)
+			if !ok || (current.min != nil && number.Cmp(current.min) < 0) || (current.max != nil && number.Cmp(current.max) > 0) {
+				return fmt.Errorf("value %q is out of range for %s", value, builtin.name)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (simpleType *xsdSimpleType) checkFacets(value string) error {
+	typeName := "type " + simpleType.name
+	if simpleType.name == "" {
+		typeName = "the anonymous type"
+	}
+
+	if len(simpleType.enumeration) > 0 {
+		found := false
+		for _, allowed := range simpleType.enumeration {
+			if simpleType.equalValues(allowed, value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("value %q is not one of the allowed values of %s: %s", value, typeName,
+				strings.Join(simpleType.enumeration, ", "))
+		}
+	}
+
+	if len(simpleType.patterns) > 0 {
+		matched := false
+		for _, pattern := range simpleType.patterns {
+			if pattern.MatchString(value) {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			return fmt.Errorf("value %q does not match the pattern of %s", value, typeName)
+		}
+	}
+
+	length := simpleType.getValueLength(value)
+	if simpleType.length != nil && length != *simpleType.length {
+		return fmt.Errorf("length of value %q should be %d", value, *simpleType.length)
+	}
+	if simpleType.minLength != nil && length < *simpleType.minLength {
+		return fmt.Errorf("length of value %q should be at least %d", value, *simpleType.minLength)
+	}
+	if simpleType.maxLength != nil && length > *simpleType.maxLength {
+		return fmt.Errorf("length of value %q should be at most %d", value, *simpleType.maxLength)
+	}
+
+	bounds := []struct {
+		limit   *string
+		check   func(int) bool
+		message string
+	}{
+		{simpleType.minInclusive, func(result int) bool { return result >= 0 }, "greater than or equal to"},
+		{simpleType.maxInclusive, func(result int) bool { return result <= 0 }, "less than or equal to"},
+		{simpleType.minExclusive, func(result int) bool { return result > 0 }, "greater than"},
+		{simpleType.maxExclusive, func(result int) bool { return result < 0 }, "less than"},
+	}
+	for _, bound := range bounds {
+		if bound.limit == nil {
+			continue
+		}
+		result, ok := compareXsdValues(simpleType.getPrimitive(), value, *bound.limit)
+		if ok && !bound.check(result) {
+			return fmt.Errorf("value %q should be %s %s", value, bound.message, *bound.limit)
+		}
+	}
+
+	if simpleType.totalDigits != nil || simpleType.fractionDigits != nil {
+		integerPart, fractionPart, _ := strings.Cut(strings.TrimLeft(value, "+-"), ".")
+		integerPart = strings.TrimLeft(integerPart, "0")
+		fractionPart = strings.TrimRight(fractionPart, "0")
+		if simpleType.totalDigits != nil && len(integerPart)+len(fractionPart) > *simpleType.totalDigits {
+			return fmt.Errorf("value %q has more than %d digits", value, *simpleType.totalDigits)
+		}
+		if simpleType.fractionDigits != nil && len(fractionPart) > *simpleType.fractionDigits {
+			return fmt.Errorf("value %q has more than %d fraction digits", value, *simpleType.fractionDigits)
+		}
+	}
+
+	return nil
+}
+
+func (simpleType *xsdSimpleType) getValueLength(value string) int {
+	if simpleType.isList() {
+		return len(strings.Fields(value))
+	}
+
+	switch simpleType.getPrimitive() {
+	case "hexBinary":
+		return len(value) / 2
+	case "base64Binary":
+		data, _ := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(value), ""))
+		return len(data)
+	}
+
+	return utf8.RuneCountInString(value)
+}
+
+func (simpleType *xsdSimpleType) equalValues(expected string, actual string) bool {
+	expected = normalizeXsdWhiteSpace(expected, simpleType.getWhiteSpace())
+	if expected == actual {
+		return true
+	}
+
+	result, ok := compareXsdValues(simpleType.getPrimitive(), expected, actual)
+	return ok && result == 0 && simpleType.getPrimitive() != "string"
+}
+
+// compareXsdValues compares the values of numeric and date/time types. The second
+// result is false if the values can't be compared.
+func compareXsdValues(primitive string, first string, second string) (int, bool) {
+	switch primitive {
+	case "decimal":
+		firstNumber, ok1 := new(big.Rat).SetString(strings.TrimPrefix(first, "+"))
+		secondNumber, ok2 := new(big.Rat).SetString(strings.TrimPrefix(second, "+"))
+		if !ok1 || !ok2 {
+			return 0, false
+		}
+		return firstNumber.Cmp(secondNumber), true
+	case "float", "double":
+		firstNumber, err1 := strconv.ParseFloat(strings.Replace(first, "INF", "Inf", 1), 64)
+		secondNumber, err2 := strconv.ParseFloat(strings.Replace(second, "INF", "Inf", 1), 64)
+		if err1 != nil || err2 != nil {
+			return 0, false
+		}
+		switch {
+		case firstNumber < secondNumber:
+			return -1, true
+		case firstNumber > secondNumber:
+			return 1, true
+		case firstNumber == secondNumber:
+			return 0, true
+		}
+		return 0, false
+	case "dateTime", "date", "time", "gYear", "gYearMonth", "gMonth", "gMonthDay", "gDay":
+		return strings.Compare(first, second), len(first) == len(second)
+	}
+
+	return 0, false
+}
+
+func isXsdDuration(value string) bool {
+	pattern := regexp.MustCompile(`^-?P(\d+Y)?(\d+M)?(\d+D)?(T(\d+H)?(\d+M)?(\d+(\.\d+)?S)?)?$`)
+	if !pattern.MatchString(value) {
+		return false
+	}
+
+	value = strings.TrimPrefix(value, "-")
+	return value != "P" && !strings.HasSuffix(value, "T")
+}
+
+// checkXsdDateTimeRanges checks the named date and time components matched by the pattern.
+func checkXsdDateTimeRanges(pattern *regexp.Regexp, value string) bool {
+	ranges := map[string][2]int{"month": {1, 12}, "day": {1, 31}, "hour": {0, 24}, "minute": {0, 59}, "second": {0, 60}}
+	matches := pattern.FindStringSubmatch(value)
+	if matches == nil {
+		return false
+	}
+
+	// a leap year is used when the year is unknown to allow February 29
+	components := map[string]int{"year": 2000}
+	for index, name := range pattern.SubexpNames() {
+		if name == "" || matches[index] == "" {
+			continue
+		}
+		number, err := strconv.Atoi(matches[index])
+		if err != nil {
+			return false
+		}
+		components[name] = number
+		if limits, ok := ranges[name]; ok && (number < limits[0] || number > limits[1]) {
+			return false
+		}
+	}
+
+	if month, ok := components["month"]; ok {
+		if day, ok := components["day"]; ok {
+			date := time.Date(components["year"], time.Month(month), day, 0, 0, 0, 0, time.UTC)
+			return date.Day() == day
+		}
+	}
+
+	return true
+}
+
+// compileXsdPattern converts the XML Schema regular expression into the Go syntax.
+// XML Schema patterns are implicitly anchored and support additional character classes.
+func compileXsdPattern(pattern string) (*regexp.Regexp, error) {
+	replacer := strings.NewReplacer(
+		`\i`, `[\p{L}_:]`,
+		`\I`, `[^\p{L}_:]`,
+		`\c`, `[\p{L}\p{N}._:\-]`,
+		`\C`, `[^\p{L}\p{N}._:\-]`,
+		`\\`, `\\`,
+	)
+
+	converted := replacer.Replace(pattern)
+	converted = regexp.MustCompile(`\\[pP]\{Is[A-Za-z0-9\-]+\}`).ReplaceAllString(converted, `.`)
+
+	return regexp.Compile(`^(?:` + converted + `)$`)
+}
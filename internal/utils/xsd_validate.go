@@ -0,0 +1,669 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// SchemaViolation describes a place in the document which does not conform to the schema.
+type SchemaViolation struct {
+	Line    int
+	Column  int
+	Path    string
+	Message string
+}
+
+type xsdInstanceElement struct {
+	name       xml.Name
+	qualified  string
+	attrs      []xml.Attr
+	children   []*xsdInstanceElement
+	text       strings.Builder
+	hasText    bool
+	line       int
+	column     int
+	path       string
+	namespaces map[string]string
+}
+
+type xsdMatch struct {
+	element  *xsdElement
+	wildcard *xsdWildcard
+}
+
+type xsdMatcher struct {
+	schema   *XmlSchema
+	children []*xsdInstanceElement
+	matches  map[int]xsdMatch
+	furthest int
+	expected map[int][]string
+	scope    map[string]string
+}
+
+type xsdValidator struct {
+	schema     *XmlSchema
+	violations []SchemaViolation
+}
+
+// Validate checks the XML document against the schema. All the found violations are
+// returned, the error is returned only if the document can't be parsed.
+func (schema *XmlSchema) Validate(reader io.Reader) ([]SchemaViolation, error) {
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	root, err := parseXsdInstance(content)
+	if err != nil {
+		return nil, err
+	}
+
+	validator := &xsdValidator{schema: schema}
+	decl, err := schema.getGlobalElement(root.name)
+	if err != nil {
+		return nil, err
+	}
+
+	if decl == nil {
+		validator.report(root, "no declaration found for element <%s>", root.qualified)
+	} else if err = validator.validateElement(root, decl, nil); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(validator.violations, func(i, j int) bool {
+		first, second := validator.violations[i], validator.violations[j]
+		if first.Line != second.Line {
+			return first.Line < second.Line
+		}
+		return first.Column < second.Column
+	})
+
+	return validator.violations, nil
+}
+
+func parseXsdInstance(content []byte) (*xsdInstanceElement, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	decoder.CharsetReader = getCharsetReader
+
+	var lineStarts []int
+	lineStarts = append(lineStarts, 0)
+	for index, char := range content {
+		if char == '\n' {
+			lineStarts = append(lineStarts, index+1)
+		}
+	}
+	getPosition := func(offset int) (int, int) {
+		line := sort.Search(len(lineStarts), func(index int) bool { return lineStarts[index] > offset })
+		start := lineStarts[line-1]
+		return line, utf8.RuneCount(content[start:min(offset, len(content))]) + 1
+	}
+
+	var root *xsdInstanceElement
+	var stack []*xsdInstanceElement
+	scope := map[string]string{"xml": xmlNamespace}
+
+	for {
+		offset := int(decoder.InputOffset())
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to parse the document: %w", err)
+		}
+
+		switch typedToken := token.(type) {
+		case xml.StartElement:
+			element := &xsdInstanceElement{name: typedToken.Name, attrs: typedToken.Attr, namespaces: scope}
+			element.line, element.column = getPosition(offset)
+			copied := false
+			for _, attr := range typedToken.Attr {
+				if attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns") {
+					if !copied {
+						copied = true
+						element.namespaces = make(map[string]string, len(scope)+1)
+						for prefix, uri := range scope {
+							element.namespaces[prefix] = uri
+						}
+					}
+					if attr.Name.Space == "xmlns" {
+						element.namespaces[attr.Name.Local] = attr.Value
+					} else {
+						element.namespaces[""] = attr.Value
+					}
+				}
+			}
+			element.qualified = formatXsdName(element.name, element.namespaces)
+
+			if len(stack) == 0 {
+				if root != nil {
+					return nil, errors.New("unable to parse the document: multiple root elements")
+				}
+				root = element
+			} else {
+				parent := stack[len(stack)-1]
+				parent.children = append(parent.children, element)
+			}
+			stack = append(stack, element)
+			scope = element.namespaces
+		case xml.EndElement:
+			element := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			if len(stack) > 0 {
+				scope = stack[len(stack)-1].namespaces
+			}
+			setXsdChildrenPaths(element)
+		case xml.CharData:
+			if len(stack) > 0 {
+				element := stack[len(stack)-1]
+				element.text.Write(typedToken)
+				if len(bytes.TrimSpace(typedToken)) > 0 {
+					element.hasText = true
+				}
+			}
+		}
+	}
+
+	if root == nil {
+		return nil, errors.New("unable to parse the document: no root element")
+	}
+	root.path = "/" + root.qualified
+	setXsdChildrenPaths(root)
+
+	return root, nil
+}
+
+// setXsdChildrenPaths builds the location paths of the children, the position is added
+// only if there are several siblings with the same name.
+func setXsdChildrenPaths(element *xsdInstanceElement) {
+	counts := map[string]int{}
+	for _, child := range element.children {
+		counts[child.qualified]++
+	}
+
+	positions := map[string]int{}
+	for _, child := range element.children {
+		positions[child.qualified]++
+		child.path = element.path + "/" + child.qualified
+		if counts[child.qualified] > 1 {
+			child.path += "[" + strconv.Itoa(positions[child.qualified]) + "]"
+		}
+		setXsdChildrenPaths(child)
+	}
+}
+
+func formatXsdName(name xml.Name, namespaces map[string]string) string {
+	if name.Space == "" || namespaces[""] == name.Space {
+		return name.Local
+	}
+
+	var prefixes []string
+	for prefix, uri := range namespaces {
+		if uri == name.Space && prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	if len(prefixes) == 0 {
+		return "{" + name.Space + "}" + name.Local
+	}
+	sort.Strings(prefixes)
+
+	return prefixes[0] + ":" + name.Local
+}
+
+func (validator *xsdValidator) report(element *xsdInstanceElement, format string, args ...any) {
+	validator.violations = append(validator.violations, SchemaViolation{
+		Line:    element.line,
+		Column:  element.column,
+		Path:    element.path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (validator *xsdValidator) getXsiAttr(element *xsdInstanceElement, name string) (string, bool) {
+	for _, attr := range element.attrs {
+		if attr.Name.Space == xsiNamespace && attr.Name.Local == name {
+			return attr.Value, true
+		}
+	}
+
+	return "", false
+}
+
+func (validator *xsdValidator) validateElement(element *xsdInstanceElement, decl *xsdElement, typeDef *xsdType) error {
+	var err error
+	if typeDef == nil {
+		if typeDef, err = validator.schema.getElementType(decl); err != nil {
+			return err
+		}
+	}
+
+	if decl != nil && decl.abstract {
+		validator.report(element, "element <%s> is abstract and can't be used in the document", element.qualified)
+	}
+
+	if xsiType, ok := validator.getXsiAttr(element, "type"); ok {
+		if typeDef, err = validator.resolveXsiType(element, xsiType); err != nil {
+			validator.report(element, "%v", err)
+			return nil
+		}
+	}
+
+	validator.validateAttributes(element, typeDef)
+
+	if nilValue, ok := validator.getXsiAttr(element, "nil"); ok && (nilValue == "true" || nilValue == "1") {
+		if decl == nil || !decl.nillable {
+			validator.report(element, "element <%s> is not nillable", element.qualified)
+		} else if len(element.children) > 0 || element.hasText {
+			validator.report(element, "nil element <%s> must be empty", element.qualified)
+		}
+		return nil
+	}
+
+	switch {
+	case typeDef.anyType:
+		return validator.validateLax(element.children)
+	case typeDef.simple != nil:
+		if len(element.children) > 0 {
+			validator.report(element, "element <%s> can't contain child elements", element.qualified)
+			return nil
+		}
+		validator.validateValue(element, typeDef.simple, element.text.String(), decl)
+		return nil
+	}
+
+	if element.hasText && !typeDef.mixed {
+		validator.report(element, "text content is not allowed in element <%s>", element.qualified)
+	}
+
+	if typeDef.content == nil {
+		if len(element.children) > 0 {
+			validator.report(element.children[0], "element <%s> is not allowed here; <%s> must be empty",
+				element.children[0].qualified, element.qualified)
+		}
+		return nil
+	}
+
+	return validator.validateContent(element, typeDef.content)
+}
+
+func (validator *xsdValidator) resolveXsiType(element *xsdInstanceElement, value string) (*xsdType, error) {
+	prefix, local, found := strings.Cut(strings.TrimSpace(value), ":")
+	if !found {
+		prefix, local = "", prefix
+	}
+	uri, ok := element.namespaces[prefix]
+	if !ok && prefix != "" {
+		return nil, fmt.Errorf("undeclared namespace prefix in xsi:type %q", value)
+	}
+	name := xml.Name{Space: uri, Local: local}
+
+	if name.Space == xsdNamespace {
+		if name.Local == "anyType" {
+			return &xsdType{name: "anyType", anyType: true}, nil
+		}
+		if simpleType := getXsdBuiltinSimpleType(name.Local); simpleType != nil {
+			return &xsdType{name: name.Local, simple: simpleType, isSimpleType: true}, nil
+		}
+	}
+	if component, ok := validator.schema.types[name]; ok {
+		return validator.schema.compileType(component)
+	}
+
+	return nil, fmt.Errorf("type %q from xsi:type is not defined", value)
+}
+
+func (validator *xsdValidator) validateValue(element *xsdInstanceElement, simpleType *xsdSimpleType, value string, decl *xsdElement) {
+	if err := simpleType.validate(value); err != nil {
+		validator.report(element, "%v", err)
+		return
+	}
+
+	if decl != nil && decl.fixed != nil &&
+		!simpleType.equalValues(*decl.fixed, normalizeXsdWhiteSpace(value, simpleType.getWhiteSpace())) {
+		validator.report(element, "value %q does not match the fixed value %q", value, *decl.fixed)
+	}
+}
+
+func (validator *xsdValidator) validateAttributes(element *xsdInstanceElement, typeDef *xsdType) {
+	present := map[xml.Name]bool{}
+
+	for _, attr := range element.attrs {
+		switch {
+		case attr.Name.Space == "xmlns" || (attr.Name.Space == "" && attr.Name.Local == "xmlns"):
+			continue
+		case attr.Name.Space == xsiNamespace:
+			continue
+		}
+		present[attr.Name] = true
+
+		var declared *xsdAttribute
+		for _, attribute := range typeDef.attributes {
+			if attribute.name == attr.Name {
+				declared = attribute
+				break
+			}
+		}
+
+		attrName := formatXsdName(attr.Name, element.namespaces)
+		if declared == nil {
+			if typeDef.anyType || attr.Name.Space == xmlNamespace {
+				continue
+			}
+			if typeDef.anyAttribute != nil && typeDef.anyAttribute.allows(attr.Name.Space) {
+				validator.validateWildcardAttribute(element, attr, attrName, typeDef.anyAttribute)
+				continue
+			}
+			validator.report(element, "attribute %q is not allowed in element <%s>", attrName, element.qualified)
+			continue
+		}
+
+		if err := declared.simple.validate(attr.Value); err != nil {
+			validator.report(element, "invalid value of attribute %q: %v", attrName, err)
+			continue
+		}
+		if declared.fixed != nil &&
+			!declared.simple.equalValues(*declared.fixed, normalizeXsdWhiteSpace(attr.Value, declared.simple.getWhiteSpace())) {
+			validator.report(element, "attribute %q must have the fixed value %q", attrName, *declared.fixed)
+		}
+	}
+
+	for _, attribute := range typeDef.attributes {
+		if attribute.required && !present[attribute.name] {
+			validator.report(element, "missing required attribute %q in element <%s>",
+				formatXsdName(attribute.name, element.namespaces), element.qualified)
+		}
+	}
+}
+
+func (validator *xsdValidator) validateWildcardAttribute(element *xsdInstanceElement, attr xml.Attr, attrName string, wildcard *xsdWildcard) {
+	if wildcard.processContents == "skip" {
+		return
+	}
+
+	component, ok := validator.schema.attributes[attr.Name]
+	if !ok {
+		if wildcard.processContents == "strict" {
+			validator.report(element, "no declaration found for attribute %q", attrName)
+		}
+		return
+	}
+
+	declared, err := validator.schema.compileAttribute(component, true)
+	if err != nil {
+		validator.report(element, "%v", err)
+		return
+	}
+	if err = declared.simple.validate(attr.Value); err != nil {
+		validator.report(element, "invalid value of attribute %q: %v", attrName, err)
+	}
+}
+
+// validateLax validates the elements which have global declarations and skips the others.
+func (validator *xsdValidator) validateLax(elements []*xsdInstanceElement) error {
+	for _, element := range elements {
+		decl, err := validator.schema.getGlobalElement(element.name)
+		if err != nil {
+			return err
+		}
+		if decl == nil {
+			if err = validator.validateLax(element.children); err != nil {
+				return err
+			}
+			continue
+		}
+		if err = validator.validateElement(element, decl, nil); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (validator *xsdValidator) validateContent(element *xsdInstanceElement, content *xsdParticle) error {
+	matcher := &xsdMatcher{
+		schema:   validator.schema,
+		children: element.children,
+		matches:  map[int]xsdMatch{},
+		expected: map[int][]string{},
+		scope:    element.namespaces,
+	}
+
+	ends := matcher.match(content, map[int]bool{0: true})
+	if !ends[len(element.children)] {
+		position := matcher.furthest
+		expected := strings.Join(matcher.expected[position], ", ")
+		switch {
+		case position < len(element.children) && expected == "":
+			validator.report(element.children[position], "element <%s> is not allowed here",
+				element.children[position].qualified)
+		case position < len(element.children):
+			validator.report(element.children[position], "element <%s> is not expected here; expected: %s",
+				element.children[position].qualified, expected)
+		default:
+			validator.report(element, "content of element <%s> is incomplete; expected: %s",
+				element.qualified, expected)
+		}
+	}
+
+	for index, child := range element.children {
+		match, ok := matcher.matches[index]
+		if !ok {
+			// the content model is broken before the element but it still can be validated
+			// against the declaration with the same name
+			if match.element = findXsdElementDecl(content, child.name); match.element == nil {
+				continue
+			}
+		}
+
+		var err error
+		switch {
+		case match.element != nil:
+			err = validator.validateElement(child, match.element, nil)
+		case match.wildcard.processContents == "skip":
+		default:
+			var decl *xsdElement
+			if decl, err = validator.schema.getGlobalElement(child.name); err != nil {
+				return err
+			}
+			switch {
+			case decl != nil:
+				err = validator.validateElement(child, decl, nil)
+			case match.wildcard.processContents == "strict":
+				validator.report(child, "no declaration found for element <%s>", child.qualified)
+			default:
+				err = validator.validateLax(child.children)
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func findXsdElementDecl(particle *xsdParticle, name xml.Name) *xsdElement {
+	element, _ := findXsdElementOccurs(particle, name, false)
+	return element
+}
+
+// findXsdElementOccurs returns the declaration of the element together with the flag whether
+// the element can be repeated by its particle or by any of the enclosing particles.
+func findXsdElementOccurs(particle *xsdParticle, name xml.Name, repeated bool) (*xsdElement, bool) {
+	repeated = repeated || particle.max != 1
+	if particle.kind == xsdElementParticle {
+		for _, candidate := range append([]*xsdElement{particle.element}, particle.element.substitutes...) {
+			if candidate.name == name && !candidate.abstract {
+				return candidate, repeated
+			}
+		}
+	}
+
+	for _, child := range particle.children {
+		if element, childRepeated := findXsdElementOccurs(child, name, repeated); element != nil {
+			return element, childRepeated
+		}
+	}
+
+	return nil, false
+}
+
+// match returns the set of the child positions which can be reached after matching the
+// particle starting from any of the given positions.
+func (matcher *xsdMatcher) match(particle *xsdParticle, starts map[int]bool) map[int]bool {
+	result := map[int]bool{}
+	if particle.min == 0 {
+		for position := range starts {
+			result[position] = true
+		}
+	}
+
+	seen := map[int]bool{}
+	current := starts
+	for count := 1; particle.max < 0 || count <= particle.max; count++ {
+		next := matcher.matchOnce(particle, current)
+		if len(next) == 0 {
+			break
+		}
+
+		progress := false
+		for position := range next {
+			if !seen[position] {
+				seen[position] = true
+				progress = true
+			}
+			if count >= particle.min {
+				result[position] = true
+			}
+		}
+		if !progress && count >= particle.min {
+			break
+		}
+		if count > particle.min+len(matcher.children) {
+			break
+		}
+		current = next
+	}
+
+	return result
+}
+
+func (matcher *xsdMatcher) matchOnce(particle *xsdParticle, starts map[int]bool) map[int]bool {
+	result := map[int]bool{}
+
+	switch particle.kind {
+	case xsdElementParticle, xsdAnyParticle:
+		for position := range starts {
+			if matcher.matchChild(particle, position) {
+				result[position+1] = true
+			}
+		}
+	case xsdSequenceParticle:
+		result = starts
+		for _, child := range particle.children {
+			result = matcher.match(child, result)
+			if len(result) == 0 {
+				break
+			}
+		}
+	case xsdChoiceParticle:
+		for _, child := range particle.children {
+			for position := range matcher.match(child, starts) {
+				result[position] = true
+			}
+		}
+	case xsdAllParticle:
+		for position := range starts {
+			if end, ok := matcher.matchAll(particle, position); ok {
+				result[end] = true
+			}
+		}
+	}
+
+	return result
+}
+
+func (matcher *xsdMatcher) matchAll(particle *xsdParticle, position int) (int, bool) {
+	used := map[*xsdParticle]bool{}
+
+	for {
+		found := false
+		for _, child := range particle.children {
+			if !used[child] && matcher.matchChild(child, position) {
+				used[child] = true
+				position++
+				found = true
+				break
+			}
+		}
+		if !found {
+			break
+		}
+	}
+
+	for _, child := range particle.children {
+		if !used[child] && child.min > 0 {
+			return position, false
+		}
+	}
+
+	return position, true
+}
+
+func (matcher *xsdMatcher) matchChild(particle *xsdParticle, position int) bool {
+	if position > matcher.furthest {
+		matcher.furthest = position
+	}
+
+	if position < len(matcher.children) {
+		child := matcher.children[position]
+
+		if particle.kind == xsdAnyParticle {
+			if particle.wildcard.allows(child.name.Space) {
+				matcher.record(position, xsdMatch{wildcard: particle.wildcard})
+				return true
+			}
+		} else {
+			candidates := append([]*xsdElement{particle.element}, particle.element.substitutes...)
+			for _, candidate := range candidates {
+				if candidate.name == child.name && !candidate.abstract {
+					matcher.record(position, xsdMatch{element: candidate})
+					return true
+				}
+			}
+		}
+	}
+
+	expected := []string{"any element"}
+	if particle.kind == xsdElementParticle {
+		expected = nil
+		for _, candidate := range append([]*xsdElement{particle.element}, particle.element.substitutes...) {
+			if !candidate.abstract {
+				expected = append(expected, "<"+formatXsdName(candidate.name, matcher.scope)+">")
+			}
+		}
+	}
+	for _, name := range expected {
+		if !slices.Contains(matcher.expected[position], name) {
+			matcher.expected[position] = append(matcher.expected[position], name)
+		}
+	}
+
+	return false
+}
+
+func (matcher *xsdMatcher) record(position int, match xsdMatch) {
+	if _, ok := matcher.matches[position]; !ok {
+		matcher.matches[position] = match
+	}
+}
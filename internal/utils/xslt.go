@@ -0,0 +1,1436 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+)
+
+const (
+	xslNamespace          = "http://www.w3.org/1999/XSL/Transform"
+	xsltMaxTemplatesDepth = 3000
+)
+
+// XsltStylesheet is a compiled XSLT 1.0 stylesheet.
+type XsltStylesheet struct {
+	templates     []*xsltTemplate
+	namedTemplate map[string]*xsltTemplate
+	globals       map[string]*xmlquery.Node
+	globalNames   []string
+	keys          map[string][]*xsltKey
+	output        xsltOutput
+	stripSpace    []string
+	preserveSpace []string
+	loadedFiles   map[string]bool
+	precedence    int
+}
+
+type xsltTemplate struct {
+	node       *xmlquery.Node
+	patterns   []xsltPattern
+	name       string
+	mode       string
+	precedence int
+}
+
+type xsltPattern struct {
+	expr     string
+	priority float64
+}
+
+type xsltKey struct {
+	node     *xmlquery.Node
+	patterns []xsltPattern
+	use      string
+}
+
+type xsltOutput struct {
+	method          string
+	omitDeclaration bool
+	doctypePublic   string
+	doctypeSystem   string
+}
+
+type xsltScope struct {
+	name   string
+	value  xsltValue
+	parent *xsltScope
+}
+
+type xsltContext struct {
+	node     xpath.NodeNavigator
+	current  xpath.NodeNavigator
+	position int
+	size     int
+	mode     string
+	scope    *xsltScope
+	params   map[string]xsltValue
+	output   *xmlquery.Node
+}
+
+type xsltNodeKey struct {
+	node *xmlquery.Node
+	attr string
+}
+
+type xsltSortKey struct {
+	node       *xmlquery.Node
+	selectExpr string
+	descending bool
+	numeric    bool
+	upperFirst bool
+}
+
+type xsltRun struct {
+	stylesheet *XsltStylesheet
+	root       xpath.NodeNavigator
+	params     map[string]string
+	globals    map[string]xsltValue
+	evaluating map[string]bool
+	matchSets  map[string]map[xsltNodeKey]bool
+	keyIndex   map[string]map[string][]xpath.NodeNavigator
+	ids        map[xsltNodeKey]int
+	order      map[xsltNodeKey]int
+	compiled   map[*xmlquery.Node]map[string]*xpath.Expr
+	namespaces map[*xmlquery.Node]map[string]string
+	depth      int
+	messages   io.Writer
+}
+
+// LoadXslt loads the XSLT stylesheet from the file together with the stylesheets
+// referenced by xsl:include and xsl:import.
+func LoadXslt(fileName string) (*XsltStylesheet, error) {
+	stylesheet := &XsltStylesheet{
+		namedTemplate: map[string]*xsltTemplate{},
+		globals:       map[string]*xmlquery.Node{},
+		keys:          map[string][]*xsltKey{},
+		loadedFiles:   map[string]bool{},
+	}
+
+	if err := stylesheet.loadFile(fileName); err != nil {
+		return nil, err
+	}
+
+	return stylesheet, nil
+}
+
+// parseFile parses the stylesheet file. The nil document is returned if the file is already loaded.
+func (stylesheet *XsltStylesheet) parseFile(fileName string) (*xmlquery.Node, error) {
+	path, err := filepath.Abs(fileName)
+	if err != nil {
+		return nil, err
+	}
+	if stylesheet.loadedFiles[path] {
+		return nil, nil
+	}
+	stylesheet.loadedFiles[path] = true
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := xmlquery.ParseWithOptions(bytes.NewReader(content), xmlquery.ParserOptions{
+		Decoder: &xmlquery.DecoderOptions{Strict: true, CharsetReader: getCharsetReader},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse stylesheet %s: %w", fileName, err)
+	}
+
+	return doc, nil
+}
+
+func (stylesheet *XsltStylesheet) loadFile(fileName string) error {
+	doc, err := stylesheet.parseFile(fileName)
+	if err != nil || doc == nil {
+		return err
+	}
+
+	root := getFirstElement(doc)
+	if root == nil {
+		return fmt.Errorf("%s is not an XSLT stylesheet", fileName)
+	}
+
+	// the simplified syntax: a literal result element is the template for the root node
+	if root.NamespaceURI != xslNamespace {
+		if getXmlAttr(root, xslNamespace, "version") == "" {
+			return fmt.Errorf("%s is not an XSLT stylesheet", fileName)
+		}
+		stylesheet.precedence++
+		stylesheet.addTemplate(&xsltTemplate{node: doc, patterns: []xsltPattern{{"/", 0.5}},
+			precedence: stylesheet.precedence})
+		return nil
+	}
+	if root.Data != "stylesheet" && root.Data != "transform" {
+		return fmt.Errorf("%s is not an XSLT stylesheet", fileName)
+	}
+
+	// imported stylesheets have lower precedence than the importing one
+	for _, child := range getXslChildren(root) {
+		if child.Data == "import" {
+			importedFile, err := getXsltReference(fileName, child)
+			if err != nil {
+				return err
+			}
+			if err = stylesheet.loadFile(importedFile); err != nil {
+				return err
+			}
+		}
+	}
+	stylesheet.precedence++
+
+	return stylesheet.loadDeclarations(fileName, root, stylesheet.precedence)
+}
+
+func getXsltReference(fileName string, node *xmlquery.Node) (string, error) {
+	location := node.SelectAttr("href")
+	if strings.Contains(location, "://") {
+		return "", fmt.Errorf("only local stylesheets are supported, got %s", location)
+	}
+
+	return filepath.Join(filepath.Dir(fileName), location), nil
+}
+
+func (stylesheet *XsltStylesheet) loadDeclarations(fileName string, root *xmlquery.Node, precedence int) error {
+	for _, child := range getXslChildren(root) {
+		var err error
+
+		switch child.Data {
+		case "import":
+		case "include":
+			err = stylesheet.loadIncluded(fileName, child, precedence)
+		case "template":
+			err = stylesheet.loadTemplate(child, precedence)
+		case "variable", "param":
+			name := child.SelectAttr("name")
+			if _, ok := stylesheet.globals[name]; !ok {
+				stylesheet.globalNames = append(stylesheet.globalNames, name)
+			}
+			stylesheet.globals[name] = child
+		case "key":
+			patterns, patternErr := parseXsltPatterns(child.SelectAttr("match"))
+			if patternErr != nil {
+				return patternErr
+			}
+			name := child.SelectAttr("name")
+			stylesheet.keys[name] = append(stylesheet.keys[name], &xsltKey{node: child, patterns: patterns,
+				use: child.SelectAttr("use")})
+		case "output":
+			if method := child.SelectAttr("method"); method != "" {
+				stylesheet.output.method = method
+			}
+			if omit := child.SelectAttr("omit-xml-declaration"); omit != "" {
+				stylesheet.output.omitDeclaration = omit == "yes"
+			}
+			if public := child.SelectAttr("doctype-public"); public != "" {
+				stylesheet.output.doctypePublic = public
+			}
+			if system := child.SelectAttr("doctype-system"); system != "" {
+				stylesheet.output.doctypeSystem = system
+			}
+		case "strip-space":
+			stylesheet.stripSpace = append(stylesheet.stripSpace, strings.Fields(child.SelectAttr("elements"))...)
+		case "preserve-space":
+			stylesheet.preserveSpace = append(stylesheet.preserveSpace, strings.Fields(child.SelectAttr("elements"))...)
+		case "attribute-set", "decimal-format", "namespace-alias":
+			err = fmt.Errorf("xsl:%s is not supported", child.Data)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (stylesheet *XsltStylesheet) loadIncluded(fileName string, node *xmlquery.Node, precedence int) error {
+	includedFile, err := getXsltReference(fileName, node)
+	if err != nil {
+		return err
+	}
+
+	doc, err := stylesheet.parseFile(includedFile)
+	if err != nil || doc == nil {
+		return err
+	}
+	root := getFirstElement(doc)
+	if root == nil || root.NamespaceURI != xslNamespace {
+		return fmt.Errorf("%s is not an XSLT stylesheet", includedFile)
+	}
+
+	return stylesheet.loadDeclarations(includedFile, root, precedence)
+}
+
+func (stylesheet *XsltStylesheet) loadTemplate(node *xmlquery.Node, precedence int) error {
+	template := &xsltTemplate{
+		node:       node,
+		name:       node.SelectAttr("name"),
+		mode:       node.SelectAttr("mode"),
+		precedence: precedence,
+	}
+
+	if match, ok := getAttrValue(node, "match"); ok {
+		patterns, err := parseXsltPatterns(match)
+		if err != nil {
+			return err
+		}
+		if priority, ok := getAttrValue(node, "priority"); ok {
+			value, err := strconv.ParseFloat(strings.TrimSpace(priority), 64)
+			if err != nil {
+				return fmt.Errorf("invalid template priority %q", priority)
+			}
+			for index := range patterns {
+				patterns[index].priority = value
+			}
+		}
+		template.patterns = patterns
+	} else if template.name == "" {
+		return errors.New("xsl:template should have either match or name attribute")
+	}
+
+	stylesheet.addTemplate(template)
+
+	return nil
+}
+
+func (stylesheet *XsltStylesheet) addTemplate(template *xsltTemplate) {
+	stylesheet.templates = append(stylesheet.templates, template)
+
+	if existing, ok := stylesheet.namedTemplate[template.name]; template.name != "" &&
+		(!ok || existing.precedence <= template.precedence) {
+		stylesheet.namedTemplate[template.name] = template
+	}
+}
+
+func getXslChildren(node *xmlquery.Node) []*xmlquery.Node {
+	var children []*xmlquery.Node
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type == xmlquery.ElementNode && child.NamespaceURI == xslNamespace {
+			children = append(children, child)
+		}
+	}
+
+	return children
+}
+
+func isXslElement(node *xmlquery.Node, name string) bool {
+	return node.Type == xmlquery.ElementNode && node.NamespaceURI == xslNamespace && node.Data == name
+}
+
+func getXmlAttr(node *xmlquery.Node, namespace string, name string) string {
+	for _, attr := range node.Attr {
+		if attr.NamespaceURI == namespace && attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+
+	return ""
+}
+
+// parseXsltPatterns splits the union pattern into the alternatives and calculates
+// their default priorities.
+func parseXsltPatterns(pattern string) ([]xsltPattern, error) {
+	if strings.TrimSpace(pattern) == "" {
+		return nil, errors.New("empty template match pattern")
+	}
+
+	var patterns []xsltPattern
+	for _, alternative := range splitXPathTopLevel(pattern, '|') {
+		alternative = strings.TrimSpace(alternative)
+		patterns = append(patterns, xsltPattern{expr: alternative, priority: getXsltDefaultPriority(alternative)})
+	}
+
+	return patterns, nil
+}
+
+func getXsltDefaultPriority(pattern string) float64 {
+	pattern = strings.TrimPrefix(strings.TrimPrefix(pattern, "child::"), "attribute::")
+	pattern = strings.TrimPrefix(pattern, "@")
+
+	switch {
+	case strings.ContainsAny(pattern, "/["):
+		return 0.5
+	case pattern == "*" || pattern == "node()" || pattern == "text()" || pattern == "comment()" ||
+		pattern == "processing-instruction()":
+		return -0.5
+	case strings.HasSuffix(pattern, ":*"):
+		return -0.25
+	case strings.HasPrefix(pattern, "processing-instruction("):
+		return 0
+	case isQualifiedXmlName(pattern):
+		return 0
+	}
+
+	return 0.5
+}
+
+// Transform applies the stylesheet to the XML document and writes the formatted result.
+// The params override the values of the global stylesheet parameters.
+func (stylesheet *XsltStylesheet) Transform(reader io.Reader, writer io.Writer, params map[string]string, options QueryOptions) (errRes error) {
+	defer func() {
+		if err := recover(); err != nil {
+			errRes = fmt.Errorf("XSLT error: %v", err)
+		}
+	}()
+
+	doc, err := parseXml(reader)
+	if err != nil {
+		return err
+	}
+	stylesheet.prepareDocument(doc)
+
+	run := &xsltRun{
+		stylesheet: stylesheet,
+		root:       xmlquery.CreateXPathNavigator(doc),
+		params:     params,
+		globals:    map[string]xsltValue{},
+		evaluating: map[string]bool{},
+		matchSets:  map[string]map[xsltNodeKey]bool{},
+		ids:        map[xsltNodeKey]int{},
+		compiled:   map[*xmlquery.Node]map[string]*xpath.Expr{},
+		namespaces: map[*xmlquery.Node]map[string]string{},
+		messages:   os.Stderr,
+	}
+
+	result := &xmlquery.Node{Type: xmlquery.DocumentNode}
+	ctx := xsltContext{node: run.root.Copy(), current: run.root.Copy(), position: 1, size: 1, output: result}
+	for _, name := range stylesheet.globalNames {
+		if _, err = run.getGlobal(name); err != nil {
+			return err
+		}
+	}
+	if err = run.applyTemplates(ctx, nil); err != nil {
+		return err
+	}
+
+	return stylesheet.serialize(result, writer, options)
+}
+
+// prepareDocument removes the nodes which are not visible to XSLT and merges CDATA sections
+// into the text nodes. The whitespace-only text nodes are removed in the elements listed by
+// xsl:strip-space and between other nodes, as the XPath engine skips them anyway.
+func (stylesheet *XsltStylesheet) prepareDocument(node *xmlquery.Node) {
+	for child := node.FirstChild; child != nil; {
+		next := child.NextSibling
+
+		switch child.Type {
+		case xmlquery.DeclarationNode, xmlquery.ProcessingInstruction, xmlquery.NotationNode:
+			xmlquery.RemoveFromTree(child)
+		case xmlquery.TextNode, xmlquery.CharDataNode:
+			child.Type = xmlquery.TextNode
+			if previous := child.PrevSibling; previous != nil && previous.Type == xmlquery.TextNode {
+				previous.Data += child.Data
+				xmlquery.RemoveFromTree(child)
+			}
+		case xmlquery.ElementNode:
+			stylesheet.prepareDocument(child)
+		}
+
+		child = next
+	}
+
+	for child := node.FirstChild; child != nil; {
+		next := child.NextSibling
+		if child.Type == xmlquery.TextNode && strings.TrimSpace(child.Data) == "" &&
+			(node.Type == xmlquery.DocumentNode || child.PrevSibling != nil || next != nil || stylesheet.isStripped(node)) {
+			xmlquery.RemoveFromTree(child)
+		}
+		child = next
+	}
+}
+
+func (stylesheet *XsltStylesheet) isStripped(node *xmlquery.Node) bool {
+	matches := func(tests []string) bool {
+		for _, test := range tests {
+			prefix, local, found := strings.Cut(test, ":")
+			if !found {
+				prefix, local = "", prefix
+			}
+			if (local == "*" || local == node.Data) && (prefix == "" || prefix == node.Prefix) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return matches(stylesheet.stripSpace) && !matches(stylesheet.preserveSpace)
+}
+
+func (run *xsltRun) getGlobal(name string) (xsltValue, error) {
+	if value, ok := run.globals[name]; ok {
+		return value, nil
+	}
+
+	node, ok := run.stylesheet.globals[name]
+	if !ok {
+		return nil, fmt.Errorf("variable $%s is not defined", name)
+	}
+	if run.evaluating[name] {
+		return nil, fmt.Errorf("circular reference to variable $%s", name)
+	}
+	run.evaluating[name] = true
+
+	var value xsltValue
+	var err error
+	if param, ok := run.params[name]; ok && node.Data == "param" {
+		value = param
+	} else {
+		ctx := xsltContext{node: run.root.Copy(), current: run.root.Copy(), position: 1, size: 1}
+		value, err = run.evaluateVariable(node, ctx)
+	}
+	if err != nil {
+		return nil, err
+	}
+	run.globals[name] = value
+
+	return value, nil
+}
+
+func (run *xsltRun) lookupVariable(name string, ctx xsltContext) (xsltValue, error) {
+	for scope := ctx.scope; scope != nil; scope = scope.parent {
+		if scope.name == name {
+			return scope.value, nil
+		}
+	}
+
+	return run.getGlobal(name)
+}
+
+func (run *xsltRun) evaluateVariable(node *xmlquery.Node, ctx xsltContext) (xsltValue, error) {
+	if selectExpr, ok := getAttrValue(node, "select"); ok {
+		return run.evaluate(selectExpr, node, ctx)
+	}
+	if node.FirstChild == nil {
+		return "", nil
+	}
+
+	fragment := &xmlquery.Node{Type: xmlquery.DocumentNode}
+	ctx.output = fragment
+	if err := run.executeSequence(node, ctx); err != nil {
+		return nil, err
+	}
+
+	return xsltFragment{fragment}, nil
+}
+
+func (run *xsltRun) findTemplate(nav xpath.NodeNavigator, mode string) (*xsltTemplate, error) {
+	var best *xsltTemplate
+	bestPriority := math.Inf(-1)
+	key := getXsltNodeKey(nav)
+
+	for _, template := range run.stylesheet.templates {
+		if template.mode != mode || template.patterns == nil {
+			continue
+		}
+		for _, pattern := range template.patterns {
+			matches, err := run.matches(pattern.expr, template.node, key)
+			if err != nil {
+				return nil, err
+			}
+			if !matches {
+				continue
+			}
+			if best == nil || template.precedence > best.precedence ||
+				(template.precedence == best.precedence && pattern.priority >= bestPriority) {
+				best, bestPriority = template, pattern.priority
+			}
+		}
+	}
+
+	return best, nil
+}
+
+// matches checks if the node matches the pattern. The nodes matched by each pattern
+// are found once by evaluating the pattern from the document root.
+func (run *xsltRun) matches(pattern string, nsNode *xmlquery.Node, key xsltNodeKey) (bool, error) {
+	cacheKey := fmt.Sprintf("%p\x00%s", nsNode, pattern)
+	matchSet, ok := run.matchSets[cacheKey]
+	if !ok {
+		query := pattern
+		if !strings.HasPrefix(pattern, "/") && !strings.HasPrefix(pattern, "key(") && !strings.HasPrefix(pattern, "id(") {
+			query = "//" + pattern
+		}
+		ctx := xsltContext{node: run.root.Copy(), current: run.root.Copy(), position: 1, size: 1}
+		value, err := run.evaluate(query, nsNode, ctx)
+		if err != nil {
+			return false, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		nodes, ok := value.(xsltNodeSet)
+		if !ok {
+			return false, fmt.Errorf("pattern %q does not select nodes", pattern)
+		}
+
+		matchSet = map[xsltNodeKey]bool{}
+		for _, nav := range nodes {
+			matchSet[getXsltNodeKey(nav)] = true
+		}
+		run.matchSets[cacheKey] = matchSet
+	}
+
+	return matchSet[key], nil
+}
+
+func getXsltNodeKey(nav xpath.NodeNavigator) xsltNodeKey {
+	navigator := nav.(*xmlquery.NodeNavigator)
+	key := xsltNodeKey{node: navigator.Current()}
+	if navigator.NodeType() == xpath.AttributeNode {
+		key.attr = navigator.Prefix() + ":" + navigator.LocalName()
+	}
+
+	return key
+}
+
+func (run *xsltRun) applyTemplates(ctx xsltContext, params map[string]xsltValue) error {
+	template, err := run.findTemplate(ctx.node, ctx.mode)
+	if err != nil {
+		return err
+	}
+
+	if template != nil {
+		return run.executeTemplate(template, ctx, params)
+	}
+
+	// built-in templates
+	switch ctx.node.NodeType() {
+	case xpath.RootNode, xpath.ElementNode:
+		children, err := run.selectNodes("node()", nil, ctx)
+		if err != nil {
+			return err
+		}
+		for index, child := range children {
+			childCtx := ctx
+			childCtx.node, childCtx.current = child, child
+			childCtx.position, childCtx.size = index+1, len(children)
+			if err = run.applyTemplates(childCtx, nil); err != nil {
+				return err
+			}
+		}
+	case xpath.TextNode, xpath.AttributeNode:
+		appendXsltText(ctx.output, getXsltStringValue(ctx.node))
+	}
+
+	return nil
+}
+
+func (run *xsltRun) executeTemplate(template *xsltTemplate, ctx xsltContext, params map[string]xsltValue) error {
+	run.depth++
+	defer func() {
+		run.depth--
+	}()
+	if run.depth > xsltMaxTemplatesDepth {
+		return errors.New("too deep recursion of templates")
+	}
+
+	ctx.scope = nil
+	ctx.params = params
+	if params == nil {
+		ctx.params = map[string]xsltValue{}
+	}
+
+	return run.executeSequence(template.node, ctx)
+}
+
+func (run *xsltRun) executeSequence(parent *xmlquery.Node, ctx xsltContext) error {
+	for child := parent.FirstChild; child != nil; child = child.NextSibling {
+		switch child.Type {
+		case xmlquery.TextNode, xmlquery.CharDataNode:
+			if strings.TrimSpace(child.Data) != "" {
+				appendXsltText(ctx.output, child.Data)
+			}
+		case xmlquery.ElementNode:
+			if child.NamespaceURI != xslNamespace {
+				if err := run.executeLiteralElement(child, ctx); err != nil {
+					return err
+				}
+				continue
+			}
+
+			switch child.Data {
+			case "variable", "param":
+				name := child.SelectAttr("name")
+				value, ok := ctx.params[name]
+				if child.Data == "variable" || !ok {
+					var err error
+					if value, err = run.evaluateVariable(child, ctx); err != nil {
+						return err
+					}
+				}
+				ctx.scope = &xsltScope{name: name, value: value, parent: ctx.scope}
+			default:
+				if err := run.executeInstruction(child, ctx); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (run *xsltRun) executeInstruction(node *xmlquery.Node, ctx xsltContext) error {
+	switch node.Data {
+	case "apply-templates":
+		return run.executeApplyTemplates(node, ctx)
+	case "call-template":
+		name := node.SelectAttr("name")
+		template, ok := run.stylesheet.namedTemplate[name]
+		if !ok {
+			return fmt.Errorf("template %q is not defined", name)
+		}
+		params, err := run.evaluateParams(node, ctx)
+		if err != nil {
+			return err
+		}
+		return run.executeTemplate(template, ctx, params)
+	case "for-each":
+		nodes, err := run.selectNodes(node.SelectAttr("select"), node, ctx)
+		if err != nil {
+			return err
+		}
+		if nodes, err = run.sortNodes(node, nodes, ctx); err != nil {
+			return err
+		}
+		for index, item := range nodes {
+			itemCtx := ctx
+			itemCtx.node, itemCtx.current = item, item
+			itemCtx.position, itemCtx.size = index+1, len(nodes)
+			if err = run.executeSequence(node, itemCtx); err != nil {
+				return err
+			}
+		}
+	case "value-of":
+		value, err := run.evaluate(node.SelectAttr("select"), node, ctx)
+		if err != nil {
+			return err
+		}
+		appendXsltText(ctx.output, xsltToString(value))
+	case "if":
+		value, err := run.evaluate(node.SelectAttr("test"), node, ctx)
+		if err != nil {
+			return err
+		}
+		if xsltToBoolean(value) {
+			return run.executeSequence(node, ctx)
+		}
+	case "choose":
+		for _, branch := range getXslChildren(node) {
+			if branch.Data == "otherwise" {
+				return run.executeSequence(branch, ctx)
+			}
+			value, err := run.evaluate(branch.SelectAttr("test"), branch, ctx)
+			if err != nil {
+				return err
+			}
+			if xsltToBoolean(value) {
+				return run.executeSequence(branch, ctx)
+			}
+		}
+	case "text":
+		appendXsltText(ctx.output, node.InnerText())
+	case "element":
+		return run.executeElement(node, ctx)
+	case "attribute":
+		return run.executeAttribute(node, ctx)
+	case "comment":
+		text, err := run.instantiateText(node, ctx)
+		if err != nil {
+			return err
+		}
+		xmlquery.AddChild(ctx.output, &xmlquery.Node{Type: xmlquery.CommentNode, Data: text})
+	case "processing-instruction":
+		name, err := run.evaluateAVT(node.SelectAttr("name"), node, ctx)
+		if err != nil {
+			return err
+		}
+		text, err := run.instantiateText(node, ctx)
+		if err != nil {
+			return err
+		}
+		xmlquery.AddChild(ctx.output, &xmlquery.Node{Type: xmlquery.ProcessingInstruction, Data: name,
+			ProcInst: &xmlquery.ProcInstData{Target: name, Inst: strings.TrimLeft(text, " \t\r\n")}})
+	case "copy":
+		return run.executeCopy(node, ctx)
+	case "copy-of":
+		value, err := run.evaluate(node.SelectAttr("select"), node, ctx)
+		if err != nil {
+			return err
+		}
+		copyXsltValue(ctx.output, value)
+	case "number":
+		return run.executeNumber(node, ctx)
+	case "message":
+		text, err := run.instantiateText(node, ctx)
+		if err != nil {
+			return err
+		}
+		if node.SelectAttr("terminate") == "yes" {
+			return fmt.Errorf("terminated by xsl:message: %s", text)
+		}
+		_, _ = fmt.Fprintln(run.messages, text)
+	case "fallback", "sort", "with-param":
+	default:
+		return fmt.Errorf("xsl:%s is not supported", node.Data)
+	}
+
+	return nil
+}
+
+func (run *xsltRun) executeApplyTemplates(node *xmlquery.Node, ctx xsltContext) error {
+	selectExpr := node.SelectAttr("select")
+	if selectExpr == "" {
+		selectExpr = "node()"
+	}
+
+	nodes, err := run.selectNodes(selectExpr, node, ctx)
+	if err != nil {
+		return err
+	}
+	if nodes, err = run.sortNodes(node, nodes, ctx); err != nil {
+		return err
+	}
+	params, err := run.evaluateParams(node, ctx)
+	if err != nil {
+		return err
+	}
+
+	for index, item := range nodes {
+		itemCtx := ctx
+		itemCtx.node, itemCtx.current = item, item
+		itemCtx.position, itemCtx.size = index+1, len(nodes)
+		itemCtx.mode = node.SelectAttr("mode")
+		if err = run.applyTemplates(itemCtx, params); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (run *xsltRun) evaluateParams(node *xmlquery.Node, ctx xsltContext) (map[string]xsltValue, error) {
+	params := map[string]xsltValue{}
+	for _, child := range getXslChildren(node) {
+		if child.Data != "with-param" {
+			continue
+		}
+		value, err := run.evaluateVariable(child, ctx)
+		if err != nil {
+			return nil, err
+		}
+		params[child.SelectAttr("name")] = value
+	}
+
+	return params, nil
+}
+
+func (run *xsltRun) sortNodes(node *xmlquery.Node, nodes xsltNodeSet, ctx xsltContext) (xsltNodeSet, error) {
+	var keys []xsltSortKey
+	for _, child := range getXslChildren(node) {
+		if child.Data != "sort" {
+			continue
+		}
+		key := xsltSortKey{node: child, selectExpr: child.SelectAttr("select")}
+		if key.selectExpr == "" {
+			key.selectExpr = "."
+		}
+		for attr, target := range map[string]*bool{"order": &key.descending, "data-type": &key.numeric,
+			"case-order": &key.upperFirst} {
+			value, err := run.evaluateAVT(child.SelectAttr(attr), child, ctx)
+			if err != nil {
+				return nil, err
+			}
+			*target = value == "descending" || value == "number" || value == "upper-first"
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nodes, nil
+	}
+
+	values := make([][]xsltValue, len(nodes))
+	for index, item := range nodes {
+		itemCtx := ctx
+		itemCtx.node, itemCtx.current = item, item
+		itemCtx.position, itemCtx.size = index+1, len(nodes)
+		for _, key := range keys {
+			value, err := run.evaluate(key.selectExpr, key.node, itemCtx)
+			if err != nil {
+				return nil, err
+			}
+			if key.numeric {
+				values[index] = append(values[index], xsltToNumber(value))
+			} else {
+				values[index] = append(values[index], xsltToString(value))
+			}
+		}
+	}
+
+	indexes := make([]int, len(nodes))
+	for index := range indexes {
+		indexes[index] = index
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		for position, key := range keys {
+			result := compareXsltSortValues(values[indexes[i]][position], values[indexes[j]][position], key.upperFirst)
+			if result != 0 {
+				return (result < 0) != key.descending
+			}
+		}
+		return false
+	})
+
+	sorted := make(xsltNodeSet, len(nodes))
+	for position, index := range indexes {
+		sorted[position] = nodes[index]
+	}
+
+	return sorted, nil
+}
+
+func compareXsltSortValues(first xsltValue, second xsltValue, upperFirst bool) int {
+	if firstNumber, ok := first.(float64); ok {
+		secondNumber := second.(float64)
+		switch {
+		// NaN values precede all other numbers
+		case math.IsNaN(firstNumber) && math.IsNaN(secondNumber):
+			return 0
+		case math.IsNaN(firstNumber):
+			return -1
+		case math.IsNaN(secondNumber):
+			return 1
+		case firstNumber < secondNumber:
+			return -1
+		case firstNumber > secondNumber:
+			return 1
+		}
+		return 0
+	}
+
+	firstText, secondText := first.(string), second.(string)
+	if result := strings.Compare(strings.ToLower(firstText), strings.ToLower(secondText)); result != 0 {
+		return result
+	}
+	result := strings.Compare(firstText, secondText)
+	if upperFirst {
+		return result
+	}
+
+	return -result
+}
+
+func (run *xsltRun) executeLiteralElement(node *xmlquery.Node, ctx xsltContext) error {
+	excluded := map[string]bool{}
+	for current := node; current != nil; current = current.Parent {
+		value := getXmlAttr(current, xslNamespace, "exclude-result-prefixes")
+		if isXslElement(current, "stylesheet") || isXslElement(current, "transform") {
+			value = current.SelectAttr("exclude-result-prefixes")
+		}
+		for _, prefix := range strings.Fields(value) {
+			excluded[prefix] = true
+		}
+	}
+
+	element := &xmlquery.Node{Type: xmlquery.ElementNode, Data: node.Data, Prefix: node.Prefix,
+		NamespaceURI: node.NamespaceURI}
+	for _, attr := range node.Attr {
+		switch {
+		case attr.NamespaceURI == xslNamespace:
+			continue
+		case isNamespaceDeclaration(attr):
+			prefix := attr.Name.Local
+			if attr.Name.Space == "" {
+				prefix = "#default"
+			}
+			if attr.Value == xslNamespace || excluded[prefix] {
+				continue
+			}
+			element.Attr = append(element.Attr, attr)
+		default:
+			value, err := run.evaluateAVT(attr.Value, node, ctx)
+			if err != nil {
+				return err
+			}
+			attr.Value = value
+			element.Attr = append(element.Attr, attr)
+		}
+	}
+	xmlquery.AddChild(ctx.output, element)
+
+	ctx.output = element
+	return run.executeSequence(node, ctx)
+}
+
+func (run *xsltRun) resolveResultName(name string, namespace string, node *xmlquery.Node, hasNamespace bool) (xml.Name, string, error) {
+	if !isQualifiedXmlName(name) {
+		return xml.Name{}, "", fmt.Errorf("invalid name %q", name)
+	}
+
+	xmlName := toXmlName(name)
+	if hasNamespace {
+		return xmlName, namespace, nil
+	}
+	if xmlName.Space == "" {
+		return xmlName, "", nil
+	}
+
+	uri, ok := run.getNamespaces(node)[xmlName.Space]
+	if !ok {
+		return xml.Name{}, "", fmt.Errorf("undeclared namespace prefix in name %q", name)
+	}
+
+	return xmlName, uri, nil
+}
+
+func (run *xsltRun) executeElement(node *xmlquery.Node, ctx xsltContext) error {
+	name, err := run.evaluateAVT(node.SelectAttr("name"), node, ctx)
+	if err != nil {
+		return err
+	}
+	namespace, hasNamespace := getAttrValue(node, "namespace")
+	if namespace, err = run.evaluateAVT(namespace, node, ctx); err != nil {
+		return err
+	}
+
+	xmlName, uri, err := run.resolveResultName(name, namespace, node, hasNamespace)
+	if err != nil {
+		return err
+	}
+	if !hasNamespace && xmlName.Space == "" {
+		uri = run.getNamespaces(node)[""]
+	}
+
+	element := &xmlquery.Node{Type: xmlquery.ElementNode, Data: xmlName.Local, Prefix: xmlName.Space, NamespaceURI: uri}
+	xmlquery.AddChild(ctx.output, element)
+
+	ctx.output = element
+	return run.executeSequence(node, ctx)
+}
+
+func (run *xsltRun) executeAttribute(node *xmlquery.Node, ctx xsltContext) error {
+	name, err := run.evaluateAVT(node.SelectAttr("name"), node, ctx)
+	if err != nil {
+		return err
+	}
+	namespace, hasNamespace := getAttrValue(node, "namespace")
+	if namespace, err = run.evaluateAVT(namespace, node, ctx); err != nil {
+		return err
+	}
+
+	xmlName, uri, err := run.resolveResultName(name, namespace, node, hasNamespace)
+	if err != nil {
+		return err
+	}
+	if uri != "" && xmlName.Space == "" {
+		xmlName.Space = "ns0"
+	}
+
+	value, err := run.instantiateText(node, ctx)
+	if err != nil {
+		return err
+	}
+	setXsltAttr(ctx.output, xmlquery.Attr{Name: xmlName, Value: value, NamespaceURI: uri})
+
+	return nil
+}
+
+// setXsltAttr adds the attribute to the element if no children are added yet.
+func setXsltAttr(element *xmlquery.Node, attr xmlquery.Attr) {
+	if element.Type != xmlquery.ElementNode || element.FirstChild != nil {
+		return
+	}
+
+	for index, existing := range element.Attr {
+		if existing.Name == attr.Name {
+			element.Attr[index] = attr
+			return
+		}
+	}
+	element.Attr = append(element.Attr, attr)
+}
+
+func (run *xsltRun) instantiateText(node *xmlquery.Node, ctx xsltContext) (string, error) {
+	fragment := &xmlquery.Node{Type: xmlquery.DocumentNode}
+	ctx.output = fragment
+	if err := run.executeSequence(node, ctx); err != nil {
+		return "", err
+	}
+
+	return fragment.InnerText(), nil
+}
+
+func (run *xsltRun) executeCopy(node *xmlquery.Node, ctx xsltContext) error {
+	source := ctx.node.(*xmlquery.NodeNavigator)
+
+	switch source.NodeType() {
+	case xpath.RootNode:
+		return run.executeSequence(node, ctx)
+	case xpath.ElementNode:
+		original := source.Current()
+		element := &xmlquery.Node{Type: xmlquery.ElementNode, Data: original.Data, Prefix: original.Prefix,
+			NamespaceURI: original.NamespaceURI}
+		for _, attr := range original.Attr {
+			if isNamespaceDeclaration(attr) {
+				element.Attr = append(element.Attr, attr)
+			}
+		}
+		xmlquery.AddChild(ctx.output, element)
+		ctx.output = element
+		return run.executeSequence(node, ctx)
+	default:
+		copyXsltValue(ctx.output, xsltNodeSet{source})
+	}
+
+	return nil
+}
+
+func copyXsltValue(output *xmlquery.Node, value xsltValue) {
+	switch typedValue := value.(type) {
+	case xsltNodeSet:
+		for _, nav := range typedValue {
+			navigator := nav.(*xmlquery.NodeNavigator)
+			node := navigator.Current()
+			switch navigator.NodeType() {
+			case xpath.AttributeNode:
+				for _, attr := range node.Attr {
+					if attr.Name.Local == navigator.LocalName() && attr.Name.Space == navigator.Prefix() {
+						setXsltAttr(output, attr)
+					}
+				}
+			case xpath.RootNode:
+				for child := node.FirstChild; child != nil; child = child.NextSibling {
+					xmlquery.AddChild(output, copyXmlNode(child))
+				}
+			case xpath.TextNode:
+				appendXsltText(output, node.Data)
+			default:
+				xmlquery.AddChild(output, copyXmlNode(node))
+			}
+		}
+	case xsltFragment:
+		for child := typedValue.root.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type == xmlquery.TextNode {
+				appendXsltText(output, child.Data)
+				continue
+			}
+			xmlquery.AddChild(output, copyXmlNode(child))
+		}
+	default:
+		appendXsltText(output, xsltToString(value))
+	}
+}
+
+func copyXmlNode(node *xmlquery.Node) *xmlquery.Node {
+	nodeCopy := &xmlquery.Node{
+		Type:         node.Type,
+		Data:         node.Data,
+		Prefix:       node.Prefix,
+		NamespaceURI: node.NamespaceURI,
+		Attr:         append([]xmlquery.Attr{}, node.Attr...),
+		ProcInst:     node.ProcInst,
+	}
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		xmlquery.AddChild(nodeCopy, copyXmlNode(child))
+	}
+
+	return nodeCopy
+}
+
+func appendXsltText(output *xmlquery.Node, text string) {
+	if text == "" {
+		return
+	}
+	if last := output.LastChild; last != nil && last.Type == xmlquery.TextNode {
+		last.Data += text
+		return
+	}
+
+	xmlquery.AddChild(output, &xmlquery.Node{Type: xmlquery.TextNode, Data: text})
+}
+
+func (run *xsltRun) executeNumber(node *xmlquery.Node, ctx xsltContext) error {
+	var numbers []int
+
+	if valueExpr, ok := getAttrValue(node, "value"); ok {
+		value, err := run.evaluate(valueExpr, node, ctx)
+		if err != nil {
+			return err
+		}
+		numbers = append(numbers, int(math.Round(xsltToNumber(value))))
+	} else {
+		var err error
+		if numbers, err = run.countNodes(node, ctx); err != nil {
+			return err
+		}
+	}
+
+	format, err := run.evaluateAVT(node.SelectAttr("format"), node, ctx)
+	if err != nil {
+		return err
+	}
+	appendXsltText(ctx.output, formatXsltNumberList(numbers, format))
+
+	return nil
+}
+
+// countNodes calculates the numbers for xsl:number according to the level and count attributes.
+func (run *xsltRun) countNodes(node *xmlquery.Node, ctx xsltContext) ([]int, error) {
+	navigator := ctx.node.(*xmlquery.NodeNavigator)
+	current := navigator.Current()
+	if navigator.NodeType() == xpath.AttributeNode || navigator.NodeType() == xpath.RootNode {
+		return nil, nil
+	}
+
+	countPattern := node.SelectAttr("count")
+	matches := func(candidate *xmlquery.Node) (bool, error) {
+		if countPattern == "" {
+			return candidate.Type == current.Type && candidate.Data == current.Data && candidate.Prefix == current.Prefix, nil
+		}
+		for _, pattern := range splitXPathTopLevel(countPattern, '|') {
+			matched, err := run.matches(strings.TrimSpace(pattern), node, xsltNodeKey{node: candidate})
+			if err != nil || matched {
+				return matched, err
+			}
+		}
+		return false, nil
+	}
+	siblingNumber := func(candidate *xmlquery.Node) (int, error) {
+		number := 1
+		for sibling := candidate.PrevSibling; sibling != nil; sibling = sibling.PrevSibling {
+			matched, err := matches(sibling)
+			if err != nil {
+				return 0, err
+			}
+			if matched {
+				number++
+			}
+		}
+		return number, nil
+	}
+
+	var numbers []int
+	switch node.SelectAttr("level") {
+	case "any":
+		count := 0
+		var walk func(candidate *xmlquery.Node) (bool, error)
+		walk = func(candidate *xmlquery.Node) (bool, error) {
+			if candidate.Type != xmlquery.DocumentNode {
+				matched, err := matches(candidate)
+				if err != nil {
+					return false, err
+				}
+				if matched {
+					count++
+				}
+			}
+			if candidate == current {
+				return true, nil
+			}
+			for child := candidate.FirstChild; child != nil; child = child.NextSibling {
+				if found, err := walk(child); err != nil || found {
+					return found, err
+				}
+			}
+			return false, nil
+		}
+		root := current
+		for root.Parent != nil {
+			root = root.Parent
+		}
+		if _, err := walk(root); err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			numbers = append(numbers, count)
+		}
+	case "multiple":
+		for candidate := current; candidate != nil && candidate.Type != xmlquery.DocumentNode; candidate = candidate.Parent {
+			matched, err := matches(candidate)
+			if err != nil {
+				return nil, err
+			}
+			if matched {
+				number, err := siblingNumber(candidate)
+				if err != nil {
+					return nil, err
+				}
+				numbers = append([]int{number}, numbers...)
+			}
+		}
+	default:
+		for candidate := current; candidate != nil && candidate.Type != xmlquery.DocumentNode; candidate = candidate.Parent {
+			matched, err := matches(candidate)
+			if err != nil {
+				return nil, err
+			}
+			if matched {
+				number, err := siblingNumber(candidate)
+				if err != nil {
+					return nil, err
+				}
+				numbers = append(numbers, number)
+				break
+			}
+		}
+	}
+
+	return numbers, nil
+}
+
+func (stylesheet *XsltStylesheet) serialize(result *xmlquery.Node, writer io.Writer, options QueryOptions) error {
+	method := stylesheet.output.method
+	if method == "" {
+		method = "xml"
+		for child := result.FirstChild; child != nil; child = child.NextSibling {
+			if child.Type == xmlquery.TextNode && strings.TrimSpace(child.Data) == "" {
+				continue
+			}
+			if child.Type == xmlquery.ElementNode && strings.EqualFold(child.Data, "html") && child.NamespaceURI == "" {
+				method = "html"
+			}
+			break
+		}
+	}
+
+	switch method {
+	case "text":
+		_, err := io.WriteString(writer, result.InnerText())
+		return err
+	case "html":
+		var output strings.Builder
+		if stylesheet.output.doctypePublic != "" || stylesheet.output.doctypeSystem != "" {
+			output.WriteString(formatXsltDoctype("html", stylesheet.output.doctypePublic, stylesheet.output.doctypeSystem))
+		}
+		writeXsltHtml(&output, result)
+		return FormatHtml(strings.NewReader(output.String()), writer, options.Indent, options.Colors)
+	case "xml":
+		fixXsltNamespaces(result, map[string]string{"xml": xmlNamespace})
+		var output strings.Builder
+		if !stylesheet.output.omitDeclaration {
+			output.WriteString(`<?xml version="1.0" encoding="UTF-8"?>` + "\n")
+		}
+		if root := getFirstElement(result); root != nil && stylesheet.output.doctypeSystem != "" {
+			name := root.Data
+			if root.Prefix != "" {
+				name = root.Prefix + ":" + name
+			}
+			output.WriteString(formatXsltDoctype(name, stylesheet.output.doctypePublic, stylesheet.output.doctypeSystem))
+		}
+		output.WriteString(result.OutputXMLWithOptions(xmlquery.WithEmptyTagSupport()))
+		return FormatXml(strings.NewReader(output.String()), writer, options.Indent, options.Colors)
+	}
+
+	return fmt.Errorf("unsupported output method %q", method)
+}
+
+func formatXsltDoctype(name string, public string, system string) string {
+	switch {
+	case public != "" && system != "":
+		return fmt.Sprintf("<!DOCTYPE %s PUBLIC %q %q>\n", name, public, system)
+	case public != "":
+		return fmt.Sprintf("<!DOCTYPE %s PUBLIC %q>\n", name, public)
+	case system != "":
+		return fmt.Sprintf("<!DOCTYPE %s SYSTEM %q>\n", name, system)
+	}
+
+	return "<!DOCTYPE " + name + ">\n"
+}
+
+// fixXsltNamespaces adds the namespace declarations which are required by the names of
+// the result elements and attributes but not declared in the scope.
+func fixXsltNamespaces(node *xmlquery.Node, scope map[string]string) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		if child.Type != xmlquery.ElementNode {
+			continue
+		}
+
+		childScope := make(map[string]string, len(scope))
+		for prefix, uri := range scope {
+			childScope[prefix] = uri
+		}
+		for _, attr := range child.Attr {
+			if attr.Name.Space == "xmlns" {
+				childScope[attr.Name.Local] = attr.Value
+			} else if attr.Name.Space == "" && attr.Name.Local == "xmlns" {
+				childScope[""] = attr.Value
+			}
+		}
+
+		declare := func(prefix string, uri string) {
+			if current, ok := childScope[prefix]; ok && current == uri || (!ok && uri == "") {
+				return
+			}
+			childScope[prefix] = uri
+			if prefix == "" {
+				child.Attr = append(child.Attr, xmlquery.Attr{Name: xml.Name{Local: "xmlns"}, Value: uri})
+			} else {
+				child.Attr = append(child.Attr, xmlquery.Attr{Name: xml.Name{Space: "xmlns", Local: prefix}, Value: uri})
+			}
+		}
+
+		if child.Prefix != "xml" {
+			declare(child.Prefix, child.NamespaceURI)
+		}
+		for _, attr := range child.Attr {
+			if attr.Name.Space != "" && attr.Name.Space != "xmlns" && attr.Name.Space != "xml" && attr.NamespaceURI != "" {
+				declare(attr.Name.Space, attr.NamespaceURI)
+			}
+		}
+
+		fixXsltNamespaces(child, childScope)
+	}
+}
+
+var htmlVoidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true, "input": true,
+	"link": true, "meta": true, "param": true, "source": true, "track": true, "wbr": true,
+	// the obsolete void elements
+	"basefont": true, "bgsound": true, "frame": true, "keygen": true,
+}
+
+func writeXsltHtml(output *strings.Builder, node *xmlquery.Node) {
+	for child := node.FirstChild; child != nil; child = child.NextSibling {
+		switch child.Type {
+		case xmlquery.TextNode, xmlquery.CharDataNode:
+			if name := strings.ToLower(node.Data); node.Type == xmlquery.ElementNode && (name == "script" || name == "style") {
+				output.WriteString(child.Data)
+			} else {
+				output.WriteString(html.EscapeString(child.Data))
+			}
+		case xmlquery.CommentNode:
+			output.WriteString("<!--" + child.Data + "-->")
+		case xmlquery.ProcessingInstruction:
+			output.WriteString("<?" + child.Data + " " + child.ProcInst.Inst + ">")
+		case xmlquery.ElementNode:
+			name := child.Data
+			if child.Prefix != "" {
+				name = child.Prefix + ":" + name
+			}
+			output.WriteString("<" + name)
+			for _, attr := range child.Attr {
+				attrName := attr.Name.Local
+				if attr.Name.Space != "" {
+					attrName = attr.Name.Space + ":" + attrName
+				}
+				output.WriteString(" " + attrName + "=\"" + html.EscapeString(attr.Value) + "\"")
+			}
+			output.WriteString(">")
+			if htmlVoidElements[strings.ToLower(name)] && child.FirstChild == nil {
+				continue
+			}
+			writeXsltHtml(output, child)
+			output.WriteString("</" + name + ">")
+		}
+	}
+}
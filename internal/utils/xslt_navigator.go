@@ -0,0 +1,236 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+
+	"github.com/antchfx/xpath"
+)
+
+// The values bound to the XPath expression are the children of the virtual node in this
+// namespace, the value with the index N is named vN.
+const (
+	xsltBindingsPrefix    = "xq-binding"
+	xsltBindingsNamespace = "urn:xq:xslt-bindings"
+)
+
+type xsltNavigatorKind int
+
+const (
+	xsltNavigatorDocument xsltNavigatorKind = iota
+	xsltNavigatorBindings
+	xsltNavigatorValue
+	xsltNavigatorMember
+)
+
+// xsltNavigator navigates the document and the values bound to the expression: the variables
+// and the results of the XSLT functions. The XPath engine does not support the variables, so
+// the expression refers to the bound value with the path /@node()/xq-binding:vN. The root
+// reached by the "/" step has the virtual bindings node as the only attribute, the values are
+// its children and the nodes of a node-set value are the children of the value. The bindings
+// node is not an ancestor of any node, its type is matched by the node() test only, and the
+// root reached by the other steps has no attributes, as usual. The compiled expression doesn't
+// depend on the values, so it is cached.
+type xsltNavigator struct {
+	node     xpath.NodeNavigator
+	bindings []xsltValue
+	kind     xsltNavigatorKind
+	// the index of the value and the index of the node in the node-set value
+	value, member int
+	// the navigator is moved to the root by the "/" step
+	fromRoot bool
+}
+
+func newXsltNavigator(node xpath.NodeNavigator, bindings []xsltValue) *xsltNavigator {
+	return &xsltNavigator{node: node, bindings: bindings}
+}
+
+func getXsltBindingPath(index int) string {
+	return fmt.Sprintf("/@node()/%s:v%d", xsltBindingsPrefix, index)
+}
+
+func (nav *xsltNavigator) isVirtual() bool {
+	return nav.kind == xsltNavigatorBindings || nav.kind == xsltNavigatorValue
+}
+
+func (nav *xsltNavigator) NodeType() xpath.NodeType {
+	switch nav.kind {
+	case xsltNavigatorBindings:
+		return xpath.RootNode
+	case xsltNavigatorValue:
+		return xpath.ElementNode
+	}
+	return nav.node.NodeType()
+}
+
+func (nav *xsltNavigator) LocalName() string {
+	switch nav.kind {
+	case xsltNavigatorBindings:
+		return ""
+	case xsltNavigatorValue:
+		return "v" + strconv.Itoa(nav.value)
+	}
+	return nav.node.LocalName()
+}
+
+func (nav *xsltNavigator) Prefix() string {
+	switch nav.kind {
+	case xsltNavigatorBindings:
+		return ""
+	case xsltNavigatorValue:
+		return xsltBindingsPrefix
+	}
+	return nav.node.Prefix()
+}
+
+func (nav *xsltNavigator) NamespaceURL() string {
+	switch nav.kind {
+	case xsltNavigatorBindings:
+		return ""
+	case xsltNavigatorValue:
+		return xsltBindingsNamespace
+	}
+	if node, ok := nav.node.(interface{ NamespaceURL() string }); ok {
+		return node.NamespaceURL()
+	}
+	return ""
+}
+
+// Value returns the string value of the bound value, the numbers are formatted to be parsed
+// back by the number() function.
+func (nav *xsltNavigator) Value() string {
+	switch nav.kind {
+	case xsltNavigatorBindings:
+		return ""
+	case xsltNavigatorValue:
+		value := nav.bindings[nav.value]
+		if number, ok := value.(float64); ok && (math.IsNaN(number) || math.IsInf(number, 0)) {
+			return strconv.FormatFloat(number, 'g', -1, 64)
+		}
+		return xsltToString(value)
+	}
+	return nav.node.Value()
+}
+
+// Copy returns the copy of the navigator. The nodes of the node-set value are iterated only
+// by the navigator of the child axis, the copies navigate the document of the node.
+func (nav *xsltNavigator) Copy() xpath.NodeNavigator {
+	result := *nav
+	result.node = nav.node.Copy()
+	if result.kind == xsltNavigatorMember {
+		result.kind = xsltNavigatorDocument
+	}
+	return &result
+}
+
+func (nav *xsltNavigator) MoveToRoot() {
+	nav.kind = xsltNavigatorDocument
+	nav.node.MoveToRoot()
+	nav.fromRoot = true
+}
+
+// MoveToParent moves to the parent node. The parent of the bindings node is the root, the
+// navigator stays on it while the values are navigated.
+func (nav *xsltNavigator) MoveToParent() bool {
+	nav.fromRoot = false
+
+	switch nav.kind {
+	case xsltNavigatorBindings:
+		nav.kind = xsltNavigatorDocument
+		return true
+	case xsltNavigatorValue:
+		nav.kind = xsltNavigatorBindings
+		return true
+	}
+	nav.kind = xsltNavigatorDocument
+	return nav.node.MoveToParent()
+}
+
+func (nav *xsltNavigator) MoveToNextAttribute() bool {
+	fromRoot := nav.fromRoot
+	nav.fromRoot = false
+
+	switch nav.kind {
+	case xsltNavigatorBindings, xsltNavigatorValue:
+		return false
+	case xsltNavigatorDocument:
+		if fromRoot && len(nav.bindings) > 0 {
+			nav.kind = xsltNavigatorBindings
+			return true
+		}
+	}
+	nav.kind = xsltNavigatorDocument
+	return nav.node.MoveToNextAttribute()
+}
+
+func (nav *xsltNavigator) MoveToChild() bool {
+	nav.fromRoot = false
+
+	switch nav.kind {
+	case xsltNavigatorBindings:
+		if len(nav.bindings) == 0 {
+			return false
+		}
+		nav.kind, nav.value = xsltNavigatorValue, 0
+		return true
+	case xsltNavigatorValue:
+		nodes, ok := nav.bindings[nav.value].(xsltNodeSet)
+		if !ok || len(nodes) == 0 {
+			return false
+		}
+		nav.kind, nav.member, nav.node = xsltNavigatorMember, 0, nodes[0].Copy()
+		return true
+	}
+	nav.kind = xsltNavigatorDocument
+	return nav.node.MoveToChild()
+}
+
+func (nav *xsltNavigator) MoveToFirst() bool {
+	return nav.moveToSibling(func(int) int { return 0 }, nav.node.MoveToFirst)
+}
+
+func (nav *xsltNavigator) MoveToNext() bool {
+	return nav.moveToSibling(func(index int) int { return index + 1 }, nav.node.MoveToNext)
+}
+
+func (nav *xsltNavigator) MoveToPrevious() bool {
+	return nav.moveToSibling(func(index int) int { return index - 1 }, nav.node.MoveToPrevious)
+}
+
+// moveToSibling moves to the sibling value, the sibling node of the node-set value or the
+// sibling node in the document.
+func (nav *xsltNavigator) moveToSibling(sibling func(index int) int, move func() bool) bool {
+	nav.fromRoot = false
+
+	switch nav.kind {
+	case xsltNavigatorBindings:
+		return false
+	case xsltNavigatorValue:
+		index := sibling(nav.value)
+		if index < 0 || index >= len(nav.bindings) {
+			return false
+		}
+		nav.value = index
+		return true
+	case xsltNavigatorMember:
+		nodes := nav.bindings[nav.value].(xsltNodeSet)
+		index := sibling(nav.member)
+		if index < 0 || index >= len(nodes) {
+			return false
+		}
+		nav.member, nav.node = index, nodes[index].Copy()
+		return true
+	}
+	return move()
+}
+
+func (nav *xsltNavigator) MoveTo(other xpath.NodeNavigator) bool {
+	target, ok := other.(*xsltNavigator)
+	if !ok {
+		return false
+	}
+	*nav = *target
+	nav.node = target.node.Copy()
+	return true
+}
@@ -0,0 +1,247 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestXsltTransform(t *testing.T) {
+	dataDir := filepath.Join("..", "..", "test", "data", "xslt")
+	input, err := os.ReadFile(filepath.Join(dataDir, "catalog.xml"))
+	assert.Nil(t, err)
+	options := QueryOptions{Indent: "  ", Colors: ColorsDisabled}
+
+	stylesheet, err := LoadXslt(filepath.Join(dataDir, "catalog.xsl"))
+	assert.Nil(t, err)
+	output := new(strings.Builder)
+	assert.Nil(t, stylesheet.Transform(strings.NewReader(string(input)), output, nil, options))
+	assert.Contains(t, output.String(), `<books count="3">`)
+	assert.Contains(t, output.String(), "<book position=\"2\" ref=\"b3\">\n    <name>DUNE</name>\n"+
+		"    <price currency=\"USD\">9.99</price>\n    <age>modern</age>\n    <fiction/>\n  </book>")
+
+	output.Reset()
+	params := map[string]string{"max-price": "10"}
+	assert.Nil(t, stylesheet.Transform(strings.NewReader(string(input)), output, params, options))
+	assert.Contains(t, output.String(), `<books count="1">`)
+
+	stylesheet, err = LoadXslt(filepath.Join(dataDir, "text.xsl"))
+	assert.Nil(t, err)
+	output.Reset()
+	assert.Nil(t, stylesheet.Transform(strings.NewReader(string(input)), output, nil, options))
+	assert.Equal(t, "fiction: The Hobbit, Dune\nhistory: SPQR\nscience: A Brief History of Time\nTotal: 65.48\n",
+		output.String())
+
+	stylesheet, err = LoadXslt(filepath.Join(dataDir, "html.xsl"))
+	assert.Nil(t, err)
+	output.Reset()
+	assert.Nil(t, stylesheet.Transform(strings.NewReader(string(input)), output, nil, options))
+	assert.Contains(t, output.String(), "<tr>\n        <td>SPQR</td>\n        <td>24.00</td>\n      </tr>")
+	assert.Contains(t, output.String(), "<br/>")
+}
+
+func TestXsltFeatures(t *testing.T) {
+	input := `<?xml version="1.0"?>
+<doc xmlns:m="urn:meta">
+  <m:info>meta</m:info>
+  <section title="One">
+    <para>First <b>bold</b> text</para>
+    <para>Second</para>
+  </section>
+  <section title="Two">
+    <para>Third</para>
+    <!-- note -->
+  </section>
+</doc>`
+
+	tests := []struct {
+		name       string
+		stylesheet string
+		expected   string
+	}{
+		{
+			name: "identity transform",
+			stylesheet: `<xsl:template match="@*|node()">
+  <xsl:copy><xsl:apply-templates select="@*|node()"/></xsl:copy>
+</xsl:template>
+<xsl:template match="para[2]"/>`,
+			expected: "<doc xmlns:m=\"urn:meta\">\n  <m:info>meta</m:info>\n  <section title=\"One\">\n" +
+				"    <para>First\n      <b>bold</b> text</para>\n  </section>\n  <section title=\"Two\">\n" +
+				"    <para>Third</para>\n    <!-- note -->\n  </section>\n</doc>",
+		},
+		{
+			name: "built-in templates",
+			stylesheet: `<xsl:output method="text"/>
+<xsl:template match="m:info"/>
+<xsl:template match="b">*<xsl:apply-templates/>*</xsl:template>`,
+			expected: "First *bold* textSecondThird",
+		},
+		{
+			name: "numbering and modes",
+			stylesheet: `<xsl:output method="text"/>
+<xsl:template match="/">
+  <xsl:apply-templates select="//para" mode="toc"/>
+</xsl:template>
+<xsl:template match="para" mode="toc">
+  <xsl:number level="multiple" count="section|para" format="1.a "/>
+  <xsl:number level="any" format="(i) "/>
+  <xsl:value-of select="normalize-space(concat(../@title, ': ', .))"/>
+  <xsl:text>&#10;</xsl:text>
+</xsl:template>`,
+			expected: "1.a (i) One: First bold text\n1.b (ii) One: Second\n2.a (iii) Two: Third",
+		},
+		{
+			name: "priorities and conflicts",
+			stylesheet: `<xsl:output method="text"/>
+<xsl:template match="/"><xsl:apply-templates select="//para"/></xsl:template>
+<xsl:template match="*">[any]</xsl:template>
+<xsl:template match="para">[para]</xsl:template>
+<xsl:template match="section[@title='Two']/para">[nested]</xsl:template>
+<xsl:template match="para[b]" priority="2">[bold]</xsl:template>`,
+			expected: "[bold][para][nested]",
+		},
+		{
+			name: "variables and result tree fragments",
+			stylesheet: `<xsl:variable name="sections" select="//section"/>
+<xsl:variable name="header"><h1>Sections: <xsl:value-of select="count($sections)"/></h1></xsl:variable>
+<xsl:template match="/">
+  <result>
+    <xsl:copy-of select="$header"/>
+    <xsl:for-each select="$sections">
+      <xsl:variable name="title" select="@title"/>
+      <xsl:element name="s-{position()}">
+        <xsl:attribute name="last"><xsl:value-of select="position() = last()"/></xsl:attribute>
+        <xsl:value-of select="$sections[@title = $title]/para[1]"/>
+      </xsl:element>
+    </xsl:for-each>
+    <xsl:comment>total <xsl:value-of select="string-length($header)"/></xsl:comment>
+  </result>
+</xsl:template>`,
+			expected: "<result>\n  <h1>Sections: 2</h1>\n  <s-1 last=\"false\">First bold text</s-1>\n" +
+				"  <s-2 last=\"true\">Third</s-2>\n  <!--total 11-->\n</result>",
+		},
+		{
+			name: "namespaces",
+			stylesheet: `<xsl:template match="/">
+  <out:result xmlns:out="urn:out" xmlns:meta="urn:meta">
+    <out:item><xsl:value-of select="//meta:info"/></out:item>
+    <xsl:element name="x:extra" namespace="urn:extra"/>
+  </out:result>
+</xsl:template>`,
+			expected: "<out:result xmlns:out=\"urn:out\" xmlns:meta=\"urn:meta\">\n  <out:item>meta</out:item>\n" +
+				"  <x:extra xmlns:x=\"urn:extra\"/>\n</out:result>",
+		},
+	}
+
+	dir := t.TempDir()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			fileName := filepath.Join(dir, "test.xsl")
+			content := `<xsl:stylesheet version="1.0" xmlns:xsl="http://www.w3.org/1999/XSL/Transform" ` +
+				`xmlns:m="urn:meta" exclude-result-prefixes="m">` + `<xsl:output omit-xml-declaration="yes"/>` +
+				test.stylesheet + `</xsl:stylesheet>`
+			assert.Nil(t, os.WriteFile(fileName, []byte(content), 0600))
+
+			stylesheet, err := LoadXslt(fileName)
+			assert.Nil(t, err)
+			output := new(strings.Builder)
+			err = stylesheet.Transform(strings.NewReader(input), output, nil, QueryOptions{Indent: "  ",
+				Colors: ColorsDisabled})
+			assert.Nil(t, err)
+			assert.Equal(t, test.expected, strings.TrimSpace(output.String()))
+		})
+	}
+}
+
+func TestXsltNodeSetVariables(t *testing.T) {
+	input := new(strings.Builder)
+	input.WriteString("<items>")
+	for index := 1; index <= 3000; index++ {
+		input.WriteString(`<item n="` + strconv.Itoa(index) + `"/>`)
+	}
+	input.WriteString("</items>")
+
+	fileName := filepath.Join(t.TempDir(), "test.xsl")
+	content := `<xsl:stylesheet version="1.0" xmlns:xsl="http://www.w3.org/1999/XSL/Transform">
+<xsl:output method="text"/>
+<xsl:variable name="all" select="//item"/>
+<xsl:variable name="even" select="$all[@n mod 2 = 0]"/>
+<xsl:template match="/">
+  <xsl:value-of select="count($all[@n > 10])"/>
+  <xsl:text> </xsl:text>
+  <xsl:value-of select="$even[position() = 2]/@n"/>
+  <xsl:text> </xsl:text>
+  <xsl:value-of select="$even[@n > 100][last()]/@n"/>
+  <xsl:text> </xsl:text>
+  <xsl:value-of select="$even[3]/following-sibling::item[1]/@n"/>
+  <xsl:text> </xsl:text>
+  <xsl:value-of select="count($even | $all[@n &lt; 4])"/>
+  <xsl:text> </xsl:text>
+  <xsl:value-of select="count(/..) + count(/ancestor-or-self::node()) * 10 + count($all[1]/ancestor::node()) * 100"/>
+  <xsl:text> </xsl:text>
+  <xsl:value-of select="count(//@* | $all/..)"/>
+</xsl:template>
+</xsl:stylesheet>`
+	assert.Nil(t, os.WriteFile(fileName, []byte(content), 0600))
+
+	stylesheet, err := LoadXslt(fileName)
+	assert.Nil(t, err)
+	output := new(strings.Builder)
+	assert.Nil(t, stylesheet.Transform(strings.NewReader(input.String()), output, nil, QueryOptions{}))
+	assert.Equal(t, "2990 4 3000 7 1502 210 3001", output.String())
+}
+
+func TestXsltErrors(t *testing.T) {
+	dir := t.TempDir()
+	load := func(content string) (*XsltStylesheet, error) {
+		fileName := filepath.Join(dir, "test.xsl")
+		assert.Nil(t, os.WriteFile(fileName, []byte(content), 0600))
+		return LoadXslt(fileName)
+	}
+
+	_, err := load(`<root/>`)
+	assert.ErrorContains(t, err, "is not an XSLT stylesheet")
+
+	_, err = LoadXslt(filepath.Join(dir, "nonexistent.xsl"))
+	assert.NotNil(t, err)
+
+	stylesheet, err := load(`<xsl:stylesheet version="1.0" xmlns:xsl="http://www.w3.org/1999/XSL/Transform">
+  <xsl:template match="/"><xsl:call-template name="missing"/></xsl:template>
+</xsl:stylesheet>`)
+	assert.Nil(t, err)
+	err = stylesheet.Transform(strings.NewReader("<a/>"), new(strings.Builder), nil, QueryOptions{})
+	assert.ErrorContains(t, err, `template "missing" is not defined`)
+
+	stylesheet, err = load(`<xsl:stylesheet version="1.0" xmlns:xsl="http://www.w3.org/1999/XSL/Transform">
+  <xsl:template match="/"><xsl:message terminate="yes">stop</xsl:message></xsl:template>
+</xsl:stylesheet>`)
+	assert.Nil(t, err)
+	err = stylesheet.Transform(strings.NewReader("<a/>"), new(strings.Builder), nil, QueryOptions{})
+	assert.ErrorContains(t, err, "terminated by xsl:message: stop")
+
+	stylesheet, err = load(`<xsl:stylesheet version="1.0" xmlns:xsl="http://www.w3.org/1999/XSL/Transform">
+  <xsl:template match="/"><xsl:apply-templates select="/"/></xsl:template>
+</xsl:stylesheet>`)
+	assert.Nil(t, err)
+	err = stylesheet.Transform(strings.NewReader("<a/>"), new(strings.Builder), nil, QueryOptions{})
+	assert.ErrorContains(t, err, "too deep recursion of templates")
+}
+
+func TestFormatXsltNumber(t *testing.T) {
+	assert.Equal(t, "1,234.50", formatXsltNumber(1234.5, "#,##0.00"))
+	assert.Equal(t, "1234.5", formatXsltNumber(1234.5, "0.##"))
+	assert.Equal(t, "(12)", formatXsltNumber(-12, "0;(0)"))
+	assert.Equal(t, "-012", formatXsltNumber(-12, "000"))
+	assert.Equal(t, "25%", formatXsltNumber(0.25, "0%"))
+	assert.Equal(t, ".5", formatXsltNumber(0.5, "#.#"))
+	assert.Equal(t, "NaN", formatXsltNumber(xsltToNumber("abc"), "0"))
+
+	assert.Equal(t, "iv-C", formatXsltNumberList([]int{4, 3}, "i-A"))
+	assert.Equal(t, "[007]", formatXsltNumberList([]int{7}, "[001]"))
+	assert.Equal(t, "1.2.3", formatXsltNumberList([]int{1, 2, 3}, ""))
+	assert.Equal(t, "aa", formatXsltNumberList([]int{27}, "a"))
+}
@@ -0,0 +1,948 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/antchfx/xpath"
+)
+
+// xsltValue is one of the XPath value types: string, float64, bool, xsltNodeSet or
+// xsltFragment (a result tree fragment).
+type xsltValue interface{}
+
+type xsltNodeSet []xpath.NodeNavigator
+
+type xsltFragment struct {
+	root *xmlquery.Node
+}
+
+// xsltFunctions are the functions added by XSLT to the core XPath library. The XPath engine
+// does not support them, so the calls are evaluated and their results are bound to the expression.
+var xsltFunctions = map[string]bool{
+	"key": true, "generate-id": true, "format-number": true, "system-property": true, "function-available": true,
+	"element-available": true, "document": true, "unparsed-entity-uri": true,
+}
+
+var xpathFunctions = map[string]bool{
+	"last": true, "position": true, "count": true, "id": true, "local-name": true, "namespace-uri": true, "name": true,
+	"string": true, "concat": true, "starts-with": true, "contains": true, "substring-before": true,
+	"substring-after": true, "substring": true, "string-length": true, "normalize-space": true, "translate": true,
+	"boolean": true, "not": true, "true": true, "false": true, "lang": true, "number": true, "sum": true, "floor": true,
+	"ceiling": true, "round": true, "current": true,
+}
+
+// splitXPathTopLevel splits the expression by the separator which is not enclosed into
+// brackets or string literals.
+func splitXPathTopLevel(input string, separator byte) []string {
+	var parts []string
+	for {
+		index := findXPathSeparator(input, separator, false)
+		if index < 0 {
+			return append(parts, input)
+		}
+		parts = append(parts, input[:index])
+		input = input[index+1:]
+	}
+}
+
+// getNamespaces returns the namespace declarations in scope of the stylesheet node.
+// The default namespace is stored with the empty prefix.
+func (run *xsltRun) getNamespaces(node *xmlquery.Node) map[string]string {
+	if namespaces, ok := run.namespaces[node]; ok {
+		return namespaces
+	}
+
+	namespaces := map[string]string{}
+	for current := node; current != nil; current = current.Parent {
+		for _, attr := range current.Attr {
+			prefix := ""
+			if attr.Name.Space == "xmlns" {
+				prefix = attr.Name.Local
+			} else if attr.Name.Space != "" || attr.Name.Local != "xmlns" {
+				continue
+			}
+			if _, ok := namespaces[prefix]; !ok {
+				namespaces[prefix] = attr.Value
+			}
+		}
+	}
+	run.namespaces[node] = namespaces
+
+	return namespaces
+}
+
+// evaluate evaluates the XPath expression of the stylesheet node in the context.
+func (run *xsltRun) evaluate(expr string, nsNode *xmlquery.Node, ctx xsltContext) (xsltValue, error) {
+	trimmed := strings.TrimSpace(expr)
+	if trimmed == "" {
+		return nil, errors.New("empty XPath expression")
+	}
+
+	// the value is returned as is to keep result tree fragments for xsl:copy-of
+	if strings.HasPrefix(trimmed, "$") && isQualifiedXmlName(trimmed[1:]) {
+		return run.lookupVariable(trimmed[1:], ctx)
+	}
+
+	rewritten, bindings, err := run.rewriteExpression(trimmed, nsNode, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return run.evaluateCompiled(rewritten, bindings, nsNode, ctx)
+}
+
+// evaluateCompiled evaluates the rewritten expression with the bound values.
+func (run *xsltRun) evaluateCompiled(expr string, bindings []xsltValue, nsNode *xmlquery.Node, ctx xsltContext) (xsltValue, error) {
+	compiled, ok := run.compiled[nsNode][expr]
+	if !ok {
+		namespaces := map[string]string{xsltBindingsPrefix: xsltBindingsNamespace}
+		for prefix, uri := range run.getNamespaces(nsNode) {
+			if prefix != "" {
+				namespaces[prefix] = uri
+			}
+		}
+
+		var err error
+		if compiled, err = xpath.CompileWithNS(expr, namespaces); err != nil {
+			return nil, fmt.Errorf("invalid XPath expression %q: %w", expr, err)
+		}
+		if run.compiled[nsNode] == nil {
+			run.compiled[nsNode] = map[string]*xpath.Expr{}
+		}
+		run.compiled[nsNode][expr] = compiled
+	}
+
+	switch value := compiled.Evaluate(newXsltNavigator(ctx.node.Copy(), bindings)).(type) {
+	case *xpath.NodeIterator:
+		var nodes xsltNodeSet
+		for value.MoveNext() {
+			// the virtual nodes are selected only by the node() test of the root attributes
+			if nav := value.Current().(*xsltNavigator); !nav.isVirtual() {
+				nodes = append(nodes, nav.node.Copy())
+			}
+		}
+		return nodes, nil
+	case string, float64, bool:
+		return value, nil
+	default:
+		return nil, fmt.Errorf("unexpected result of XPath expression %q: %v", expr, value)
+	}
+}
+
+func (run *xsltRun) selectNodes(expr string, nsNode *xmlquery.Node, ctx xsltContext) (xsltNodeSet, error) {
+	value, err := run.evaluate(expr, nsNode, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes, ok := value.(xsltNodeSet)
+	if !ok {
+		return nil, fmt.Errorf("expression %q does not select nodes", expr)
+	}
+
+	return nodes, nil
+}
+
+// rewriteExpression replaces the parts of the expression which the XPath engine does not
+// support: variable references, the XSLT functions and the context-dependent functions
+// position(), last() and current(). The values are bound to the expression and replaced by
+// the references to them, see xsltNavigator. The predicates calling the XSLT functions are
+// evaluated for each node separately, as well as the predicates of the bound node-sets calling
+// position() or last(): the XPath engine counts the siblings of the node in the document.
+func (run *xsltRun) rewriteExpression(expr string, nsNode *xmlquery.Node, ctx xsltContext) (string, []xsltValue, error) {
+	var output bytes.Buffer
+	var bindings []xsltValue
+	depth := 0
+	// the position of the last node-set bound at the top level and the position after it and
+	// its predicates
+	bindingStart, bindingEnd := -1, -1
+	filteringBinding := false
+
+	writeValue := func(value xsltValue, rest string) {
+		if nodes, ok := value.(xsltNodeSet); ok {
+			value = run.sortDocumentOrder(nodes)
+		}
+		path := getXsltBindingPath(len(bindings))
+		bindings = append(bindings, value)
+
+		switch value.(type) {
+		case xsltNodeSet:
+			if depth == 0 {
+				bindingStart = output.Len()
+				output.WriteString(path + "/node()")
+				bindingEnd = output.Len()
+				return
+			}
+			output.WriteString(path + "/node()")
+		case float64:
+			output.WriteString("number(" + path + ")")
+		case bool:
+			output.WriteString("(string(" + path + ") = 'true')")
+		default:
+			// the relational operators compare numbers, but the XPath engine compares strings
+			before := strings.TrimSuffix(strings.TrimRight(output.String(), " \t\r\n"), "=")
+			after := strings.TrimLeft(rest, " \t\r\n")
+			if strings.HasSuffix(before, "<") || strings.HasSuffix(before, ">") || strings.HasPrefix(after, "<") ||
+				strings.HasPrefix(after, ">") {
+				output.WriteString("number(" + path + ")")
+			} else {
+				output.WriteString("string(" + path + ")")
+			}
+		}
+	}
+
+	for index := 0; index < len(expr); {
+		char := expr[index]
+
+		switch {
+		case char == '\'' || char == '"':
+			end := strings.IndexByte(expr[index+1:], char)
+			if end < 0 {
+				return "", nil, fmt.Errorf("unterminated string literal in %q", expr)
+			}
+			output.WriteString(expr[index : index+end+2])
+			index += end + 2
+		case char == '$':
+			name := readXPathName(expr[index+1:])
+			if name == "" {
+				return "", nil, fmt.Errorf("invalid variable reference in %q", expr)
+			}
+			value, err := run.lookupVariable(name, ctx)
+			if err != nil {
+				return "", nil, err
+			}
+			index += len(name) + 1
+			writeValue(value, expr[index:])
+		case char == '[':
+			end := findXPathClosing(expr, index)
+			if end < 0 {
+				return "", nil, fmt.Errorf("unbalanced brackets in %q", expr)
+			}
+			predicate := expr[index+1 : end]
+			afterBinding := depth == 0 && bindingEnd >= 0 && strings.TrimSpace(output.String()[bindingEnd:]) == ""
+			if depth > 0 || (!hasXPathFunctionCall(predicate, xsltFunctions) &&
+				!(afterBinding && hasXPathFunctionCall(predicate, map[string]bool{"position": true, "last": true}))) {
+				filteringBinding = afterBinding
+				output.WriteByte(char)
+				depth++
+				index++
+				continue
+			}
+
+			start := bindingStart
+			if !afterBinding {
+				start = findXPathPathStart(output.String())
+			}
+			nodes, err := run.filterPredicate(output.String()[start:], bindings, predicate, nsNode, ctx)
+			if err != nil {
+				return "", nil, err
+			}
+			output.Truncate(start)
+			index = end + 1
+			writeValue(nodes, expr[index:])
+		case char == ']':
+			depth--
+			output.WriteByte(char)
+			index++
+			if depth == 0 && filteringBinding {
+				bindingEnd, filteringBinding = output.Len(), false
+			}
+		case unicode.IsLetter(rune(char)) || char == '_' || char >= 0x80:
+			name := readXPathName(expr[index:])
+			if name == "" {
+				output.WriteByte(char)
+				index++
+				continue
+			}
+			next := index + len(name)
+			for next < len(expr) && strings.ContainsRune(" \t\r\n", rune(expr[next])) {
+				next++
+			}
+			isCall := next < len(expr) && expr[next] == '(' && (index == 0 || expr[index-1] != '@') &&
+				!strings.HasPrefix(expr[index+len(name):], "::")
+			if !isCall || (!xsltFunctions[name] && name != "current" && (depth > 0 || (name != "position" && name != "last"))) {
+				output.WriteString(name)
+				index += len(name)
+				continue
+			}
+
+			end := findXPathClosing(expr, next)
+			if end < 0 {
+				return "", nil, fmt.Errorf("unbalanced brackets in %q", expr)
+			}
+			value, err := run.callXsltFunction(name, expr[next+1:end], nsNode, ctx)
+			if err != nil {
+				return "", nil, err
+			}
+			index = end + 1
+			writeValue(value, expr[index:])
+		default:
+			output.WriteByte(char)
+			index++
+		}
+	}
+
+	return output.String(), bindings, nil
+}
+
+func readXPathName(input string) string {
+	length := 0
+	for index, char := range input {
+		switch {
+		case unicode.IsLetter(char) || char == '_':
+		case index > 0 && (unicode.IsDigit(char) || char == '-' || char == '.'):
+		case index > 0 && char == ':' && index+1 < len(input) && input[index+1] != ':' && input[index-1] != ':' &&
+			(unicode.IsLetter(rune(input[index+1])) || input[index+1] == '_' || input[index+1] == '*'):
+			if input[index+1] == '*' {
+				return input[:index+2]
+			}
+		default:
+			return input[:length]
+		}
+		length = index + len(string(char))
+	}
+
+	return input[:length]
+}
+
+// findXPathClosing finds the bracket closing the one at the position.
+func findXPathClosing(expr string, position int) int {
+	depth := 0
+	var quote byte
+
+	for index := position; index < len(expr); index++ {
+		char := expr[index]
+		switch {
+		case quote != 0:
+			if char == quote {
+				quote = 0
+			}
+		case char == '\'' || char == '"':
+			quote = char
+		case char == '[' || char == '(':
+			depth++
+		case char == ']' || char == ')':
+			depth--
+			if depth == 0 {
+				return index
+			}
+		}
+	}
+
+	return -1
+}
+
+// hasXPathFunctionCall checks that the expression calls one of the functions.
+func hasXPathFunctionCall(expr string, functions map[string]bool) bool {
+	for index := 0; index < len(expr); index++ {
+		char := expr[index]
+		if char == '\'' || char == '"' {
+			end := strings.IndexByte(expr[index+1:], char)
+			if end < 0 {
+				return false
+			}
+			index += end + 1
+			continue
+		}
+		if index > 0 && (unicode.IsLetter(rune(expr[index-1])) || strings.ContainsRune("-_.:$@", rune(expr[index-1]))) {
+			continue
+		}
+		name := readXPathName(expr[index:])
+		if functions[name] && strings.HasPrefix(strings.TrimLeft(expr[index+len(name):], " \t\r\n"), "(") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// filterPredicate evaluates the path and filters the nodes by the predicate. The position
+// of each node is its position in the node-set in the document order.
+func (run *xsltRun) filterPredicate(path string, bindings []xsltValue, predicate string, nsNode *xmlquery.Node,
+	ctx xsltContext) (xsltNodeSet, error) {
+	path = strings.TrimSpace(path)
+	if path == "" {
+		return nil, fmt.Errorf("unsupported predicate [%s]", predicate)
+	}
+
+	value, err := run.evaluateCompiled(path, bindings, nsNode, ctx)
+	if err != nil {
+		return nil, err
+	}
+	nodes, ok := value.(xsltNodeSet)
+	if !ok {
+		return nil, fmt.Errorf("expression %q does not select nodes", path)
+	}
+	nodes = run.sortDocumentOrder(nodes)
+
+	filtered := xsltNodeSet{}
+	for index, node := range nodes {
+		nodeCtx := ctx
+		nodeCtx.node = node
+		nodeCtx.position, nodeCtx.size = index+1, len(nodes)
+		result, err := run.evaluate(predicate, nsNode, nodeCtx)
+		if err != nil {
+			return nil, err
+		}
+		if number, ok := result.(float64); ok {
+			result = number == float64(index+1)
+		}
+		if xsltToBoolean(result) {
+			filtered = append(filtered, node)
+		}
+	}
+
+	return filtered, nil
+}
+
+// findXPathPathStart finds the start of the location path or the filter expression which
+// ends the expression.
+func findXPathPathStart(expr string) int {
+	depth := 0
+	var quote byte
+
+	for index := len(expr) - 1; index >= 0; index-- {
+		char := expr[index]
+		switch {
+		case quote != 0:
+			if char == quote {
+				quote = 0
+			}
+		case char == '\'' || char == '"':
+			quote = char
+		case char == ')' || char == ']':
+			depth++
+		case char == '(' || char == '[':
+			if depth == 0 {
+				return index + 1
+			}
+			depth--
+		case depth > 0:
+		case strings.IndexByte(",=<>!+|", char) >= 0:
+			return index + 1
+		case char == ' ' || char == '\t' || char == '\r' || char == '\n':
+			// the whitespace can be a part of the path only around the steps separators
+			before := strings.TrimRight(expr[:index], " \t\r\n")
+			after := strings.TrimLeft(expr[index:], " \t\r\n")
+			if !strings.HasSuffix(before, "/") && !strings.HasSuffix(before, "::") && !strings.HasPrefix(after, "/") {
+				return index + 1
+			}
+		}
+	}
+
+	return 0
+}
+
+func (run *xsltRun) callXsltFunction(name string, argsExpr string, nsNode *xmlquery.Node, ctx xsltContext) (xsltValue, error) {
+	var args []xsltValue
+	if strings.TrimSpace(argsExpr) != "" {
+		for _, argExpr := range splitXPathTopLevel(argsExpr, ',') {
+			value, err := run.evaluate(argExpr, nsNode, ctx)
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, value)
+		}
+	}
+
+	checkArgs := func(minArgs int, maxArgs int) error {
+		if len(args) < minArgs || len(args) > maxArgs {
+			return fmt.Errorf("wrong number of arguments of function %s()", name)
+		}
+		return nil
+	}
+
+	var result xsltValue
+	switch name {
+	case "position", "last", "current":
+		if err := checkArgs(0, 0); err != nil {
+			return nil, err
+		}
+		result = float64(ctx.position)
+		if name == "last" {
+			result = float64(ctx.size)
+		} else if name == "current" {
+			result = xsltNodeSet{ctx.current}
+		}
+	case "key":
+		if err := checkArgs(2, 2); err != nil {
+			return nil, err
+		}
+		nodes, err := run.getKeyNodes(xsltToString(args[0]), args[1])
+		if err != nil {
+			return nil, err
+		}
+		result = nodes
+	case "generate-id":
+		if err := checkArgs(0, 1); err != nil {
+			return nil, err
+		}
+		node := ctx.node
+		if len(args) == 1 {
+			nodes, ok := args[0].(xsltNodeSet)
+			if !ok {
+				return nil, errors.New("the argument of generate-id() should be a node-set")
+			}
+			if len(nodes) == 0 {
+				return "", nil
+			}
+			node = run.sortDocumentOrder(nodes)[0]
+		}
+		key := getXsltNodeKey(node)
+		if _, ok := run.ids[key]; !ok {
+			run.ids[key] = len(run.ids) + 1
+		}
+		result = fmt.Sprintf("id%d", run.ids[key])
+	case "format-number":
+		if err := checkArgs(2, 3); err != nil {
+			return nil, err
+		}
+		if len(args) == 3 {
+			return nil, errors.New("named decimal formats are not supported")
+		}
+		result = formatXsltNumber(xsltToNumber(args[0]), xsltToString(args[1]))
+	case "system-property":
+		if err := checkArgs(1, 1); err != nil {
+			return nil, err
+		}
+		result = map[string]xsltValue{"xsl:version": 1.0, "xsl:vendor": "xq",
+			"xsl:vendor-url": "https://github.com/sibprogrammer/xq"}[xsltToString(args[0])]
+		if result == nil {
+			result = ""
+		}
+	case "function-available":
+		if err := checkArgs(1, 1); err != nil {
+			return nil, err
+		}
+		function := xsltToString(args[0])
+		result = xpathFunctions[function] || (xsltFunctions[function] && function != "document")
+	case "element-available":
+		if err := checkArgs(1, 1); err != nil {
+			return nil, err
+		}
+		elementName := toXmlName(xsltToString(args[0]))
+		result = run.getNamespaces(nsNode)[elementName.Space] == xslNamespace && map[string]bool{
+			"apply-templates": true, "call-template": true, "for-each": true, "value-of": true, "if": true,
+			"choose": true, "text": true, "element": true, "attribute": true, "comment": true, "copy": true,
+			"copy-of": true, "number": true, "message": true, "variable": true, "processing-instruction": true,
+		}[elementName.Local]
+	case "unparsed-entity-uri":
+		result = ""
+	default:
+		return nil, fmt.Errorf("function %s() is not supported", name)
+	}
+
+	return result, nil
+}
+
+func (run *xsltRun) getKeyNodes(name string, value xsltValue) (xsltNodeSet, error) {
+	keys, ok := run.stylesheet.keys[name]
+	if !ok {
+		return nil, fmt.Errorf("key %q is not defined", name)
+	}
+
+	if run.keyIndex == nil {
+		run.keyIndex = map[string]map[string][]xpath.NodeNavigator{}
+	}
+	index, ok := run.keyIndex[name]
+	if !ok {
+		index = map[string][]xpath.NodeNavigator{}
+		for _, key := range keys {
+			for _, pattern := range key.patterns {
+				query := pattern.expr
+				if !strings.HasPrefix(query, "/") {
+					query = "//" + query
+				}
+				ctx := xsltContext{node: run.root.Copy(), current: run.root.Copy(), position: 1, size: 1}
+				nodes, err := run.selectNodes(query, key.node, ctx)
+				if err != nil {
+					return nil, err
+				}
+				for position, node := range nodes {
+					nodeCtx := ctx
+					nodeCtx.node, nodeCtx.current = node, node
+					nodeCtx.position, nodeCtx.size = position+1, len(nodes)
+					used, err := run.evaluate(key.use, key.node, nodeCtx)
+					if err != nil {
+						return nil, err
+					}
+					if usedNodes, ok := used.(xsltNodeSet); ok {
+						for _, usedNode := range usedNodes {
+							text := getXsltStringValue(usedNode)
+							index[text] = append(index[text], node)
+						}
+					} else {
+						text := xsltToString(used)
+						index[text] = append(index[text], node)
+					}
+				}
+			}
+		}
+		run.keyIndex[name] = index
+	}
+
+	var nodes xsltNodeSet
+	if values, ok := value.(xsltNodeSet); ok {
+		for _, node := range values {
+			nodes = append(nodes, index[getXsltStringValue(node)]...)
+		}
+	} else {
+		nodes = append(nodes, index[xsltToString(value)]...)
+	}
+
+	return run.sortDocumentOrder(nodes), nil
+}
+
+// sortDocumentOrder sorts the nodes in the document order and removes the duplicates.
+func (run *xsltRun) sortDocumentOrder(nodes xsltNodeSet) xsltNodeSet {
+	if run.order == nil {
+		run.order = map[xsltNodeKey]int{}
+		var walk func(node *xmlquery.Node)
+		walk = func(node *xmlquery.Node) {
+			run.order[xsltNodeKey{node: node}] = len(run.order)
+			for _, attr := range node.Attr {
+				run.order[xsltNodeKey{node: node, attr: attr.Name.Space + ":" + attr.Name.Local}] = len(run.order)
+			}
+			for child := node.FirstChild; child != nil; child = child.NextSibling {
+				walk(child)
+			}
+		}
+		walk(run.root.(*xmlquery.NodeNavigator).Current())
+	}
+
+	seen := map[xsltNodeKey]bool{}
+	var sorted xsltNodeSet
+	for _, node := range nodes {
+		key := getXsltNodeKey(node)
+		if !seen[key] {
+			seen[key] = true
+			sorted = append(sorted, node)
+		}
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return run.order[getXsltNodeKey(sorted[i])] < run.order[getXsltNodeKey(sorted[j])]
+	})
+
+	return sorted
+}
+
+// evaluateAVT evaluates the attribute value template.
+func (run *xsltRun) evaluateAVT(template string, nsNode *xmlquery.Node, ctx xsltContext) (string, error) {
+	var result strings.Builder
+
+	for index := 0; index < len(template); index++ {
+		char := template[index]
+		switch {
+		case char == '{' && strings.HasPrefix(template[index:], "{{"):
+			result.WriteByte('{')
+			index++
+		case char == '}' && strings.HasPrefix(template[index:], "}}"):
+			result.WriteByte('}')
+			index++
+		case char == '{':
+			end := index + 1
+			for ; end < len(template) && template[end] != '}'; end++ {
+				if template[end] == '\'' || template[end] == '"' {
+					closing := strings.IndexByte(template[end+1:], template[end])
+					if closing < 0 {
+						return "", fmt.Errorf("unterminated string literal in %q", template)
+					}
+					end += closing + 1
+				}
+			}
+			if end >= len(template) {
+				return "", fmt.Errorf("unterminated expression in %q", template)
+			}
+			value, err := run.evaluate(template[index+1:end], nsNode, ctx)
+			if err != nil {
+				return "", err
+			}
+			result.WriteString(xsltToString(value))
+			index = end
+		default:
+			result.WriteByte(char)
+		}
+	}
+
+	return result.String(), nil
+}
+
+func getXsltStringValue(nav xpath.NodeNavigator) string {
+	if nav.NodeType() == xpath.RootNode {
+		return nav.(*xmlquery.NodeNavigator).Current().InnerText()
+	}
+
+	return nav.Value()
+}
+
+func xsltToString(value xsltValue) string {
+	switch typedValue := value.(type) {
+	case string:
+		return typedValue
+	case float64:
+		return formatXPathNumber(typedValue)
+	case bool:
+		return strconv.FormatBool(typedValue)
+	case xsltNodeSet:
+		if len(typedValue) == 0 {
+			return ""
+		}
+		return getXsltStringValue(typedValue[0])
+	case xsltFragment:
+		return typedValue.root.InnerText()
+	}
+
+	return ""
+}
+
+func xsltToNumber(value xsltValue) float64 {
+	switch typedValue := value.(type) {
+	case float64:
+		return typedValue
+	case bool:
+		if typedValue {
+			return 1
+		}
+		return 0
+	}
+
+	text := strings.TrimSpace(xsltToString(value))
+	if text == "" || strings.ContainsAny(text, "eE+xXiInN") {
+		return math.NaN()
+	}
+	number, err := strconv.ParseFloat(text, 64)
+	if err != nil {
+		return math.NaN()
+	}
+
+	return number
+}
+
+func xsltToBoolean(value xsltValue) bool {
+	switch typedValue := value.(type) {
+	case string:
+		return typedValue != ""
+	case float64:
+		return typedValue != 0 && !math.IsNaN(typedValue)
+	case bool:
+		return typedValue
+	case xsltNodeSet:
+		return len(typedValue) > 0
+	case xsltFragment:
+		return true
+	}
+
+	return false
+}
+
+func formatXPathNumber(value float64) string {
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 1):
+		return "Infinity"
+	case math.IsInf(value, -1):
+		return "-Infinity"
+	case value == 0:
+		return "0"
+	}
+
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// formatXsltNumber implements format-number() for the patterns of the default decimal format.
+func formatXsltNumber(value float64, pattern string) string {
+	positive, negative, hasNegative := strings.Cut(pattern, ";")
+
+	switch {
+	case math.IsNaN(value):
+		return "NaN"
+	case math.IsInf(value, 0):
+		if value < 0 {
+			return "-Infinity"
+		}
+		return "Infinity"
+	}
+
+	prefix, numeric, suffix := splitXsltNumberPattern(positive)
+	if value < 0 {
+		value = -value
+		if hasNegative {
+			prefix, _, suffix = splitXsltNumberPattern(negative)
+		} else {
+			prefix = "-" + prefix
+		}
+	}
+
+	switch {
+	case strings.Contains(prefix+suffix, "%"):
+		value *= 100
+	case strings.Contains(prefix+suffix, "‰"):
+		value *= 1000
+	}
+
+	integerPattern, fractionPattern, _ := strings.Cut(numeric, ".")
+	minInteger := strings.Count(integerPattern, "0")
+	grouping := 0
+	if index := strings.LastIndex(integerPattern, ","); index >= 0 {
+		grouping = len(integerPattern) - index - 1
+	}
+	minFraction := strings.Count(fractionPattern, "0")
+	maxFraction := minFraction + strings.Count(fractionPattern, "#")
+
+	formatted := strconv.FormatFloat(value, 'f', maxFraction, 64)
+	integerPart, fractionPart, _ := strings.Cut(formatted, ".")
+	for len(fractionPart) > minFraction && strings.HasSuffix(fractionPart, "0") {
+		fractionPart = fractionPart[:len(fractionPart)-1]
+	}
+	if integerPart == "0" && minInteger == 0 {
+		integerPart = ""
+	}
+	if len(integerPart) < minInteger {
+		integerPart = strings.Repeat("0", minInteger-len(integerPart)) + integerPart
+	}
+	if grouping > 0 {
+		var grouped []string
+		for len(integerPart) > grouping {
+			grouped = append([]string{integerPart[len(integerPart)-grouping:]}, grouped...)
+			integerPart = integerPart[:len(integerPart)-grouping]
+		}
+		integerPart = strings.Join(append([]string{integerPart}, grouped...), ",")
+	}
+
+	result := integerPart
+	if fractionPart != "" {
+		result += "." + fractionPart
+	}
+	if result == "" {
+		result = "0"
+	}
+
+	return prefix + result + suffix
+}
+
+func splitXsltNumberPattern(pattern string) (string, string, string) {
+	first := strings.IndexAny(pattern, "#0.,")
+	if first < 0 {
+		return pattern, "", ""
+	}
+	last := strings.LastIndexAny(pattern, "#0.,")
+
+	return pattern[:first], pattern[first : last+1], pattern[last+1:]
+}
+
+// formatXsltNumberList formats the numbers of xsl:number according to the format string.
+func formatXsltNumberList(numbers []int, format string) string {
+	if format == "" {
+		format = "1"
+	}
+
+	// the format is split into the alphanumeric tokens and the separators between them
+	var tokens, separators []string
+	isToken := func(char rune) bool {
+		return unicode.IsLetter(char) || unicode.IsDigit(char)
+	}
+	var current strings.Builder
+	currentIsToken := false
+	for index, char := range format {
+		if index > 0 && isToken(char) != currentIsToken {
+			if currentIsToken {
+				tokens = append(tokens, current.String())
+			} else {
+				separators = append(separators, current.String())
+			}
+			current.Reset()
+		}
+		if index == 0 && isToken(char) {
+			separators = append(separators, "")
+		}
+		currentIsToken = isToken(char)
+		current.WriteRune(char)
+	}
+	if currentIsToken {
+		tokens = append(tokens, current.String())
+		separators = append(separators, "")
+	} else {
+		separators = append(separators, current.String())
+	}
+	if len(tokens) == 0 {
+		return separators[0] + formatXsltNumberList(numbers, "1")
+	}
+
+	prefix, suffix := separators[0], separators[len(separators)-1]
+	separators = separators[1 : len(separators)-1]
+
+	var result strings.Builder
+	result.WriteString(prefix)
+	for index, number := range numbers {
+		if index > 0 {
+			separator := "."
+			if index-1 < len(separators) {
+				separator = separators[index-1]
+			} else if len(separators) > 0 {
+				separator = separators[len(separators)-1]
+			}
+			result.WriteString(separator)
+		}
+		token := tokens[len(tokens)-1]
+		if index < len(tokens) {
+			token = tokens[index]
+		}
+		result.WriteString(formatXsltNumberToken(number, token))
+	}
+	result.WriteString(suffix)
+
+	return result.String()
+}
+
+func formatXsltNumberToken(number int, token string) string {
+	switch {
+	case number <= 0:
+		return strconv.Itoa(number)
+	case token == "a" || token == "A":
+		var letters []byte
+		for ; number > 0; number = (number - 1) / 26 {
+			letters = append([]byte{byte('a' + (number-1)%26)}, letters...)
+		}
+		if token == "A" {
+			return strings.ToUpper(string(letters))
+		}
+		return string(letters)
+	case token == "i" || token == "I":
+		roman := toRomanNumeral(number)
+		if token == "i" {
+			return strings.ToLower(roman)
+		}
+		return roman
+	case strings.Trim(token, "0") == "1":
+		text := strconv.Itoa(number)
+		if len(text) < len(token) {
+			text = strings.Repeat("0", len(token)-len(text)) + text
+		}
+		return text
+	}
+
+	return strconv.Itoa(number)
+}
+
+func toRomanNumeral(number int) string {
+	values := []int{1000, 900, 500, 400, 100, 90, 50, 40, 10, 9, 5, 4, 1}
+	symbols := []string{"M", "CM", "D", "CD", "C", "XC", "L", "XL", "X", "IX", "V", "IV", "I"}
+
+	var result strings.Builder
+	for index, value := range values {
+		for ; number >= value; number -= value {
+			result.WriteString(symbols[index])
+		}
+	}
+
+	return result.String()
+}
@@ -0,0 +1,564 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/fatih/color"
+	"gopkg.in/yaml.v3"
+)
+
+type yamlPrinter struct {
+	write        func(args ...any) error
+	indent       string
+	tagColor     func(a ...interface{}) string
+	keyColor     func(a ...interface{}) string
+	valueColor   func(a ...interface{}) string
+	commentColor func(a ...interface{}) string
+}
+
+// FormatYaml pretty-prints all documents of a YAML stream. Since YAML does not allow tabs
+// for indentation, two spaces are used if the provided indent is not made of spaces.
+func FormatYaml(reader io.Reader, writer io.Writer, indent string, colors int) error {
+	source := newSourceRecorder(reader)
+	decoder := yaml.NewDecoder(source)
+
+	if indent == "" || strings.Trim(indent, " ") != "" {
+		indent = "  "
+	}
+
+	printer := &yamlPrinter{
+		write: func(args ...any) error {
+			_, err := fmt.Fprint(writer, args...)
+			return err
+		},
+		indent:       indent,
+		tagColor:     newColorFunc(colors, color.FgYellow),
+		keyColor:     newColorFunc(colors, color.FgHiBlue),
+		valueColor:   newColorFunc(colors, color.FgGreen),
+		commentColor: newColorFunc(colors, color.FgHiBlue),
+	}
+
+	for index := 0; ; index++ {
+		var document yaml.Node
+		err := decoder.Decode(&document)
+
+		if err == io.EOF {
+			break
+		}
+
+		if err != nil {
+			return newYamlDiagnostic(err, source)
+		}
+
+		if index > 0 {
+			if err = printer.write(printer.tagColor("---"), "\n"); err != nil {
+				return err
+			}
+		}
+
+		if err = printer.printDocument(&document); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *yamlPrinter) printDocument(document *yaml.Node) error {
+	if err := p.printComment(document.HeadComment, 0); err != nil {
+		return err
+	}
+
+	for _, node := range document.Content {
+		if err := p.printNode(node, 0, false); err != nil {
+			return err
+		}
+	}
+
+	return p.printComment(document.FootComment, 0)
+}
+
+// printNode prints the node at the given nesting level. The inline flag means that the
+// cursor is already placed at the node position right after a sequence dash.
+func (p *yamlPrinter) printNode(node *yaml.Node, level int, inline bool) error {
+	if isYamlBlockCollection(node) {
+		if properties := p.nodeProperties(node); properties != "" {
+			properties = strings.TrimPrefix(properties, " ")
+			if !inline {
+				properties = strings.Repeat(p.indent, level) + properties
+			}
+			if err := p.write(properties, p.lineComment(node), "\n"); err != nil {
+				return err
+			}
+			inline = false
+		}
+		if node.Kind == yaml.MappingNode {
+			return p.printMapping(node, level, inline)
+		}
+		return p.printSequence(node, level, inline)
+	}
+
+	if !inline {
+		if err := p.write(strings.Repeat(p.indent, level)); err != nil {
+			return err
+		}
+	}
+
+	value, err := p.inlineValue(node, level)
+	if err != nil {
+		return err
+	}
+
+	return p.write(value, p.lineComment(node), "\n")
+}
+
+// startItem prepares the line of a mapping key or a sequence item: prints its head
+// comment and the indentation unless the cursor is already placed after a sequence dash.
+func (p *yamlPrinter) startItem(headComment string, level int, inline bool) error {
+	if inline {
+		if headComment == "" {
+			return nil
+		}
+		if err := p.write("\n"); err != nil {
+			return err
+		}
+	}
+
+	if err := p.printComment(headComment, level); err != nil {
+		return err
+	}
+
+	return p.write(strings.Repeat(p.indent, level))
+}
+
+func (p *yamlPrinter) printMapping(node *yaml.Node, level int, inline bool) error {
+	for index := 0; index+1 < len(node.Content); index += 2 {
+		key, value := node.Content[index], node.Content[index+1]
+
+		if err := p.startItem(key.HeadComment, level, inline && index == 0); err != nil {
+			return err
+		}
+
+		keyStr, err := p.scalarValue(key)
+		if err != nil {
+			return err
+		}
+		if err = p.write(p.keyColor(keyStr), p.tagColor(":")); err != nil {
+			return err
+		}
+
+		if isYamlBlockCollection(value) {
+			if err = p.write(p.nodeProperties(value), p.lineComment(key), p.lineComment(value), "\n"); err != nil {
+				return err
+			}
+			if err = p.printComment(value.HeadComment, level+1); err != nil {
+				return err
+			}
+			if value.Kind == yaml.MappingNode {
+				err = p.printMapping(value, level+1, false)
+			} else {
+				err = p.printSequence(value, level+1, false)
+			}
+		} else {
+			var valueStr string
+			if valueStr, err = p.inlineValue(value, level+1); err != nil {
+				return err
+			}
+			err = p.write(" ", valueStr, p.lineComment(key), p.lineComment(value), "\n")
+		}
+		if err != nil {
+			return err
+		}
+
+		if err = p.printComment(key.FootComment, level); err != nil {
+			return err
+		}
+		if err = p.printComment(value.FootComment, level); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *yamlPrinter) printSequence(node *yaml.Node, level int, inline bool) error {
+	spacing := strings.Repeat(" ", max(len(p.indent)-1, 1))
+
+	for index, item := range node.Content {
+		if err := p.startItem(item.HeadComment, level, inline && index == 0); err != nil {
+			return err
+		}
+		if err := p.write(p.tagColor("-"), spacing); err != nil {
+			return err
+		}
+		if err := p.printNode(item, level+1, true); err != nil {
+			return err
+		}
+		if err := p.printComment(item.FootComment, level); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// inlineValue returns the representation of a node which is printed on the same line as
+// its key or sequence dash. Multiline strings are represented as literal blocks.
+func (p *yamlPrinter) inlineValue(node *yaml.Node, level int) (string, error) {
+	switch node.Kind {
+	case yaml.AliasNode:
+		return p.tagColor("*" + node.Value), nil
+	case yaml.MappingNode:
+		return p.nodeProperties(node) + p.tagColor("{}"), nil
+	case yaml.SequenceNode:
+		return p.nodeProperties(node) + p.tagColor("[]"), nil
+	}
+
+	if node.Kind == yaml.ScalarNode && strings.Contains(node.Value, "\n") &&
+		node.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) == 0 && node.ShortTag() == "!!str" {
+		return p.literalBlock(node, level), nil
+	}
+
+	value, err := p.scalarValue(node)
+	if err != nil {
+		return "", err
+	}
+
+	return p.nodeProperties(node) + p.valueColor(value), nil
+}
+
+func (p *yamlPrinter) literalBlock(node *yaml.Node, level int) string {
+	header := "|"
+	if strings.HasPrefix(node.Value, " ") {
+		header += strconv.Itoa(len(p.indent))
+	}
+
+	value := node.Value
+	switch {
+	case !strings.HasSuffix(value, "\n"):
+		header += "-"
+	case strings.HasSuffix(value, "\n\n"):
+		header += "+"
+	}
+	value = strings.TrimSuffix(value, "\n")
+
+	var lines []string
+	for _, line := range strings.Split(value, "\n") {
+		if line != "" {
+			line = strings.Repeat(p.indent, level) + line
+		}
+		lines = append(lines, p.valueColor(line))
+	}
+
+	return p.nodeProperties(node) + p.tagColor(header) + "\n" + strings.Join(lines, "\n")
+}
+
+func (p *yamlPrinter) scalarValue(node *yaml.Node) (string, error) {
+	if node.Kind == yaml.AliasNode {
+		return "*" + node.Value, nil
+	}
+
+	if node.ShortTag() == "!!merge" {
+		return node.Value, nil
+	}
+
+	scalar := &yaml.Node{
+		Kind:  node.Kind,
+		Style: node.Style &^ (yaml.TaggedStyle | yaml.LiteralStyle | yaml.FoldedStyle),
+		Tag:   node.Tag,
+		Value: node.Value,
+	}
+	if node.Kind != yaml.ScalarNode {
+		scalar.Style |= yaml.FlowStyle
+		scalar.Content = node.Content
+	}
+
+	data, err := yaml.Marshal(scalar)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSuffix(string(data), "\n"), nil
+}
+
+func (p *yamlPrinter) nodeProperties(node *yaml.Node) string {
+	var properties []string
+	if node.Style&yaml.TaggedStyle != 0 {
+		properties = append(properties, p.tagColor(node.Tag))
+	}
+	if node.Anchor != "" {
+		properties = append(properties, p.tagColor("&"+node.Anchor))
+	}
+	if len(properties) == 0 {
+		return ""
+	}
+
+	if isYamlBlockCollection(node) {
+		return " " + strings.Join(properties, " ")
+	}
+
+	return strings.Join(properties, " ") + " "
+}
+
+func (p *yamlPrinter) lineComment(node *yaml.Node) string {
+	if node.LineComment == "" {
+		return ""
+	}
+
+	return " " + p.commentColor(node.LineComment)
+}
+
+func (p *yamlPrinter) printComment(comment string, level int) error {
+	if comment == "" {
+		return nil
+	}
+
+	for _, line := range strings.Split(comment, "\n") {
+		if line == "" {
+			if err := p.write("\n"); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := p.write(strings.Repeat(p.indent, level), p.commentColor(line), "\n"); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func isYamlBlockCollection(node *yaml.Node) bool {
+	return (node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode) && len(node.Content) > 0
+}
+
+// YamlToJSON converts a YAML document into JSON preserving the order of mapping keys.
+// Only the first document of a stream is converted.
+func YamlToJSON(reader io.Reader) ([]byte, error) {
+	var document yaml.Node
+	if err := yaml.NewDecoder(reader).Decode(&document); err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	if len(document.Content) == 0 {
+		buf.WriteString("null")
+		return buf.Bytes(), nil
+	}
+
+	if err := writeYamlNodeAsJSON(buf, document.Content[0], map[*yaml.Node]bool{}); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeYamlNodeAsJSON writes the node expanding the aliases. The collections being written are
+// tracked, so that the aliases referring to their own ancestors are reported instead of being
+// expanded endlessly.
+func writeYamlNodeAsJSON(buf *bytes.Buffer, node *yaml.Node, expanding map[*yaml.Node]bool) error {
+	if node.Kind == yaml.MappingNode || node.Kind == yaml.SequenceNode {
+		if expanding[node] {
+			return errYamlRecursiveAlias
+		}
+		expanding[node] = true
+		defer delete(expanding, node)
+	}
+
+	switch node.Kind {
+	case yaml.AliasNode:
+		return writeYamlNodeAsJSON(buf, node.Alias, expanding)
+	case yaml.MappingNode:
+		buf.WriteString("{")
+		pairs, err := getYamlMappingPairs(node, expanding)
+		if err != nil {
+			return err
+		}
+		for index, pair := range pairs {
+			if index > 0 {
+				buf.WriteString(",")
+			}
+			keyData, _ := json.Marshal(pair[0].Value)
+			buf.Write(keyData)
+			buf.WriteString(":")
+			if err = writeYamlNodeAsJSON(buf, pair[1], expanding); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("}")
+	case yaml.SequenceNode:
+		buf.WriteString("[")
+		for index, item := range node.Content {
+			if index > 0 {
+				buf.WriteString(",")
+			}
+			if err := writeYamlNodeAsJSON(buf, item, expanding); err != nil {
+				return err
+			}
+		}
+		buf.WriteString("]")
+	case yaml.ScalarNode:
+		var value interface{}
+		if err := node.Decode(&value); err != nil {
+			return err
+		}
+		switch node.ShortTag() {
+		case "!!int", "!!float", "!!bool", "!!null":
+		default:
+			value = node.Value
+		}
+		data, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("unable to convert YAML value %q to JSON: %w", node.Value, err)
+		}
+		buf.Write(data)
+	default:
+		return fmt.Errorf("unsupported YAML node kind: %v", node.Kind)
+	}
+
+	return nil
+}
+
+// getYamlMappingPairs returns key-value pairs of a mapping with merge keys ("<<") resolved.
+// Explicitly defined keys take precedence over the merged ones. The mappings being expanded
+// can't be merged into themselves.
+func getYamlMappingPairs(node *yaml.Node, expanding map[*yaml.Node]bool) ([][2]*yaml.Node, error) {
+	var pairs [][2]*yaml.Node
+	positions := map[string]int{}
+
+	add := func(key, value *yaml.Node, override bool) {
+		if position, ok := positions[key.Value]; ok {
+			if override {
+				pairs[position][1] = value
+			}
+			return
+		}
+		positions[key.Value] = len(pairs)
+		pairs = append(pairs, [2]*yaml.Node{key, value})
+	}
+
+	var merged []*yaml.Node
+	for index := 0; index+1 < len(node.Content); index += 2 {
+		key, value := resolveYamlAlias(node.Content[index]), node.Content[index+1]
+		if key.Kind != yaml.ScalarNode {
+			return nil, errors.New("only scalar YAML keys can be converted to JSON")
+		}
+		if key.ShortTag() != "!!merge" {
+			add(key, value, true)
+			continue
+		}
+		value = resolveYamlAlias(value)
+		if value.Kind == yaml.SequenceNode {
+			merged = append(merged, value.Content...)
+		} else {
+			merged = append(merged, value)
+		}
+	}
+
+	for _, mapping := range merged {
+		mapping = resolveYamlAlias(mapping)
+		if mapping.Kind != yaml.MappingNode {
+			return nil, errors.New("only mappings can be merged into a YAML mapping")
+		}
+		if expanding[mapping] {
+			return nil, errYamlRecursiveAlias
+		}
+		expanding[mapping] = true
+		mergedPairs, err := getYamlMappingPairs(mapping, expanding)
+		delete(expanding, mapping)
+		if err != nil {
+			return nil, err
+		}
+		for _, pair := range mergedPairs {
+			add(pair[0], pair[1], false)
+		}
+	}
+
+	return pairs, nil
+}
+
+var errYamlRecursiveAlias = errors.New("recursive alias")
+
+// yamlStartRegexp matches the first line of YAML: a document marker, a directive, a sequence
+// item or a key, the keys with spaces should be quoted to tell them from the plain text.
+var yamlStartRegexp = regexp.MustCompile(`^(---|%YAML|- |-$|([\w.\-/]+|"[^"]*"|'[^']*'):( |$))`)
+
+// yaml11ScalarRegexp matches the plain scalars resolved to the booleans, nulls and numbers by
+// the YAML 1.1 parsers, e.g. yes, off or 1:20, while they are strings in YAML 1.2.
+var yaml11ScalarRegexp = regexp.MustCompile(`^(y|Y|yes|Yes|YES|n|N|no|No|NO|true|True|TRUE|false|False|` +
+	`FALSE|on|On|ON|off|Off|OFF|~|null|Null|NULL|` +
+	`[-+]?(0b[01_]+|0[0-7_]+|0|[1-9][0-9_]*|0x[0-9a-fA-F_]+|[1-9][0-9_]*(:[0-5]?[0-9])+)|` +
+	`[-+]?([0-9][0-9_]*)?\.[0-9.]*([eE][-+][0-9]+)?|[-+]?[0-9][0-9_]*(:[0-5]?[0-9])+\.[0-9_]*|` +
+	`[-+]?\.(inf|Inf|INF)|\.(nan|NaN|NAN))$`)
+
+func resolveYamlAlias(node *yaml.Node) *yaml.Node {
+	for node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// JSONToYaml converts a JSON document into a YAML document preserving the order of keys.
+func JSONToYaml(reader io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	var document yaml.Node
+	if err = yaml.Unmarshal(data, &document); err != nil {
+		return nil, err
+	}
+	resetYamlStyle(&document)
+
+	return yaml.Marshal(&document)
+}
+
+// resetYamlStyle switches the nodes to the block style with the plain scalars. The strings which
+// would be read as other types by the YAML 1.1 parsers are kept double-quoted.
+func resetYamlStyle(node *yaml.Node) {
+	node.Style = 0
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!str" && yaml11ScalarRegexp.MatchString(node.Value) {
+		node.Style = yaml.DoubleQuotedStyle
+	}
+	for _, child := range node.Content {
+		resetYamlStyle(child)
+	}
+}
+
+// IsYAML checks that the input starts with a YAML mapping or sequence. The first line should
+// look like YAML and the input should be parsed. The documents of a single scalar are not YAML,
+// since any plain text is such a document. If the input is the truncated beginning of the
+// content, its last line is ignored as it can be cut.
+func IsYAML(input string, truncated bool) bool {
+	matched := false
+	for _, line := range strings.Split(input, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		matched = yamlStartRegexp.MatchString(line)
+		break
+	}
+	if !matched {
+		return false
+	}
+
+	if truncated {
+		input = input[:strings.LastIndex(input, "\n")+1]
+	}
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(input), &document); err != nil || len(document.Content) == 0 {
+		return false
+	}
+	kind := document.Content[0].Kind
+
+	return kind == yaml.MappingNode || kind == yaml.SequenceNode
+}
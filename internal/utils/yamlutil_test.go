@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatYaml(t *testing.T) {
+	files := map[string]string{
+		"unformatted.yaml": "formatted.yaml",
+	}
+
+	for unformattedFile, expectedFile := range files {
+		unformattedYamlReader := getFileReader(filepath.Join("..", "..", "test", "data", "yaml", unformattedFile))
+
+		data, readErr := os.ReadFile(filepath.Join("..", "..", "test", "data", "yaml", expectedFile))
+		assert.Nil(t, readErr)
+		expectedYaml := string(data)
+
+		output := new(strings.Builder)
+		formatErr := FormatYaml(unformattedYamlReader, output, "  ", ColorsDisabled)
+		assert.Nil(t, formatErr)
+		assert.Equal(t, expectedYaml, output.String())
+	}
+
+	tests := []struct {
+		name     string
+		input    string
+		indent   string
+		expected string
+	}{
+		{"nested sequences", "- [a, b]\n- c", "  ", "- - a\n  - b\n- c\n"},
+		{"mapping in sequence", "- {a: 1, b: 2}", "    ", "-   a: 1\n    b: 2\n"},
+		{"tab indent", "a: {b: 1}", "\t", "a:\n  b: 1\n"},
+		{"anchors", "- &x {k: v}\n- *x", "  ", "- &x\n  k: v\n- *x\n"},
+		{"literal block", "a: \"1\\n2\"\nb: |\n  3\n  4\n", "  ", "a: \"1\\n2\"\nb: |\n  3\n  4\n"},
+		{"comments", "# head\na: 1 # line\n", "  ", "# head\na: 1 # line\n"},
+		{"empty collections", "a: {}\nb: []", "  ", "a: {}\nb: []\n"},
+	}
+
+	for _, testCase := range tests {
+		t.Run(testCase.name, func(t *testing.T) {
+			output := new(strings.Builder)
+			formatErr := FormatYaml(strings.NewReader(testCase.input), output, testCase.indent, ColorsDisabled)
+			assert.NoError(t, formatErr)
+			assert.Equal(t, testCase.expected, output.String())
+		})
+	}
+
+	formatErr := FormatYaml(strings.NewReader("a: [b"), new(strings.Builder), "  ", ColorsDisabled)
+	assert.Error(t, formatErr)
+
+	formatErr = FormatYaml(strings.NewReader("a: b"), failingWriter{}, "  ", ColorsDisabled)
+	assert.ErrorIs(t, formatErr, errWriteFailed)
+}
+
+func TestYamlToJSON(t *testing.T) {
+	tests := map[string]string{
+		"b: 1\na: [true, null, 1.5, \"2\", x]":              `{"b":1,"a":[true,null,1.5,"2","x"]}`,
+		"base: &base {x: 1, y: 2}\nitem: {<<: *base, y: 3}": `{"base":{"x":1,"y":2},"item":{"y":3,"x":1}}`,
+		"": "null",
+	}
+
+	for input, expected := range tests {
+		data, err := YamlToJSON(strings.NewReader(input))
+		assert.Nil(t, err)
+		assert.Equal(t, expected, string(data))
+	}
+
+	_, err := YamlToJSON(strings.NewReader("{[a]: b}"))
+	assert.Error(t, err)
+
+	_, err = YamlToJSON(strings.NewReader("a: &x\n  - *x\n"))
+	assert.EqualError(t, err, "recursive alias")
+	_, err = YamlToJSON(strings.NewReader("a: &x\n  b: 1\n  <<: *x\n"))
+	assert.EqualError(t, err, "recursive alias")
+}
+
+func TestJSONToYaml(t *testing.T) {
+	data, err := JSONToYaml(strings.NewReader(`{"b": {"c": "true"}, "a": [1, "x"]}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "b:\n    c: \"true\"\na:\n    - 1\n    - x\n", string(data))
+
+	data, err = JSONToYaml(strings.NewReader(`{"yes": ["on", "N", "OFF", "1:20", "190:20:30.15", "0b1_0", "y1", "no way"]}`))
+	assert.Nil(t, err)
+	assert.Equal(t, "\"yes\":\n    - \"on\"\n    - \"N\"\n    - \"OFF\"\n    - \"1:20\"\n    - \"190:20:30.15\"\n"+
+		"    - \"0b1_0\"\n    - y1\n    - no way\n", string(data))
+}
+
+func TestIsYAML(t *testing.T) {
+	assert.True(t, IsYAML("---\nkey: value", false))
+	assert.True(t, IsYAML("# comment\n\napiVersion: v1", false))
+	assert.True(t, IsYAML("- item", false))
+	assert.True(t, IsYAML("%YAML 1.1\n---\nkey: value", false))
+	assert.True(t, IsYAML("\"first key\": 1\nsecond: [1, 2", true))
+
+	assert.False(t, IsYAML("<root></root>", false))
+	assert.False(t, IsYAML("Thank you\n<thinking>", false))
+	assert.False(t, IsYAML("", false))
+	assert.False(t, IsYAML("Hello world: this is text", false))
+	assert.False(t, IsYAML("Note: this is text\nwhich is not YAML.", false))
+	assert.False(t, IsYAML("\"first key\": 1\nsecond: [1, 2", false))
+	assert.False(t, IsYAML("---\nplain text", false))
+}
@@ -0,0 +1,131 @@
+package xq
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"sync"
+
+	"github.com/sibprogrammer/xq/internal/utils"
+)
+
+// ContentType is the name of the document format, e.g. "xml".
+type ContentType string
+
+const (
+	ContentXml  ContentType = "xml"
+	ContentHtml ContentType = "html"
+	ContentJson ContentType = "json"
+	ContentYaml ContentType = "yaml"
+)
+
+// detectionSize is the size of the content beginning passed to the detectors.
+const detectionSize = 512
+
+// Formatter formats the documents of a content type.
+type Formatter interface {
+	Format(ctx context.Context, reader io.Reader, writer io.Writer, options Options) error
+}
+
+// FormatterFunc adapts the function to the Formatter interface.
+type FormatterFunc func(ctx context.Context, reader io.Reader, writer io.Writer, options Options) error
+
+func (format FormatterFunc) Format(ctx context.Context, reader io.Reader, writer io.Writer, options Options) error {
+	return format(ctx, reader, writer, options)
+}
+
+// Detector checks whether the beginning of the content belongs to the content type.
+type Detector func(head []byte) bool
+
+type registration struct {
+	contentType ContentType
+	formatter   Formatter
+	detector    Detector
+}
+
+var registry = struct {
+	sync.RWMutex
+	formatters map[ContentType]registration
+	// custom are the content types registered by the library users, they are detected before
+	// the built-in ones
+	custom []ContentType
+}{formatters: map[ContentType]registration{}}
+
+func init() {
+	builtin := func(format func(io.Reader, io.Writer, string, int) error) Formatter {
+		return FormatterFunc(func(ctx context.Context, reader io.Reader, writer io.Writer, options Options) error {
+			return format(reader, writer, options.Indent, options.colors())
+		})
+	}
+
+	registry.formatters[ContentXml] = registration{ContentXml, builtin(utils.FormatXml), nil}
+	registry.formatters[ContentHtml] = registration{ContentHtml, builtin(utils.FormatHtml), nil}
+	registry.formatters[ContentJson] = registration{ContentJson, builtin(utils.FormatJson), nil}
+	registry.formatters[ContentYaml] = registration{ContentYaml, builtin(utils.FormatYaml), nil}
+}
+
+// RegisterFormatter adds the formatter for the content type or replaces the existing one. If the
+// detector is not nil, Format uses the formatter for the documents accepted by the detector when
+// the content type is not provided. The detectors are checked in the order of registration before
+// the built-in detection.
+func RegisterFormatter(contentType ContentType, formatter Formatter, detector Detector) {
+	registry.Lock()
+	defer registry.Unlock()
+
+	if previous, ok := registry.formatters[contentType]; ok && previous.detector != nil {
+		for index, custom := range registry.custom {
+			if custom == contentType {
+				registry.custom = append(registry.custom[:index], registry.custom[index+1:]...)
+				break
+			}
+		}
+	}
+	if detector != nil {
+		registry.custom = append(registry.custom, contentType)
+	}
+
+	registry.formatters[contentType] = registration{contentType, formatter, detector}
+}
+
+// LookupFormatter returns the formatter registered for the content type.
+func LookupFormatter(contentType ContentType) (Formatter, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+
+	registration, ok := registry.formatters[contentType]
+	return registration.formatter, ok
+}
+
+// DetectContentType returns the type of the content by its beginning. XML is assumed if no other
+// type is detected.
+func DetectContentType(head []byte) ContentType {
+	registry.RLock()
+	for _, contentType := range registry.custom {
+		if registry.formatters[contentType].detector(head) {
+			registry.RUnlock()
+			return contentType
+		}
+	}
+	registry.RUnlock()
+
+	switch {
+	case utils.IsJSON(string(head[:min(len(head), 10)])):
+		return ContentJson
+	case utils.IsHTML(string(head[:min(len(head), 10)])):
+		return ContentHtml
+	case utils.IsYAML(string(head), len(head) == detectionSize):
+		return ContentYaml
+	}
+
+	return ContentXml
+}
+
+// detectContentType reads the beginning of the content to detect its type. The returned reader
+// provides the whole content.
+func detectContentType(reader io.Reader) (ContentType, io.Reader) {
+	head := make([]byte, detectionSize)
+	length, _ := io.ReadFull(reader, head)
+	head = head[:length]
+
+	return DetectContentType(head), io.MultiReader(bytes.NewReader(head), reader)
+}
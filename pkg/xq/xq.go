@@ -0,0 +1,225 @@
+// Package xq formats XML, HTML, JSON and YAML documents and extracts the nodes from them using
+// XPath queries and CSS selectors, with the same output as the xq command-line tool.
+package xq
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/antchfx/xmlquery"
+	"github.com/sibprogrammer/xq/internal/utils"
+)
+
+// ColorMode defines whether the output is highlighted with the terminal colors.
+type ColorMode int
+
+const (
+	// ColorsDisabled produces the plain output, it is the default mode.
+	ColorsDisabled ColorMode = iota
+	// ColorsAuto highlights the output if the standard output is a terminal.
+	ColorsAuto
+	// ColorsForced always highlights the output.
+	ColorsForced
+)
+
+// Options configure the formatting and the output of the queries. The zero value produces the
+// compact output without colors for the detected content type, see DefaultOptions.
+type Options struct {
+	// Indent is the string used for one level of indentation. The documents are printed on
+	// a single line if it is empty.
+	Indent string
+	// Colors defines the highlighting of the output. The mode applies to the call only, the
+	// global color settings of the program are not changed.
+	Colors ColorMode
+	// ContentType is the type of the input, it is detected by the beginning of the content if
+	// empty.
+	ContentType ContentType
+	// WithTags returns the content of the matched nodes with the tags instead of the text.
+	WithTags bool
+	// JSON returns the result of the queries as JSON.
+	JSON bool
+	// Single returns the first matched node only.
+	Single bool
+	// Namespaces binds the prefixes used in the XPath queries to the namespace URIs. The
+	// prefixes declared in the document are available as well.
+	Namespaces map[string]string
+}
+
+// DefaultOptions returns the options used by the command line tool without a config file.
+func DefaultOptions() Options {
+	return Options{Indent: "  ", Colors: ColorsAuto}
+}
+
+func (options Options) colors() int {
+	switch options.Colors {
+	case ColorsAuto:
+		return utils.ColorsDefault
+	case ColorsForced:
+		return utils.ColorsForced
+	default:
+		return utils.ColorsDisabled
+	}
+}
+
+func (options Options) queryOptions() utils.QueryOptions {
+	return utils.QueryOptions{
+		WithTags:   options.WithTags,
+		JSON:       options.JSON,
+		Indent:     options.Indent,
+		Colors:     options.colors(),
+		Namespaces: options.Namespaces,
+	}
+}
+
+// Format formats the document using the formatter registered for its content type.
+func Format(ctx context.Context, reader io.Reader, writer io.Writer, options Options) error {
+	reader, writer = withContext(ctx, reader, writer)
+
+	contentType := options.ContentType
+	if contentType == "" {
+		contentType, reader = detectContentType(reader)
+	}
+
+	formatter, ok := LookupFormatter(contentType)
+	if !ok {
+		return fmt.Errorf("no formatter registered for content type %q", contentType)
+	}
+
+	return finish(ctx, formatter.Format(ctx, reader, writer, options))
+}
+
+// FormatXml formats the XML document.
+func FormatXml(ctx context.Context, reader io.Reader, writer io.Writer, options Options) error {
+	options.ContentType = ContentXml
+	return Format(ctx, reader, writer, options)
+}
+
+// FormatHtml formats the HTML document.
+func FormatHtml(ctx context.Context, reader io.Reader, writer io.Writer, options Options) error {
+	options.ContentType = ContentHtml
+	return Format(ctx, reader, writer, options)
+}
+
+// FormatJson formats the JSON document.
+func FormatJson(ctx context.Context, reader io.Reader, writer io.Writer, options Options) error {
+	options.ContentType = ContentJson
+	return Format(ctx, reader, writer, options)
+}
+
+// FormatYaml formats the YAML document.
+func FormatYaml(ctx context.Context, reader io.Reader, writer io.Writer, options Options) error {
+	options.ContentType = ContentYaml
+	return Format(ctx, reader, writer, options)
+}
+
+// XPathQuery prints the nodes matched by the XPath query, one per line, or the result of the
+// expression. JSON and YAML documents are queried as the trees of elements named after the keys.
+func XPathQuery(ctx context.Context, reader io.Reader, writer io.Writer, query string, options Options) error {
+	reader, writer, contentType, err := prepareQuery(ctx, reader, writer, options)
+	if err != nil {
+		return err
+	}
+
+	if contentType == ContentJson {
+		err = utils.JSONXPathQuery(reader, writer, query, options.Single, options.queryOptions())
+	} else {
+		err = utils.XPathQuery(reader, writer, query, options.Single, options.queryOptions())
+	}
+
+	return finish(ctx, err)
+}
+
+// CSSQuery prints the nodes matched by the CSS selector, one per line. The value of the attribute
+// is printed instead of the node content if the attribute name is not empty.
+func CSSQuery(ctx context.Context, reader io.Reader, writer io.Writer, selector string, attr string, options Options) error {
+	reader, writer, contentType, err := prepareQuery(ctx, reader, writer, options)
+	if err != nil {
+		return err
+	}
+
+	if contentType == ContentJson {
+		err = utils.JSONCSSQuery(reader, writer, selector, attr, options.queryOptions())
+	} else {
+		err = utils.CSSQuery(reader, writer, selector, attr, options.queryOptions())
+	}
+
+	return finish(ctx, err)
+}
+
+func prepareQuery(ctx context.Context, reader io.Reader, writer io.Writer, options Options) (io.Reader, io.Writer, ContentType, error) {
+	reader, writer = withContext(ctx, reader, writer)
+
+	contentType := options.ContentType
+	if contentType == "" {
+		contentType, reader = detectContentType(reader)
+	}
+
+	switch contentType {
+	case ContentXml, ContentHtml, ContentJson:
+	case ContentYaml:
+		data, err := utils.YamlToJSON(reader)
+		if err != nil {
+			return nil, nil, "", finish(ctx, fmt.Errorf("error while parsing YAML: %w", err))
+		}
+		contentType, reader = ContentJson, bytes.NewReader(data)
+	default:
+		return nil, nil, "", fmt.Errorf("queries are not supported for content type %q", contentType)
+	}
+
+	return reader, writer, contentType, nil
+}
+
+// ParseXml parses the XML document into the tree of nodes. The parser is not strict, so the
+// unclosed tags and the unknown entities are accepted.
+func ParseXml(reader io.Reader) (*xmlquery.Node, error) {
+	return utils.ParseDocument(reader, utils.ContentXml)
+}
+
+// NodeToJSON converts the node into the value encoded by encoding/json as the JSON output of
+// xq, the attributes are prefixed with "@" and the text is stored as "#text". The nested elements
+// deeper than the given depth are replaced with their text, a negative depth means no limit.
+func NodeToJSON(node *xmlquery.Node, depth int) any {
+	return utils.NodeToJSON(node, depth)
+}
+
+// withContext returns the reader and the writer which fail once the context is done.
+func withContext(ctx context.Context, reader io.Reader, writer io.Writer) (io.Reader, io.Writer) {
+	return &contextReader{ctx: ctx, reader: reader}, &contextWriter{ctx: ctx, writer: writer}
+}
+
+// finish returns the context error if the operation is interrupted by the cancellation.
+func finish(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+
+	return err
+}
+
+type contextReader struct {
+	ctx    context.Context
+	reader io.Reader
+}
+
+func (reader *contextReader) Read(buf []byte) (int, error) {
+	if err := reader.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return reader.reader.Read(buf)
+}
+
+type contextWriter struct {
+	ctx    context.Context
+	writer io.Writer
+}
+
+func (writer *contextWriter) Write(buf []byte) (int, error) {
+	if err := writer.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return writer.writer.Write(buf)
+}
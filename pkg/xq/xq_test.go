@@ -0,0 +1,175 @@
+package xq
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/fatih/color"
+	"github.com/stretchr/testify/assert"
+)
+
+func getFileContent(t *testing.T, fileName string) string {
+	content, err := os.ReadFile(filepath.Join("..", "..", "test", "data", fileName))
+	assert.Nil(t, err)
+	return string(content)
+}
+
+func TestFormat(t *testing.T) {
+	ctx := context.Background()
+	options := Options{Indent: "  "}
+
+	files := map[string]string{
+		"xml/unformatted.xml":   "xml/formatted.xml",
+		"html/unformatted.html": "html/formatted.html",
+		"json/unformatted.json": "json/formatted.json",
+		"yaml/unformatted.yaml": "yaml/formatted.yaml",
+	}
+	for unformatted, formatted := range files {
+		output := new(strings.Builder)
+		err := Format(ctx, strings.NewReader(getFileContent(t, unformatted)), output, options)
+		assert.Nil(t, err, unformatted)
+		assert.Equal(t, getFileContent(t, formatted), output.String(), unformatted)
+	}
+
+	output := new(strings.Builder)
+	err := FormatXml(ctx, strings.NewReader("<a><b>1</b></a>"), output, Options{Indent: "\t"})
+	assert.Nil(t, err)
+	assert.Equal(t, "<a>\n\t<b>1</b>\n</a>\n", output.String())
+
+	output.Reset()
+	err = FormatJson(ctx, strings.NewReader(`{"a": [1, 2]}`), output, Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, `{"a": [1,2]}`+"\n", output.String())
+
+	err = Format(ctx, strings.NewReader("a"), output, Options{ContentType: "csv"})
+	assert.ErrorContains(t, err, `no formatter registered for content type "csv"`)
+}
+
+func TestFormatColors(t *testing.T) {
+	ctx := context.Background()
+	noColor := color.NoColor
+
+	var wg sync.WaitGroup
+	outputs := make([]string, 8)
+	for index := range outputs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			options := Options{Colors: ColorsDisabled}
+			if index%2 == 1 {
+				options.Colors = ColorsForced
+			}
+			output := new(strings.Builder)
+			assert.Nil(t, FormatXml(ctx, strings.NewReader("<a>1</a>"), output, options))
+			outputs[index] = output.String()
+		}()
+	}
+	wg.Wait()
+
+	for index, output := range outputs {
+		if index%2 == 1 {
+			assert.Contains(t, output, "\x1b[")
+		} else {
+			assert.Equal(t, "<a>1</a>\n", output)
+		}
+	}
+	assert.Equal(t, noColor, color.NoColor)
+}
+
+func TestFormatCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	output := new(strings.Builder)
+	err := Format(ctx, strings.NewReader("<a><b>1</b></a>"), output, DefaultOptions())
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, "", output.String())
+
+	ctx, cancel = context.WithCancel(context.Background())
+	reader, writer := io.Pipe()
+	go func() {
+		_, _ = writer.Write([]byte("<a><b>1</b>"))
+		cancel()
+		_, _ = writer.Write([]byte("<b>2</b>"))
+		_ = writer.Close()
+	}()
+	err = FormatXml(ctx, reader, io.Discard, Options{Indent: "  "})
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRegisterFormatter(t *testing.T) {
+	ctx := context.Background()
+	upper := FormatterFunc(func(ctx context.Context, reader io.Reader, writer io.Writer, options Options) error {
+		content, err := io.ReadAll(reader)
+		if err != nil {
+			return err
+		}
+		_, err = writer.Write(bytes.ToUpper(content))
+		return err
+	})
+	RegisterFormatter("log", upper, func(head []byte) bool {
+		return bytes.HasPrefix(head, []byte("LOG:"))
+	})
+
+	formatter, ok := LookupFormatter("log")
+	assert.True(t, ok)
+	assert.NotNil(t, formatter)
+	assert.Equal(t, ContentType("log"), DetectContentType([]byte("LOG: started")))
+	assert.Equal(t, ContentXml, DetectContentType([]byte("<log/>")))
+	assert.Equal(t, ContentJson, DetectContentType([]byte(` {"log": 1}`)))
+
+	output := new(strings.Builder)
+	err := Format(ctx, strings.NewReader("LOG: started"), output, Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, "LOG: STARTED", output.String())
+
+	RegisterFormatter("log", upper, nil)
+	assert.Equal(t, ContentYaml, DetectContentType([]byte("LOG: started")))
+}
+
+func TestQueries(t *testing.T) {
+	ctx := context.Background()
+	xml := getFileContent(t, "xml/unformatted.xml")
+
+	output := new(strings.Builder)
+	err := XPathQuery(ctx, strings.NewReader(xml), output, "//first_name", Options{})
+	assert.Nil(t, err)
+	assert.Equal(t, "John\n", output.String())
+
+	output.Reset()
+	err = XPathQuery(ctx, strings.NewReader(xml), output, "/user/address", Options{Indent: "  ", WithTags: true})
+	assert.Nil(t, err)
+	assert.Equal(t, "<address>\n  <street>1234 Main Road</street>\n  <city>Bellville</city>\n</address>\n",
+		output.String())
+
+	output.Reset()
+	err = XPathQuery(ctx, strings.NewReader(getFileContent(t, "json/store.json")), output,
+		"//items/name", Options{Single: true})
+	assert.Nil(t, err)
+	assert.Equal(t, "pen\n", output.String())
+
+	output.Reset()
+	err = CSSQuery(ctx, strings.NewReader(getFileContent(t, "html/unformatted.html")), output, "title", "",
+		Options{ContentType: ContentHtml})
+	assert.Nil(t, err)
+	assert.Equal(t, "Test\n", output.String())
+
+	err = XPathQuery(ctx, strings.NewReader("a"), output, "/a", Options{ContentType: "log"})
+	assert.ErrorContains(t, err, "queries are not supported")
+}
+
+func TestNodeToJSON(t *testing.T) {
+	doc, err := ParseXml(strings.NewReader(`<a id="1"><b>text</b></a>`))
+	assert.Nil(t, err)
+
+	data, err := json.Marshal(NodeToJSON(doc, -1))
+	assert.Nil(t, err)
+	assert.Equal(t, `{"a":{"@id":"1","b":"text"}}`, string(data))
+}
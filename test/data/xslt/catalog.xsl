@@ -0,0 +1,47 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<xsl:stylesheet version="1.0" xmlns:xsl="http://www.w3.org/1999/XSL/Transform">
+  <xsl:output method="xml" omit-xml-declaration="yes"/>
+
+  <xsl:param name="max-price" select="20"/>
+  <xsl:variable name="currency">USD</xsl:variable>
+
+  <xsl:template match="/">
+    <books count="{count(catalog/book[price &lt; $max-price])}">
+      <xsl:apply-templates select="catalog/book[price &lt; $max-price]">
+        <xsl:sort select="title"/>
+      </xsl:apply-templates>
+    </books>
+  </xsl:template>
+
+  <xsl:template match="book">
+    <book position="{position()}">
+      <xsl:attribute name="ref">
+        <xsl:value-of select="@id"/>
+      </xsl:attribute>
+      <xsl:apply-templates select="title"/>
+      <price currency="{$currency}">
+        <xsl:value-of select="format-number(price, '#,##0.00')"/>
+      </price>
+      <xsl:choose>
+        <xsl:when test="year &lt; 1950">
+          <age>classic</age>
+        </xsl:when>
+        <xsl:when test="year &lt; 2000">
+          <age>modern</age>
+        </xsl:when>
+        <xsl:otherwise>
+          <age>recent</age>
+        </xsl:otherwise>
+      </xsl:choose>
+      <xsl:if test="@category = 'fiction'">
+        <fiction/>
+      </xsl:if>
+    </book>
+  </xsl:template>
+
+  <xsl:template match="title">
+    <name>
+      <xsl:value-of select="translate(., 'abcdefghijklmnopqrstuvwxyz', 'ABCDEFGHIJKLMNOPQRSTUVWXYZ')"/>
+    </name>
+  </xsl:template>
+</xsl:stylesheet>
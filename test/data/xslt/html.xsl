@@ -0,0 +1,24 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<xsl:stylesheet version="1.0" xmlns:xsl="http://www.w3.org/1999/XSL/Transform">
+  <xsl:output method="html"/>
+
+  <xsl:template match="/catalog">
+    <html>
+      <head>
+        <title>Catalog</title>
+      </head>
+      <body>
+        <table>
+          <xsl:for-each select="book">
+            <xsl:sort select="price" data-type="number" order="descending"/>
+            <tr>
+              <td><xsl:value-of select="title"/></td>
+              <td><xsl:value-of select="price"/></td>
+            </tr>
+          </xsl:for-each>
+        </table>
+        <br/>
+      </body>
+    </html>
+  </xsl:template>
+</xsl:stylesheet>
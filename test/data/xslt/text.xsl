@@ -0,0 +1,43 @@
+<?xml version="1.0" encoding="UTF-8"?>
+<xsl:stylesheet version="1.0" xmlns:xsl="http://www.w3.org/1999/XSL/Transform">
+  <xsl:output method="text"/>
+
+  <xsl:param name="separator" select="': '"/>
+  <xsl:key name="books-by-category" match="book" use="@category"/>
+
+  <xsl:template match="/">
+    <xsl:for-each select="catalog/book[generate-id() = generate-id(key('books-by-category', @category)[1])]">
+      <xsl:sort select="@category"/>
+      <xsl:variable name="books" select="key('books-by-category', @category)"/>
+      <xsl:value-of select="@category"/>
+      <xsl:value-of select="$separator"/>
+      <xsl:for-each select="$books">
+        <xsl:value-of select="title"/>
+        <xsl:if test="position() != last()">, </xsl:if>
+      </xsl:for-each>
+      <xsl:text>&#10;</xsl:text>
+    </xsl:for-each>
+    <xsl:call-template name="total">
+      <xsl:with-param name="books" select="catalog/book"/>
+    </xsl:call-template>
+  </xsl:template>
+
+  <xsl:template name="total">
+    <xsl:param name="books"/>
+    <xsl:param name="sum" select="0"/>
+    <xsl:choose>
+      <xsl:when test="$books">
+        <xsl:call-template name="total">
+          <xsl:with-param name="books" select="$books[position() &gt; 1]"/>
+          <xsl:with-param name="sum" select="$sum + $books[1]/price"/>
+        </xsl:call-template>
+      </xsl:when>
+      <xsl:otherwise>
+        <xsl:text>Total</xsl:text>
+        <xsl:value-of select="$separator"/>
+        <xsl:value-of select="format-number($sum, '0.00')"/>
+        <xsl:text>&#10;</xsl:text>
+      </xsl:otherwise>
+    </xsl:choose>
+  </xsl:template>
+</xsl:stylesheet>